@@ -0,0 +1,110 @@
+package rye
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRequestBodyTooLarge is what reads of a request body fail with once they go past the limit
+// set by NewMiddlewareSizeLimitByPath (or NewMiddlewareMaxBody); check for it with errors.Is.
+var ErrRequestBodyTooLarge = errors.New("Request body too large")
+
+// readBody reads the full request body and replaces it with an in-memory copy
+// so that downstream handlers can still read it.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return body, err
+}
+
+// peekBody reads up to n bytes of the request body and puts them back in front of the rest of
+// it, so that downstream handlers still read the full body.
+func peekBody(r *http.Request, n int64) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	peeked, err := ioutil.ReadAll(io.LimitReader(r.Body, n))
+
+	r.Body = &peekedBody{
+		Reader: io.MultiReader(bytes.NewReader(peeked), r.Body),
+		Closer: r.Body,
+	}
+
+	return peeked, err
+}
+
+// peekedBody is a request body whose start was read by peekBody
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// bodyReadError returns the error *Response of a handler that failed to read the request body:
+// a 413 (counted as `request.too_large`) when the body is over the size limit, a 400 otherwise
+func bodyReadError(r *http.Request, err error) *Response {
+	if errors.Is(err, ErrRequestBodyTooLarge) {
+		chainFromRequest(r).inc("request.too_large")
+
+		return &Response{
+			Err:        err,
+			StatusCode: http.StatusRequestEntityTooLarge,
+		}
+	}
+
+	return &Response{
+		Err:        fmt.Errorf("Unable to read request body: %v", err),
+		StatusCode: http.StatusBadRequest,
+	}
+}
+
+// maxBodyReader wraps the http.MaxBytesReader set up by the size limit middlewares so that
+// reading past the limit fails with ErrRequestBodyTooLarge, which callers can tell apart from
+// other read errors (ie. a client going away)
+type maxBodyReader struct {
+	io.ReadCloser
+
+	limit int64
+	read  int64
+}
+
+func (m *maxBodyReader) Read(p []byte) (int, error) {
+	n, err := m.ReadCloser.Read(p)
+	m.read += int64(n)
+
+	// http.MaxBytesReader fails once it has handed out limit bytes and is asked for more
+	if err != nil && err != io.EOF && m.read >= m.limit {
+		return n, fmt.Errorf("%w; limit is %d bytes", ErrRequestBodyTooLarge, m.limit)
+	}
+
+	return n, err
+}
+
+// timedBodyReader adds up the time spent blocked in reads of the request body
+// (see Config.BodyReadTiming)
+type timedBodyReader struct {
+	io.ReadCloser
+
+	chain *chainState
+}
+
+func (t *timedBodyReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := t.ReadCloser.Read(p)
+	atomic.AddInt64(&t.chain.bodyReadTime, int64(time.Since(start)))
+
+	return n, err
+}
@@ -0,0 +1,87 @@
+package rye
+
+import (
+	"context"
+	"sync"
+)
+
+// RequestCacheStore is a key/value store scoped to a single request, which handlers in
+// a chain can use to share computed values (ie. a user loaded by an auth middleware).
+// It is safe for concurrent use and is cleared once the chain has finished.
+type RequestCacheStore struct {
+	mu     sync.RWMutex
+	values map[string]interface{}
+}
+
+// Get returns the value stored under key
+func (c *RequestCacheStore) Get(key string) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	value, ok := c.values[key]
+	return value, ok
+}
+
+// Set stores a value under key
+func (c *RequestCacheStore) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.values == nil {
+		c.values = make(map[string]interface{})
+	}
+
+	c.values[key] = value
+}
+
+// Delete removes the value stored under key
+func (c *RequestCacheStore) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.values, key)
+}
+
+// clear drops all values; called once the chain is done
+func (c *RequestCacheStore) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values = nil
+}
+
+/*
+RequestCache returns the cache of the request the context belongs to. Outside of a rye chain
+a new, detached, cache is returned so callers never have to check for nil.
+
+Example usage:
+
+	func authHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		rye.RequestCache(r.Context()).Set("user", loadUser(r))
+		return nil
+	}
+
+	func businessHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		user, ok := rye.RequestCacheGet[*User](r.Context(), "user")
+		...
+	}
+*/
+func RequestCache(ctx context.Context) *RequestCacheStore {
+	if c := chainFromContext(ctx); c != nil {
+		return c.requestCache()
+	}
+
+	return &RequestCacheStore{}
+}
+
+// RequestCacheGet returns the value stored under key in the request cache, provided it is of type T
+func RequestCacheGet[T any](ctx context.Context, key string) (T, bool) {
+	value, ok := RequestCache(ctx).Get(key)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+
+	typed, ok := value.(T)
+	return typed, ok
+}
@@ -0,0 +1,107 @@
+package rye
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RequestCache", func() {
+
+	var (
+		request   *http.Request
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		request = &http.Request{
+			Header: make(map[string][]string, 0),
+		}
+		mwHandler = NewMWHandler(Config{})
+	})
+
+	Context("when a handler stores a value", func() {
+		It("should be retrievable by a later handler in the chain", func() {
+			var (
+				value string
+				found bool
+			)
+
+			h := mwHandler.Handle([]Handler{
+				func(rw http.ResponseWriter, r *http.Request) *Response {
+					RequestCache(r.Context()).Set("user", "rye-user")
+					return nil
+				},
+				contextHandler,
+				func(rw http.ResponseWriter, r *http.Request) *Response {
+					value, found = RequestCacheGet[string](r.Context(), "user")
+					return nil
+				},
+			})
+			h.ServeHTTP(response, request)
+
+			Expect(found).To(BeTrue())
+			Expect(value).To(Equal("rye-user"))
+		})
+
+		It("should not be visible to other requests", func() {
+			var found bool
+
+			h := mwHandler.Handle([]Handler{
+				func(rw http.ResponseWriter, r *http.Request) *Response {
+					_, found = RequestCache(r.Context()).Get("user")
+					RequestCache(r.Context()).Set("user", "rye-user")
+					return nil
+				},
+			})
+
+			h.ServeHTTP(response, request)
+			Expect(found).To(BeFalse())
+
+			h.ServeHTTP(httptest.NewRecorder(), request)
+			Expect(found).To(BeFalse())
+		})
+
+		It("should be cleared once the chain is done", func() {
+			var cache *RequestCacheStore
+
+			h := mwHandler.Handle([]Handler{
+				func(rw http.ResponseWriter, r *http.Request) *Response {
+					cache = RequestCache(r.Context())
+					cache.Set("user", "rye-user")
+					return nil
+				},
+			})
+			h.ServeHTTP(response, request)
+
+			_, found := cache.Get("user")
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Describe("RequestCacheGet", func() {
+		It("should not return values of a different type", func() {
+			state := &chainState{}
+			state.cache.Set("count", 1)
+
+			ctx := context.WithValue(context.Background(), CONTEXT_CHAIN, state)
+			_, found := RequestCacheGet[string](ctx, "count")
+			Expect(found).To(BeFalse())
+
+			count, found := RequestCacheGet[int](ctx, "count")
+			Expect(found).To(BeTrue())
+			Expect(count).To(Equal(1))
+		})
+
+		It("should not find anything outside of a chain", func() {
+			RequestCache(context.Background()).Set("user", "rye-user")
+
+			_, found := RequestCacheGet[string](context.Background(), "user")
+			Expect(found).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,197 @@
+package rye
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// CanaryConfig configures a handler created by Canary.
+type CanaryConfig struct {
+	// SampleRate is the fraction of requests (between 0 and 1) the candidate runs for
+	// (defaults to 1, every request)
+	SampleRate float64
+
+	// OnMismatch, when set, is called with the request and both results whenever the
+	// candidate diverges from the primary (ie. to log the difference)
+	OnMismatch func(r *http.Request, primary, candidate CanaryResult)
+}
+
+// CanaryResult is the outcome of a handler run by Canary: the status code it ended with
+// and its body (or error message).
+type CanaryResult struct {
+	StatusCode int
+	Body       []byte
+}
+
+/*
+Canary runs a candidate implementation of a handler alongside the primary one, to refactor or replace it safely:
+clients are always served by the primary handler, while the candidate runs in the background (for a sampled
+fraction of requests) against a copy of the request. Whenever their status codes or bodies differ, a
+`canary.mismatch` stat is recorded (and `OnMismatch` is called).
+
+The candidate runs detached from the chain: it doesn't see the deadline of the request and can't affect the
+response or the stats of the chain. As the request body is read upfront to hand a copy to each handler, and both
+responses are held in memory to compare them, it is best suited to requests and responses of reasonable size.
+Stats are recorded under the name of the primary handler.
+
+Example usage:
+
+	routes.Handle("/search", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.Canary(searchHandler, newSearchHandler, rye.CanaryConfig{SampleRate: 0.05}),
+		})).Methods("GET")
+*/
+func Canary(primary Handler, candidate Handler, cfg CanaryConfig) Handler {
+	if cfg.SampleRate == 0 {
+		cfg.SampleRate = 1
+	}
+
+	wrapped := Handler(func(rw http.ResponseWriter, r *http.Request) *Response {
+		if cfg.SampleRate < 1 && rand.Float64() >= cfg.SampleRate {
+			return primary(rw, r)
+		}
+
+		var body []byte
+		if r.Body != nil && r.Body != http.NoBody {
+			var err error
+			if body, err = ioutil.ReadAll(r.Body); err != nil {
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+				return primary(rw, r)
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		// The candidate gets its own copy of the request, detached from
+		// the chain (and from its cancellation once the response is sent)
+		ctx := context.WithValue(detachedContext{parent: r.Context()}, CONTEXT_CHAIN, (*chainState)(nil))
+		candidateReq := r.Clone(ctx)
+		if body != nil {
+			candidateReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		tee := &teeResponseWriter{ResponseWriter: rw}
+		resp := primary(tee, r)
+		primaryResult := canaryResultOf(tee.status, tee.body.Bytes(), resp)
+
+		chain := chainFromRequest(r)
+
+		go func() {
+			candidateResult, ok := runCandidate(candidate, candidateReq)
+
+			if ok && candidateResult.StatusCode == primaryResult.StatusCode && bytes.Equal(candidateResult.Body, primaryResult.Body) {
+				return
+			}
+
+			chain.inc("canary.mismatch")
+
+			if cfg.OnMismatch != nil {
+				cfg.OnMismatch(candidateReq, primaryResult, candidateResult)
+			}
+		}()
+
+		return resp
+	})
+
+	// Keep recording stats under the name of the primary handler
+	return describeAs(wrapped, primary)
+}
+
+// runCandidate runs the candidate handler, reporting whether it completed (rather than panicked)
+func runCandidate(candidate Handler, r *http.Request) (result CanaryResult, ok bool) {
+	defer func() {
+		if recover() != nil {
+			result, ok = CanaryResult{StatusCode: http.StatusInternalServerError}, false
+		}
+	}()
+
+	rec := newRecordingResponseWriter()
+	resp := candidate(rec, r)
+
+	return canaryResultOf(rec.status, rec.body.Bytes(), resp), true
+}
+
+// canaryResultOf works out the result of a handler from what it wrote and the Response it returned,
+// the way the chain would write it out
+func canaryResultOf(status int, written []byte, resp *Response) CanaryResult {
+	result := CanaryResult{
+		StatusCode: status,
+		Body:       append([]byte(nil), written...),
+	}
+
+	if resp != nil {
+		if resp.StatusCode != 0 {
+			result.StatusCode = resp.StatusCode
+		}
+
+		if resp.Err != nil {
+			result.Body = append(result.Body, resp.Err.Error()...)
+		} else if resp.StopExecution {
+			result.Body = append(result.Body, resp.StatusContent...)
+		}
+	}
+
+	if result.StatusCode == 0 {
+		result.StatusCode = http.StatusOK
+	}
+
+	return result
+}
+
+// detachedContext carries the values of its parent but none of its cancellation or deadline,
+// so that work started for a request can outlive it
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (d detachedContext) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
+
+// teeResponseWriter writes through to the wrapped writer, keeping a copy of the status code and body
+type teeResponseWriter struct {
+	http.ResponseWriter
+
+	status int
+	body   bytes.Buffer
+}
+
+func (t *teeResponseWriter) WriteHeader(statusCode int) {
+	if t.status == 0 {
+		t.status = statusCode
+	}
+
+	t.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (t *teeResponseWriter) Write(p []byte) (int, error) {
+	if t.status == 0 {
+		t.status = http.StatusOK
+	}
+
+	t.body.Write(p)
+
+	return t.ResponseWriter.Write(p)
+}
+
+// Flush passes through to the wrapped writer (if it supports flushing)
+func (t *teeResponseWriter) Flush() {
+	if f, ok := t.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,136 @@
+package rye
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Canary", func() {
+
+	var (
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		reporter  *recordingReporter
+		cfg       CanaryConfig
+		ran       chan string
+	)
+
+	primary := NamedHandler("search", func(rw http.ResponseWriter, r *http.Request) *Response {
+		body, _ := ioutil.ReadAll(r.Body)
+		rw.Write([]byte("results for " + string(body)))
+		return nil
+	})
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		reporter = &recordingReporter{}
+		mwHandler = NewMWHandler(Config{Reporter: reporter})
+		cfg = CanaryConfig{}
+		ran = make(chan string, 1)
+	})
+
+	serve := func(candidate Handler) {
+		h := mwHandler.Handle([]Handler{Canary(primary, func(rw http.ResponseWriter, r *http.Request) *Response {
+			defer func() { ran <- r.URL.Path }()
+			return candidate(rw, r)
+		}, cfg)})
+		h.ServeHTTP(response, httptest.NewRequest("POST", "/search", strings.NewReader("rye")))
+	}
+
+	Context("when the candidate matches the primary", func() {
+		It("should serve the primary response without counting a mismatch", func() {
+			serve(func(rw http.ResponseWriter, r *http.Request) *Response {
+				body, _ := ioutil.ReadAll(r.Body)
+				rw.Write([]byte("results for " + string(body)))
+				return nil
+			})
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(Equal("results for rye"))
+
+			Eventually(ran).Should(Receive(Equal("/search")))
+			Consistently(reporter.recordedIncs).ShouldNot(ContainElement("canary.mismatch"))
+			Expect(reporter.recordedIncs()).To(ConsistOf("handlers.search.2xx"))
+		})
+	})
+
+	Context("when the candidate diverges", func() {
+		It("should serve the primary response and count a mismatch", func() {
+			var (
+				mu              sync.Mutex
+				primaryResult   CanaryResult
+				candidateResult CanaryResult
+			)
+
+			cfg.OnMismatch = func(r *http.Request, p, c CanaryResult) {
+				mu.Lock()
+				defer mu.Unlock()
+				primaryResult, candidateResult = p, c
+			}
+
+			serve(func(rw http.ResponseWriter, r *http.Request) *Response {
+				return &Response{Err: errors.New("not found"), StatusCode: http.StatusNotFound}
+			})
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(Equal("results for rye"))
+
+			Eventually(reporter.recordedIncs).Should(ContainElement("canary.mismatch"))
+
+			Eventually(func() CanaryResult {
+				mu.Lock()
+				defer mu.Unlock()
+				return candidateResult
+			}).Should(Equal(CanaryResult{StatusCode: http.StatusNotFound, Body: []byte("not found")}))
+
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(primaryResult).To(Equal(CanaryResult{StatusCode: http.StatusOK, Body: []byte("results for rye")}))
+		})
+
+		It("should count a panicking candidate as a mismatch", func() {
+			serve(func(rw http.ResponseWriter, r *http.Request) *Response {
+				panic("boom")
+			})
+
+			Expect(response.Body.String()).To(Equal("results for rye"))
+			Eventually(reporter.recordedIncs).Should(ContainElement("canary.mismatch"))
+		})
+	})
+
+	Context("when the request is not sampled", func() {
+		It("should only run the primary", func() {
+			cfg.SampleRate = 0.0000001
+
+			serve(func(rw http.ResponseWriter, r *http.Request) *Response {
+				return nil
+			})
+
+			Expect(response.Body.String()).To(Equal("results for rye"))
+			Consistently(ran).ShouldNot(Receive())
+		})
+	})
+
+	Describe("detachedContext", func() {
+		It("should keep the values of its parent but not its cancellation", func() {
+			parent, cancel := context.WithTimeout(context.WithValue(context.Background(), CONTEXT_CHAIN, "chain"), time.Minute)
+			cancel()
+
+			ctx := detachedContext{parent: parent}
+			Expect(ctx.Value(CONTEXT_CHAIN)).To(Equal("chain"))
+			Expect(ctx.Err()).ToNot(HaveOccurred())
+			Expect(ctx.Done()).To(BeNil())
+
+			_, ok := ctx.Deadline()
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,318 @@
+package rye
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// Context key holding the state of the chain currently being executed
+	CONTEXT_CHAIN = "rye-chain"
+
+	// Default number of chains that can be nested within each other
+	DEFAULT_MAX_CHAIN_DEPTH = 32
+)
+
+// chainState is stored in the request context by Handle so that handlers
+// can access information about the chain they are running in.
+type chainState struct {
+	// bodyReadTime is the time spent reading the request body so far, in nanoseconds
+	// (see Config.BodyReadTiming); it is only accessed atomically and comes first so
+	// that it is 64-bit aligned on 32-bit platforms
+	bodyReadTime int64
+
+	name  string
+	start time.Time
+	mw    *MWHandler
+	cache RequestCacheStore
+
+	// depth is how many chains (including this one) the request is running in;
+	// chains are nested when a handler serves the request through another chain
+	depth int
+
+	// statRate is the stat rate of the request (see Config.SampleFunc)
+	statRate float32
+
+	// statPrefix is prepended to the stats of the chain (see Config.StatPrefix)
+	statPrefix string
+
+	// handler is the name of the handler currently running (see CtxHandlerName)
+	handler string
+
+	// statName overrides the stat name of the handler currently running (see AsMiddleware)
+	statName string
+
+	// skipped is set when the handler currently running was skipped (see When)
+	skipped bool
+
+	// emitted collects the stats sent during the request (debug mode only, see EmittedMetrics)
+	emitted *emittedMetrics
+
+	// timings of the handlers executed so far, in order
+	timings []handlerTiming
+
+	// recoverPanics is set by MiddlewareRecover
+	recoverPanics bool
+
+	// sampling is the sampling decision of the request (see Config.UnifiedSampling)
+	sampling *SamplingDecision
+
+	// err is the error returned by the handler that ended the chain (if any)
+	err error
+
+	// final is the response of the handler that ended the chain (if any)
+	final *Response
+
+	// pairsEntered counts the MiddlewarePairs whose Before step went through (see HandlePairs)
+	pairsEntered int
+
+	// deadline is set by NewMiddlewareTimeout; the chain stops once it is done
+	deadline context.Context
+
+	// chainSpan covers the whole chain while span is the span of the handler
+	// currently running (both nil when tracing is disabled)
+	chainSpan Span
+	span      Span
+
+	// forkedFrom is the state this one is a copy of (see fork)
+	forkedFrom *chainState
+}
+
+// handlerTiming is how long a single handler in the chain took to run
+type handlerTiming struct {
+	name     string
+	duration time.Duration
+}
+
+// withChainState returns a copy of the request carrying the chain state
+func withChainState(r *http.Request, c *chainState) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), CONTEXT_CHAIN, c))
+}
+
+// chainFromRequest returns the state of the chain the request is running in (or nil)
+func chainFromRequest(r *http.Request) *chainState {
+	return chainFromContext(r.Context())
+}
+
+// chainFromContext returns the state of the chain the context belongs to (or nil)
+func chainFromContext(ctx context.Context) *chainState {
+	c, _ := ctx.Value(CONTEXT_CHAIN).(*chainState)
+	return c
+}
+
+// fork returns a copy of the state for a handler running in a goroutine of its own (see WithTimeout),
+// so that it can't touch the state of the chain should it be abandoned; join copies back what it
+// changed if it is not. The request cache (safe for concurrent use) is still the chain's.
+func (c *chainState) fork() *chainState {
+	return &chainState{
+		name:          c.name,
+		start:         c.start,
+		mw:            c.mw,
+		depth:         c.depth,
+		statRate:      c.statRate,
+		statPrefix:    c.statPrefix,
+		handler:       c.handler,
+		statName:      c.statName,
+		skipped:       c.skipped,
+		emitted:       c.emitted,
+		timings:       append([]handlerTiming(nil), c.timings...),
+		recoverPanics: c.recoverPanics,
+		sampling:      c.sampling,
+		err:           c.err,
+		final:         c.final,
+		pairsEntered:  c.pairsEntered,
+		deadline:      c.deadline,
+		chainSpan:     c.chainSpan,
+		span:          c.span,
+		forkedFrom:    c,
+	}
+}
+
+// join copies back the changes made to a forked state (see fork)
+func (c *chainState) join(f *chainState) {
+	c.handler = f.handler
+	c.statName = f.statName
+	c.skipped = f.skipped
+	c.timings = f.timings
+	c.recoverPanics = f.recoverPanics
+	c.err = f.err
+	c.final = f.final
+	c.pairsEntered = f.pairsEntered
+	c.deadline = f.deadline
+	c.span = f.span
+}
+
+// requestCache returns the cache of the request, which forked states share with the chain
+func (c *chainState) requestCache() *RequestCacheStore {
+	for c.forkedFrom != nil {
+		c = c.forkedFrom
+	}
+
+	return &c.cache
+}
+
+// CtxHandlerName returns the name of the handler of the chain currently handling the request (as
+// recorded in its stats, see NamedHandler), or "" outside of a chain. In nested chains, it is the
+// handler of the innermost chain.
+func CtxHandlerName(r *http.Request) string {
+	if c := chainFromRequest(r); c != nil {
+		return c.handler
+	}
+
+	return ""
+}
+
+// chainDepth returns the depth of a chain started for the request: one more than the
+// depth of the chain it is already running in (if any)
+func chainDepth(r *http.Request) int {
+	if parent := chainFromRequest(r); parent != nil {
+		return parent.depth + 1
+	}
+
+	return 1
+}
+
+// maxChainDepth returns Config.MaxChainDepth, or its default
+func (m *MWHandler) maxChainDepth() int {
+	if m.Config.MaxChainDepth == 0 {
+		return DEFAULT_MAX_CHAIN_DEPTH
+	}
+
+	return m.Config.MaxChainDepth
+}
+
+// rejectTooDeep fails a request whose chains are nested deeper than max, reporting the
+// error (OnError and the errors stat) as if a handler had returned it
+func (m *MWHandler) rejectTooDeep(w http.ResponseWriter, r *http.Request, max int) {
+	resp := &Response{
+		Err:        fmt.Errorf("Chains nested more than %d deep; are chains nested in a cycle?", max),
+		StatusCode: http.StatusInternalServerError,
+	}
+
+	// No chain is started for the request; this state only sends the stat
+	state := &chainState{mw: m, statRate: m.statRate(r)}
+	state.inc(m.Config.ErrorStatName)
+
+	if m.Config.OnError != nil {
+		m.Config.OnError(r, resp)
+	}
+
+	resp.writeHeaders(w)
+
+	if m.Config.ErrorRenderer != nil {
+		m.Config.ErrorRenderer(w, resp)
+	} else {
+		writeErrorStatus(w, r, resp.Error(), resp.StatusCode)
+	}
+}
+
+// timing records a timing stat through the chain's reporter (if any)
+func (c *chainState) timing(stat string, d time.Duration) {
+	if c == nil || c.mw.Config.DisableTiming || !c.sampled() {
+		return
+	}
+
+	if reporter := c.mw.reporter(); reporter != nil {
+		c.emitted.record("TimingDuration", stat, d.String())
+		rate := c.statRate
+		c.send(func() { reporter.TimingDuration(stat, d, rate) })
+	}
+}
+
+// inc increments a counter through the chain's reporter (if any)
+func (c *chainState) inc(stat string) {
+	c.count(stat, 1)
+}
+
+// count adds the value to a counter through the chain's reporter (if any)
+func (c *chainState) count(stat string, value int64) {
+	if c == nil || c.mw.Config.DisableCount || !c.sampled() {
+		return
+	}
+
+	if reporter := c.mw.reporter(); reporter != nil {
+		c.emitted.record("Inc", stat, strconv.FormatInt(value, 10))
+		rate := c.statRate
+		c.send(func() { reporter.Inc(stat, value, rate) })
+	}
+}
+
+// gauge records a gauge through the chain's gauge reporter (if any)
+func (c *chainState) gauge(stat string, value int64) {
+	if c == nil || !c.sampled() {
+		return
+	}
+
+	if reporter := c.mw.gaugeReporter(); reporter != nil {
+		c.emitted.record("Gauge", stat, strconv.FormatInt(value, 10))
+		rate := c.statRate
+		c.send(func() { reporter.Gauge(stat, value, rate) })
+	}
+}
+
+// send sends a stat in the background, or inline when Config.SyncStats is set
+func (c *chainState) send(stat func()) {
+	if c.mw.Config.SyncStats {
+		stat()
+		return
+	}
+
+	go stat()
+}
+
+/*
+Checkpoint creates a no-op handler that records the time elapsed since the start of the chain
+as `handlers.<chain>.checkpoint.<name>` (after the stat prefix, if any), where `<chain>` is the name of the
+first handler in the chain and `handlers` is Config.HandlerStatNamespace.
+This is useful to measure how long the portion of a chain preceding your business logic takes.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareJWT(secret),
+			rye.Checkpoint("auth"), // records handlers.<chain>.checkpoint.auth
+			yourHandler,
+		})).Methods("GET")
+*/
+func Checkpoint(name string) Handler {
+	return func(rw http.ResponseWriter, r *http.Request) *Response {
+		if c := chainFromRequest(r); c != nil {
+			c.timing(c.statPrefix+c.mw.Config.HandlerStatNamespace+"."+c.name+".checkpoint."+name, time.Since(c.start))
+		}
+
+		return nil
+	}
+}
+
+/*
+FinalResponse returns the response of the handler that ended the chain the request is
+running in, or nil if every handler ran (or the request is not running in a chain).
+It is meant for the after handlers of HandleWithAfter, which run once the chain is done.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.HandleWithAfter(
+		[]rye.Handler{
+			rye.NewMiddlewareJWT(secret),
+			yourHandler,
+		},
+		[]rye.Handler{
+			func(rw http.ResponseWriter, r *http.Request) *rye.Response {
+				if resp := rye.FinalResponse(r); resp != nil && resp.Err != nil {
+					log.Errorf("%v failed with %v: %v", r.URL, resp.StatusCode, resp.Err)
+				}
+				return nil
+			},
+		})).Methods("GET")
+*/
+func FinalResponse(r *http.Request) *Response {
+	if c := chainFromRequest(r); c != nil {
+		return c.final
+	}
+
+	return nil
+}
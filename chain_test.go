@@ -0,0 +1,196 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/InVisionApp/rye/fakes/statsdfakes"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Chain", func() {
+
+	var (
+		request     *http.Request
+		response    *httptest.ResponseRecorder
+		mwHandler   *MWHandler
+		fakeStatter *statsdfakes.FakeStatter
+		timing      chan statsTiming
+	)
+
+	BeforeEach(func() {
+		fakeStatter = &statsdfakes.FakeStatter{}
+		mwHandler = NewMWHandler(Config{
+			Statter:  fakeStatter,
+			StatRate: 1,
+		})
+
+		response = httptest.NewRecorder()
+		request = &http.Request{
+			Header: make(map[string][]string, 0),
+		}
+
+		// Stats are sent asynchronously; keep those of earlier specs out of this one's channel
+		timings := make(chan statsTiming, 10)
+		timing = timings
+		fakeStatter.TimingDurationStub = func(name string, time time.Duration, statrate float32) error {
+			timings <- statsTiming{name, time, statrate}
+			return nil
+		}
+	})
+
+	Describe("chain state", func() {
+		It("should be available to handlers in the chain", func() {
+			var state *chainState
+
+			h := mwHandler.Handle([]Handler{successHandler, func(rw http.ResponseWriter, r *http.Request) *Response {
+				state = chainFromRequest(r)
+				return nil
+			}})
+			h.ServeHTTP(response, request)
+
+			Expect(state).ToNot(BeNil())
+			Expect(state.name).To(Equal("successHandler"))
+			Expect(state.mw).To(Equal(mwHandler))
+		})
+	})
+
+	Describe("chain depth", func() {
+		// nest serves the request through depth chains nested within each other
+		var nest func(depth int) http.Handler
+		nest = func(depth int) http.Handler {
+			if depth == 1 {
+				return mwHandler.Handle([]Handler{successHandler})
+			}
+
+			inner := nest(depth - 1)
+			return mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+				inner.ServeHTTP(rw, r)
+				return &Response{StopExecution: true}
+			}})
+		}
+
+		It("should let chains be nested up to the maximum depth", func() {
+			mwHandler.Config.MaxChainDepth = 3
+
+			nest(3).ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).ToNot(ContainSubstring("nested"))
+		})
+
+		It("should fail chains nested deeper than the maximum depth", func() {
+			mwHandler.Config.MaxChainDepth = 3
+
+			nest(4).ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			Expect(response.Body.String()).To(ContainSubstring("Chains nested more than 3 deep"))
+		})
+
+		It("should report the failure like any other error", func() {
+			reporter := &recordingReporter{}
+			mwHandler = NewMWHandler(Config{Reporter: reporter, SyncStats: true, MaxChainDepth: 3})
+
+			var failed *Response
+			mwHandler.Config.OnError = func(r *http.Request, resp *Response) {
+				if failed == nil {
+					failed = resp
+				}
+			}
+
+			nest(4).ServeHTTP(response, request)
+
+			Expect(failed).ToNot(BeNil())
+			Expect(failed.StatusCode).To(Equal(http.StatusInternalServerError))
+			Expect(failed.Error()).To(ContainSubstring("Chains nested more than 3 deep"))
+			Expect(reporter.recordedIncs()).To(ContainElement(DEFAULT_ERROR_STAT_NAME))
+		})
+
+		It("should stop chains nested in a cycle", func() {
+			var h http.Handler
+			h = mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+				h.ServeHTTP(rw, r)
+				return &Response{StopExecution: true}
+			}})
+
+			h.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			Expect(response.Body.String()).To(ContainSubstring("Chains nested more than 32 deep"))
+		})
+
+		It("should not check the depth when disabled", func() {
+			mwHandler.Config.MaxChainDepth = -1
+
+			nest(DEFAULT_MAX_CHAIN_DEPTH+1).ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("CtxHandlerName", func() {
+		var names []string
+
+		BeforeEach(func() {
+			names = nil
+		})
+
+		record := func(rw http.ResponseWriter, r *http.Request) *Response {
+			names = append(names, CtxHandlerName(r))
+			return nil
+		}
+
+		It("should be the name of the handler currently running", func() {
+			h := mwHandler.HandleWithAfter([]Handler{
+				NamedHandler("auth", record),
+				NamedHandler("list", record),
+			}, []Handler{NamedHandler("audit", record)})
+			h.ServeHTTP(response, request)
+
+			Expect(names).To(Equal([]string{"auth", "list", "audit"}))
+		})
+
+		It("should fall back on the function name", func() {
+			mwHandler.Handle([]Handler{record}).ServeHTTP(response, request)
+
+			Expect(names).To(Equal([]string{handlerName(record)}))
+			Expect(names[0]).To(MatchRegexp(`^func\d+`))
+		})
+
+		It("should be the handler of the innermost chain", func() {
+			inner := mwHandler.Handle([]Handler{NamedHandler("inner", record)})
+
+			mwHandler.Handle([]Handler{
+				NamedHandler("outer", func(rw http.ResponseWriter, r *http.Request) *Response {
+					inner.ServeHTTP(rw, r)
+					return record(rw, r)
+				}),
+			}).ServeHTTP(response, request)
+
+			Expect(names).To(Equal([]string{"inner", "outer"}))
+		})
+
+		It("should be empty outside of a chain", func() {
+			Expect(CtxHandlerName(request)).To(BeEmpty())
+		})
+	})
+
+	Describe("Checkpoint", func() {
+		It("should emit a timing from the chain start with the checkpoint name", func() {
+			h := mwHandler.Handle([]Handler{slowHandler, Checkpoint("auth"), successHandler})
+			h.ServeHTTP(response, request)
+
+			Eventually(timing).Should(Receive(WithTransform(func(t statsTiming) bool {
+				return t.Name == "handlers.slowHandler.checkpoint.auth" && t.Time >= 150*time.Millisecond
+			}, BeTrue())))
+		})
+
+		It("should be a no-op outside of a chain", func() {
+			resp := Checkpoint("auth")(response, request)
+			Expect(resp).To(BeNil())
+			Expect(fakeStatter.TimingDurationCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,80 @@
+package rye
+
+import (
+	"context"
+	"fmt"
+)
+
+// flusher is implemented by statters that buffer stats (ie. a batching statter)
+type flusher interface {
+	Flush() error
+}
+
+/*
+OnClose registers a hook to run when the MWHandler is closed (see Close), ie. to close a
+shared store (such as a Redis backed BreakerStore) used by middleware. Hooks run in the
+order they were registered.
+
+Example usage:
+
+	mwHandler.OnClose(func(ctx context.Context) error {
+		return redisClient.Close()
+	})
+*/
+func (m *MWHandler) OnClose(hook func(ctx context.Context) error) {
+	m.closeMu.Lock()
+	defer m.closeMu.Unlock()
+
+	m.closeHooks = append(m.closeHooks, hook)
+}
+
+/*
+Close cleans up on graceful shutdown: the registered OnClose hooks are run, then the statter
+is flushed (if it buffers stats) and closed so that no buffered stats are lost. Every step is
+attempted; the first error encountered is returned. Hooks are skipped once ctx is done.
+
+Example usage:
+
+	server.Shutdown(ctx)
+
+	if err := mwHandler.Close(ctx); err != nil {
+		log.Errorf("Unable to clean up: %v", err)
+	}
+*/
+func (m *MWHandler) Close(ctx context.Context) error {
+	m.closeMu.Lock()
+	hooks := m.closeHooks
+	m.closeHooks = nil
+	m.closeMu.Unlock()
+
+	var firstErr error
+
+	record := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for _, hook := range hooks {
+		if err := ctx.Err(); err != nil {
+			record(fmt.Errorf("Unable to run close hooks: %v", err))
+			break
+		}
+
+		record(hook(ctx))
+	}
+
+	if m.Config.Statter != nil {
+		if f, ok := m.Config.Statter.(flusher); ok {
+			if err := f.Flush(); err != nil {
+				record(fmt.Errorf("Unable to flush statter: %v", err))
+			}
+		}
+
+		if err := m.Config.Statter.Close(); err != nil {
+			record(fmt.Errorf("Unable to close statter: %v", err))
+		}
+	}
+
+	return firstErr
+}
@@ -0,0 +1,85 @@
+package rye
+
+import (
+	"context"
+	"errors"
+
+	"github.com/InVisionApp/rye/fakes/statsdfakes"
+	. "github.com/onsi/gomega"
+)
+
+// bufferedStatter is a statter that buffers stats until flushed
+type bufferedStatter struct {
+	*statsdfakes.FakeStatter
+	flushes int
+}
+
+func (b *bufferedStatter) Flush() error {
+	b.flushes++
+	return nil
+}
+
+var _ = Describe("Close", func() {
+
+	var (
+		statter   *bufferedStatter
+		mwHandler *MWHandler
+	)
+
+	BeforeEach(func() {
+		statter = &bufferedStatter{FakeStatter: &statsdfakes.FakeStatter{}}
+		mwHandler = NewMWHandler(Config{Statter: statter})
+	})
+
+	It("should flush and close a buffered statter", func() {
+		Expect(mwHandler.Close(context.Background())).To(Succeed())
+
+		Expect(statter.flushes).To(Equal(1))
+		Expect(statter.CloseCallCount()).To(Equal(1))
+	})
+
+	It("should run the registered hooks in order", func() {
+		var ran []string
+
+		mwHandler.OnClose(func(ctx context.Context) error {
+			ran = append(ran, "first")
+			return nil
+		})
+		mwHandler.OnClose(func(ctx context.Context) error {
+			ran = append(ran, "second")
+			return nil
+		})
+
+		Expect(mwHandler.Close(context.Background())).To(Succeed())
+		Expect(ran).To(Equal([]string{"first", "second"}))
+	})
+
+	It("should carry on after a failing hook and return its error", func() {
+		mwHandler.OnClose(func(ctx context.Context) error {
+			return errors.New("store close failed")
+		})
+
+		err := mwHandler.Close(context.Background())
+		Expect(err).To(MatchError("store close failed"))
+		Expect(statter.flushes).To(Equal(1))
+	})
+
+	It("should skip the hooks when the context is done", func() {
+		ran := false
+		mwHandler.OnClose(func(ctx context.Context) error {
+			ran = true
+			return nil
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		Expect(mwHandler.Close(ctx)).ToNot(Succeed())
+		Expect(ran).To(BeFalse())
+		Expect(statter.CloseCallCount()).To(Equal(1))
+	})
+
+	It("should work without a statter", func() {
+		Expect(NewMWHandler(Config{}).Close(context.Background())).To(Succeed())
+	})
+})
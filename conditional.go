@@ -0,0 +1,56 @@
+package rye
+
+import (
+	"net/http"
+	"strings"
+)
+
+/*
+When wraps a handler so that it only runs for requests matching the predicate; for other requests, the chain
+carries on as if the handler wasn't there (no stats are recorded for it). This applies handlers to specific
+paths or methods without building separate chains. See OnMethods and OnPathPrefix for common predicates.
+
+Example usage:
+
+	routes.PathPrefix("/").Handler(a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.When(rye.OnMethods("POST", "PUT"), rye.NewMiddlewareSizeLimitByPath(limits, 64<<10)),
+			rye.When(rye.OnPathPrefix("/admin"), rye.NewMiddlewareJWT(secret)),
+			yourHandler,
+		}))
+*/
+func When(pred func(*http.Request) bool, h Handler) Handler {
+	wrapped := Handler(func(rw http.ResponseWriter, r *http.Request) *Response {
+		if !pred(r) {
+			if c := chainFromRequest(r); c != nil {
+				c.skipped = true
+			}
+			return nil
+		}
+
+		return h(rw, r)
+	})
+
+	// Keep recording stats under the name of the wrapped handler
+	return describeAs(wrapped, h)
+}
+
+// OnMethods returns a predicate (see When) matching requests with any of the given methods
+func OnMethods(methods ...string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		for _, method := range methods {
+			if strings.EqualFold(r.Method, method) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// OnPathPrefix returns a predicate (see When) matching requests whose path starts with the prefix
+func OnPathPrefix(prefix string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		return r.URL != nil && strings.HasPrefix(r.URL.Path, prefix)
+	}
+}
@@ -0,0 +1,78 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("When", func() {
+
+	var (
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		reporter  *recordingReporter
+		ran       bool
+		guarded   Handler
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		reporter = &recordingReporter{}
+		mwHandler = NewMWHandler(Config{Reporter: reporter, StatRate: 1})
+		ran = false
+
+		guarded = NamedHandler("guarded", func(rw http.ResponseWriter, r *http.Request) *Response {
+			ran = true
+			return nil
+		})
+	})
+
+	Context("when the predicate matches", func() {
+		It("should run the handler and record its stats", func() {
+			h := mwHandler.Handle([]Handler{When(OnMethods("POST", "put"), guarded), successHandler})
+			h.ServeHTTP(response, httptest.NewRequest("PUT", "/items", nil))
+
+			Expect(ran).To(BeTrue())
+			Eventually(reporter.recordedIncs).Should(ContainElement("handlers.guarded.2xx"))
+			Eventually(reporter.recordedTimings).Should(ContainElement("handlers.guarded.runtime"))
+		})
+	})
+
+	Context("when the predicate does not match", func() {
+		It("should skip the handler without recording stats for it", func() {
+			h := mwHandler.Handle([]Handler{When(OnMethods("POST"), guarded), successHandler})
+			h.ServeHTTP(response, httptest.NewRequest("GET", "/items", nil))
+
+			Expect(ran).To(BeFalse())
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			Eventually(reporter.recordedIncs).Should(ContainElement("handlers.successHandler.2xx"))
+			Consistently(reporter.recordedIncs).Should(HaveLen(1))
+			Expect(reporter.recordedTimings()).To(ConsistOf(
+				"handlers.successHandler.runtime",
+				"handlers.guarded.chain.runtime",
+			))
+		})
+
+		It("should leave it out of the chain timings", func() {
+			var timings []handlerTiming
+
+			h := mwHandler.Handle([]Handler{When(OnPathPrefix("/admin"), guarded), func(rw http.ResponseWriter, r *http.Request) *Response {
+				timings = chainFromRequest(r).timings
+				return nil
+			}})
+			h.ServeHTTP(response, httptest.NewRequest("GET", "/items", nil))
+
+			Expect(timings).To(BeEmpty())
+		})
+	})
+
+	Describe("OnPathPrefix", func() {
+		It("should match paths starting with the prefix", func() {
+			Expect(OnPathPrefix("/admin")(httptest.NewRequest("GET", "/admin/users", nil))).To(BeTrue())
+			Expect(OnPathPrefix("/admin")(httptest.NewRequest("GET", "/items", nil))).To(BeFalse())
+		})
+	})
+})
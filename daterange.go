@@ -0,0 +1,106 @@
+package rye
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// relativeDateRegex matches relative date values such as `now`, `now-7d` or `now+12h` (an
+// unescaped `+` in a query string decodes to a space, so `now 12h` is accepted as well)
+var relativeDateRegex = regexp.MustCompile(`^now(?:([+ -])(\d+)([smhdw]))?$`)
+
+/*
+ParseDateRange parses and validates a date range passed in the query string of a request.
+Both params are required, must be parseable using `layout` (or be a relative value such as
+`now`, `now-7d` or `now-12h`; supported units are s, m, h, d and w) and `from` must not be
+after `to`. Future values such as `now+12h` need the `+` escaped in the URL (`now%2B12h`),
+though an unescaped `+` (decoded as a space) is tolerated.
+
+On failure, a *Response with a 400 and a detailed error is returned which can be handed
+straight back to rye.
+
+Example usage:
+
+	func reportHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		from, to, resp := rye.ParseDateRange(r, "from", "to", "2006-01-02")
+		if resp != nil {
+			return resp
+		}
+		...
+	}
+*/
+func ParseDateRange(r *http.Request, fromParam, toParam string, layout string) (from, to time.Time, resp *Response) {
+	now := time.Now()
+	query := r.URL.Query()
+
+	from, err := parseDate(query.Get(fromParam), layout, now)
+	if err != nil {
+		return time.Time{}, time.Time{}, dateRangeError(fmt.Errorf("Invalid '%s' date: %v", fromParam, err))
+	}
+
+	to, err = parseDate(query.Get(toParam), layout, now)
+	if err != nil {
+		return time.Time{}, time.Time{}, dateRangeError(fmt.Errorf("Invalid '%s' date: %v", toParam, err))
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, dateRangeError(fmt.Errorf("Invalid date range: '%s' (%v) is after '%s' (%v)",
+			fromParam, from.Format(layout), toParam, to.Format(layout)))
+	}
+
+	return from, to, nil
+}
+
+func dateRangeError(err error) *Response {
+	return &Response{
+		Err:        err,
+		StatusCode: http.StatusBadRequest,
+	}
+}
+
+// parseDate parses either an absolute date (using layout) or a relative one (ie. `now-7d`)
+func parseDate(value, layout string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("value is missing")
+	}
+
+	matches := relativeDateRegex.FindStringSubmatch(value)
+	if matches == nil {
+		return time.Parse(layout, value)
+	}
+
+	// Plain `now`
+	if matches[1] == "" {
+		return now, nil
+	}
+
+	amount, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var unit time.Duration
+
+	switch matches[3] {
+	case "s":
+		unit = time.Second
+	case "m":
+		unit = time.Minute
+	case "h":
+		unit = time.Hour
+	case "d":
+		unit = 24 * time.Hour
+	case "w":
+		unit = 7 * 24 * time.Hour
+	}
+
+	offset := time.Duration(amount) * unit
+	if matches[1] == "-" {
+		offset = -offset
+	}
+
+	return now.Add(offset), nil
+}
@@ -0,0 +1,93 @@
+package rye
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseDateRange", func() {
+
+	var (
+		request *http.Request
+		layout  = "2006-01-02"
+	)
+
+	BeforeEach(func() {
+		request = &http.Request{
+			URL: &url.URL{},
+		}
+	})
+
+	Context("when a valid range is passed", func() {
+		It("should return the parsed dates", func() {
+			request.URL.RawQuery = "from=2017-01-01&to=2017-01-31"
+
+			from, to, resp := ParseDateRange(request, "from", "to", layout)
+			Expect(resp).To(BeNil())
+			Expect(from).To(Equal(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)))
+			Expect(to).To(Equal(time.Date(2017, 1, 31, 0, 0, 0, 0, time.UTC)))
+		})
+
+		It("should support relative dates", func() {
+			request.URL.RawQuery = "from=now-7d&to=now"
+
+			from, to, resp := ParseDateRange(request, "from", "to", layout)
+			Expect(resp).To(BeNil())
+			Expect(to.Sub(from)).To(Equal(7 * 24 * time.Hour))
+			Expect(to).To(BeTemporally("~", time.Now(), time.Second))
+		})
+
+		It("should support future relative dates, escaped or not", func() {
+			for _, query := range []string{"from=now&to=now%2B12h", "from=now&to=now+12h"} {
+				request.URL.RawQuery = query
+
+				from, to, resp := ParseDateRange(request, "from", "to", layout)
+				Expect(resp).To(BeNil())
+				Expect(to.Sub(from)).To(Equal(12 * time.Hour))
+			}
+		})
+	})
+
+	Context("when an inverted range is passed", func() {
+		It("should return a 400 response", func() {
+			request.URL.RawQuery = "from=2017-02-01&to=2017-01-01"
+
+			_, _, resp := ParseDateRange(request, "from", "to", layout)
+			Expect(resp).ToNot(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(resp.Error()).To(ContainSubstring("Invalid date range"))
+		})
+	})
+
+	Context("when a malformed date is passed", func() {
+		It("should return a 400 response for the from param", func() {
+			request.URL.RawQuery = "from=yesterday&to=2017-01-01"
+
+			_, _, resp := ParseDateRange(request, "from", "to", layout)
+			Expect(resp).ToNot(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(resp.Error()).To(ContainSubstring("Invalid 'from' date"))
+		})
+
+		It("should return a 400 response for the to param", func() {
+			request.URL.RawQuery = "from=2017-01-01&to=now-7x"
+
+			_, _, resp := ParseDateRange(request, "from", "to", layout)
+			Expect(resp).ToNot(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(resp.Error()).To(ContainSubstring("Invalid 'to' date"))
+		})
+
+		It("should return a 400 response when a param is missing", func() {
+			request.URL.RawQuery = "from=2017-01-01"
+
+			_, _, resp := ParseDateRange(request, "from", "to", layout)
+			Expect(resp).ToNot(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(resp.Error()).To(ContainSubstring("value is missing"))
+		})
+	})
+})
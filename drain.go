@@ -0,0 +1,74 @@
+package rye
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Default Retry-After of the requests rejected while draining (see Config.DrainRetryAfter)
+	DEFAULT_DRAIN_RETRY_AFTER = 5 * time.Second
+)
+
+/*
+Drain stops the chains of the MWHandler from handling new requests, for zero-downtime deploys: from then on,
+requests are answered with a 503 (along with a `Retry-After` header, see Config.DrainRetryAfter) before any
+handler runs, and counted as `draining.rejected` (under Config.StatPrefix). Requests already in flight complete
+normally, including chains nested in them. Undrain resumes handling requests.
+
+Example usage:
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		mwHandler.Drain()
+	}()
+*/
+func (m *MWHandler) Drain() {
+	atomic.StoreInt32(&m.draining, 1)
+}
+
+// Undrain resumes handling requests after Drain.
+func (m *MWHandler) Undrain() {
+	atomic.StoreInt32(&m.draining, 0)
+}
+
+// Draining reports whether the MWHandler is draining (see Drain).
+func (m *MWHandler) Draining() bool {
+	return atomic.LoadInt32(&m.draining) == 1
+}
+
+// drainRetryAfter returns Config.DrainRetryAfter, or its default
+func (m *MWHandler) drainRetryAfter() time.Duration {
+	if m.Config.DrainRetryAfter <= 0 {
+		return DEFAULT_DRAIN_RETRY_AFTER
+	}
+
+	return m.Config.DrainRetryAfter
+}
+
+// rejectDraining answers a request received while draining with a 503
+func (m *MWHandler) rejectDraining(w http.ResponseWriter, r *http.Request, statPrefix string) {
+	// The request never makes it into a chain; this state only sends the stat
+	state := &chainState{mw: m, statRate: m.statRate(r), statPrefix: statPrefix}
+	state.inc(statPrefix + "draining.rejected")
+
+	seconds := int64((m.drainRetryAfter() + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+
+	resp := &Response{
+		Err:        errors.New("Service is draining"),
+		StatusCode: http.StatusServiceUnavailable,
+	}
+
+	if m.Config.ErrorRenderer != nil {
+		m.Config.ErrorRenderer(w, resp)
+	} else {
+		writeErrorStatus(w, r, resp.Error(), resp.StatusCode)
+	}
+}
@@ -0,0 +1,141 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Drain", func() {
+
+	var (
+		mwHandler *MWHandler
+		reporter  *recordingReporter
+		calls     int
+		h         http.Handler
+	)
+
+	BeforeEach(func() {
+		reporter = &recordingReporter{}
+		mwHandler = NewMWHandler(Config{Reporter: reporter, SyncStats: true})
+		calls = 0
+		h = mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+			calls++
+			return nil
+		}})
+	})
+
+	serve := func() *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		h.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+		return response
+	}
+
+	It("should answer with a 503 before any handler runs while draining", func() {
+		mwHandler.Drain()
+		Expect(mwHandler.Draining()).To(BeTrue())
+
+		response := serve()
+
+		Expect(calls).To(BeZero())
+		Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(response.Header().Get("Retry-After")).To(Equal("5"))
+		Expect(response.Body.String()).To(ContainSubstring("Service is draining"))
+		Expect(reporter.recordedIncs()).To(Equal([]string{"draining.rejected"}))
+	})
+
+	It("should resume handling requests once undrained", func() {
+		mwHandler.Drain()
+		serve()
+
+		mwHandler.Undrain()
+		Expect(mwHandler.Draining()).To(BeFalse())
+
+		response := serve()
+
+		Expect(calls).To(Equal(1))
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Header()).ToNot(HaveKey("Retry-After"))
+	})
+
+	It("should send the configured Retry-After, in whole seconds", func() {
+		mwHandler.Config.DrainRetryAfter = 1500 * time.Millisecond
+		mwHandler.Drain()
+
+		Expect(serve().Header().Get("Retry-After")).To(Equal("2"))
+	})
+
+	It("should let requests already in flight complete", func() {
+		release := make(chan struct{})
+		started := make(chan struct{})
+
+		slow := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+			close(started)
+			<-release
+			return &Response{StatusCode: http.StatusNoContent}
+		}})
+
+		inflight := httptest.NewRecorder()
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slow.ServeHTTP(inflight, httptest.NewRequest("GET", "/", nil))
+		}()
+
+		Eventually(started).Should(BeClosed())
+		mwHandler.Drain()
+		close(release)
+		wg.Wait()
+
+		Expect(inflight.Code).To(Equal(http.StatusNoContent))
+		Expect(serve().Code).To(Equal(http.StatusServiceUnavailable))
+	})
+
+	It("should let chains nested in a request in flight run", func() {
+		outer := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+			mwHandler.Drain()
+			h.ServeHTTP(rw, r)
+			return &Response{StopExecution: true}
+		}})
+
+		response := httptest.NewRecorder()
+		outer.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+
+		Expect(calls).To(Equal(1))
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(reporter.recordedIncs()).ToNot(ContainElement("draining.rejected"))
+	})
+
+	It("should prefix the stat with the StatPrefix", func() {
+		mwHandler.Config.StatPrefix = "api"
+		h = mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+			calls++
+			return nil
+		}})
+		mwHandler.Drain()
+
+		serve()
+
+		Expect(reporter.recordedIncs()).To(Equal([]string{"api.draining.rejected"}))
+	})
+
+	It("should not write the rejection to the client in dry run mode", func() {
+		var result DryRunResult
+		mwHandler.Config.DryRun = func(r DryRunResult) {
+			result = r
+		}
+		mwHandler.Drain()
+
+		response := serve()
+
+		Expect(calls).To(BeZero())
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Body.Len()).To(BeZero())
+		Expect(result.StatusCode).To(Equal(http.StatusServiceUnavailable))
+	})
+})
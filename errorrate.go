@@ -0,0 +1,108 @@
+package rye
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// Number of buckets the error rate window is split into
+	ERROR_RATE_BUCKETS = 10
+
+	// Maximum number of handlers whose error rate is tracked
+	ERROR_RATE_MAX_HANDLERS = 1000
+)
+
+// errorRateBucket counts the calls of a handler during a slice of the window
+type errorRateBucket struct {
+	slice  int64
+	total  int64
+	errors int64
+}
+
+// errorRateTracker keeps a rolling window of calls and errors per handler
+type errorRateTracker struct {
+	mu       sync.Mutex
+	handlers map[string]*[ERROR_RATE_BUCKETS]errorRateBucket
+}
+
+// record counts a call of the handler (and whether it failed) in the bucket of the current slice
+func (t *errorRateTracker) record(name string, failed bool, window time.Duration, now time.Time) {
+	slice := errorRateSlice(window, now)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.handlers == nil {
+		t.handlers = make(map[string]*[ERROR_RATE_BUCKETS]errorRateBucket)
+	}
+
+	buckets, ok := t.handlers[name]
+	if !ok {
+		// Keep memory bounded should handler names be generated dynamically
+		if len(t.handlers) >= ERROR_RATE_MAX_HANDLERS {
+			return
+		}
+
+		buckets = &[ERROR_RATE_BUCKETS]errorRateBucket{}
+		t.handlers[name] = buckets
+	}
+
+	bucket := &buckets[slice%ERROR_RATE_BUCKETS]
+	if bucket.slice != slice {
+		*bucket = errorRateBucket{slice: slice}
+	}
+
+	bucket.total++
+	if failed {
+		bucket.errors++
+	}
+}
+
+// rate returns the share of failed calls of the handler over the window (0 without calls)
+func (t *errorRateTracker) rate(name string, window time.Duration, now time.Time) float64 {
+	slice := errorRateSlice(window, now)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	buckets, ok := t.handlers[name]
+	if !ok {
+		return 0
+	}
+
+	var total, errors int64
+	for _, bucket := range buckets {
+		if bucket.slice > slice-ERROR_RATE_BUCKETS && bucket.slice <= slice {
+			total += bucket.total
+			errors += bucket.errors
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	return float64(errors) / float64(total)
+}
+
+// errorRateSlice returns the index of the slice of the window the given time falls in
+func errorRateSlice(window time.Duration, now time.Time) int64 {
+	width := int64(window / ERROR_RATE_BUCKETS)
+	if width <= 0 {
+		width = 1
+	}
+
+	return now.UnixNano() / width
+}
+
+// ErrorRate returns the share of calls of the named handler (ie. `loginHandler`) that ended in a
+// server error over the last Config.ErrorRateWindow, between 0 and 1. It returns 0 for handlers
+// that have not been called (or when ErrorRateWindow is not set).
+func (m *MWHandler) ErrorRate(handlerName string) float64 {
+	if m.Config.ErrorRateWindow <= 0 {
+		return 0
+	}
+
+	return m.errorRates.rate(handlerName, m.Config.ErrorRateWindow, time.Now())
+}
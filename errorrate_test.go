@@ -0,0 +1,110 @@
+package rye
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Error rate", func() {
+
+	Describe("errorRateTracker", func() {
+		var (
+			tracker *errorRateTracker
+			window  time.Duration
+			now     time.Time
+		)
+
+		BeforeEach(func() {
+			tracker = &errorRateTracker{}
+			window = 10 * time.Second
+			now = time.Unix(1000, 0)
+		})
+
+		It("should compute the share of failed calls over the window", func() {
+			for i := 0; i < 3; i++ {
+				tracker.record("loginHandler", false, window, now)
+			}
+			tracker.record("loginHandler", true, window, now.Add(5*time.Second))
+
+			Expect(tracker.rate("loginHandler", window, now.Add(5*time.Second))).To(Equal(0.25))
+		})
+
+		It("should forget calls that fell out of the window", func() {
+			tracker.record("loginHandler", true, window, now)
+			tracker.record("loginHandler", false, window, now.Add(9*time.Second))
+
+			Expect(tracker.rate("loginHandler", window, now.Add(9*time.Second))).To(Equal(0.5))
+			Expect(tracker.rate("loginHandler", window, now.Add(10*time.Second))).To(Equal(0.0))
+			Expect(tracker.rate("loginHandler", window, now.Add(time.Minute))).To(Equal(0.0))
+		})
+
+		It("should reuse buckets once they are stale", func() {
+			tracker.record("loginHandler", true, window, now)
+			tracker.record("loginHandler", false, window, now.Add(window))
+
+			Expect(tracker.rate("loginHandler", window, now.Add(window))).To(Equal(0.0))
+		})
+
+		It("should return 0 for unknown handlers", func() {
+			Expect(tracker.rate("unknownHandler", window, now)).To(Equal(0.0))
+		})
+
+		It("should bound the number of tracked handlers", func() {
+			for i := 0; i < ERROR_RATE_MAX_HANDLERS+10; i++ {
+				tracker.record(fmt.Sprintf("handler%d", i), true, window, now)
+			}
+
+			Expect(tracker.handlers).To(HaveLen(ERROR_RATE_MAX_HANDLERS))
+		})
+
+		It("should be safe for concurrent use", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					tracker.record("loginHandler", i%2 == 0, window, now)
+					tracker.rate("loginHandler", window, now)
+				}(i)
+			}
+			wg.Wait()
+
+			Expect(tracker.rate("loginHandler", window, now)).To(Equal(0.5))
+		})
+	})
+
+	Describe("ErrorRate", func() {
+		It("should report the server errors of a handler", func() {
+			mwHandler := NewMWHandler(Config{ErrorRateWindow: time.Minute})
+
+			failing := mwHandler.Handle([]Handler{failureHandler})
+			for i := 0; i < 2; i++ {
+				failing.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			}
+
+			Expect(mwHandler.ErrorRate("failureHandler")).To(Equal(1.0))
+			Expect(mwHandler.ErrorRate("successHandler")).To(Equal(0.0))
+		})
+
+		It("should not count client errors", func() {
+			mwHandler := NewMWHandler(Config{ErrorRateWindow: time.Minute})
+
+			mwHandler.Handle([]Handler{badRequestHandler}).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			Expect(mwHandler.ErrorRate("badRequestHandler")).To(Equal(0.0))
+		})
+
+		It("should return 0 when ErrorRateWindow is not set", func() {
+			mwHandler := NewMWHandler(Config{})
+
+			mwHandler.Handle([]Handler{failureHandler}).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			Expect(mwHandler.ErrorRate("failureHandler")).To(Equal(0.0))
+			Expect(mwHandler.errorRates.handlers).To(BeEmpty())
+		})
+	})
+})
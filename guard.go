@@ -0,0 +1,81 @@
+package rye
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// stopGuardWriter sits in front of the client's ResponseWriter (in debug builds, see
+// debugMode) and panics if anything is written after a handler stopped the chain.
+// This surfaces wiring errors such as handlers holding on to the writer and writing
+// to it once the chain has finished. Finalizers flushing buffered output are allowed.
+type stopGuardWriter struct {
+	http.ResponseWriter
+
+	stoppedBy  string
+	finalizing bool
+}
+
+func newStopGuardWriter(rw http.ResponseWriter) *stopGuardWriter {
+	return &stopGuardWriter{ResponseWriter: rw}
+}
+
+// stop records that the named handler stopped the chain
+func (g *stopGuardWriter) stop(name string) {
+	if g == nil {
+		return
+	}
+
+	g.stoppedBy = name
+}
+
+// setFinalizing allows (or disallows again) writes by finalizers
+func (g *stopGuardWriter) setFinalizing(finalizing bool) {
+	if g == nil {
+		return
+	}
+
+	g.finalizing = finalizing
+}
+
+func (g *stopGuardWriter) check() {
+	if g.stoppedBy != "" && !g.finalizing {
+		panic(fmt.Sprintf("rye: write to ResponseWriter after %s stopped the chain", g.stoppedBy))
+	}
+}
+
+func (g *stopGuardWriter) WriteHeader(statusCode int) {
+	g.check()
+	g.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (g *stopGuardWriter) Write(p []byte) (int, error) {
+	g.check()
+	return g.ResponseWriter.Write(p)
+}
+
+// Flush passes through to the wrapped writer (if it supports flushing)
+func (g *stopGuardWriter) Flush() {
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack passes through to the wrapped writer, so that debug builds don't
+// change what handlers can do with the connection
+func (g *stopGuardWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := g.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("The response writer does not support hijacking")
+	}
+
+	return h.Hijack()
+}
+
+// Unwrap returns the wrapped writer (for http.ResponseController)
+func (g *stopGuardWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
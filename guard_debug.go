@@ -0,0 +1,8 @@
+//go:build ryedebug
+// +build ryedebug
+
+package rye
+
+// Built with `-tags ryedebug`: chain wiring errors (ie. writes after a handler
+// stopped the chain or missing context values) fail loudly
+var debugMode = true
@@ -0,0 +1,93 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stop guard", func() {
+
+	var (
+		response  *httptest.ResponseRecorder
+		request   *http.Request
+		mwHandler *MWHandler
+		captured  http.ResponseWriter
+		enabled   bool
+	)
+
+	// leakyStopHandler stops the chain but keeps hold of the writer
+	leakyStopHandler := func(rw http.ResponseWriter, r *http.Request) *Response {
+		captured = rw
+		return &Response{StatusCode: http.StatusNoContent, StopExecution: true}
+	}
+
+	BeforeEach(func() {
+		enabled = debugMode
+		debugMode = true
+
+		response = httptest.NewRecorder()
+		request = httptest.NewRequest("GET", "/", nil)
+		mwHandler = NewMWHandler(Config{})
+		captured = nil
+	})
+
+	AfterEach(func() {
+		debugMode = enabled
+	})
+
+	Context("when a handler writes after the chain was stopped", func() {
+		It("should panic", func() {
+			h := mwHandler.Handle([]Handler{leakyStopHandler})
+			h.ServeHTTP(response, request)
+
+			Expect(func() { captured.Write([]byte("late")) }).To(PanicWith(ContainSubstring("stopped the chain")))
+			Expect(func() { captured.WriteHeader(http.StatusOK) }).To(Panic())
+		})
+	})
+
+	Context("when the chain is used correctly", func() {
+		It("should stay silent for stopped chains", func() {
+			h := mwHandler.Handle([]Handler{stopWithStatusHandler})
+
+			Expect(func() { h.ServeHTTP(response, request) }).ToNot(Panic())
+			Expect(response.Code).To(Equal(http.StatusNotModified))
+		})
+
+		It("should let finalizers flush after the chain was stopped", func() {
+			h := mwHandler.Handle([]Handler{NewMiddlewareEnvelope(), func(rw http.ResponseWriter, r *http.Request) *Response {
+				return &Response{StatusContent: "done", StopExecution: true}
+			}})
+
+			Expect(func() { h.ServeHTTP(response, request) }).ToNot(Panic())
+			Expect(response.Body.String()).To(Equal("done"))
+		})
+
+		It("should keep the flushing and hijacking abilities of the writer", func() {
+			var hijackErr error
+			h := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+				rw.Write([]byte("streamed"))
+				rw.(http.Flusher).Flush()
+
+				conn, _, err := rw.(http.Hijacker).Hijack()
+				if err == nil {
+					conn.Close()
+				}
+				hijackErr = err
+				return nil
+			}})
+			h.ServeHTTP(&hijackableRecorder{ResponseRecorder: response}, request)
+
+			Expect(response.Flushed).To(BeTrue())
+			Expect(hijackErr).ToNot(HaveOccurred())
+		})
+
+		It("should stay silent for chains that are not stopped", func() {
+			h := mwHandler.Handle([]Handler{textHandler})
+
+			Expect(func() { h.ServeHTTP(response, request) }).ToNot(Panic())
+			Expect(response.Body.String()).To(Equal("plain text"))
+		})
+	})
+})
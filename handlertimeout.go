@@ -0,0 +1,176 @@
+package rye
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+/*
+WithTimeout bounds a single (ie. expensive) handler of the chain, as opposed to NewMiddlewareTimeout which bounds
+the whole chain: the handler runs in its own goroutine and, if it takes longer than `d`, the chain is stopped
+with a 504 and the handler is abandoned. Its request context is cancelled, so the calls it makes with it are cut
+off as well.
+
+The handler writes to a buffer that is only copied to the response if it finishes in time, so an abandoned
+handler can't write anything after the 504 is sent. This also means responses can't be streamed (ie. flushed)
+from it. Likewise, it runs on a copy of the state of the chain (the current handler name, spans, etc.), so an
+abandoned handler can't change it while the chain moves on. Panics are passed on to the chain (see
+MiddlewareRecover) if the handler finishes in time, and logged otherwise. Stats are recorded under the name of
+the wrapped handler.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareJWT(secret),
+			rye.WithTimeout(2*time.Second, yourExpensiveHandler),
+		})).Methods("GET")
+*/
+func WithTimeout(d time.Duration, h Handler) Handler {
+	wrapped := Handler(func(rw http.ResponseWriter, r *http.Request) *Response {
+		ctx, cancel := context.WithCancel(r.Context())
+
+		// The handler runs on a copy of the chain state, so that it can't
+		// touch the state of the chain once it has been abandoned
+		var forked *chainState
+		chain := chainFromRequest(r)
+		if chain != nil {
+			forked = chain.fork()
+			ctx = context.WithValue(ctx, CONTEXT_CHAIN, forked)
+		}
+
+		tw := &handlerTimeoutWriter{header: rw.Header().Clone()}
+
+		var (
+			resp      *Response
+			recovered interface{}
+			done      = make(chan struct{})
+		)
+
+		go func() {
+			defer close(done)
+			defer func() {
+				recovered = recover()
+			}()
+
+			resp = h(tw, r.WithContext(ctx))
+		}()
+
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+
+		select {
+		case <-done:
+			if chain != nil {
+				chain.join(forked)
+
+				// Later handlers carry on with the state of the chain
+				if resp != nil && resp.Context != nil {
+					resp.Context = context.WithValue(resp.Context, CONTEXT_CHAIN, chain)
+				}
+			}
+
+			// A context returned by the handler is derived from ctx, so it
+			// is only released along with the context of the request
+			defer func() {
+				if resp == nil || resp.Context == nil {
+					cancel()
+				}
+			}()
+
+			if recovered != nil {
+				panic(recovered)
+			}
+
+			tw.flushTo(rw)
+			return resp
+		case <-timer.C:
+			tw.timeOut()
+			cancel()
+
+			go func() {
+				<-done
+				if recovered != nil {
+					log.Errorf("Panic in handler abandoned after timing out: %v", recovered)
+				}
+			}()
+
+			return &Response{
+				StatusCode:    http.StatusGatewayTimeout,
+				StopExecution: true,
+			}
+		}
+	})
+
+	// Keep recording stats under the name of the wrapped handler
+	return describeAs(wrapped, h)
+}
+
+// handlerTimeoutWriter buffers the response of a handler run by WithTimeout; once the handler
+// timed out, anything it writes is dropped
+type handlerTimeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	timedOut bool
+}
+
+func (t *handlerTimeoutWriter) Header() http.Header {
+	return t.header
+}
+
+func (t *handlerTimeoutWriter) WriteHeader(statusCode int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.status == 0 && !t.timedOut {
+		t.status = statusCode
+	}
+}
+
+func (t *handlerTimeoutWriter) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if t.status == 0 {
+		t.status = http.StatusOK
+	}
+
+	return t.body.Write(p)
+}
+
+// timeOut drops anything written from now on
+func (t *handlerTimeoutWriter) timeOut() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.timedOut = true
+}
+
+// flushTo copies the buffered response to the writer of the chain
+func (t *handlerTimeoutWriter) flushTo(rw http.ResponseWriter) {
+	header := rw.Header()
+	for key := range header {
+		delete(header, key)
+	}
+	for key, values := range t.header {
+		header[key] = values
+	}
+
+	if t.status == 0 {
+		return
+	}
+
+	rw.WriteHeader(t.status)
+	rw.Write(t.body.Bytes())
+}
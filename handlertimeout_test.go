@@ -0,0 +1,151 @@
+package rye
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WithTimeout", func() {
+
+	var (
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		reporter  *recordingReporter
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		reporter = &recordingReporter{}
+		mwHandler = NewMWHandler(Config{Reporter: reporter})
+	})
+
+	serve := func(handlers ...Handler) {
+		mwHandler.Handle(handlers).ServeHTTP(response, httptest.NewRequest("GET", "/report", nil))
+	}
+
+	Context("when the handler finishes in time", func() {
+		It("should write out its response and carry on with the chain", func() {
+			fast := NamedHandler("fast", func(rw http.ResponseWriter, r *http.Request) *Response {
+				rw.Header().Set("X-Report", "ready")
+				rw.WriteHeader(http.StatusCreated)
+				rw.Write([]byte("report"))
+				return nil
+			})
+
+			var carriedOn bool
+			serve(WithTimeout(time.Second, fast), func(rw http.ResponseWriter, r *http.Request) *Response {
+				carriedOn = true
+				return nil
+			})
+
+			Expect(response.Code).To(Equal(http.StatusCreated))
+			Expect(response.Header().Get("X-Report")).To(Equal("ready"))
+			Expect(response.Body.String()).To(Equal("report"))
+			Expect(carriedOn).To(BeTrue())
+			Eventually(reporter.recordedIncs).Should(ContainElement("handlers.fast.2xx"))
+		})
+
+		It("should pass on a panic to the chain", func() {
+			serve(MiddlewareRecover, WithTimeout(time.Second, func(rw http.ResponseWriter, r *http.Request) *Response {
+				panic("boom")
+			}))
+
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Context("when the handler takes too long", func() {
+		It("should stop the chain with a 504 and keep the handler from writing", func() {
+			cancelled := make(chan bool, 1)
+			wrote := make(chan error, 1)
+
+			slow := NamedHandler("slow", func(rw http.ResponseWriter, r *http.Request) *Response {
+				select {
+				case <-r.Context().Done():
+					cancelled <- true
+				case <-time.After(time.Second):
+					cancelled <- false
+				}
+
+				_, err := rw.Write([]byte("too late"))
+				wrote <- err
+				return nil
+			})
+
+			var carriedOn bool
+			start := time.Now()
+			serve(WithTimeout(20*time.Millisecond, slow), func(rw http.ResponseWriter, r *http.Request) *Response {
+				carriedOn = true
+				return nil
+			})
+
+			Expect(time.Since(start)).To(BeNumerically("<", 500*time.Millisecond))
+			Expect(response.Code).To(Equal(http.StatusGatewayTimeout))
+			Expect(carriedOn).To(BeFalse())
+			Eventually(reporter.recordedIncs).Should(ContainElement("handlers.slow.504"))
+
+			Eventually(cancelled).Should(Receive(BeTrue()))
+			Eventually(wrote).Should(Receive(Equal(http.ErrHandlerTimeout)))
+			Expect(response.Body.String()).To(BeEmpty())
+		})
+
+		// Run with -race: the abandoned handler touches the state of a chain which has moved on
+		It("should keep the abandoned handler from touching the state of the chain", func() {
+			finished := make(chan struct{})
+
+			slow := NamedHandler("slow", func(rw http.ResponseWriter, r *http.Request) *Response {
+				defer close(finished)
+
+				// Nothing tells the handler when the chain moves on
+				time.Sleep(100 * time.Millisecond)
+
+				if c := chainFromRequest(r); c != nil {
+					c.handler = "late"
+					c.statName = "late"
+					c.timings = append(c.timings, handlerTiming{name: "late"})
+				}
+				RequestCache(r.Context()).Set("late", true)
+				RecordCacheResult(r, true)
+
+				return nil
+			})
+
+			var names []string
+			after := func(rw http.ResponseWriter, r *http.Request) *Response {
+				names = append(names, CtxHandlerName(r))
+				return nil
+			}
+
+			mwHandler.HandleWithAfter([]Handler{WithTimeout(10*time.Millisecond, slow)}, []Handler{NamedHandler("after", after)}).
+				ServeHTTP(response, httptest.NewRequest("GET", "/report", nil))
+
+			Expect(response.Code).To(Equal(http.StatusGatewayTimeout))
+			Expect(names).To(Equal([]string{"after"}))
+			Eventually(finished).Should(BeClosed())
+		})
+	})
+
+	Context("when the handler returns a context", func() {
+		It("should carry on with the state of the chain", func() {
+			var name string
+			var cached interface{}
+
+			serve(WithTimeout(time.Second, func(rw http.ResponseWriter, r *http.Request) *Response {
+				RequestCache(r.Context()).Set("user", "rye")
+				return &Response{Context: context.WithValue(r.Context(), "key", "value")}
+			}), NamedHandler("next", func(rw http.ResponseWriter, r *http.Request) *Response {
+				name = CtxHandlerName(r)
+				cached, _ = RequestCache(r.Context()).Get("user")
+				Expect(chainFromRequest(r).forkedFrom).To(BeNil())
+				return nil
+			}))
+
+			Expect(name).To(Equal("next"))
+			Expect(cached).To(Equal("rye"))
+		})
+	})
+})
@@ -0,0 +1,234 @@
+package rye
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	stdlog "log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// Log levels
+	LOG_LEVEL_ERROR = "error"
+	LOG_LEVEL_WARN  = "warn"
+	LOG_LEVEL_INFO  = "info"
+)
+
+// LogEntry is a single structured log line. Fields that do not apply
+// to the entry are left to their zero value.
+type LogEntry struct {
+	Level     string
+	Message   string
+	Method    string
+	Path      string
+	Route     string
+	Status    int
+	Duration  time.Duration
+	Handler   string
+	RequestID string
+	Err       error
+}
+
+// Logger is the interface used by rye to emit structured logs.
+type Logger interface {
+	Log(entry LogEntry)
+}
+
+// LogrusLogger is a Logger writing entries through the standard logrus logger.
+type LogrusLogger struct{}
+
+// Log writes the entry at its level, with its non-empty fields as logrus fields
+func (l LogrusLogger) Log(entry LogEntry) {
+	fields := log.Fields{}
+
+	if entry.Method != "" {
+		fields["method"] = entry.Method
+	}
+	if entry.Path != "" {
+		fields["path"] = entry.Path
+	}
+	if entry.Route != "" {
+		fields["route"] = entry.Route
+	}
+	if entry.Status != 0 {
+		fields["status"] = entry.Status
+	}
+	if entry.Duration != 0 {
+		fields["duration"] = entry.Duration
+	}
+	if entry.Handler != "" {
+		fields["handler"] = entry.Handler
+	}
+	if entry.RequestID != "" {
+		fields["request_id"] = entry.RequestID
+	}
+	if entry.Err != nil {
+		fields["error"] = entry.Err.Error()
+	}
+
+	logEntry := log.WithFields(fields)
+
+	switch entry.Level {
+	case LOG_LEVEL_ERROR:
+		logEntry.Error(entry.Message)
+	case LOG_LEVEL_WARN:
+		logEntry.Warn(entry.Message)
+	default:
+		logEntry.Info(entry.Message)
+	}
+}
+
+// StdLogger is a Logger writing entries as `key=value` pairs through the standard log
+// package; Logger, when set, is used instead of the standard logger.
+type StdLogger struct {
+	Logger *stdlog.Logger
+}
+
+// Log writes the entry on a single line, leaving out empty fields
+func (l StdLogger) Log(entry LogEntry) {
+	level := entry.Level
+	if level == "" {
+		level = LOG_LEVEL_INFO
+	}
+
+	line := fmt.Sprintf("level=%s msg=%q", level, entry.Message)
+
+	if entry.Method != "" {
+		line += " method=" + entry.Method
+	}
+	if entry.Path != "" {
+		line += fmt.Sprintf(" path=%q", entry.Path)
+	}
+	if entry.Route != "" {
+		line += fmt.Sprintf(" route=%q", entry.Route)
+	}
+	if entry.Status != 0 {
+		line += fmt.Sprintf(" status=%d", entry.Status)
+	}
+	if entry.Duration != 0 {
+		line += " duration=" + entry.Duration.String()
+	}
+	if entry.Handler != "" {
+		line += " handler=" + entry.Handler
+	}
+	if entry.RequestID != "" {
+		line += fmt.Sprintf(" request_id=%q", entry.RequestID)
+	}
+	if entry.Err != nil {
+		line += fmt.Sprintf(" error=%q", entry.Err.Error())
+	}
+
+	if l.Logger != nil {
+		l.Logger.Print(line)
+	} else {
+		stdlog.Print(line)
+	}
+}
+
+// JSONLogger is a Logger writing each entry as a single line JSON object, which log
+// aggregators can ingest as is; it is the recommended Logger for production.
+type JSONLogger struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+// jsonLogLine is the JSON representation of a LogEntry
+type jsonLogLine struct {
+	Timestamp  string  `json:"timestamp"`
+	Level      string  `json:"level"`
+	Message    string  `json:"message,omitempty"`
+	Method     string  `json:"method,omitempty"`
+	Path       string  `json:"path,omitempty"`
+	Route      string  `json:"route,omitempty"`
+	Status     int     `json:"status,omitempty"`
+	DurationMS float64 `json:"duration_ms,omitempty"`
+	Handler    string  `json:"handler,omitempty"`
+	RequestID  string  `json:"request_id,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// NewJSONLogger returns a JSONLogger writing to the given writer (os.Stderr if nil)
+func NewJSONLogger(w io.Writer) *JSONLogger {
+	if w == nil {
+		w = os.Stderr
+	}
+
+	return &JSONLogger{
+		writer: w,
+	}
+}
+
+// Log writes the entry as a JSON object on its own line, leaving out empty fields
+func (l *JSONLogger) Log(entry LogEntry) {
+	line := jsonLogLine{
+		Timestamp:  time.Now().UTC().Format(time.RFC3339Nano),
+		Level:      entry.Level,
+		Message:    entry.Message,
+		Method:     entry.Method,
+		Path:       entry.Path,
+		Route:      entry.Route,
+		Status:     entry.Status,
+		DurationMS: float64(entry.Duration) / float64(time.Millisecond),
+		Handler:    entry.Handler,
+		RequestID:  entry.RequestID,
+	}
+
+	if line.Level == "" {
+		line.Level = LOG_LEVEL_INFO
+	}
+
+	if entry.Err != nil {
+		line.Error = entry.Err.Error()
+	}
+
+	data, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.writer.Write(append(data, '\n'))
+}
+
+// logHandler logs a handler invocation through Config.Logger, at error level for server
+// errors and warn level for client errors
+func (m *MWHandler) logHandler(r *http.Request, name string, resp *Response, elapsed time.Duration) {
+	entry := LogEntry{
+		Level:     LOG_LEVEL_INFO,
+		Message:   "Handler completed",
+		Method:    r.Method,
+		Duration:  elapsed,
+		Handler:   name,
+		RequestID: requestIDOf(r, DEFAULT_REQUEST_ID_HEADER),
+	}
+
+	if r.URL != nil {
+		entry.Path = r.URL.Path
+	}
+
+	if template, ok := PathTemplateFromContext(r.Context()); ok {
+		entry.Route = template
+	}
+
+	if resp != nil {
+		entry.Status = resp.StatusCode
+		entry.Err = resp.Err
+	}
+
+	switch m.classify(resp) {
+	case OUTCOME_SERVER_ERROR:
+		entry.Level = LOG_LEVEL_ERROR
+	case OUTCOME_CLIENT_ERROR:
+		entry.Level = LOG_LEVEL_WARN
+	}
+
+	m.Config.Logger.Log(entry)
+}
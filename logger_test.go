@@ -0,0 +1,192 @@
+package rye
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	stdlog "log"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LogrusLogger", func() {
+
+	var (
+		output *bytes.Buffer
+		level  log.Level
+	)
+
+	BeforeEach(func() {
+		output = &bytes.Buffer{}
+		log.SetOutput(output)
+
+		level = log.GetLevel()
+		log.SetLevel(log.InfoLevel)
+	})
+
+	AfterEach(func() {
+		log.SetOutput(GinkgoWriter)
+		log.SetLevel(level)
+	})
+
+	Describe("Log", func() {
+		It("should write the entry at its level with its fields", func() {
+			LogrusLogger{}.Log(LogEntry{
+				Level:     LOG_LEVEL_ERROR,
+				Message:   "Request failed",
+				Method:    "GET",
+				Path:      "/foo/1",
+				Route:     "/foo/:id",
+				Status:    500,
+				Duration:  time.Second,
+				Handler:   "failureHandler",
+				RequestID: "abc",
+				Err:       errors.New("boom"),
+			})
+
+			Expect(output.String()).To(ContainSubstring("level=error"))
+			Expect(output.String()).To(ContainSubstring(`msg="Request failed"`))
+			Expect(output.String()).To(ContainSubstring("method=GET"))
+			Expect(output.String()).To(ContainSubstring("path=/foo/1"))
+			Expect(output.String()).To(ContainSubstring("route=\"/foo/:id\""))
+			Expect(output.String()).To(ContainSubstring("status=500"))
+			Expect(output.String()).To(ContainSubstring("duration=1s"))
+			Expect(output.String()).To(ContainSubstring("handler=failureHandler"))
+			Expect(output.String()).To(ContainSubstring("request_id=abc"))
+			Expect(output.String()).To(ContainSubstring("error=boom"))
+		})
+
+		It("should leave out empty fields", func() {
+			LogrusLogger{}.Log(LogEntry{Level: LOG_LEVEL_WARN, Message: "careful"})
+
+			Expect(output.String()).To(ContainSubstring("level=warning"))
+			Expect(output.String()).ToNot(ContainSubstring("status="))
+			Expect(output.String()).ToNot(ContainSubstring("error="))
+		})
+	})
+})
+
+var _ = Describe("JSONLogger", func() {
+
+	var (
+		output *bytes.Buffer
+		logger *JSONLogger
+	)
+
+	BeforeEach(func() {
+		output = &bytes.Buffer{}
+		logger = NewJSONLogger(output)
+	})
+
+	decode := func() []map[string]interface{} {
+		var lines []map[string]interface{}
+
+		for _, line := range strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n") {
+			var fields map[string]interface{}
+			Expect(json.Unmarshal([]byte(line), &fields)).To(Succeed())
+			lines = append(lines, fields)
+		}
+
+		return lines
+	}
+
+	Describe("Log", func() {
+		It("should write the entry as a JSON object with its fields", func() {
+			logger.Log(LogEntry{
+				Level:     LOG_LEVEL_ERROR,
+				Message:   "Request failed",
+				Method:    "GET",
+				Path:      "/foo/1",
+				Route:     "/foo/:id",
+				Status:    500,
+				Duration:  1500 * time.Microsecond,
+				Handler:   "failureHandler",
+				RequestID: "abc",
+				Err:       errors.New("boom"),
+			})
+
+			lines := decode()
+			Expect(lines).To(HaveLen(1))
+			Expect(lines[0]).To(HaveKeyWithValue("level", "error"))
+			Expect(lines[0]).To(HaveKeyWithValue("message", "Request failed"))
+			Expect(lines[0]).To(HaveKeyWithValue("method", "GET"))
+			Expect(lines[0]).To(HaveKeyWithValue("path", "/foo/1"))
+			Expect(lines[0]).To(HaveKeyWithValue("route", "/foo/:id"))
+			Expect(lines[0]).To(HaveKeyWithValue("status", float64(500)))
+			Expect(lines[0]).To(HaveKeyWithValue("duration_ms", 1.5))
+			Expect(lines[0]).To(HaveKeyWithValue("handler", "failureHandler"))
+			Expect(lines[0]).To(HaveKeyWithValue("request_id", "abc"))
+			Expect(lines[0]).To(HaveKeyWithValue("error", "boom"))
+
+			_, err := time.Parse(time.RFC3339Nano, lines[0]["timestamp"].(string))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should write one line per entry and leave out empty fields", func() {
+			logger.Log(LogEntry{Level: LOG_LEVEL_WARN, Message: "careful"})
+			logger.Log(LogEntry{Message: "hello"})
+
+			lines := decode()
+			Expect(lines).To(HaveLen(2))
+			Expect(lines[0]).To(HaveKeyWithValue("level", "warn"))
+			Expect(lines[0]).ToNot(HaveKey("status"))
+			Expect(lines[0]).ToNot(HaveKey("error"))
+			Expect(lines[1]).To(HaveKeyWithValue("level", "info"))
+		})
+
+		It("should escape fields", func() {
+			logger.Log(LogEntry{
+				Message: "line\nbreak \"quoted\"",
+				Path:    `/foo?bar=<script>`,
+			})
+
+			Expect(output.String()).To(HaveSuffix("}\n"))
+			Expect(strings.Count(output.String(), "\n")).To(Equal(1))
+
+			lines := decode()
+			Expect(lines[0]).To(HaveKeyWithValue("message", "line\nbreak \"quoted\""))
+			Expect(lines[0]).To(HaveKeyWithValue("path", "/foo?bar=<script>"))
+		})
+	})
+})
+
+var _ = Describe("StdLogger", func() {
+
+	var (
+		output *bytes.Buffer
+		logger StdLogger
+	)
+
+	BeforeEach(func() {
+		output = &bytes.Buffer{}
+		logger = StdLogger{Logger: stdlog.New(output, "", 0)}
+	})
+
+	Describe("Log", func() {
+		It("should write the entry on a single line with its fields", func() {
+			logger.Log(LogEntry{
+				Level:     LOG_LEVEL_ERROR,
+				Message:   "Handler completed",
+				Method:    "GET",
+				Path:      "/foo/1",
+				Route:     "/foo/:id",
+				Status:    500,
+				Duration:  1500 * time.Microsecond,
+				Handler:   "failureHandler",
+				RequestID: "abc",
+				Err:       errors.New("boom"),
+			})
+
+			Expect(output.String()).To(Equal(`level=error msg="Handler completed" method=GET path="/foo/1" route="/foo/:id" status=500 duration=1.5ms handler=failureHandler request_id="abc" error="boom"` + "\n"))
+		})
+
+		It("should leave out empty fields", func() {
+			logger.Log(LogEntry{Message: "hello"})
+
+			Expect(output.String()).To(Equal(`level=info msg="hello"` + "\n"))
+		})
+	})
+})
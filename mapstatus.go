@@ -0,0 +1,40 @@
+package rye
+
+import (
+	"net/http"
+)
+
+/*
+MapStatus wraps a handler so that the status codes of the Responses it returns are translated according to the
+mapping (ie. for legacy clients that can't handle some of them), before the chain records its stats and writes the
+response out. Status codes missing from the mapping are left untouched, and so are responses the handler writes
+itself (without a `StatusCode` in its Response). Stats are recorded under the name of the wrapped handler.
+
+Example usage:
+
+	routes.Handle("/legacy/items", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.MapStatus(map[int]int{http.StatusUnprocessableEntity: http.StatusBadRequest}, createItemHandler),
+		})).Methods("POST")
+*/
+func MapStatus(mapping map[int]int, h Handler) Handler {
+	statuses := make(map[int]int, len(mapping))
+	for from, to := range mapping {
+		statuses[from] = to
+	}
+
+	wrapped := Handler(func(rw http.ResponseWriter, r *http.Request) *Response {
+		resp := h(rw, r)
+
+		if resp != nil {
+			if status, ok := statuses[resp.StatusCode]; ok {
+				resp.StatusCode = status
+			}
+		}
+
+		return resp
+	})
+
+	// Keep recording stats under the name of the wrapped handler
+	return describeAs(wrapped, h)
+}
@@ -0,0 +1,70 @@
+package rye
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MapStatus", func() {
+
+	var (
+		request  *http.Request
+		response *httptest.ResponseRecorder
+		reporter *recordingReporter
+		legacy   map[int]int
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("POST", "/items", nil)
+		response = httptest.NewRecorder()
+		reporter = &recordingReporter{}
+		legacy = map[int]int{http.StatusUnprocessableEntity: http.StatusBadRequest}
+	})
+
+	serve := func(h Handler) {
+		NewMWHandler(Config{Reporter: reporter, SyncStats: true}).Handle([]Handler{h}).ServeHTTP(response, request)
+	}
+
+	It("should remap the status code of the Response, in stats and on the wire", func() {
+		serve(MapStatus(legacy, NamedHandler("validate", func(rw http.ResponseWriter, r *http.Request) *Response {
+			return &Response{Err: errors.New("Invalid item"), StatusCode: http.StatusUnprocessableEntity}
+		})))
+
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(reporter.recordedIncs()).To(ContainElements("handlers.validate.400", "handlers.validate.4xx"))
+		Expect(reporter.recordedIncs()).ToNot(ContainElement("handlers.validate.422"))
+	})
+
+	It("should leave other status codes untouched", func() {
+		serve(MapStatus(legacy, NamedHandler("validate", func(rw http.ResponseWriter, r *http.Request) *Response {
+			return &Response{Err: errors.New("Conflict"), StatusCode: http.StatusConflict}
+		})))
+
+		Expect(response.Code).To(Equal(http.StatusConflict))
+		Expect(reporter.recordedIncs()).To(ContainElement("handlers.validate.409"))
+	})
+
+	It("should not apply to responses written by the handler itself", func() {
+		serve(MapStatus(legacy, NamedHandler("validate", func(rw http.ResponseWriter, r *http.Request) *Response {
+			rw.WriteHeader(http.StatusUnprocessableEntity)
+			return nil
+		})))
+
+		Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(reporter.recordedIncs()).To(ContainElement("handlers.validate.422"))
+	})
+
+	It("should not be affected by later changes to the mapping", func() {
+		h := MapStatus(legacy, NamedHandler("validate", func(rw http.ResponseWriter, r *http.Request) *Response {
+			return &Response{Err: errors.New("Invalid item"), StatusCode: http.StatusUnprocessableEntity}
+		}))
+		legacy[http.StatusUnprocessableEntity] = http.StatusTeapot
+
+		serve(h)
+
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+	})
+})
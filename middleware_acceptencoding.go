@@ -0,0 +1,186 @@
+package rye
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Context key holding the parsed Accept-Encoding header (an AcceptEncoding)
+	CONTEXT_ACCEPT_ENCODING = "rye-middlewareacceptencoding-acceptencoding"
+)
+
+// AcceptedEncoding is an encoding listed in an Accept-Encoding header, along with its quality
+type AcceptedEncoding struct {
+	Name string
+	Q    float64
+}
+
+// AcceptEncoding is a parsed Accept-Encoding header: its encodings, lower cased and ordered by
+// decreasing quality (encodings of the same quality keep their order).
+type AcceptEncoding []AcceptedEncoding
+
+type normalizeAcceptEncoding struct{}
+
+/*
+NewMiddlewareNormalizeAcceptEncoding creates a new handler that parses the `Accept-Encoding` header once, so that
+the rest of the chain has a consistent view of what the client accepts: encoding names are lower cased (`x-gzip`
+becomes `gzip`), quality values are checked (invalid ones count as 0) and encodings are ordered by decreasing
+quality. The header is rewritten in that canonical form, and the parsed `rye.AcceptEncoding` is available
+through `rye.AcceptEncodingFromContext` (the compression middleware uses it when present).
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareNormalizeAcceptEncoding(),
+			rye.NewMiddlewareCompress(rye.CompressConfig{}),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareNormalizeAcceptEncoding() func(rw http.ResponseWriter, req *http.Request) *Response {
+	n := &normalizeAcceptEncoding{}
+	return n.handle
+}
+
+func (n *normalizeAcceptEncoding) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	accept := parseAcceptEncoding(strings.Join(r.Header.Values("Accept-Encoding"), ","))
+
+	if len(accept) > 0 {
+		r.Header.Set("Accept-Encoding", accept.String())
+	} else {
+		r.Header.Del("Accept-Encoding")
+	}
+
+	return &Response{
+		Context: context.WithValue(r.Context(), CONTEXT_ACCEPT_ENCODING, accept),
+	}
+}
+
+// AcceptEncodingFromContext returns the Accept-Encoding header parsed by NewMiddlewareNormalizeAcceptEncoding (if any)
+func AcceptEncodingFromContext(ctx context.Context) (AcceptEncoding, bool) {
+	accept, ok := ctx.Value(CONTEXT_ACCEPT_ENCODING).(AcceptEncoding)
+	return accept, ok
+}
+
+// acceptEncodingOf returns the Accept-Encoding header of the request, parsed by
+// NewMiddlewareNormalizeAcceptEncoding if it ran or parsed on the spot otherwise
+func acceptEncodingOf(r *http.Request) AcceptEncoding {
+	if accept, ok := AcceptEncodingFromContext(r.Context()); ok {
+		return accept
+	}
+
+	return parseAcceptEncoding(strings.Join(r.Header.Values("Accept-Encoding"), ","))
+}
+
+// parseAcceptEncoding parses an Accept-Encoding header; the first occurrence of an encoding wins
+func parseAcceptEncoding(header string) AcceptEncoding {
+	var accept AcceptEncoding
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+
+		// Aliases the spec asks to treat as the encoding they stand for
+		name = strings.TrimPrefix(name, "x-")
+
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if len(param) < 2 || !strings.EqualFold(param[:2], "q=") {
+				continue
+			}
+
+			var err error
+			if q, err = strconv.ParseFloat(param[2:], 64); err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+		}
+
+		accept = append(accept, AcceptedEncoding{Name: name, Q: q})
+	}
+
+	sort.SliceStable(accept, func(i, j int) bool {
+		return accept[i].Q > accept[j].Q
+	})
+
+	return accept
+}
+
+// Quality returns the quality the client gives to the encoding: the one it was listed with, or else
+// that of `*`; `identity` is acceptable unless ruled out, anything else is not (a quality of 0)
+func (a AcceptEncoding) Quality(encoding string) float64 {
+	encoding = strings.ToLower(encoding)
+
+	wildcard := -1.0
+	for _, accepted := range a {
+		if accepted.Name == encoding {
+			return accepted.Q
+		}
+
+		if accepted.Name == "*" {
+			wildcard = accepted.Q
+		}
+	}
+
+	if wildcard >= 0 {
+		return wildcard
+	}
+
+	if encoding == "identity" {
+		return 1
+	}
+
+	return 0
+}
+
+// Accepts reports whether the client accepts the encoding
+func (a AcceptEncoding) Accepts(encoding string) bool {
+	return a.Quality(encoding) > 0
+}
+
+// Preferred returns the supported encoding the client prefers (the first one listed among those
+// of the same quality), or "" if it accepts none of them
+func (a AcceptEncoding) Preferred(supported ...string) string {
+	var (
+		preferred string
+		best      float64
+	)
+
+	for _, encoding := range supported {
+		if q := a.Quality(encoding); q > best {
+			preferred, best = encoding, q
+		}
+	}
+
+	return preferred
+}
+
+// String renders the header in its canonical form (ie. `gzip, br;q=0.8, *;q=0`)
+func (a AcceptEncoding) String() string {
+	parts := make([]string, 0, len(a))
+
+	for _, accepted := range a {
+		if accepted.Q == 1 {
+			parts = append(parts, accepted.Name)
+			continue
+		}
+
+		parts = append(parts, accepted.Name+";q="+strconv.FormatFloat(accepted.Q, 'f', -1, 64))
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,138 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Normalize Accept-Encoding Middleware", func() {
+
+	var (
+		request   *http.Request
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		accept    AcceptEncoding
+		stored    bool
+		header    string
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("GET", "/", nil)
+		response = httptest.NewRecorder()
+		mwHandler = NewMWHandler(Config{})
+		accept, stored, header = nil, false, ""
+	})
+
+	serve := func() {
+		h := mwHandler.Handle([]Handler{NewMiddlewareNormalizeAcceptEncoding(), func(rw http.ResponseWriter, r *http.Request) *Response {
+			accept, stored = AcceptEncodingFromContext(r.Context())
+			header = r.Header.Get("Accept-Encoding")
+			return nil
+		}})
+		h.ServeHTTP(response, request)
+	}
+
+	Describe("handle", func() {
+		It("should store the parsed header and rewrite it in canonical form", func() {
+			request.Header.Set("Accept-Encoding", "deflate;q=0.5, GZIP, br;q=0.8")
+
+			serve()
+
+			Expect(stored).To(BeTrue())
+			Expect(accept).To(Equal(AcceptEncoding{{"gzip", 1}, {"br", 0.8}, {"deflate", 0.5}}))
+			Expect(header).To(Equal("gzip, br;q=0.8, deflate;q=0.5"))
+		})
+
+		It("should merge headers sent several times", func() {
+			request.Header.Add("Accept-Encoding", "gzip")
+			request.Header.Add("Accept-Encoding", "br")
+
+			serve()
+
+			Expect(header).To(Equal("gzip, br"))
+		})
+
+		It("should store an empty list when the header is missing", func() {
+			serve()
+
+			Expect(stored).To(BeTrue())
+			Expect(accept).To(BeEmpty())
+			Expect(header).To(BeEmpty())
+			Expect(accept.Accepts("identity")).To(BeTrue())
+			Expect(accept.Accepts("gzip")).To(BeFalse())
+		})
+
+		It("should be used by the compression middleware", func() {
+			request.Header.Set("Accept-Encoding", "gzip;q=0.1, deflate")
+
+			h := mwHandler.Handle([]Handler{
+				NewMiddlewareNormalizeAcceptEncoding(),
+				NewMiddlewareCompress(CompressConfig{MinSize: 1}),
+				jsonHandler,
+			})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Header().Get("Content-Encoding")).To(Equal("deflate"))
+		})
+	})
+
+	Describe("parseAcceptEncoding", func() {
+		It("should lower case names and treat x- aliases as the encoding", func() {
+			Expect(parseAcceptEncoding("X-GZIP, Identity").String()).To(Equal("gzip, identity"))
+		})
+
+		It("should keep the first occurrence of an encoding", func() {
+			Expect(parseAcceptEncoding("gzip;q=0.2, gzip").String()).To(Equal("gzip;q=0.2"))
+		})
+
+		It("should give invalid qualities a quality of 0", func() {
+			Expect(parseAcceptEncoding("gzip;q=abc, br;q=2, deflate;Q=0.3").String()).To(Equal("deflate;q=0.3, gzip;q=0, br;q=0"))
+		})
+
+		It("should skip empty entries", func() {
+			Expect(parseAcceptEncoding(" , gzip,, ")).To(Equal(AcceptEncoding{{"gzip", 1}}))
+		})
+	})
+
+	Describe("Quality", func() {
+		It("should fall back to the wildcard for encodings that are not listed", func() {
+			accept := parseAcceptEncoding("gzip, *;q=0.5")
+
+			Expect(accept.Quality("gzip")).To(Equal(1.0))
+			Expect(accept.Quality("br")).To(Equal(0.5))
+			Expect(accept.Quality("identity")).To(Equal(0.5))
+		})
+
+		It("should let identity be ruled out", func() {
+			Expect(parseAcceptEncoding("gzip, identity;q=0").Accepts("identity")).To(BeFalse())
+			Expect(parseAcceptEncoding("gzip, *;q=0").Accepts("identity")).To(BeFalse())
+			Expect(parseAcceptEncoding("gzip").Accepts("identity")).To(BeTrue())
+		})
+	})
+
+	Describe("Preferred", func() {
+		It("should prefer the first supported encoding of the same quality", func() {
+			Expect(parseAcceptEncoding("deflate, gzip").Preferred("gzip", "deflate")).To(Equal("gzip"))
+		})
+
+		It("should prefer the encoding of highest quality", func() {
+			Expect(parseAcceptEncoding("gzip;q=0.5, deflate").Preferred("gzip", "deflate")).To(Equal("deflate"))
+		})
+
+		It("should skip encodings with a zero quality", func() {
+			Expect(parseAcceptEncoding("gzip;q=0, deflate;q=0.5").Preferred("gzip", "deflate")).To(Equal("deflate"))
+		})
+
+		It("should accept any encoding for a wildcard", func() {
+			Expect(parseAcceptEncoding("*").Preferred("gzip", "deflate")).To(Equal("gzip"))
+			Expect(parseAcceptEncoding("gzip;q=0, *").Preferred("gzip", "deflate")).To(Equal("deflate"))
+		})
+
+		It("should return nothing when no encoding is acceptable", func() {
+			Expect(parseAcceptEncoding("").Preferred("gzip")).To(BeEmpty())
+			Expect(parseAcceptEncoding("identity").Preferred("gzip")).To(BeEmpty())
+		})
+	})
+})
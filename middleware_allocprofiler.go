@@ -0,0 +1,54 @@
+package rye
+
+import (
+	"net/http"
+	"runtime"
+)
+
+type allocProfiler struct{}
+
+/*
+NewMiddlewareAllocProfiler creates a new handler that, in debug builds (`-tags ryedebug`), records the bytes
+allocated while the rest of the chain runs as `handlers.<name>.alloc_bytes`, where `<name>` is the name of the
+first handler in the chain, to help find allocation heavy handlers. Outside of debug builds it does nothing.
+
+The allocations are read from runtime.MemStats, which covers the whole process: the stat is approximate, and
+only meaningful under low concurrency (ie. when profiling a handler locally or in a load test of a single
+endpoint), as the allocations of concurrent requests are counted too. Reading MemStats also briefly stops
+the world, so keep it out of production builds.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareAllocProfiler(),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareAllocProfiler() func(rw http.ResponseWriter, req *http.Request) *Response {
+	p := &allocProfiler{}
+	return p.handle
+}
+
+func (p *allocProfiler) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	chain := chainFromRequest(r)
+	if !debugMode || chain == nil {
+		return nil
+	}
+
+	before := totalAlloc()
+
+	return &Response{
+		Writer: newStatusRecordingWriter(rw, func(status int) {
+			chain.count(chain.statPrefix+chain.mw.Config.HandlerStatNamespace+"."+chain.name+".alloc_bytes", int64(totalAlloc()-before))
+		}),
+	}
+}
+
+// totalAlloc returns the cumulative bytes allocated for heap objects
+func totalAlloc() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	return stats.TotalAlloc
+}
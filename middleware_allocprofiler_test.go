@@ -0,0 +1,74 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/InVisionApp/rye/fakes/statsdfakes"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Alloc Profiler Middleware", func() {
+
+	var (
+		fakeStatter *statsdfakes.FakeStatter
+		debug       bool
+	)
+
+	allocating := NamedHandler("allocating", func(rw http.ResponseWriter, r *http.Request) *Response {
+		buf := make([][]byte, 64)
+		for i := range buf {
+			buf[i] = make([]byte, 1<<10)
+		}
+		rw.Write(buf[len(buf)-1][:1])
+		return nil
+	})
+
+	BeforeEach(func() {
+		debug = debugMode
+		fakeStatter = &statsdfakes.FakeStatter{}
+	})
+
+	AfterEach(func() {
+		debugMode = debug
+	})
+
+	// allocBytes returns the values sent for the alloc_bytes stat of the chain
+	allocBytes := func() []int64 {
+		var values []int64
+		for i := 0; i < fakeStatter.IncCallCount(); i++ {
+			if name, value, _ := fakeStatter.IncArgsForCall(i); name == "handlers.profiled.alloc_bytes" {
+				values = append(values, value)
+			}
+		}
+		return values
+	}
+
+	serve := func() {
+		h := NewMWHandler(Config{Statter: fakeStatter}).Handle([]Handler{
+			NamedHandler("profiled", NewMiddlewareAllocProfiler()),
+			allocating,
+		})
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+
+	Describe("handle", func() {
+		It("should record the bytes allocated by the chain in debug mode", func() {
+			debugMode = true
+
+			serve()
+
+			Eventually(allocBytes).Should(ConsistOf(BeNumerically(">=", 64<<10)))
+		})
+
+		It("should do nothing outside of debug mode", func() {
+			debugMode = false
+
+			serve()
+
+			Eventually(fakeStatter.IncCallCount).Should(BeNumerically(">", 0))
+			Consistently(allocBytes).Should(BeEmpty())
+		})
+	})
+})
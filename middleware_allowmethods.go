@@ -0,0 +1,93 @@
+package rye
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// AllowMethodsConfig configures the allowed methods middleware.
+type AllowMethodsConfig struct {
+	// Methods lists the allowed methods (ie. `GET`, `POST`)
+	Methods []string
+
+	// AnswerOptions allows OPTIONS requests as well, answering them with a 200 listing the
+	// allowed methods in the `Allow` header (the rest of the chain doesn't run)
+	AnswerOptions bool
+}
+
+type allowMethods struct {
+	methods       []string
+	allow         string
+	answerOptions bool
+}
+
+/*
+NewMiddlewareAllowMethods creates a new handler that stops requests whose method isn't one of the given ones with a
+405 listing the allowed methods in the `Allow` header, rather than checking the method in every handler. Rejected
+requests are counted as `method.not_allowed`. Use NewMiddlewareAllowMethodsWithConfig to answer OPTIONS requests.
+
+Example usage:
+
+	routes.Handle("/items", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareAllowMethods("GET", "POST"),
+			yourHandler,
+		}))
+*/
+func NewMiddlewareAllowMethods(methods ...string) func(rw http.ResponseWriter, req *http.Request) *Response {
+	return NewMiddlewareAllowMethodsWithConfig(AllowMethodsConfig{Methods: methods})
+}
+
+/*
+NewMiddlewareAllowMethodsWithConfig works like NewMiddlewareAllowMethods, with more options.
+
+Example usage:
+
+	routes.Handle("/items", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareAllowMethodsWithConfig(rye.AllowMethodsConfig{
+				Methods:       []string{"GET", "POST"},
+				AnswerOptions: true,
+			}),
+			yourHandler,
+		}))
+*/
+func NewMiddlewareAllowMethodsWithConfig(cfg AllowMethodsConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	a := &allowMethods{answerOptions: cfg.AnswerOptions}
+
+	for _, method := range cfg.Methods {
+		a.methods = append(a.methods, strings.ToUpper(method))
+	}
+
+	allowed := a.methods
+	if cfg.AnswerOptions && !stringListContains(allowed, http.MethodOptions) {
+		allowed = append(append([]string(nil), allowed...), http.MethodOptions)
+	}
+	a.allow = strings.Join(allowed, ", ")
+
+	return a.handle
+}
+
+func (a *allowMethods) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	if a.answerOptions && r.Method == http.MethodOptions {
+		return &Response{
+			Headers:       http.Header{"Allow": []string{a.allow}},
+			StatusCode:    http.StatusOK,
+			StopExecution: true,
+		}
+	}
+
+	if stringListContains(a.methods, r.Method) {
+		return nil
+	}
+
+	chainFromRequest(r).inc("method.not_allowed")
+
+	return &Response{
+		Err:           fmt.Errorf("Method %s is not allowed; allowed methods are %s", r.Method, a.allow),
+		StatusCode:    http.StatusMethodNotAllowed,
+		StopExecution: true,
+		Headers:       http.Header{"Allow": []string{a.allow}},
+	}
+}
@@ -0,0 +1,87 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Allow Methods Middleware", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+		reporter *recordingReporter
+		reached  bool
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		reporter = &recordingReporter{}
+		reached = false
+	})
+
+	serve := func(handler Handler, method string) {
+		NewMWHandler(Config{Reporter: reporter, SyncStats: true}).Handle([]Handler{
+			handler,
+			func(rw http.ResponseWriter, r *http.Request) *Response {
+				reached = true
+				return nil
+			},
+		}).ServeHTTP(response, httptest.NewRequest(method, "/items", nil))
+	}
+
+	Describe("handle", func() {
+		It("should let allowed methods through", func() {
+			serve(NewMiddlewareAllowMethods("GET", "post"), "POST")
+
+			Expect(reached).To(BeTrue())
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header()).ToNot(HaveKey("Allow"))
+		})
+
+		It("should reject other methods with a 405 listing the allowed ones", func() {
+			serve(NewMiddlewareAllowMethods("GET", "POST"), "DELETE")
+
+			Expect(reached).To(BeFalse())
+			Expect(response.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(response.Header().Values("Allow")).To(Equal([]string{"GET, POST"}))
+			Expect(reporter.recordedIncs()).To(ContainElement("method.not_allowed"))
+		})
+
+		It("should reject OPTIONS requests by default", func() {
+			serve(NewMiddlewareAllowMethods("GET"), "OPTIONS")
+
+			Expect(reached).To(BeFalse())
+			Expect(response.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(response.Header().Get("Allow")).To(Equal("GET"))
+		})
+
+		Context("when answering OPTIONS requests", func() {
+			var handler Handler
+
+			BeforeEach(func() {
+				handler = NewMiddlewareAllowMethodsWithConfig(AllowMethodsConfig{
+					Methods:       []string{"GET", "POST"},
+					AnswerOptions: true,
+				})
+			})
+
+			It("should answer them with a 200 listing the allowed methods", func() {
+				serve(handler, "OPTIONS")
+
+				Expect(reached).To(BeFalse())
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(response.Header().Get("Allow")).To(Equal("GET, POST, OPTIONS"))
+				Expect(reporter.recordedIncs()).ToNot(ContainElement("method.not_allowed"))
+			})
+
+			It("should list OPTIONS in the Allow header of rejections", func() {
+				serve(handler, "PUT")
+
+				Expect(response.Code).To(Equal(http.StatusMethodNotAllowed))
+				Expect(response.Header().Get("Allow")).To(Equal("GET, POST, OPTIONS"))
+			})
+		})
+	})
+})
@@ -0,0 +1,133 @@
+package rye
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// Context key holding the username of a request authenticated by NewMiddlewareBasicAuth
+	CONTEXT_BASIC_AUTH_USER = "rye-middlewarebasicauth-user"
+
+	DEFAULT_BASIC_AUTH_REALM = "Restricted"
+)
+
+// BasicAuthConfig configures the basic auth middleware.
+type BasicAuthConfig struct {
+	// Validator checks the credentials of each request; requests are all rejected
+	// when it is nil (see BasicAuthCredentials for a simple validator)
+	Validator func(user, pass string, r *http.Request) bool
+
+	// Realm is sent to clients in the `WWW-Authenticate` header
+	// (defaults to DEFAULT_BASIC_AUTH_REALM)
+	Realm string
+
+	// StoreUser puts the username of authenticated requests in the context
+	// (see BasicAuthUserFromContext)
+	StoreUser bool
+}
+
+type basicAuth struct {
+	config BasicAuthConfig
+}
+
+/*
+NewMiddlewareBasicAuth creates a new handler that authenticates requests through HTTP basic auth: the credentials
+of the `Authorization: Basic` header are checked with the `Validator`. Requests without valid credentials are
+stopped with a 401 and a `WWW-Authenticate` header prompting for them.
+
+When `StoreUser` is set, the username of authenticated requests is available to the rest of the chain through
+`rye.BasicAuthUserFromContext`.
+
+Example usage:
+
+	routes.Handle("/admin", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareBasicAuth(rye.BasicAuthConfig{
+				Validator: rye.BasicAuthCredentials(map[string]string{"admin": adminPassword}),
+				StoreUser: true,
+			}),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareBasicAuth(cfg BasicAuthConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if cfg.Realm == "" {
+		cfg.Realm = DEFAULT_BASIC_AUTH_REALM
+	}
+
+	b := &basicAuth{config: cfg}
+	return b.handle
+}
+
+func (b *basicAuth) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	if r.Header.Get("Authorization") == "" {
+		return b.unauthorized(errors.New("No basic auth credentials found; ensure you pass them in the 'Authorization' header"))
+	}
+
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return b.unauthorized(errors.New("Malformed basic auth credentials"))
+	}
+
+	if b.config.Validator == nil || !b.config.Validator(user, pass, r) {
+		return b.unauthorized(errors.New("Unauthorized request: invalid basic auth credentials"))
+	}
+
+	if !b.config.StoreUser {
+		return nil
+	}
+
+	return &Response{
+		Context: context.WithValue(r.Context(), CONTEXT_BASIC_AUTH_USER, user),
+	}
+}
+
+// unauthorized stops the chain with a 401 prompting the client for credentials
+func (b *basicAuth) unauthorized(err error) *Response {
+	return &Response{
+		Err:           err,
+		StatusCode:    http.StatusUnauthorized,
+		StopExecution: true,
+		Headers: http.Header{
+			"Www-Authenticate": []string{"Basic realm=" + strconv.Quote(b.config.Realm) + `, charset="UTF-8"`},
+		},
+	}
+}
+
+// BasicAuthUserFromContext returns the username stored by NewMiddlewareBasicAuth (if any)
+func BasicAuthUserFromContext(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(CONTEXT_BASIC_AUTH_USER).(string)
+	return user, ok
+}
+
+// BasicAuthCredentials returns a Validator (see BasicAuthConfig) accepting the given users, keyed by
+// username, with their password. Credentials are compared in constant time, so that response times
+// don't give away how much of a username or password was right.
+func BasicAuthCredentials(credentials map[string]string) func(user, pass string, r *http.Request) bool {
+	hashed := make(map[string][sha256.Size]byte, len(credentials))
+	for user, pass := range credentials {
+		hashed[user] = sha256.Sum256([]byte(pass))
+	}
+
+	return func(user, pass string, r *http.Request) bool {
+		// Compare every username (hashing levels out their lengths) so that
+		// the time taken doesn't depend on which user, if any, matches
+		userHash := sha256.Sum256([]byte(user))
+		passHash := sha256.Sum256([]byte(pass))
+
+		match := 0
+		for knownUser, knownPass := range hashed {
+			knownUserHash := sha256.Sum256([]byte(knownUser))
+
+			userMatch := subtle.ConstantTimeCompare(userHash[:], knownUserHash[:])
+			passMatch := subtle.ConstantTimeCompare(passHash[:], knownPass[:])
+			match |= userMatch & passMatch
+		}
+
+		return match == 1
+	}
+}
@@ -0,0 +1,134 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Basic Auth Middleware", func() {
+
+	var (
+		request   *http.Request
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		validator func(user, pass string, r *http.Request) bool
+		user      string
+		stored    bool
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("GET", "/admin", nil)
+		response = httptest.NewRecorder()
+		mwHandler = NewMWHandler(Config{})
+		validator = BasicAuthCredentials(map[string]string{"admin": "s3cret", "ops": "hunter2"})
+		user, stored = "", false
+	})
+
+	serve := func(cfg BasicAuthConfig) {
+		h := mwHandler.Handle([]Handler{NewMiddlewareBasicAuth(cfg), func(rw http.ResponseWriter, r *http.Request) *Response {
+			user, stored = BasicAuthUserFromContext(r.Context())
+			return nil
+		}})
+		h.ServeHTTP(response, request)
+	}
+
+	Describe("handle", func() {
+		Context("when the credentials are valid", func() {
+			It("should let the request through", func() {
+				request.SetBasicAuth("admin", "s3cret")
+
+				serve(BasicAuthConfig{Validator: validator})
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(stored).To(BeFalse())
+			})
+
+			It("should store the username when asked to", func() {
+				request.SetBasicAuth("ops", "hunter2")
+
+				serve(BasicAuthConfig{Validator: validator, StoreUser: true})
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(stored).To(BeTrue())
+				Expect(user).To(Equal("ops"))
+			})
+
+			It("should pass the request to the validator", func() {
+				request.SetBasicAuth("admin", "s3cret")
+
+				var validated *http.Request
+				serve(BasicAuthConfig{Validator: func(user, pass string, r *http.Request) bool {
+					validated = r
+					return true
+				}})
+
+				Expect(validated).ToNot(BeNil())
+				Expect(validated.URL.Path).To(Equal("/admin"))
+			})
+		})
+
+		Context("when the credentials are invalid", func() {
+			It("should stop with a 401 prompting for credentials", func() {
+				request.SetBasicAuth("admin", "hunter2")
+
+				serve(BasicAuthConfig{Validator: validator, Realm: "admin"})
+
+				Expect(response.Code).To(Equal(http.StatusUnauthorized))
+				Expect(response.Header().Get("WWW-Authenticate")).To(Equal(`Basic realm="admin", charset="UTF-8"`))
+				Expect(response.Body.String()).To(ContainSubstring("invalid basic auth credentials"))
+				Expect(stored).To(BeFalse())
+			})
+
+			It("should reject every request without a validator", func() {
+				request.SetBasicAuth("admin", "s3cret")
+
+				serve(BasicAuthConfig{})
+
+				Expect(response.Code).To(Equal(http.StatusUnauthorized))
+			})
+		})
+
+		Context("when the header is missing", func() {
+			It("should stop with a 401 prompting for credentials", func() {
+				serve(BasicAuthConfig{Validator: validator})
+
+				Expect(response.Code).To(Equal(http.StatusUnauthorized))
+				Expect(response.Header().Get("WWW-Authenticate")).To(Equal(`Basic realm="Restricted", charset="UTF-8"`))
+				Expect(response.Body.String()).To(ContainSubstring("No basic auth credentials found"))
+			})
+		})
+
+		Context("when the header is malformed", func() {
+			It("should stop with a 401", func() {
+				request.Header.Set("Authorization", "Basic not-base64!")
+
+				serve(BasicAuthConfig{Validator: validator})
+
+				Expect(response.Code).To(Equal(http.StatusUnauthorized))
+				Expect(response.Header().Get("WWW-Authenticate")).ToNot(BeEmpty())
+				Expect(response.Body.String()).To(ContainSubstring("Malformed basic auth credentials"))
+			})
+
+			It("should reject other schemes", func() {
+				request.Header.Set("Authorization", "Bearer token")
+
+				serve(BasicAuthConfig{Validator: validator})
+
+				Expect(response.Code).To(Equal(http.StatusUnauthorized))
+				Expect(response.Body.String()).To(ContainSubstring("Malformed basic auth credentials"))
+			})
+		})
+	})
+
+	Describe("BasicAuthCredentials", func() {
+		It("should only accept known users with their password", func() {
+			Expect(validator("admin", "s3cret", request)).To(BeTrue())
+			Expect(validator("ops", "hunter2", request)).To(BeTrue())
+			Expect(validator("admin", "hunter2", request)).To(BeFalse())
+			Expect(validator("nobody", "s3cret", request)).To(BeFalse())
+			Expect(validator("", "", request)).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,73 @@
+package rye
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+type bodyReadTimeout struct {
+	timeout time.Duration
+}
+
+/*
+NewMiddlewareBodyReadTimeout creates a new handler that watches how the request body is delivered and emits
+a `slowloris.suspected` stat (once per request, through the MWHandler's statter) when a single read of the
+body blocks for longer than `timeout`, which surfaces slow-loris style DoS attempts for monitoring.
+
+This middleware only detects slow delivery; enforce limits with `http.Server` timeouts. Request headers are
+read before any handler runs, so slow header delivery can only be cut off with `ReadHeaderTimeout`:
+
+	server := &http.Server{
+		Handler:           routes,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+	}
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareBodyReadTimeout(2 * time.Second),
+			yourHandler,
+		})).Methods("POST")
+*/
+func NewMiddlewareBodyReadTimeout(timeout time.Duration) func(rw http.ResponseWriter, req *http.Request) *Response {
+	b := &bodyReadTimeout{timeout: timeout}
+	return b.handle
+}
+
+func (b *bodyReadTimeout) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	r.Body = &slowBodyReader{
+		ReadCloser: r.Body,
+		timeout:    b.timeout,
+		chain:      chainFromRequest(r),
+	}
+
+	return nil
+}
+
+// slowBodyReader reports reads that block for longer than the timeout
+type slowBodyReader struct {
+	io.ReadCloser
+
+	timeout  time.Duration
+	chain    *chainState
+	reported bool
+}
+
+func (s *slowBodyReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := s.ReadCloser.Read(p)
+
+	if !s.reported && time.Since(start) > s.timeout {
+		s.reported = true
+		s.chain.inc("slowloris.suspected")
+	}
+
+	return n, err
+}
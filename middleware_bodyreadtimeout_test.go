@@ -0,0 +1,91 @@
+package rye
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/InVisionApp/rye/fakes/statsdfakes"
+	. "github.com/onsi/gomega"
+)
+
+// trickleReader delivers its content a byte at a time, pausing before every read
+type trickleReader struct {
+	content string
+	delay   time.Duration
+}
+
+func (t *trickleReader) Read(p []byte) (int, error) {
+	if t.content == "" {
+		return 0, io.EOF
+	}
+
+	time.Sleep(t.delay)
+
+	p[0] = t.content[0]
+	t.content = t.content[1:]
+
+	return 1, nil
+}
+
+var _ = Describe("Body Read Timeout Middleware", func() {
+
+	var (
+		mwHandler *MWHandler
+		incs      chan string
+		body      string
+	)
+
+	// readHandler reads the whole body
+	readHandler := func(rw http.ResponseWriter, r *http.Request) *Response {
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+		return nil
+	}
+
+	serve := func(reader io.Reader) {
+		request := httptest.NewRequest("POST", "/", reader)
+
+		h := mwHandler.Handle([]Handler{NewMiddlewareBodyReadTimeout(10 * time.Millisecond), readHandler})
+		h.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	BeforeEach(func() {
+		incs = make(chan string, 10)
+		body = ""
+
+		fakeStatter := &statsdfakes.FakeStatter{}
+		fakeStatter.IncStub = func(name string, value int64, rate float32) error {
+			if name == "slowloris.suspected" {
+				incs <- name
+			}
+			return nil
+		}
+
+		mwHandler = NewMWHandler(Config{Statter: fakeStatter, StatRate: 1})
+	})
+
+	Describe("handle", func() {
+		Context("when the body is delivered slowly", func() {
+			It("should emit the stat once and still deliver the body", func() {
+				serve(&trickleReader{content: "abc", delay: 20 * time.Millisecond})
+
+				Expect(body).To(Equal("abc"))
+				Eventually(incs).Should(Receive())
+				Consistently(incs).ShouldNot(Receive())
+			})
+		})
+
+		Context("when the body is delivered promptly", func() {
+			It("should not emit the stat", func() {
+				serve(strings.NewReader("abc"))
+
+				Expect(body).To(Equal("abc"))
+				Consistently(incs).ShouldNot(Receive())
+			})
+		})
+	})
+})
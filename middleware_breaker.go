@@ -0,0 +1,198 @@
+package rye
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_BREAKER_NAME              = "default"
+	DEFAULT_BREAKER_FAILURE_THRESHOLD = 5
+	DEFAULT_BREAKER_OPEN_DURATION     = 30 * time.Second
+)
+
+// BreakerState is the state of a circuit breaker shared through a BreakerStore.
+type BreakerState struct {
+	// Failures is the number of consecutive failed requests
+	Failures int `json:"failures"`
+
+	// OpenedAt is when the breaker tripped (zero while the breaker is closed)
+	OpenedAt time.Time `json:"opened_at"`
+}
+
+// BreakerStore holds circuit breaker state so that it can be shared by multiple
+// instances of a service (ie. backed by Redis). Implementations must be safe for
+// concurrent use.
+type BreakerStore interface {
+	Get(ctx context.Context, key string) (BreakerState, error)
+	Set(ctx context.Context, key string, state BreakerState) error
+}
+
+// BreakerConfig configures the circuit breaker middleware.
+type BreakerConfig struct {
+	// Name of the breaker; instances using the same store and name share state
+	// (defaults to DEFAULT_BREAKER_NAME)
+	Name string
+
+	// FailureThreshold is the number of consecutive 5xx responses that trip the breaker
+	// (defaults to DEFAULT_BREAKER_FAILURE_THRESHOLD)
+	FailureThreshold int
+
+	// OpenDuration is how long the breaker stays open before letting requests
+	// through again (defaults to DEFAULT_BREAKER_OPEN_DURATION)
+	OpenDuration time.Duration
+}
+
+type distributedBreaker struct {
+	config BreakerConfig
+	store  BreakerStore
+	local  BreakerStore
+}
+
+/*
+NewMiddlewareDistributedBreaker creates a new circuit breaker handler whose state is kept in a shared
+BreakerStore, so every replica of a service trips (and recovers) together when a shared downstream is
+failing rather than each instance tripping independently.
+
+Every 5xx response written by the rest of the chain counts as a failure; once `FailureThreshold`
+consecutive failures are recorded the breaker opens and requests are rejected with a 503 (and a
+`Retry-After` header) for `OpenDuration`. After that, requests are let through again; the first success
+closes the breaker while another failure re-opens it.
+
+If the store is unavailable, the breaker falls back to state local to the instance.
+
+Example usage:
+
+	breaker := rye.NewMiddlewareDistributedBreaker(yourRedisBreakerStore, rye.BreakerConfig{
+		Name:             "billing-api",
+		FailureThreshold: 10,
+		OpenDuration:     time.Minute,
+	})
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			breaker,
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareDistributedBreaker(store BreakerStore, cfg BreakerConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if cfg.Name == "" {
+		cfg.Name = DEFAULT_BREAKER_NAME
+	}
+
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = DEFAULT_BREAKER_FAILURE_THRESHOLD
+	}
+
+	if cfg.OpenDuration <= 0 {
+		cfg.OpenDuration = DEFAULT_BREAKER_OPEN_DURATION
+	}
+
+	local := NewMemoryBreakerStore()
+	if store == nil {
+		store = local
+	}
+
+	b := &distributedBreaker{
+		config: cfg,
+		store:  store,
+		local:  local,
+	}
+
+	return b.handle
+}
+
+func (b *distributedBreaker) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	ctx := r.Context()
+	state := b.get(ctx)
+
+	if !state.OpenedAt.IsZero() {
+		if remaining := b.config.OpenDuration - time.Since(state.OpenedAt); remaining > 0 {
+			rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(remaining.Seconds()))))
+
+			return &Response{
+				Err:        fmt.Errorf("Circuit breaker '%v' is open", b.config.Name),
+				StatusCode: http.StatusServiceUnavailable,
+			}
+		}
+	}
+
+	return &Response{
+		Writer: newStatusRecordingWriter(rw, func(status int) {
+			b.record(ctx, status < http.StatusInternalServerError)
+		}),
+	}
+}
+
+// record updates the breaker state with the outcome of a request
+func (b *distributedBreaker) record(ctx context.Context, success bool) {
+	state := b.get(ctx)
+
+	if success {
+		if state.Failures == 0 && state.OpenedAt.IsZero() {
+			return
+		}
+
+		b.set(ctx, BreakerState{})
+		return
+	}
+
+	state.Failures++
+	if state.Failures >= b.config.FailureThreshold {
+		state.OpenedAt = time.Now()
+	}
+
+	b.set(ctx, state)
+}
+
+// get reads the shared state, falling back to the local state if the store is unavailable
+func (b *distributedBreaker) get(ctx context.Context) BreakerState {
+	state, err := b.store.Get(ctx, b.config.Name)
+	if err != nil {
+		state, _ = b.local.Get(ctx, b.config.Name)
+	}
+
+	return state
+}
+
+// set writes the shared state, falling back to the local state if the store is unavailable
+func (b *distributedBreaker) set(ctx context.Context, state BreakerState) {
+	if err := b.store.Set(ctx, b.config.Name, state); err != nil {
+		b.local.Set(ctx, b.config.Name, state)
+	}
+}
+
+// MemoryBreakerStore is a BreakerStore local to the current process.
+type MemoryBreakerStore struct {
+	mu     sync.Mutex
+	states map[string]BreakerState
+}
+
+// NewMemoryBreakerStore creates an empty in-memory BreakerStore.
+func NewMemoryBreakerStore() *MemoryBreakerStore {
+	return &MemoryBreakerStore{
+		states: make(map[string]BreakerState),
+	}
+}
+
+// Get returns the state of the named breaker (closed if it is unknown).
+func (m *MemoryBreakerStore) Get(ctx context.Context, key string) (BreakerState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.states[key], nil
+}
+
+// Set stores the state of the named breaker.
+func (m *MemoryBreakerStore) Set(ctx context.Context, key string, state BreakerState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.states[key] = state
+	return nil
+}
@@ -0,0 +1,137 @@
+package rye
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+// fakeBreakerStore is a shared store that can be made unavailable
+type fakeBreakerStore struct {
+	*MemoryBreakerStore
+	unavailable bool
+}
+
+func (f *fakeBreakerStore) Get(ctx context.Context, key string) (BreakerState, error) {
+	if f.unavailable {
+		return BreakerState{}, errors.New("store unavailable")
+	}
+
+	return f.MemoryBreakerStore.Get(ctx, key)
+}
+
+func (f *fakeBreakerStore) Set(ctx context.Context, key string, state BreakerState) error {
+	if f.unavailable {
+		return errors.New("store unavailable")
+	}
+
+	return f.MemoryBreakerStore.Set(ctx, key, state)
+}
+
+var _ = Describe("Distributed Breaker Middleware", func() {
+
+	var (
+		store     *fakeBreakerStore
+		config    BreakerConfig
+		mwHandler *MWHandler
+	)
+
+	serve := func(breaker Handler, handler Handler) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", "/", nil)
+
+		mwHandler.Handle([]Handler{breaker, handler}).ServeHTTP(response, request)
+		return response
+	}
+
+	downstreamFailure := func(rw http.ResponseWriter, r *http.Request) *Response {
+		return &Response{Err: errors.New("downstream failed"), StatusCode: http.StatusBadGateway}
+	}
+
+	downstreamSuccess := func(rw http.ResponseWriter, r *http.Request) *Response {
+		return nil
+	}
+
+	BeforeEach(func() {
+		store = &fakeBreakerStore{MemoryBreakerStore: NewMemoryBreakerStore()}
+		config = BreakerConfig{Name: "billing", FailureThreshold: 2, OpenDuration: time.Minute}
+		mwHandler = NewMWHandler(Config{})
+	})
+
+	Describe("handle", func() {
+		Context("when failures reach the threshold on one instance", func() {
+			It("should open the breaker on every instance sharing the store", func() {
+				instanceA := NewMiddlewareDistributedBreaker(store, config)
+				instanceB := NewMiddlewareDistributedBreaker(store, config)
+
+				Expect(serve(instanceA, downstreamFailure).Code).To(Equal(http.StatusBadGateway))
+				Expect(serve(instanceB, downstreamFailure).Code).To(Equal(http.StatusBadGateway))
+
+				state, _ := store.Get(context.Background(), "billing")
+				Expect(state.Failures).To(Equal(2))
+				Expect(state.OpenedAt).ToNot(BeZero())
+
+				response := serve(instanceA, downstreamSuccess)
+				Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+				Expect(response.Header().Get("Retry-After")).To(Equal("60"))
+
+				Expect(serve(instanceB, downstreamSuccess).Code).To(Equal(http.StatusServiceUnavailable))
+			})
+		})
+
+		Context("when a request succeeds", func() {
+			It("should reset the shared failure count", func() {
+				breaker := NewMiddlewareDistributedBreaker(store, config)
+
+				serve(breaker, downstreamFailure)
+				Expect(serve(breaker, downstreamSuccess).Code).To(Equal(http.StatusOK))
+
+				state, _ := store.Get(context.Background(), "billing")
+				Expect(state).To(Equal(BreakerState{}))
+			})
+		})
+
+		Context("when the open duration has elapsed", func() {
+			It("should let requests through and close on success", func() {
+				store.Set(context.Background(), "billing", BreakerState{
+					Failures: 2,
+					OpenedAt: time.Now().Add(-2 * time.Minute),
+				})
+
+				breaker := NewMiddlewareDistributedBreaker(store, config)
+				Expect(serve(breaker, downstreamSuccess).Code).To(Equal(http.StatusOK))
+
+				state, _ := store.Get(context.Background(), "billing")
+				Expect(state.OpenedAt).To(BeZero())
+			})
+
+			It("should re-open on failure", func() {
+				store.Set(context.Background(), "billing", BreakerState{
+					Failures: 2,
+					OpenedAt: time.Now().Add(-2 * time.Minute),
+				})
+
+				breaker := NewMiddlewareDistributedBreaker(store, config)
+				serve(breaker, downstreamFailure)
+
+				Expect(serve(breaker, downstreamSuccess).Code).To(Equal(http.StatusServiceUnavailable))
+			})
+		})
+
+		Context("when the store is unavailable", func() {
+			It("should fall back to local state", func() {
+				store.unavailable = true
+				breaker := NewMiddlewareDistributedBreaker(store, config)
+
+				serve(breaker, downstreamFailure)
+				serve(breaker, downstreamFailure)
+
+				Expect(serve(breaker, downstreamSuccess).Code).To(Equal(http.StatusServiceUnavailable))
+			})
+		})
+	})
+})
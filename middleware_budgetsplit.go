@@ -0,0 +1,111 @@
+package rye
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+const (
+	// Context key holding the deadlines of the downstream phases (a map[string]time.Time)
+	CONTEXT_PHASE_DEADLINES = "rye-middlewarebudgetsplit-phase-deadlines"
+)
+
+type budgetSplit struct {
+	fractions map[string]float64
+}
+
+/*
+NewMiddlewareBudgetSplit creates a new handler that divides the remaining time budget of the request
+(up to the deadline of the request context) among named downstream phases, so that a single slow
+downstream call cannot use up the budget of the others. Each phase gets its fraction of the budget that
+remains when the middleware runs; fractions adding up to more than 1 are scaled down proportionally.
+
+Handlers fetch the deadline of a phase with `rye.PhaseDeadline`. When the request context has no
+deadline there is no budget to split and no phase deadlines are set.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareBudgetSplit(map[string]float64{"db": 0.6, "cache": 0.2}),
+			yourHandler,
+		})).Methods("GET")
+
+	func yourHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		ctx := r.Context()
+		if deadline, ok := rye.PhaseDeadline(ctx, "db"); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, deadline)
+			defer cancel()
+		}
+		...
+	}
+*/
+func NewMiddlewareBudgetSplit(fractions map[string]float64) func(rw http.ResponseWriter, req *http.Request) *Response {
+	return newBudgetSplit(fractions).handle
+}
+
+// newBudgetSplit drops non-positive fractions and scales the others down to add up to at most 1
+func newBudgetSplit(fractions map[string]float64) *budgetSplit {
+	var total float64
+	for _, fraction := range fractions {
+		if fraction > 0 {
+			total += fraction
+		}
+	}
+
+	scale := 1.0
+	if total > 1 {
+		scale = 1 / total
+	}
+
+	b := &budgetSplit{fractions: make(map[string]float64, len(fractions))}
+	for phase, fraction := range fractions {
+		if fraction > 0 {
+			b.fractions[phase] = fraction * scale
+		}
+	}
+
+	return b
+}
+
+func (b *budgetSplit) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	deadline, ok := r.Context().Deadline()
+	if !ok {
+		return nil
+	}
+
+	deadlines := b.deadlines(time.Now(), deadline)
+
+	return &Response{
+		Context: context.WithValue(r.Context(), CONTEXT_PHASE_DEADLINES, deadlines),
+	}
+}
+
+// deadlines splits the budget left between now and the overall deadline among the phases
+func (b *budgetSplit) deadlines(now, deadline time.Time) map[string]time.Time {
+	remaining := deadline.Sub(now)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	deadlines := make(map[string]time.Time, len(b.fractions))
+	for phase, fraction := range b.fractions {
+		deadlines[phase] = now.Add(time.Duration(float64(remaining) * fraction))
+	}
+
+	return deadlines
+}
+
+// PhaseDeadline returns the deadline set by NewMiddlewareBudgetSplit for the given phase
+// (false if the phase has no deadline)
+func PhaseDeadline(ctx context.Context, phase string) (time.Time, bool) {
+	deadlines, ok := ctx.Value(CONTEXT_PHASE_DEADLINES).(map[string]time.Time)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	deadline, ok := deadlines[phase]
+	return deadline, ok
+}
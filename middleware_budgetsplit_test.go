@@ -0,0 +1,105 @@
+package rye
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Budget Split Middleware", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		request = httptest.NewRequest("GET", "/", nil)
+	})
+
+	Describe("handle", func() {
+		Context("when the request has a deadline", func() {
+			It("should store a deadline for each phase within the overall budget", func() {
+				deadline := time.Now().Add(time.Second)
+				ctx, cancel := context.WithDeadline(request.Context(), deadline)
+				defer cancel()
+
+				resp := NewMiddlewareBudgetSplit(map[string]float64{"db": 0.5, "cache": 0.25})(response, request.WithContext(ctx))
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Context).ToNot(BeNil())
+
+				db, ok := PhaseDeadline(resp.Context, "db")
+				Expect(ok).To(BeTrue())
+				cache, ok := PhaseDeadline(resp.Context, "cache")
+				Expect(ok).To(BeTrue())
+
+				Expect(db).To(BeTemporally("<", deadline))
+				Expect(cache).To(BeTemporally("<", db))
+				Expect(time.Until(db) + time.Until(cache)).To(BeNumerically("<=", time.Until(deadline)))
+
+				_, ok = PhaseDeadline(resp.Context, "queue")
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		Context("when the request has no deadline", func() {
+			It("should not set phase deadlines", func() {
+				resp := NewMiddlewareBudgetSplit(map[string]float64{"db": 0.5})(response, request)
+				Expect(resp).To(BeNil())
+
+				_, ok := PhaseDeadline(request.Context(), "db")
+				Expect(ok).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("deadlines", func() {
+		var (
+			now      time.Time
+			deadline time.Time
+		)
+
+		BeforeEach(func() {
+			now = time.Now()
+			deadline = now.Add(time.Second)
+		})
+
+		It("should sum within the overall budget", func() {
+			split := newBudgetSplit(map[string]float64{"db": 0.5, "cache": 0.25, "queue": 0.25})
+
+			var total time.Duration
+			for _, d := range split.deadlines(now, deadline) {
+				total += d.Sub(now)
+			}
+			Expect(total).To(BeNumerically("<=", time.Second))
+		})
+
+		It("should scale down fractions adding up to more than 1", func() {
+			split := newBudgetSplit(map[string]float64{"db": 1.5, "cache": 0.5, "queue": -1})
+
+			Expect(split.fractions).To(Equal(map[string]float64{"db": 0.75, "cache": 0.25}))
+		})
+
+		It("should shrink as time elapses", func() {
+			split := newBudgetSplit(map[string]float64{"db": 0.5})
+
+			early := split.deadlines(now, deadline)["db"].Sub(now)
+			later := now.Add(500 * time.Millisecond)
+			late := split.deadlines(later, deadline)["db"].Sub(later)
+
+			Expect(early).To(Equal(500 * time.Millisecond))
+			Expect(late).To(Equal(250 * time.Millisecond))
+		})
+
+		It("should leave no time once the budget is exhausted", func() {
+			split := newBudgetSplit(map[string]float64{"db": 0.5})
+
+			later := deadline.Add(time.Second)
+			Expect(split.deadlines(later, deadline)["db"]).To(Equal(later))
+		})
+	})
+})
@@ -0,0 +1,100 @@
+package rye
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// ChaosConfig configures the chaos middleware.
+type ChaosConfig struct {
+	// Enabled must be set for anything to be injected, so that the middleware can be left in a
+	// chain (ie. enabled from an environment variable) without ever running in production by accident
+	Enabled bool
+
+	// SampleRate is the fraction of requests (between 0 and 1) chaos is injected into
+	// (defaults to 1, every request)
+	SampleRate float64
+
+	// Latency delays the sampled requests by this long
+	Latency time.Duration
+
+	// ErrorStatusCode, when set, fails the sampled requests with this status code
+	// (ie. 503), after the latency if any
+	ErrorStatusCode int
+}
+
+type chaosLatency struct {
+	config ChaosConfig
+
+	// random returns a number in [0, 1) deciding whether a request is sampled
+	random func() float64
+}
+
+/*
+NewMiddlewareChaosLatency creates a new handler injecting artificial latency and/or synthetic errors into a sampled
+fraction of requests, to test how clients cope with a slow or failing service. Nothing is injected unless `Enabled`
+is set. The delay is cut short when the request is cancelled, in which case the chain is stopped with a 503. Each
+request chaos is injected into is counted as `chaos.injected`.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareChaosLatency(rye.ChaosConfig{
+				Enabled:         os.Getenv("CHAOS_ENABLED") == "true",
+				SampleRate:      0.1,
+				Latency:         500 * time.Millisecond,
+				ErrorStatusCode: http.StatusServiceUnavailable,
+			}),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareChaosLatency(cfg ChaosConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if cfg.SampleRate == 0 {
+		cfg.SampleRate = 1
+	}
+
+	c := &chaosLatency{
+		config: cfg,
+		random: rand.Float64,
+	}
+	return c.handle
+}
+
+func (c *chaosLatency) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	if !c.config.Enabled {
+		return nil
+	}
+
+	if c.config.SampleRate < 1 && c.random() >= c.config.SampleRate {
+		return nil
+	}
+
+	chainFromRequest(r).inc("chaos.injected")
+
+	if c.config.Latency > 0 {
+		timer := time.NewTimer(c.config.Latency)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return &Response{
+				Err:        fmt.Errorf("Request cancelled while injecting latency: %v", r.Context().Err()),
+				StatusCode: http.StatusServiceUnavailable,
+			}
+		}
+	}
+
+	if c.config.ErrorStatusCode != 0 {
+		return &Response{
+			Err:        errors.New("Chaos injected error"),
+			StatusCode: c.config.ErrorStatusCode,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package rye
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Chaos Latency Middleware", func() {
+
+	var (
+		request  *http.Request
+		response *httptest.ResponseRecorder
+		reporter *recordingReporter
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("GET", "/", nil)
+		response = httptest.NewRecorder()
+		reporter = &recordingReporter{}
+	})
+
+	injections := func() int {
+		var n int
+		for _, name := range reporter.recordedIncs() {
+			if name == "chaos.injected" {
+				n++
+			}
+		}
+		return n
+	}
+
+	// serve runs the middleware in a chain, reporting whether the handler after it was reached
+	serve := func(handler Handler) bool {
+		var reached bool
+		NewMWHandler(Config{Reporter: reporter, SyncStats: true}).Handle([]Handler{
+			handler,
+			func(rw http.ResponseWriter, r *http.Request) *Response {
+				reached = true
+				return nil
+			},
+		}).ServeHTTP(response, request)
+		return reached
+	}
+
+	Describe("handle", func() {
+		It("should inject nothing unless enabled", func() {
+			start := time.Now()
+			reached := serve(NewMiddlewareChaosLatency(ChaosConfig{
+				Latency:         time.Second,
+				ErrorStatusCode: http.StatusServiceUnavailable,
+			}))
+
+			Expect(reached).To(BeTrue())
+			Expect(time.Since(start)).To(BeNumerically("<", 500*time.Millisecond))
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(injections()).To(BeZero())
+		})
+
+		It("should delay the request when enabled", func() {
+			start := time.Now()
+			reached := serve(NewMiddlewareChaosLatency(ChaosConfig{Enabled: true, Latency: 50 * time.Millisecond}))
+
+			Expect(reached).To(BeTrue())
+			Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+			Expect(injections()).To(Equal(1))
+		})
+
+		It("should fail the request with the configured status when enabled", func() {
+			reached := serve(NewMiddlewareChaosLatency(ChaosConfig{Enabled: true, ErrorStatusCode: http.StatusServiceUnavailable}))
+
+			Expect(reached).To(BeFalse())
+			Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(response.Body.String()).To(ContainSubstring("Chaos injected error"))
+			Expect(injections()).To(Equal(1))
+		})
+
+		It("should only inject chaos into the sampled fraction of requests", func() {
+			values := []float64{0.1, 0.5, 0.29, 0.3, 0.9}
+			c := &chaosLatency{
+				config: ChaosConfig{Enabled: true, SampleRate: 0.3, ErrorStatusCode: http.StatusInternalServerError},
+				random: func() float64 {
+					v := values[0]
+					values = values[1:]
+					return v
+				},
+			}
+
+			var failed int
+			for range values {
+				response = httptest.NewRecorder()
+				if !serve(c.handle) {
+					failed++
+				}
+			}
+
+			Expect(failed).To(Equal(2))
+			Expect(injections()).To(Equal(2))
+		})
+
+		It("should stop delaying when the request is cancelled", func() {
+			ctx, cancel := context.WithCancel(request.Context())
+			request = request.WithContext(ctx)
+			time.AfterFunc(20*time.Millisecond, cancel)
+
+			start := time.Now()
+			reached := serve(NewMiddlewareChaosLatency(ChaosConfig{Enabled: true, Latency: 5 * time.Second}))
+
+			Expect(reached).To(BeFalse())
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+			Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+		})
+	})
+})
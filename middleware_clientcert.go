@@ -0,0 +1,75 @@
+package rye
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	CONTEXT_CLIENT_CERT_IDENTITY = "rye-middlewareclientcert-identity"
+)
+
+// ClientCertConfig restricts which client certificates are accepted. Both lists match against
+// the certificate's subject/issuer common name; an empty list allows any value.
+type ClientCertConfig struct {
+	AllowedSubjects []string
+	AllowedIssuers  []string
+}
+
+type clientCert struct {
+	config ClientCertConfig
+}
+
+/*
+NewMiddlewareRequireClientCert creates a new handler for mTLS protected endpoints that requires the client
+to have presented a TLS certificate. It returns a 401 when no certificate was presented and a 403 when the
+certificate's subject or issuer is not in the configured allowlists.
+
+On success, the certificate's subject common name is added to the context under CONTEXT_CLIENT_CERT_IDENTITY.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareRequireClientCert(rye.ClientCertConfig{
+				AllowedSubjects: []string{"billing-service"},
+				AllowedIssuers:  []string{"Internal CA"},
+			}),
+			yourHandler,
+		})).Methods("POST")
+*/
+func NewMiddlewareRequireClientCert(cfg ClientCertConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	c := &clientCert{config: cfg}
+	return c.handle
+}
+
+func (c *clientCert) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return &Response{
+			Err:        errors.New("Client certificate required"),
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+
+	cert := r.TLS.PeerCertificates[0]
+
+	if len(c.config.AllowedSubjects) > 0 && !stringListContains(c.config.AllowedSubjects, cert.Subject.CommonName) {
+		return &Response{
+			Err:        fmt.Errorf("Client certificate subject '%s' is not allowed", cert.Subject.CommonName),
+			StatusCode: http.StatusForbidden,
+		}
+	}
+
+	if len(c.config.AllowedIssuers) > 0 && !stringListContains(c.config.AllowedIssuers, cert.Issuer.CommonName) {
+		return &Response{
+			Err:        fmt.Errorf("Client certificate issuer '%s' is not allowed", cert.Issuer.CommonName),
+			StatusCode: http.StatusForbidden,
+		}
+	}
+
+	ctx := context.WithValue(r.Context(), CONTEXT_CLIENT_CERT_IDENTITY, cert.Subject.CommonName)
+
+	return &Response{Context: ctx}
+}
@@ -0,0 +1,90 @@
+package rye
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Require Client Cert Middleware", func() {
+
+	var (
+		request  *http.Request
+		response *httptest.ResponseRecorder
+		cert     *x509.Certificate
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		request = &http.Request{
+			Header: make(map[string][]string, 0),
+		}
+		cert = &x509.Certificate{
+			Subject: pkix.Name{CommonName: "billing-service"},
+			Issuer:  pkix.Name{CommonName: "Internal CA"},
+		}
+	})
+
+	Describe("handle", func() {
+		Context("when no TLS connection is used", func() {
+			It("should return a 401", func() {
+				resp := NewMiddlewareRequireClientCert(ClientCertConfig{})(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+				Expect(resp.Error()).To(ContainSubstring("Client certificate required"))
+			})
+		})
+
+		Context("when no client certificate was presented", func() {
+			It("should return a 401", func() {
+				request.TLS = &tls.ConnectionState{}
+
+				resp := NewMiddlewareRequireClientCert(ClientCertConfig{})(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			})
+		})
+
+		Context("when an allowed client certificate was presented", func() {
+			It("should add the identity to the context", func() {
+				request.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+
+				resp := NewMiddlewareRequireClientCert(ClientCertConfig{
+					AllowedSubjects: []string{"billing-service"},
+					AllowedIssuers:  []string{"Internal CA"},
+				})(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Err).To(BeNil())
+				Expect(resp.Context.Value(CONTEXT_CLIENT_CERT_IDENTITY)).To(Equal("billing-service"))
+			})
+		})
+
+		Context("when a disallowed client certificate was presented", func() {
+			It("should return a 403 for a disallowed subject", func() {
+				request.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+
+				resp := NewMiddlewareRequireClientCert(ClientCertConfig{
+					AllowedSubjects: []string{"orders-service"},
+				})(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+				Expect(resp.Error()).To(ContainSubstring("subject 'billing-service'"))
+			})
+
+			It("should return a 403 for a disallowed issuer", func() {
+				request.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+
+				resp := NewMiddlewareRequireClientCert(ClientCertConfig{
+					AllowedIssuers: []string{"Public CA"},
+				})(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+				Expect(resp.Error()).To(ContainSubstring("issuer 'Internal CA'"))
+			})
+		})
+	})
+})
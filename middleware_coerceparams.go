@@ -0,0 +1,169 @@
+package rye
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// Context key holding the coerced query params (a map[string]interface{})
+	CONTEXT_PARAMS = "rye-middlewarecoerceparams-params"
+
+	// Param types
+	PARAM_BOOL  = "bool"
+	PARAM_INT   = "int"
+	PARAM_FLOAT = "float"
+	PARAM_TIME  = "time"
+)
+
+// ParamSpec describes a single query param to coerce. Default (of the matching Go type:
+// bool, int64, float64 or time.Time) is used when the param is absent, unless it is Required.
+// Min and Max optionally bound int and float params; Layout is the time layout for time
+// params (defaults to time.RFC3339).
+type ParamSpec struct {
+	Name     string
+	Type     string
+	Default  interface{}
+	Required bool
+	Min      *float64
+	Max      *float64
+	Layout   string
+}
+
+type coerceParams struct {
+	specs []ParamSpec
+}
+
+/*
+NewMiddlewareCoerceParams creates a new handler that reads the configured query params, coerces them
+into typed values (bool, int64, float64 or time.Time), applies defaults and validates ranges, returning
+a 400 if a param is missing, malformed or out of range. The typed values are stored in the request
+context and can be read with `rye.Param`, sparing handlers the repetitive strconv calls.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareCoerceParams([]rye.ParamSpec{
+				{Name: "limit", Type: rye.PARAM_INT, Default: int64(20), Min: rye.Float(1), Max: rye.Float(100)},
+				{Name: "archived", Type: rye.PARAM_BOOL, Default: false},
+			}),
+			yourHandler,
+		})).Methods("GET")
+
+	func yourHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		limit, _ := rye.Param[int64](r.Context(), "limit")
+		...
+	}
+*/
+func NewMiddlewareCoerceParams(specs []ParamSpec) func(rw http.ResponseWriter, req *http.Request) *Response {
+	c := &coerceParams{specs: specs}
+	return c.handle
+}
+
+func (c *coerceParams) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	query := r.URL.Query()
+	params := make(map[string]interface{}, len(c.specs))
+
+	for _, spec := range c.specs {
+		raw := query.Get(spec.Name)
+
+		if raw == "" {
+			if spec.Required {
+				return &Response{
+					Err:        fmt.Errorf("Missing required query param '%v'", spec.Name),
+					StatusCode: http.StatusBadRequest,
+				}
+			}
+
+			if spec.Default != nil {
+				params[spec.Name] = spec.Default
+			}
+
+			continue
+		}
+
+		value, err := spec.coerce(raw)
+		if err != nil {
+			return &Response{
+				Err:        fmt.Errorf("Invalid query param '%v': %v", spec.Name, err),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		params[spec.Name] = value
+	}
+
+	return &Response{
+		Context: context.WithValue(r.Context(), CONTEXT_PARAMS, params),
+	}
+}
+
+// coerce converts the raw value to the spec's type and checks its range
+func (p ParamSpec) coerce(raw string) (interface{}, error) {
+	switch p.Type {
+	case PARAM_BOOL:
+		return strconv.ParseBool(raw)
+	case PARAM_INT:
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%v' is not an integer", raw)
+		}
+
+		return value, p.checkRange(float64(value))
+	case PARAM_FLOAT:
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%v' is not a number", raw)
+		}
+
+		return value, p.checkRange(value)
+	case PARAM_TIME:
+		layout := p.Layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+
+		value, err := time.Parse(layout, raw)
+		if err != nil {
+			return nil, fmt.Errorf("'%v' does not match the layout '%v'", raw, layout)
+		}
+
+		return value, nil
+	}
+
+	return nil, fmt.Errorf("unknown param type '%v'", p.Type)
+}
+
+func (p ParamSpec) checkRange(value float64) error {
+	if p.Min != nil && value < *p.Min {
+		return fmt.Errorf("must be at least %v", *p.Min)
+	}
+
+	if p.Max != nil && value > *p.Max {
+		return fmt.Errorf("must be at most %v", *p.Max)
+	}
+
+	return nil
+}
+
+// Float returns a pointer to the given value, for use as a ParamSpec Min or Max
+func Float(f float64) *float64 {
+	return &f
+}
+
+// Param returns the coerced query param stored by NewMiddlewareCoerceParams, provided it is of type T
+func Param[T any](ctx context.Context, name string) (T, bool) {
+	var zero T
+
+	params, ok := ctx.Value(CONTEXT_PARAMS).(map[string]interface{})
+	if !ok {
+		return zero, false
+	}
+
+	typed, ok := params[name].(T)
+	return typed, ok
+}
@@ -0,0 +1,106 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Coerce Params Middleware", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+		handler  func(rw http.ResponseWriter, req *http.Request) *Response
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		handler = NewMiddlewareCoerceParams([]ParamSpec{
+			{Name: "limit", Type: PARAM_INT, Default: int64(20), Min: Float(1), Max: Float(100)},
+			{Name: "archived", Type: PARAM_BOOL, Default: false},
+			{Name: "ratio", Type: PARAM_FLOAT},
+			{Name: "since", Type: PARAM_TIME},
+		})
+	})
+
+	Describe("handle", func() {
+		Context("when the params are valid", func() {
+			It("should store the typed values in the context", func() {
+				request := httptest.NewRequest("GET", "/?limit=50&archived=true&ratio=0.5&since=2020-01-02T03:04:05Z", nil)
+
+				resp := handler(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Err).To(BeNil())
+
+				ctx := resp.Context
+
+				limit, ok := Param[int64](ctx, "limit")
+				Expect(ok).To(BeTrue())
+				Expect(limit).To(Equal(int64(50)))
+
+				archived, _ := Param[bool](ctx, "archived")
+				Expect(archived).To(BeTrue())
+
+				ratio, _ := Param[float64](ctx, "ratio")
+				Expect(ratio).To(Equal(0.5))
+
+				since, _ := Param[time.Time](ctx, "since")
+				Expect(since).To(Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)))
+			})
+		})
+
+		Context("when params are absent", func() {
+			It("should apply the defaults", func() {
+				request := httptest.NewRequest("GET", "/", nil)
+
+				resp := handler(response, request)
+				Expect(resp.Err).To(BeNil())
+
+				limit, _ := Param[int64](resp.Context, "limit")
+				Expect(limit).To(Equal(int64(20)))
+
+				archived, ok := Param[bool](resp.Context, "archived")
+				Expect(ok).To(BeTrue())
+				Expect(archived).To(BeFalse())
+
+				_, ok = Param[float64](resp.Context, "ratio")
+				Expect(ok).To(BeFalse())
+			})
+
+			It("should return a 400 for required params", func() {
+				request := httptest.NewRequest("GET", "/", nil)
+
+				resp := NewMiddlewareCoerceParams([]ParamSpec{{Name: "id", Type: PARAM_INT, Required: true}})(response, request)
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(resp.Error()).To(ContainSubstring("Missing required query param 'id'"))
+			})
+		})
+
+		Context("when params are invalid", func() {
+			It("should return a 400 for values that cannot be coerced", func() {
+				request := httptest.NewRequest("GET", "/?limit=ten", nil)
+
+				resp := handler(response, request)
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(resp.Error()).To(ContainSubstring("'ten' is not an integer"))
+			})
+
+			It("should return a 400 for values out of range", func() {
+				request := httptest.NewRequest("GET", "/?limit=500", nil)
+
+				resp := handler(response, request)
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(resp.Error()).To(ContainSubstring("must be at most 100"))
+			})
+
+			It("should return a 400 for malformed times", func() {
+				request := httptest.NewRequest("GET", "/?since=yesterday", nil)
+
+				resp := handler(response, request)
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			})
+		})
+	})
+})
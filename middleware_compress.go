@@ -0,0 +1,241 @@
+package rye
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// Bodies smaller than this are not worth compressing
+	DEFAULT_COMPRESS_MIN_SIZE = 1024
+)
+
+// DEFAULT_COMPRESS_SKIP_CONTENT_TYPES are content types that are already compressed; an entry ending
+// with a "/" matches any content type starting with it
+var DEFAULT_COMPRESS_SKIP_CONTENT_TYPES = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"font/woff2",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zip",
+	"application/zstd",
+	"application/octet-stream",
+}
+
+// CompressConfig configures the compression middleware.
+type CompressConfig struct {
+	// MinSize is the body size (in bytes) under which responses are sent uncompressed
+	// (defaults to DEFAULT_COMPRESS_MIN_SIZE)
+	MinSize int
+
+	// Level is the compression level (see compress/flate); 0 means the default level
+	Level int
+
+	// SkipContentTypes are content types that are never compressed
+	// (defaults to DEFAULT_COMPRESS_SKIP_CONTENT_TYPES)
+	SkipContentTypes []string
+}
+
+type compress struct {
+	minSize          int
+	level            int
+	skipContentTypes []string
+}
+
+/*
+NewMiddlewareCompress creates a new handler that transparently compresses responses for clients that accept
+it (`gzip` is preferred over `deflate`, as negotiated through `Accept-Encoding`); the rest of the chain writes
+to a wrapped writer, so handlers don't need to change.
+
+Responses are sent uncompressed when their body is smaller than `MinSize`, when their content type is already
+compressed (see `SkipContentTypes`) or when a handler already set a `Content-Encoding`.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareCompress(rye.CompressConfig{MinSize: 512}),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareCompress(cfg CompressConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = DEFAULT_COMPRESS_MIN_SIZE
+	}
+
+	if cfg.Level == 0 {
+		cfg.Level = gzip.DefaultCompression
+	}
+
+	if cfg.SkipContentTypes == nil {
+		cfg.SkipContentTypes = DEFAULT_COMPRESS_SKIP_CONTENT_TYPES
+	}
+
+	c := &compress{
+		minSize:          cfg.MinSize,
+		level:            cfg.Level,
+		skipContentTypes: cfg.SkipContentTypes,
+	}
+
+	return c.handle
+}
+
+func (c *compress) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	// Whether or not this response ends up compressed, it depends on the header
+	rw.Header().Add("Vary", "Accept-Encoding")
+
+	encoding := acceptEncodingOf(r).Preferred("gzip", "deflate")
+	if encoding == "" {
+		return nil
+	}
+
+	return &Response{
+		Writer: &compressWriter{
+			ResponseWriter: rw,
+			compress:       c,
+			encoding:       encoding,
+		},
+	}
+}
+
+// skip reports whether responses of the given content type should be left alone
+func (c *compress) skip(contentType string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+
+	for _, skipped := range c.skipContentTypes {
+		if strings.HasSuffix(skipped, "/") && strings.HasPrefix(contentType, skipped) {
+			return true
+		}
+
+		if contentType == skipped {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compressWriter holds on to the start of the body until it knows whether the response
+// is worth compressing: once MinSize bytes have been written (or the writer is flushed)
+// it either starts compressing or passes everything straight through.
+type compressWriter struct {
+	http.ResponseWriter
+
+	compress *compress
+	encoding string
+
+	status  int
+	buf     bytes.Buffer
+	decided bool
+	encoder io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if cw.status == 0 {
+		cw.status = statusCode
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	if !cw.decided {
+		if cw.buf.Len()+len(p) < cw.compress.minSize {
+			return cw.buf.Write(p)
+		}
+
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+
+	if cw.encoder != nil {
+		return cw.encoder.Write(p)
+	}
+
+	return cw.ResponseWriter.Write(p)
+}
+
+// decide writes out the status code (compressing the rest of the response if it should be)
+// followed by whatever was buffered so far
+func (cw *compressWriter) decide(large bool) error {
+	cw.decided = true
+
+	header := cw.Header()
+
+	// Sniff the content type the way net/http would, before the body gets compressed
+	if header.Get("Content-Type") == "" && cw.buf.Len() > 0 {
+		header.Set("Content-Type", http.DetectContentType(cw.buf.Bytes()))
+	}
+
+	if large && bodyAllowedForStatus(cw.status) && header.Get("Content-Encoding") == "" && !cw.compress.skip(header.Get("Content-Type")) {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+
+		if cw.encoding == "gzip" {
+			cw.encoder, _ = gzip.NewWriterLevel(cw.ResponseWriter, cw.compress.level)
+		} else {
+			cw.encoder, _ = zlib.NewWriterLevel(cw.ResponseWriter, cw.compress.level)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(cw.buf.Bytes())
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf.Bytes())
+	}
+	cw.buf.Reset()
+
+	return err
+}
+
+// Flush compresses what was buffered so far (if the response should be) and passes through
+// to the wrapped writer (if it supports flushing)
+func (cw *compressWriter) Flush() {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	if !cw.decided {
+		cw.decide(true)
+	}
+
+	if f, ok := cw.encoder.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finalize sends a response too small to compress as is, or ends the compressed stream
+func (cw *compressWriter) finalize() {
+	if cw.status == 0 {
+		return
+	}
+
+	if !cw.decided {
+		cw.decide(false)
+	}
+
+	if cw.encoder != nil {
+		cw.encoder.Close()
+	}
+}
@@ -0,0 +1,141 @@
+package rye
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Compress Middleware", func() {
+
+	var (
+		request   *http.Request
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		largeBody string
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		request = httptest.NewRequest("GET", "/", nil)
+		request.Header.Set("Accept-Encoding", "gzip, deflate")
+		mwHandler = NewMWHandler(Config{})
+		largeBody = `{"items":"` + strings.Repeat("rye", 1000) + `"}`
+	})
+
+	writeBody := func(contentType, body string) Handler {
+		return func(rw http.ResponseWriter, r *http.Request) *Response {
+			if contentType != "" {
+				rw.Header().Set("Content-Type", contentType)
+			}
+			rw.WriteHeader(http.StatusCreated)
+			io.WriteString(rw, body)
+			return nil
+		}
+	}
+
+	gunzip := func(body io.Reader) string {
+		reader, err := gzip.NewReader(body)
+		Expect(err).ToNot(HaveOccurred())
+
+		b, err := io.ReadAll(reader)
+		Expect(err).ToNot(HaveOccurred())
+		return string(b)
+	}
+
+	Describe("handle", func() {
+		It("should gzip large responses", func() {
+			h := mwHandler.Handle([]Handler{NewMiddlewareCompress(CompressConfig{}), writeBody("application/json", largeBody)})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusCreated))
+			Expect(response.Header().Get("Content-Encoding")).To(Equal("gzip"))
+			Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(response.Header().Get("Vary")).To(Equal("Accept-Encoding"))
+			Expect(response.Body.Len()).To(BeNumerically("<", len(largeBody)))
+			Expect(gunzip(response.Body)).To(Equal(largeBody))
+		})
+
+		It("should compress bodies written in several chunks", func() {
+			h := mwHandler.Handle([]Handler{NewMiddlewareCompress(CompressConfig{MinSize: 10}), func(rw http.ResponseWriter, r *http.Request) *Response {
+				io.WriteString(rw, "hello ")
+				io.WriteString(rw, "compressed ")
+				io.WriteString(rw, "world")
+				return nil
+			}})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Header().Get("Content-Encoding")).To(Equal("gzip"))
+			Expect(response.Header().Get("Content-Type")).To(HavePrefix("text/plain"))
+			Expect(gunzip(response.Body)).To(Equal("hello compressed world"))
+		})
+
+		It("should use deflate when gzip is not accepted", func() {
+			request.Header.Set("Accept-Encoding", "gzip;q=0, deflate")
+
+			h := mwHandler.Handle([]Handler{NewMiddlewareCompress(CompressConfig{}), writeBody("application/json", largeBody)})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Header().Get("Content-Encoding")).To(Equal("deflate"))
+
+			reader, err := zlib.NewReader(response.Body)
+			Expect(err).ToNot(HaveOccurred())
+			b, err := io.ReadAll(reader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(b)).To(Equal(largeBody))
+		})
+
+		It("should send responses below the threshold as is", func() {
+			h := mwHandler.Handle([]Handler{NewMiddlewareCompress(CompressConfig{}), jsonHandler})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Header().Get("Content-Encoding")).To(BeEmpty())
+			Expect(response.Body.String()).To(Equal(`{"name":"rye"}`))
+		})
+
+		It("should not compress already compressed content types", func() {
+			h := mwHandler.Handle([]Handler{NewMiddlewareCompress(CompressConfig{}), writeBody("image/png", largeBody)})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Header().Get("Content-Encoding")).To(BeEmpty())
+			Expect(response.Body.String()).To(Equal(largeBody))
+		})
+
+		It("should not compress responses that already have a content encoding", func() {
+			h := mwHandler.Handle([]Handler{NewMiddlewareCompress(CompressConfig{}), func(rw http.ResponseWriter, r *http.Request) *Response {
+				rw.Header().Set("Content-Encoding", "br")
+				io.WriteString(rw, largeBody)
+				return nil
+			}})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Header().Get("Content-Encoding")).To(Equal("br"))
+			Expect(response.Body.String()).To(Equal(largeBody))
+		})
+
+		It("should not compress when the client does not accept it", func() {
+			request.Header.Del("Accept-Encoding")
+
+			h := mwHandler.Handle([]Handler{NewMiddlewareCompress(CompressConfig{}), writeBody("application/json", largeBody)})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Header().Get("Content-Encoding")).To(BeEmpty())
+			Expect(response.Header().Get("Vary")).To(Equal("Accept-Encoding"))
+			Expect(response.Body.String()).To(Equal(largeBody))
+		})
+
+		It("should compress error responses", func() {
+			h := mwHandler.Handle([]Handler{NewMiddlewareCompress(CompressConfig{MinSize: 1}), failureHandler})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(505))
+			Expect(response.Header().Get("Content-Encoding")).To(Equal("gzip"))
+			Expect(gunzip(response.Body)).To(ContainSubstring("Foo"))
+		})
+	})
+})
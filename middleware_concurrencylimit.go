@@ -0,0 +1,97 @@
+package rye
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	DEFAULT_CONCURRENCY_MAX_WAIT = time.Second
+)
+
+// ConcurrencyLimitConfig configures the concurrency limit middleware.
+type ConcurrencyLimitConfig struct {
+	// Max is the number of requests allowed to run the rest of the chain at the same time
+	Max int
+
+	// MaxWait is how long a request may be queued waiting for a slot before it is
+	// rejected with a 503 (defaults to DEFAULT_CONCURRENCY_MAX_WAIT)
+	MaxWait time.Duration
+}
+
+type concurrencyLimit struct {
+	config ConcurrencyLimitConfig
+	slots  chan struct{}
+}
+
+/*
+NewMiddlewareConcurrencyLimit creates a new handler that caps the number of requests running the rest
+of the chain at the same time. Requests arriving while all slots are taken are queued for up to `MaxWait`
+and rejected with a 503 if no slot frees up in time.
+
+How long each admitted request waited for a slot is recorded as the `concurrency.wait` timing (through the
+MWHandler's statter), which surfaces saturation before rejections start.
+
+The slot is released once the chain finishes, so this middleware must be used within `MWHandler.Handle`.
+
+Example usage:
+
+	limit := rye.NewMiddlewareConcurrencyLimit(rye.ConcurrencyLimitConfig{Max: 50})
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			limit,
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareConcurrencyLimit(cfg ConcurrencyLimitConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if cfg.Max <= 0 {
+		cfg.Max = 1
+	}
+
+	if cfg.MaxWait <= 0 {
+		cfg.MaxWait = DEFAULT_CONCURRENCY_MAX_WAIT
+	}
+
+	c := &concurrencyLimit{
+		config: cfg,
+		slots:  make(chan struct{}, cfg.Max),
+	}
+
+	return c.handle
+}
+
+func (c *concurrencyLimit) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	chain := chainFromRequest(r)
+	if chain == nil {
+		// Without a chain there is nothing to release the slot when the request is done
+		return nil
+	}
+
+	start := time.Now()
+	timer := time.NewTimer(c.config.MaxWait)
+	defer timer.Stop()
+
+	select {
+	case c.slots <- struct{}{}:
+	case <-timer.C:
+		return &Response{
+			Err:        fmt.Errorf("Too many concurrent requests; gave up after waiting %v", c.config.MaxWait),
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	case <-r.Context().Done():
+		return &Response{
+			Err:        fmt.Errorf("Request cancelled while waiting for a slot: %v", r.Context().Err()),
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+
+	chain.timing("concurrency.wait", time.Since(start))
+
+	return &Response{
+		Writer: newStatusRecordingWriter(rw, func(status int) {
+			<-c.slots
+		}),
+	}
+}
@@ -0,0 +1,106 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/InVisionApp/rye/fakes/statsdfakes"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Concurrency Limit Middleware", func() {
+
+	var (
+		mwHandler       *MWHandler
+		waits           chan time.Duration
+		release         chan struct{}
+		started         chan struct{}
+		blockingHandler Handler
+	)
+
+	serve := func(handlers []Handler) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		mwHandler.Handle(handlers).ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+		return response
+	}
+
+	BeforeEach(func() {
+		// Requests may outlive the spec, so the handler and stubs hold
+		// on to this spec's channels rather than the shared variables
+		specWaits, specRelease, specStarted := make(chan time.Duration, 10), make(chan struct{}), make(chan struct{}, 10)
+		waits, release, started = specWaits, specRelease, specStarted
+
+		// blockingHandler holds its slot until released
+		blockingHandler = func(rw http.ResponseWriter, r *http.Request) *Response {
+			specStarted <- struct{}{}
+			<-specRelease
+			return nil
+		}
+
+		fakeStatter := &statsdfakes.FakeStatter{}
+		fakeStatter.TimingDurationStub = func(name string, d time.Duration, rate float32) error {
+			if name == "concurrency.wait" {
+				specWaits <- d
+			}
+			return nil
+		}
+
+		mwHandler = NewMWHandler(Config{Statter: fakeStatter, StatRate: 1})
+	})
+
+	Describe("handle", func() {
+		Context("when a slot is free", func() {
+			It("should admit the request and emit a near-zero wait", func() {
+				limit := NewMiddlewareConcurrencyLimit(ConcurrencyLimitConfig{Max: 1})
+
+				response := serve([]Handler{limit, textHandler})
+				Expect(response.Code).To(Equal(http.StatusOK))
+
+				var wait time.Duration
+				Eventually(waits).Should(Receive(&wait))
+				Expect(wait).To(BeNumerically("<", 10*time.Millisecond))
+			})
+		})
+
+		Context("when all slots are taken", func() {
+			It("should queue the request and emit how long it waited", func() {
+				limit := NewMiddlewareConcurrencyLimit(ConcurrencyLimitConfig{Max: 1})
+
+				go serve([]Handler{limit, blockingHandler})
+				Eventually(started).Should(Receive())
+				Eventually(waits).Should(Receive())
+
+				go func() {
+					time.Sleep(50 * time.Millisecond)
+					close(release)
+				}()
+
+				response := serve([]Handler{limit, textHandler})
+				Expect(response.Code).To(Equal(http.StatusOK))
+
+				var wait time.Duration
+				Eventually(waits).Should(Receive(&wait))
+				Expect(wait).To(BeNumerically(">=", 50*time.Millisecond))
+			})
+
+			It("should reject the request once MaxWait has passed", func() {
+				limit := NewMiddlewareConcurrencyLimit(ConcurrencyLimitConfig{Max: 1, MaxWait: 20 * time.Millisecond})
+
+				go serve([]Handler{limit, blockingHandler})
+				Eventually(started).Should(Receive())
+				defer close(release)
+
+				response := serve([]Handler{limit, textHandler})
+				Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+			})
+		})
+
+		Context("when used outside of a chain", func() {
+			It("should return nil", func() {
+				limit := NewMiddlewareConcurrencyLimit(ConcurrencyLimitConfig{Max: 1})
+				Expect(limit(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))).To(BeNil())
+			})
+		})
+	})
+})
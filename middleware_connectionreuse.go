@@ -0,0 +1,68 @@
+package rye
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+const (
+	// Context key holding the connection tracked by ConnContext
+	CONTEXT_CONNECTION = "rye-middlewareconnectionreuse-connection"
+)
+
+// trackedConnection counts the requests served over a connection
+type trackedConnection struct {
+	requests int64 // accessed atomically
+}
+
+/*
+ConnContext tracks the connections of an http.Server so that NewMiddlewareConnectionReuse can tell new
+connections from reused (keep-alive) ones; net/http doesn't tell handlers otherwise. Set it as the
+`ConnContext` of the server:
+
+	server := &http.Server{
+		Handler:     routes,
+		ConnContext: rye.ConnContext,
+	}
+*/
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, CONTEXT_CONNECTION, &trackedConnection{})
+}
+
+type connectionReuse struct{}
+
+/*
+NewMiddlewareConnectionReuse creates a new handler that counts requests by whether they were the first on their
+connection (`connections.new`) or came over a reused keep-alive connection (`connections.reused`), to diagnose
+clients churning through connections. It needs the server to track its connections (see ConnContext); requests
+of untracked connections are not counted.
+
+Example usage:
+
+	routes.PathPrefix("/").Handler(a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareConnectionReuse(),
+			yourHandler,
+		}))
+*/
+func NewMiddlewareConnectionReuse() func(rw http.ResponseWriter, req *http.Request) *Response {
+	c := &connectionReuse{}
+	return c.handle
+}
+
+func (c *connectionReuse) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	conn, ok := r.Context().Value(CONTEXT_CONNECTION).(*trackedConnection)
+	if !ok {
+		return nil
+	}
+
+	if atomic.AddInt64(&conn.requests, 1) > 1 {
+		chainFromRequest(r).inc("connections.reused")
+	} else {
+		chainFromRequest(r).inc("connections.new")
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package rye
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Connection Reuse Middleware", func() {
+
+	var (
+		mwHandler *MWHandler
+		reporter  *recordingReporter
+		h         http.Handler
+	)
+
+	BeforeEach(func() {
+		reporter = &recordingReporter{}
+		mwHandler = NewMWHandler(Config{Reporter: reporter})
+		h = mwHandler.Handle([]Handler{NewMiddlewareConnectionReuse(), successHandler})
+	})
+
+	connectionStats := func() []string {
+		var names []string
+		for _, name := range reporter.recordedIncs() {
+			if name == "connections.new" || name == "connections.reused" {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+
+	Describe("handle", func() {
+		It("should count the first request of a connection as new and the next ones as reused", func() {
+			conn := ConnContext(context.Background(), nil)
+
+			for i := 0; i < 3; i++ {
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(conn))
+			}
+
+			Eventually(connectionStats).Should(HaveLen(3))
+			Expect(connectionStats()).To(ConsistOf("connections.new", "connections.reused", "connections.reused"))
+		})
+
+		It("should count requests of separate connections as new", func() {
+			for i := 0; i < 2; i++ {
+				conn := ConnContext(context.Background(), nil)
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(conn))
+			}
+
+			Eventually(connectionStats).Should(Equal([]string{"connections.new", "connections.new"}))
+		})
+
+		It("should not count requests of untracked connections", func() {
+			response := httptest.NewRecorder()
+			h.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Eventually(reporter.recordedIncs).Should(ContainElement("handlers.successHandler.2xx"))
+			Consistently(connectionStats).Should(BeEmpty())
+		})
+	})
+
+	Describe("ConnContext", func() {
+		It("should track the connections of a server", func() {
+			server := httptest.NewUnstartedServer(h)
+			server.Config.ConnContext = ConnContext
+			server.Start()
+			defer server.Close()
+
+			for i := 0; i < 2; i++ {
+				resp, err := server.Client().Get(server.URL)
+				Expect(err).ToNot(HaveOccurred())
+				resp.Body.Close()
+			}
+
+			Eventually(connectionStats).Should(HaveLen(2))
+			Expect(connectionStats()).To(ConsistOf("connections.new", "connections.reused"))
+		})
+	})
+})
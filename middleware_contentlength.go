@@ -0,0 +1,47 @@
+package rye
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type contentLengthGuard struct{}
+
+/*
+NewMiddlewareContentLengthGuard creates a new handler that, for requests declaring a `Content-Length`,
+reads the body and returns a 400 if its actual length does not match the declared one. This prevents some
+request smuggling and truncation issues. The body is restored for downstream handlers.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareContentLengthGuard(),
+			yourHandler,
+		})).Methods("POST")
+*/
+func NewMiddlewareContentLengthGuard() func(rw http.ResponseWriter, req *http.Request) *Response {
+	c := &contentLengthGuard{}
+	return c.handle
+}
+
+func (c *contentLengthGuard) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	// Nothing declared (-1) or nothing to compare against
+	if r.ContentLength < 0 || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return bodyReadError(r, err)
+	}
+
+	if int64(len(body)) != r.ContentLength {
+		return &Response{
+			Err:        fmt.Errorf("Request body length (%d bytes) does not match the declared Content-Length (%d bytes)", len(body), r.ContentLength),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package rye
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Content Length Guard Middleware", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+		handler  func(rw http.ResponseWriter, req *http.Request) *Response
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		handler = NewMiddlewareContentLengthGuard()
+	})
+
+	Describe("handle", func() {
+		Context("when the declared length matches the body", func() {
+			It("should return nil and restore the body", func() {
+				request := httptest.NewRequest("POST", "/", strings.NewReader("payload"))
+
+				resp := handler(response, request)
+				Expect(resp).To(BeNil())
+
+				body, err := ioutil.ReadAll(request.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("payload"))
+			})
+		})
+
+		Context("when the declared length does not match the body", func() {
+			It("should return a 400 for a longer body", func() {
+				request := httptest.NewRequest("POST", "/", strings.NewReader("payload"))
+				request.ContentLength = 3
+
+				resp := handler(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(resp.Error()).To(ContainSubstring("(7 bytes) does not match the declared Content-Length (3 bytes)"))
+			})
+
+			It("should return a 400 for a truncated body", func() {
+				request := httptest.NewRequest("POST", "/", strings.NewReader("payload"))
+				request.ContentLength = 20
+
+				resp := handler(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			})
+		})
+
+		Context("when no length is declared", func() {
+			It("should return nil without reading the body", func() {
+				request := httptest.NewRequest("POST", "/", strings.NewReader("payload"))
+				request.ContentLength = -1
+
+				resp := handler(response, request)
+				Expect(resp).To(BeNil())
+			})
+		})
+	})
+})
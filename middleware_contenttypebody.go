@@ -0,0 +1,91 @@
+package rye
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type contentTypeBodyMatch struct{}
+
+/*
+NewMiddlewareContentTypeBodyMatch creates a new handler that cross-checks the declared `Content-Type` of
+the request against the shape of its body and returns a 400 on mismatch, catching clients that send the
+wrong content type. The body is restored for downstream handlers.
+
+The following content types are checked; others (and empty bodies) are let through:
+
+	application/json (and +json types)   body must start with `{` or `[`
+	application/x-www-form-urlencoded     body must parse as a query string
+	multipart/form-data                   body must start with the declared boundary
+
+This is a cheap sanity check of the shape of the body, not a full validation of its content.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareContentTypeBodyMatch(),
+			yourHandler,
+		})).Methods("POST")
+*/
+func NewMiddlewareContentTypeBodyMatch() func(rw http.ResponseWriter, req *http.Request) *Response {
+	c := &contentTypeBodyMatch{}
+	return c.handle
+}
+
+func (c *contentTypeBodyMatch) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return &Response{
+			Err:        fmt.Errorf("Invalid Content-Type '%s': %v", contentType, err),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return bodyReadError(r, err)
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+
+	if !bodyMatchesMediaType(body, mediaType, params) {
+		return &Response{
+			Err:        fmt.Errorf("Request body does not match its Content-Type '%s'", mediaType),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	return nil
+}
+
+// bodyMatchesMediaType returns whether the body has the shape expected of the media type
+// (true for media types it knows nothing about)
+func bodyMatchesMediaType(body []byte, mediaType string, params map[string]string) bool {
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		trimmed := bytes.TrimSpace(body)
+		return trimmed[0] == '{' || trimmed[0] == '['
+
+	case mediaType == "application/x-www-form-urlencoded":
+		_, err := url.ParseQuery(string(body))
+		return err == nil
+
+	case mediaType == "multipart/form-data":
+		boundary := params["boundary"]
+		return boundary != "" && bytes.HasPrefix(bytes.TrimLeft(body, "\r\n"), []byte("--"+boundary))
+	}
+
+	return true
+}
@@ -0,0 +1,93 @@
+package rye
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Content Type Body Match Middleware", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+	)
+
+	newRequest := func(contentType, body string) *http.Request {
+		request := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+		return request
+	}
+
+	expectMatch := func(contentType, body string) {
+		request := newRequest(contentType, body)
+
+		Expect(NewMiddlewareContentTypeBodyMatch()(response, request)).To(BeNil())
+
+		restored, _ := ioutil.ReadAll(request.Body)
+		Expect(string(restored)).To(Equal(body))
+	}
+
+	expectMismatch := func(contentType, body string) {
+		resp := NewMiddlewareContentTypeBodyMatch()(response, newRequest(contentType, body))
+
+		Expect(resp).ToNot(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	}
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+	})
+
+	Describe("handle", func() {
+		Context("when the body matches its content type", func() {
+			It("should accept JSON bodies and restore them", func() {
+				expectMatch("application/json; charset=utf-8", ` {"name":"rye"}`)
+				expectMatch("application/json", `[1, 2]`)
+				expectMatch("application/problem+json", `{"title":"oops"}`)
+			})
+
+			It("should accept form bodies", func() {
+				expectMatch("application/x-www-form-urlencoded", "name=rye&tags=a&tags=b")
+			})
+
+			It("should accept multipart bodies", func() {
+				expectMatch("multipart/form-data; boundary=xyz", "--xyz\r\nContent-Disposition: form-data; name=\"a\"\r\n\r\n1\r\n--xyz--")
+			})
+
+			It("should let unchecked content types and empty bodies through", func() {
+				expectMatch("text/plain", "{not checked")
+				expectMatch("application/json", "")
+			})
+		})
+
+		Context("when the body does not match its content type", func() {
+			It("should reject JSON content types with another body", func() {
+				expectMismatch("application/json", "name=rye")
+			})
+
+			It("should reject malformed form bodies", func() {
+				expectMismatch("application/x-www-form-urlencoded", "name=%zz")
+			})
+
+			It("should reject multipart bodies without the declared boundary", func() {
+				expectMismatch("multipart/form-data; boundary=xyz", "--abc\r\n")
+				expectMismatch("multipart/form-data", "--abc\r\n")
+			})
+
+			It("should reject malformed content types", func() {
+				expectMismatch("application/json; =", `{}`)
+			})
+		})
+
+		Context("when no content type is declared", func() {
+			It("should return nil", func() {
+				request := httptest.NewRequest("POST", "/", strings.NewReader("anything"))
+
+				Expect(NewMiddlewareContentTypeBodyMatch()(response, request)).To(BeNil())
+			})
+		})
+	})
+})
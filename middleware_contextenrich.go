@@ -0,0 +1,102 @@
+package rye
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	// Context source types
+	CONTEXT_SOURCE_HEADER = "header"
+	CONTEXT_SOURCE_COOKIE = "cookie"
+	CONTEXT_SOURCE_QUERY  = "query"
+	CONTEXT_SOURCE_STATIC = "static"
+)
+
+// ContextSource describes where a single context value comes from. Name is the
+// header, cookie or query param to read (ignored for static sources), Value is
+// only used by static sources and Key is the context key the value is stored under.
+type ContextSource struct {
+	Type  string
+	Name  string
+	Key   string
+	Value string
+}
+
+type contextEnrich struct {
+	sources []ContextSource
+}
+
+/*
+NewMiddlewareContextEnrich creates a new handler that populates the request context from
+multiple sources (headers, cookies, query params and static values) in a single step.
+
+Sources are evaluated in order; when several sources target the same context key, the
+last source that yields a non-empty value wins. All values are merged into the context once.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareContextEnrich([]rye.ContextSource{
+				{Type: rye.CONTEXT_SOURCE_STATIC, Key: "tenant", Value: "default"},
+				{Type: rye.CONTEXT_SOURCE_HEADER, Name: "X-Tenant", Key: "tenant"},
+				{Type: rye.CONTEXT_SOURCE_QUERY, Name: "tenant", Key: "tenant"},
+			}),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareContextEnrich(sources []ContextSource) func(rw http.ResponseWriter, req *http.Request) *Response {
+	c := &contextEnrich{sources: sources}
+	return c.handle
+}
+
+func (c *contextEnrich) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	values := make(map[string]string)
+	keys := make([]string, 0, len(c.sources))
+
+	for _, source := range c.sources {
+		value := source.lookup(r)
+		if value == "" {
+			continue
+		}
+
+		if _, ok := values[source.Key]; !ok {
+			keys = append(keys, source.Key)
+		}
+
+		values[source.Key] = value
+	}
+
+	// Nothing to add, leave the request context untouched
+	if len(keys) == 0 {
+		return nil
+	}
+
+	ctx := r.Context()
+	for _, key := range keys {
+		ctx = context.WithValue(ctx, key, values[key])
+	}
+
+	return &Response{Context: ctx}
+}
+
+// lookup returns the value of the source for the given request (or "" if not present)
+func (s ContextSource) lookup(r *http.Request) string {
+	switch s.Type {
+	case CONTEXT_SOURCE_HEADER:
+		return r.Header.Get(s.Name)
+	case CONTEXT_SOURCE_COOKIE:
+		if cookie, err := r.Cookie(s.Name); err == nil {
+			return cookie.Value
+		}
+	case CONTEXT_SOURCE_QUERY:
+		if r.URL != nil {
+			return r.URL.Query().Get(s.Name)
+		}
+	case CONTEXT_SOURCE_STATIC:
+		return s.Value
+	}
+
+	return ""
+}
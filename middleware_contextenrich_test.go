@@ -0,0 +1,83 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Context Enrich Middleware", func() {
+
+	var (
+		request  *http.Request
+		response *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		request = &http.Request{
+			Header: make(map[string][]string, 0),
+			URL:    &url.URL{RawQuery: "tenant=query-tenant&region=eu"},
+		}
+	})
+
+	Describe("handle", func() {
+		Context("when all configured sources have values", func() {
+			It("should add every value to the context", func() {
+				request.Header.Add("X-User", "header-user")
+				request.AddCookie(&http.Cookie{Name: "session", Value: "cookie-session"})
+
+				resp := NewMiddlewareContextEnrich([]ContextSource{
+					{Type: CONTEXT_SOURCE_HEADER, Name: "X-User", Key: "user"},
+					{Type: CONTEXT_SOURCE_COOKIE, Name: "session", Key: "session"},
+					{Type: CONTEXT_SOURCE_QUERY, Name: "region", Key: "region"},
+					{Type: CONTEXT_SOURCE_STATIC, Key: "service", Value: "rye"},
+				})(response, request)
+
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Context).ToNot(BeNil())
+				Expect(resp.Context.Value("user")).To(Equal("header-user"))
+				Expect(resp.Context.Value("session")).To(Equal("cookie-session"))
+				Expect(resp.Context.Value("region")).To(Equal("eu"))
+				Expect(resp.Context.Value("service")).To(Equal("rye"))
+			})
+		})
+
+		Context("when multiple sources target the same key", func() {
+			It("should let later sources override earlier ones", func() {
+				request.Header.Add("X-Tenant", "header-tenant")
+
+				resp := NewMiddlewareContextEnrich([]ContextSource{
+					{Type: CONTEXT_SOURCE_STATIC, Key: "tenant", Value: "static-tenant"},
+					{Type: CONTEXT_SOURCE_HEADER, Name: "X-Tenant", Key: "tenant"},
+					{Type: CONTEXT_SOURCE_QUERY, Name: "tenant", Key: "tenant"},
+				})(response, request)
+
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Context.Value("tenant")).To(Equal("query-tenant"))
+			})
+
+			It("should not let a missing value override an earlier one", func() {
+				resp := NewMiddlewareContextEnrich([]ContextSource{
+					{Type: CONTEXT_SOURCE_STATIC, Key: "tenant", Value: "static-tenant"},
+					{Type: CONTEXT_SOURCE_HEADER, Name: "X-Tenant", Key: "tenant"},
+				})(response, request)
+
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Context.Value("tenant")).To(Equal("static-tenant"))
+			})
+		})
+
+		Context("when no source yields a value", func() {
+			It("should return nil", func() {
+				resp := NewMiddlewareContextEnrich([]ContextSource{
+					{Type: CONTEXT_SOURCE_HEADER, Name: "X-Missing", Key: "missing"},
+				})(response, request)
+
+				Expect(resp).To(BeNil())
+			})
+		})
+	})
+})
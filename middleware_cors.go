@@ -2,6 +2,7 @@ package rye
 
 import (
 	"net/http"
+	"strings"
 )
 
 const (
@@ -89,3 +90,110 @@ func (c *cors) handle(rw http.ResponseWriter, req *http.Request) *Response {
 
 	return nil
 }
+
+// CORSConfig configures the CORS handler created by NewMiddlewareCORSWithConfig.
+// Empty AllowOrigins, AllowMethods or AllowHeaders fall back to the defaults.
+type CORSConfig struct {
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	AllowCredentials bool
+}
+
+type corsWithConfig struct {
+	allowAll         bool
+	origins          map[string]bool
+	allowMethods     string
+	allowHeaders     string
+	allowCredentials bool
+}
+
+/*
+NewMiddlewareCORSWithConfig creates a new handler to support CORS functionality, matching the `Origin` of
+requests against a list of allowed origins (`*` allows any origin). Preflight requests (`OPTIONS`) from an
+allowed origin get the `Access-Control-*` headers and a 200, and the rest of the chain is skipped; other
+requests from an allowed origin get the `Access-Control-Allow-Origin` header and carry on.
+
+The allowed origin is echoed back (along with `Vary: Origin`) unless any origin is allowed, in which case
+`*` is returned; since browsers reject `*` on credentialed requests, the origin is always echoed back when
+`AllowCredentials` is set.
+
+Example use case:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareCORSWithConfig(rye.CORSConfig{
+				AllowOrigins:     []string{"https://app.invisionapp.com"},
+				AllowMethods:     []string{"GET", "PUT"},
+				AllowHeaders:     []string{"Content-Type", "Authorization"},
+				AllowCredentials: true,
+			}),
+			yourHandler,
+		})).Methods("PUT", "OPTIONS")
+*/
+func NewMiddlewareCORSWithConfig(cfg CORSConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	c := &corsWithConfig{
+		origins:          make(map[string]bool),
+		allowMethods:     DEFAULT_CORS_ALLOW_METHODS,
+		allowHeaders:     DEFAULT_CORS_ALLOW_HEADERS,
+		allowCredentials: cfg.AllowCredentials,
+	}
+
+	if len(cfg.AllowOrigins) == 0 {
+		cfg.AllowOrigins = []string{DEFAULT_CORS_ALLOW_ORIGIN}
+	}
+
+	for _, origin := range cfg.AllowOrigins {
+		if origin == "*" {
+			c.allowAll = true
+		}
+		c.origins[origin] = true
+	}
+
+	if len(cfg.AllowMethods) > 0 {
+		c.allowMethods = strings.Join(cfg.AllowMethods, ", ")
+	}
+
+	if len(cfg.AllowHeaders) > 0 {
+		c.allowHeaders = strings.Join(cfg.AllowHeaders, ", ")
+	}
+
+	return c.handle
+}
+
+func (c *corsWithConfig) handle(rw http.ResponseWriter, req *http.Request) *Response {
+	origin := req.Header.Get("Origin")
+
+	// Origin header not provided, nothing for CORS to do
+	if origin == "" {
+		return nil
+	}
+
+	if !c.allowAll && !c.origins[origin] {
+		return nil
+	}
+
+	if c.allowAll && !c.allowCredentials {
+		rw.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		rw.Header().Set("Access-Control-Allow-Origin", origin)
+		rw.Header().Add("Vary", "Origin")
+	}
+
+	if c.allowCredentials {
+		rw.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	// If this was a preflight request, stop further middleware execution
+	if req.Method == "OPTIONS" {
+		rw.Header().Set("Access-Control-Allow-Methods", c.allowMethods)
+		rw.Header().Set("Access-Control-Allow-Headers", c.allowHeaders)
+
+		return &Response{
+			StatusCode:    http.StatusOK,
+			StopExecution: true,
+		}
+	}
+
+	return nil
+}
@@ -4,7 +4,6 @@ import (
 	"net/http"
 	"net/http/httptest"
 
-	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
@@ -73,4 +72,107 @@ var _ = Describe("CORS Middleware", func() {
 			})
 		})
 	})
+
+	Describe("handle with config", func() {
+		var config CORSConfig
+
+		BeforeEach(func() {
+			config = CORSConfig{
+				AllowOrigins: []string{"https://a.invisionapp.com", "https://b.invisionapp.com"},
+				AllowMethods: []string{"GET", "PUT"},
+				AllowHeaders: []string{"Content-Type", "Authorization"},
+			}
+		})
+
+		Context("when origin header is not set", func() {
+			It("should return nil without setting headers", func() {
+				resp := NewMiddlewareCORSWithConfig(config)(response, request)
+				Expect(resp).To(BeNil())
+				Expect(response.Header().Get("Access-Control-Allow-Origin")).To(BeEmpty())
+			})
+		})
+
+		Context("when the origin is in the allowed list", func() {
+			BeforeEach(func() {
+				request.Header.Add("Origin", "https://b.invisionapp.com")
+			})
+
+			It("should echo the origin back and carry on", func() {
+				resp := NewMiddlewareCORSWithConfig(config)(response, request)
+
+				Expect(resp).To(BeNil())
+				Expect(response.Header().Get("Access-Control-Allow-Origin")).To(Equal("https://b.invisionapp.com"))
+				Expect(response.Header().Get("Vary")).To(Equal("Origin"))
+				Expect(response.Header().Get("Access-Control-Allow-Methods")).To(BeEmpty())
+				Expect(response.Header().Get("Access-Control-Allow-Credentials")).To(BeEmpty())
+			})
+
+			It("should answer preflight requests with a 200 and stop execution", func() {
+				request.Method = "OPTIONS"
+
+				resp := NewMiddlewareCORSWithConfig(config)(response, request)
+
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StopExecution).To(BeTrue())
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(response.Header().Get("Access-Control-Allow-Origin")).To(Equal("https://b.invisionapp.com"))
+				Expect(response.Header().Get("Access-Control-Allow-Methods")).To(Equal("GET, PUT"))
+				Expect(response.Header().Get("Access-Control-Allow-Headers")).To(Equal("Content-Type, Authorization"))
+			})
+
+			It("should allow credentials if configured", func() {
+				config.AllowCredentials = true
+
+				NewMiddlewareCORSWithConfig(config)(response, request)
+
+				Expect(response.Header().Get("Access-Control-Allow-Credentials")).To(Equal("true"))
+			})
+		})
+
+		Context("when the origin is not allowed", func() {
+			It("should not set any CORS headers", func() {
+				request.Method = "OPTIONS"
+				request.Header.Add("Origin", "https://evil.com")
+
+				resp := NewMiddlewareCORSWithConfig(config)(response, request)
+
+				Expect(resp).To(BeNil())
+				Expect(response.Header().Get("Access-Control-Allow-Origin")).To(BeEmpty())
+			})
+		})
+
+		Context("when any origin is allowed", func() {
+			BeforeEach(func() {
+				config.AllowOrigins = []string{"*"}
+				request.Header.Add("Origin", "https://evil.com")
+			})
+
+			It("should return the wildcard", func() {
+				NewMiddlewareCORSWithConfig(config)(response, request)
+
+				Expect(response.Header().Get("Access-Control-Allow-Origin")).To(Equal("*"))
+			})
+
+			It("should echo the origin back when credentials are allowed", func() {
+				config.AllowCredentials = true
+
+				NewMiddlewareCORSWithConfig(config)(response, request)
+
+				Expect(response.Header().Get("Access-Control-Allow-Origin")).To(Equal("https://evil.com"))
+			})
+		})
+
+		Context("when instantiated with an empty config", func() {
+			It("should use the defaults", func() {
+				request.Method = "OPTIONS"
+				request.Header.Add("Origin", "https://a.invisionapp.com")
+
+				NewMiddlewareCORSWithConfig(CORSConfig{})(response, request)
+
+				Expect(response.Header().Get("Access-Control-Allow-Origin")).To(Equal(DEFAULT_CORS_ALLOW_ORIGIN))
+				Expect(response.Header().Get("Access-Control-Allow-Methods")).To(Equal(DEFAULT_CORS_ALLOW_METHODS))
+				Expect(response.Header().Get("Access-Control-Allow-Headers")).To(Equal(DEFAULT_CORS_ALLOW_HEADERS))
+			})
+		})
+	})
 })
@@ -0,0 +1,155 @@
+package rye
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// Remaining time of the request, in the gRPC format (ie. `500m` for 500ms)
+	GRPC_TIMEOUT_HEADER = "grpc-timeout"
+
+	// Absolute deadline of the request, as an RFC 3339 timestamp
+	DEADLINE_HEADER = "X-Deadline"
+
+	// Largest value of a gRPC timeout
+	grpcTimeoutMaxValue = 99999999
+)
+
+// grpcTimeoutUnits are the gRPC timeout units, from the finest to the coarsest
+var grpcTimeoutUnits = []struct {
+	unit     byte
+	duration time.Duration
+}{
+	{'n', time.Nanosecond},
+	{'u', time.Microsecond},
+	{'m', time.Millisecond},
+	{'S', time.Second},
+	{'M', time.Minute},
+	{'H', time.Hour},
+}
+
+/*
+NewMiddlewareDeadline creates a new handler that carries the deadline of the caller over to this service:
+the deadline is read from the `grpc-timeout` header (the time the caller has left, ie. `500m`) or else from
+the `X-Deadline` header (an RFC 3339 timestamp) and set on the request context. Like NewMiddlewareTimeout,
+the chain stops once the deadline passes and a 503 is written unless a response was already started.
+Requests without a (well-formed) deadline header are left alone.
+
+To pass the deadline on to the services called in turn, set the headers of the outgoing requests with
+`rye.PropagateDeadline`.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareDeadline(),
+			yourHandler,
+		})).Methods("GET")
+
+	func yourHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		req, _ := http.NewRequestWithContext(r.Context(), "GET", "http://downstream/items", nil)
+		rye.PropagateDeadline(r.Context(), req.Header)
+		...
+	}
+*/
+func NewMiddlewareDeadline() func(rw http.ResponseWriter, req *http.Request) *Response {
+	return handleDeadline
+}
+
+func handleDeadline(rw http.ResponseWriter, r *http.Request) *Response {
+	chain := chainFromRequest(r)
+	if chain == nil {
+		// Without a chain there is nothing to cancel the context when the request is done
+		return nil
+	}
+
+	deadline, ok := deadlineFromHeaders(r.Header, time.Now())
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithDeadline(r.Context(), deadline)
+	chain.deadline = ctx
+
+	return &Response{
+		Context: ctx,
+		Writer: &timeoutWriter{
+			ResponseWriter: rw,
+			ctx:            ctx,
+			cancel:         cancel,
+		},
+	}
+}
+
+// deadlineFromHeaders returns the deadline set by the grpc-timeout header or else the X-Deadline header
+func deadlineFromHeaders(header http.Header, now time.Time) (time.Time, bool) {
+	if timeout, ok := parseGRPCTimeout(header.Get(GRPC_TIMEOUT_HEADER)); ok {
+		return now.Add(timeout), true
+	}
+
+	if deadline, err := time.Parse(time.RFC3339Nano, header.Get(DEADLINE_HEADER)); err == nil {
+		return deadline, true
+	}
+
+	return time.Time{}, false
+}
+
+// parseGRPCTimeout parses a gRPC timeout: up to 8 digits followed by a unit
+func parseGRPCTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+
+	digits, unit := value[:len(value)-1], value[len(value)-1]
+
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	for _, u := range grpcTimeoutUnits {
+		if u.unit == unit {
+			return time.Duration(n) * u.duration, true
+		}
+	}
+
+	return 0, false
+}
+
+// formatGRPCTimeout formats a duration as a gRPC timeout, in the finest unit it fits in
+// (rounding up so a timeout is never shortened)
+func formatGRPCTimeout(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+
+	for _, u := range grpcTimeoutUnits {
+		value := (d + u.duration - 1) / u.duration
+		if value <= grpcTimeoutMaxValue {
+			return strconv.FormatInt(int64(value), 10) + string(u.unit)
+		}
+	}
+
+	return strconv.Itoa(grpcTimeoutMaxValue) + "H"
+}
+
+// PropagateDeadline sets the deadline of the context (if any) on the headers of an outgoing
+// request, both as the remaining time (`grpc-timeout`) and as an absolute time (`X-Deadline`).
+func PropagateDeadline(ctx context.Context, header http.Header) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+
+	header.Set(GRPC_TIMEOUT_HEADER, formatGRPCTimeout(time.Until(deadline)))
+	header.Set(DEADLINE_HEADER, deadline.UTC().Format(time.RFC3339Nano))
+}
@@ -0,0 +1,162 @@
+package rye
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Deadline Middleware", func() {
+
+	var (
+		request     *http.Request
+		response    *httptest.ResponseRecorder
+		mwHandler   *MWHandler
+		deadline    time.Time
+		hasDeadline bool
+	)
+
+	// deadlineHandler records the deadline of the request context
+	deadlineHandler := func(rw http.ResponseWriter, r *http.Request) *Response {
+		deadline, hasDeadline = r.Context().Deadline()
+		return nil
+	}
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("GET", "/", nil)
+		response = httptest.NewRecorder()
+		mwHandler = NewMWHandler(Config{})
+		deadline, hasDeadline = time.Time{}, false
+	})
+
+	Describe("handle", func() {
+		It("should set the deadline of a grpc-timeout header on the context", func() {
+			request.Header.Set(GRPC_TIMEOUT_HEADER, "2S")
+
+			mwHandler.Handle([]Handler{NewMiddlewareDeadline(), deadlineHandler}).ServeHTTP(response, request)
+
+			Expect(hasDeadline).To(BeTrue())
+			Expect(deadline).To(BeTemporally("~", time.Now().Add(2*time.Second), 100*time.Millisecond))
+		})
+
+		It("should set the deadline of an X-Deadline header on the context", func() {
+			expected := time.Now().Add(time.Minute).UTC().Truncate(time.Millisecond)
+			request.Header.Set(DEADLINE_HEADER, expected.Format(time.RFC3339Nano))
+
+			mwHandler.Handle([]Handler{NewMiddlewareDeadline(), deadlineHandler}).ServeHTTP(response, request)
+
+			Expect(hasDeadline).To(BeTrue())
+			Expect(deadline.Equal(expected)).To(BeTrue())
+		})
+
+		It("should ignore malformed deadlines", func() {
+			request.Header.Set(GRPC_TIMEOUT_HEADER, "10x")
+			request.Header.Set(DEADLINE_HEADER, "tomorrow")
+
+			mwHandler.Handle([]Handler{NewMiddlewareDeadline(), deadlineHandler}).ServeHTTP(response, request)
+
+			Expect(hasDeadline).To(BeFalse())
+			Expect(response.Code).To(Equal(http.StatusOK))
+		})
+
+		It("should fall back to X-Deadline when grpc-timeout is malformed", func() {
+			request.Header.Set(GRPC_TIMEOUT_HEADER, "123456789S")
+			request.Header.Set(DEADLINE_HEADER, time.Now().Add(time.Minute).Format(time.RFC3339Nano))
+
+			mwHandler.Handle([]Handler{NewMiddlewareDeadline(), deadlineHandler}).ServeHTTP(response, request)
+
+			Expect(hasDeadline).To(BeTrue())
+			Expect(deadline).To(BeTemporally("~", time.Now().Add(time.Minute), time.Second))
+		})
+
+		It("should stop the chain with a 503 once the deadline passed", func() {
+			request.Header.Set(GRPC_TIMEOUT_HEADER, "10m")
+
+			mwHandler.Handle([]Handler{NewMiddlewareDeadline(), slowHandler, textHandler}).ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(response.Body.String()).To(ContainSubstring("Request timed out"))
+		})
+
+		Context("when used outside of a chain", func() {
+			It("should return nil", func() {
+				request.Header.Set(GRPC_TIMEOUT_HEADER, "2S")
+				Expect(NewMiddlewareDeadline()(response, request)).To(BeNil())
+			})
+		})
+	})
+
+	Describe("parseGRPCTimeout", func() {
+		It("should parse every unit", func() {
+			for value, expected := range map[string]time.Duration{
+				"1H":  time.Hour,
+				"2M":  2 * time.Minute,
+				"3S":  3 * time.Second,
+				"40m": 40 * time.Millisecond,
+				"50u": 50 * time.Microsecond,
+				"60n": 60 * time.Nanosecond,
+			} {
+				timeout, ok := parseGRPCTimeout(value)
+				Expect(ok).To(BeTrue())
+				Expect(timeout).To(Equal(expected))
+			}
+		})
+
+		It("should reject malformed timeouts", func() {
+			for _, value := range []string{"", "S", "10", "-1S", "1.5S", "123456789S", "10s"} {
+				_, ok := parseGRPCTimeout(value)
+				Expect(ok).To(BeFalse(), value)
+			}
+		})
+	})
+
+	Describe("PropagateDeadline", func() {
+		It("should set the remaining time and the deadline on the headers", func() {
+			deadline := time.Now().Add(1500 * time.Millisecond)
+			ctx, cancel := context.WithDeadline(context.Background(), deadline)
+			defer cancel()
+
+			header := http.Header{}
+			PropagateDeadline(ctx, header)
+
+			remaining, ok := parseGRPCTimeout(header.Get(GRPC_TIMEOUT_HEADER))
+			Expect(ok).To(BeTrue())
+			Expect(remaining).To(BeNumerically("~", 1500*time.Millisecond, 100*time.Millisecond))
+
+			propagated, err := time.Parse(time.RFC3339Nano, header.Get(DEADLINE_HEADER))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(propagated.Equal(deadline)).To(BeTrue())
+		})
+
+		It("should leave the headers alone without a deadline", func() {
+			header := http.Header{}
+			PropagateDeadline(context.Background(), header)
+
+			Expect(header).To(BeEmpty())
+		})
+
+		It("should round trip the deadline through the middleware", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+
+			PropagateDeadline(ctx, request.Header)
+			mwHandler.Handle([]Handler{NewMiddlewareDeadline(), deadlineHandler}).ServeHTTP(response, request)
+
+			expected, _ := ctx.Deadline()
+			Expect(hasDeadline).To(BeTrue())
+			Expect(deadline).To(BeTemporally("~", expected, 50*time.Millisecond))
+		})
+	})
+
+	Describe("formatGRPCTimeout", func() {
+		It("should use the finest unit the timeout fits in", func() {
+			Expect(formatGRPCTimeout(50 * time.Millisecond)).To(Equal("50000000n"))
+			Expect(formatGRPCTimeout(2 * time.Second)).To(Equal("2000000u"))
+			Expect(formatGRPCTimeout(2 * time.Hour)).To(Equal("7200000m"))
+			Expect(formatGRPCTimeout(-time.Second)).To(Equal("0n"))
+		})
+	})
+})
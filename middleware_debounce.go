@@ -0,0 +1,148 @@
+package rye
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_DEBOUNCE_WINDOW              = time.Second
+	DEFAULT_DEBOUNCE_MAX_BODY_SIZE int64 = 64 << 10 // 64KB
+)
+
+// DebounceConfig configures the write debounce middleware.
+type DebounceConfig struct {
+	// Window during which identical writes are collapsed (defaults to DEFAULT_DEBOUNCE_WINDOW)
+	Window time.Duration
+
+	// KeyFunc identifies "identical" requests; defaults to client IP + method + URL + body hash.
+	// Requests it returns an empty key for are let through without being debounced.
+	KeyFunc func(r *http.Request) string
+
+	// MaxBodySize caps how much of the body the default KeyFunc reads to hash it, in bytes
+	// (defaults to DEFAULT_DEBOUNCE_MAX_BODY_SIZE); larger requests are not debounced
+	MaxBodySize int64
+
+	// StatusCode returned for collapsed requests (defaults to 202)
+	StatusCode int
+}
+
+type writeDebounce struct {
+	config DebounceConfig
+
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+/*
+NewMiddlewareWriteDebounce creates a new handler that collapses identical write requests (POST, PUT,
+PATCH and DELETE) arriving within a short window, protecting backends from clients that spam the same
+update. The first request goes through; repeats within the window are answered straight away with the
+configured status code (202 by default) without running the rest of the chain.
+
+By default, requests are told apart by a hash of their body. So that clients can't make the server buffer
+arbitrarily large bodies, only up to `MaxBodySize` bytes are read; larger requests are never collapsed.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareWriteDebounce(rye.DebounceConfig{Window: 2 * time.Second}),
+			yourHandler,
+		})).Methods("POST")
+*/
+func NewMiddlewareWriteDebounce(cfg DebounceConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if cfg.Window <= 0 {
+		cfg.Window = DEFAULT_DEBOUNCE_WINDOW
+	}
+
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = DEFAULT_DEBOUNCE_MAX_BODY_SIZE
+	}
+
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = debounceKey(cfg.MaxBodySize)
+	}
+
+	if cfg.StatusCode == 0 {
+		cfg.StatusCode = http.StatusAccepted
+	}
+
+	d := &writeDebounce{
+		config: cfg,
+		seen:   make(map[string]time.Time),
+	}
+
+	return d.handle
+}
+
+func (d *writeDebounce) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return nil
+	}
+
+	key := d.config.KeyFunc(r)
+	if key == "" {
+		return nil
+	}
+
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.prune(now)
+
+	if last, ok := d.seen[key]; ok && now.Sub(last) < d.config.Window {
+		return &Response{
+			StatusCode:    d.config.StatusCode,
+			StopExecution: true,
+		}
+	}
+
+	d.seen[key] = now
+
+	return nil
+}
+
+// prune drops expired keys, at most once per window; must be called with the lock held
+func (d *writeDebounce) prune(now time.Time) {
+	if now.Sub(d.lastPrune) < d.config.Window {
+		return
+	}
+
+	for key, last := range d.seen {
+		if now.Sub(last) >= d.config.Window {
+			delete(d.seen, key)
+		}
+	}
+
+	d.lastPrune = now
+}
+
+// debounceKey returns the default key: client IP, method, URL and a hash of the body, or an
+// empty key (no debouncing) for bodies larger than maxBodySize
+func debounceKey(maxBodySize int64) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+
+		body, err := peekBody(r, maxBodySize+1)
+		if err != nil || int64(len(body)) > maxBodySize {
+			return ""
+		}
+
+		sum := sha256.Sum256(body)
+
+		return host + " " + r.Method + " " + r.URL.String() + " " + hex.EncodeToString(sum[:])
+	}
+}
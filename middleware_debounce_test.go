@@ -0,0 +1,131 @@
+package rye
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+// countingReader counts the bytes read from the wrapped reader
+type countingReader struct {
+	io.Reader
+	read int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.read += n
+	return n, err
+}
+
+var _ = Describe("Write Debounce Middleware", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+		handler  func(rw http.ResponseWriter, req *http.Request) *Response
+	)
+
+	newRequest := func(method, body string) *http.Request {
+		request := httptest.NewRequest(method, "/items/1", strings.NewReader(body))
+		request.RemoteAddr = "10.0.0.1:1234"
+		return request
+	}
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		handler = NewMiddlewareWriteDebounce(DebounceConfig{Window: 50 * time.Millisecond})
+	})
+
+	Describe("handle", func() {
+		Context("when identical writes arrive within the window", func() {
+			It("should let the first through and collapse the rest", func() {
+				Expect(handler(response, newRequest("PUT", `{"a":1}`))).To(BeNil())
+
+				resp := handler(response, newRequest("PUT", `{"a":1}`))
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusAccepted))
+				Expect(resp.StopExecution).To(BeTrue())
+			})
+
+			It("should leave the body readable for the first request", func() {
+				request := newRequest("PUT", `{"a":1}`)
+				Expect(handler(response, request)).To(BeNil())
+
+				body, err := ioutil.ReadAll(request.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal(`{"a":1}`))
+			})
+		})
+
+		Context("when the body is larger than MaxBodySize", func() {
+			BeforeEach(func() {
+				handler = NewMiddlewareWriteDebounce(DebounceConfig{Window: 50 * time.Millisecond, MaxBodySize: 4})
+			})
+
+			It("should never collapse it", func() {
+				Expect(handler(response, newRequest("PUT", `{"a":1}`))).To(BeNil())
+				Expect(handler(response, newRequest("PUT", `{"a":1}`))).To(BeNil())
+			})
+
+			It("should only read up to the cap, leaving the full body readable", func() {
+				request := newRequest("PUT", `{"a":1}`)
+				source := &countingReader{Reader: request.Body}
+				request.Body = ioutil.NopCloser(source)
+
+				Expect(handler(response, request)).To(BeNil())
+				Expect(source.read).To(BeNumerically("<=", 5))
+
+				body, err := ioutil.ReadAll(request.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal(`{"a":1}`))
+			})
+
+			It("should still collapse bodies up to the cap", func() {
+				Expect(handler(response, newRequest("PUT", `{}`))).To(BeNil())
+				Expect(handler(response, newRequest("PUT", `{}`))).ToNot(BeNil())
+			})
+		})
+
+		Context("when writes differ", func() {
+			It("should let both through", func() {
+				Expect(handler(response, newRequest("PUT", `{"a":1}`))).To(BeNil())
+				Expect(handler(response, newRequest("PUT", `{"a":2}`))).To(BeNil())
+			})
+		})
+
+		Context("when the window has passed", func() {
+			It("should let the repeated write through", func() {
+				Expect(handler(response, newRequest("PUT", `{"a":1}`))).To(BeNil())
+				time.Sleep(60 * time.Millisecond)
+				Expect(handler(response, newRequest("PUT", `{"a":1}`))).To(BeNil())
+			})
+		})
+
+		Context("when the request is not a write", func() {
+			It("should never collapse it", func() {
+				Expect(handler(response, newRequest("GET", ""))).To(BeNil())
+				Expect(handler(response, newRequest("GET", ""))).To(BeNil())
+			})
+		})
+
+		Context("when configured with a custom key and status", func() {
+			It("should use them", func() {
+				handler = NewMiddlewareWriteDebounce(DebounceConfig{
+					KeyFunc:    func(r *http.Request) string { return r.URL.Path },
+					StatusCode: http.StatusOK,
+				})
+
+				Expect(handler(response, newRequest("POST", `{"a":1}`))).To(BeNil())
+
+				resp := handler(response, newRequest("POST", `{"a":2}`))
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			})
+		})
+	})
+})
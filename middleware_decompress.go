@@ -0,0 +1,194 @@
+package rye
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	DEFAULT_DECOMPRESS_MAX_SIZE int64 = 10 << 20 // 10MB
+)
+
+// ErrDecompressedBodyTooLarge is returned when reading a decompressed request body past its size cap
+var ErrDecompressedBodyTooLarge = errors.New("Decompressed request body too large")
+
+// Decoder wraps a compressed request body with a reader decompressing it.
+type Decoder func(body io.Reader) (io.ReadCloser, error)
+
+// DecompressConfig configures the decompression middleware.
+type DecompressConfig struct {
+	// MaxSize caps the decompressed size of the body in bytes
+	// (defaults to DEFAULT_DECOMPRESS_MAX_SIZE)
+	MaxSize int64
+
+	// Decoders adds (or overrides) decoders by content encoding; gzip and deflate are
+	// supported out of the box, br (brotli) only once a decoder is added for it
+	Decoders map[string]Decoder
+}
+
+type decompress struct {
+	maxSize  int64
+	decoders map[string]Decoder
+}
+
+/*
+NewMiddlewareDecompress creates a new handler that transparently decompresses request bodies according
+to their `Content-Encoding` (`gzip` and `deflate` out of the box), so handlers always read plain bodies.
+Requests with an unsupported encoding are rejected with a 415 and malformed compressed bodies with a 400.
+
+To guard against decompression bombs, reading more than `MaxSize` decompressed bytes fails with
+`rye.ErrDecompressedBodyTooLarge`.
+
+Brotli is not supported out of the box: the standard library has no decoder for it and rye doesn't pull in
+one, so `br` bodies are rejected with a 415 by default. To accept them, plug a decoder in (ie. from
+`github.com/andybalholm/brotli`):
+
+	rye.NewMiddlewareDecompress(rye.DecompressConfig{
+		Decoders: map[string]rye.Decoder{
+			"br": func(body io.Reader) (io.ReadCloser, error) {
+				return io.NopCloser(brotli.NewReader(body)), nil
+			},
+		},
+	})
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareDecompress(rye.DecompressConfig{MaxSize: 1 << 20}),
+			yourHandler,
+		})).Methods("POST")
+*/
+func NewMiddlewareDecompress(cfg DecompressConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DEFAULT_DECOMPRESS_MAX_SIZE
+	}
+
+	d := &decompress{
+		maxSize: cfg.MaxSize,
+		decoders: map[string]Decoder{
+			"gzip": func(body io.Reader) (io.ReadCloser, error) {
+				return gzip.NewReader(body)
+			},
+			"deflate": func(body io.Reader) (io.ReadCloser, error) {
+				return zlib.NewReader(body)
+			},
+		},
+	}
+
+	for encoding, decoder := range cfg.Decoders {
+		d.decoders[strings.ToLower(encoding)] = decoder
+	}
+
+	return d.handle
+}
+
+func (d *decompress) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	encodings := contentEncodings(r.Header.Get("Content-Encoding"))
+	if len(encodings) == 0 || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	for _, encoding := range encodings {
+		if _, ok := d.decoders[encoding]; !ok {
+			return &Response{
+				Err:        fmt.Errorf("Unsupported content encoding '%s'", encoding),
+				StatusCode: http.StatusUnsupportedMediaType,
+			}
+		}
+	}
+
+	body := &decompressedBody{closers: []io.Closer{r.Body}}
+	reader := io.Reader(r.Body)
+
+	// Encodings are listed in the order they were applied, so undo them last to first
+	for i := len(encodings) - 1; i >= 0; i-- {
+		decoded, err := d.decoders[encodings[i]](reader)
+		if err != nil {
+			body.Close()
+
+			return &Response{
+				Err:        fmt.Errorf("Unable to decompress '%s' request body: %v", encodings[i], err),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		body.closers = append(body.closers, decoded)
+		reader = decoded
+	}
+
+	body.Reader = &cappedReader{Reader: reader, remaining: d.maxSize}
+
+	r.Body = body
+	r.ContentLength = -1
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+
+	return nil
+}
+
+// contentEncodings returns the lowercased encodings of a Content-Encoding header, without `identity`
+func contentEncodings(header string) []string {
+	var encodings []string
+
+	for _, encoding := range strings.Split(header, ",") {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if encoding != "" && encoding != "identity" {
+			encodings = append(encodings, encoding)
+		}
+	}
+
+	return encodings
+}
+
+// decompressedBody reads the decompressed body and closes every decoder along with the original body
+type decompressedBody struct {
+	io.Reader
+
+	closers []io.Closer
+}
+
+func (d *decompressedBody) Close() error {
+	var firstErr error
+
+	for i := len(d.closers) - 1; i >= 0; i-- {
+		if err := d.closers[i].Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// cappedReader fails with ErrDecompressedBodyTooLarge once more than `remaining` bytes are read
+type cappedReader struct {
+	io.Reader
+
+	remaining int64
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	if c.remaining <= 0 {
+		// Only fail if there actually is more data past the cap
+		var probe [1]byte
+		if n, err := c.Reader.Read(probe[:]); n == 0 {
+			return 0, err
+		}
+
+		return 0, ErrDecompressedBodyTooLarge
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+
+	n, err := c.Reader.Read(p)
+	c.remaining -= int64(n)
+
+	return n, err
+}
@@ -0,0 +1,149 @@
+package rye
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Decompress Middleware", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+		config   DecompressConfig
+	)
+
+	gzipped := func(body string) []byte {
+		buf := &bytes.Buffer{}
+		w := gzip.NewWriter(buf)
+		w.Write([]byte(body))
+		w.Close()
+		return buf.Bytes()
+	}
+
+	deflated := func(body string) []byte {
+		buf := &bytes.Buffer{}
+		w := zlib.NewWriter(buf)
+		w.Write([]byte(body))
+		w.Close()
+		return buf.Bytes()
+	}
+
+	newRequest := func(body []byte, encoding string) *http.Request {
+		request := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+		request.Header.Set("Content-Encoding", encoding)
+		return request
+	}
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		config = DecompressConfig{
+			Decoders: map[string]Decoder{
+				// stands in for a brotli decoder
+				"br": func(body io.Reader) (io.ReadCloser, error) {
+					return ioutil.NopCloser(base64.NewDecoder(base64.StdEncoding, body)), nil
+				},
+			},
+		}
+	})
+
+	Describe("handle", func() {
+		It("should decompress gzip bodies", func() {
+			request := newRequest(gzipped("hello gzip"), "gzip")
+
+			resp := NewMiddlewareDecompress(config)(response, request)
+			Expect(resp).To(BeNil())
+
+			body, err := ioutil.ReadAll(request.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("hello gzip"))
+			Expect(request.Header.Get("Content-Encoding")).To(BeEmpty())
+			Expect(request.ContentLength).To(Equal(int64(-1)))
+			Expect(request.Body.Close()).To(Succeed())
+		})
+
+		It("should decompress deflate bodies", func() {
+			request := newRequest(deflated("hello deflate"), "Deflate")
+
+			Expect(NewMiddlewareDecompress(config)(response, request)).To(BeNil())
+
+			body, err := ioutil.ReadAll(request.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("hello deflate"))
+		})
+
+		It("should decompress brotli bodies with a configured decoder", func() {
+			request := newRequest([]byte(base64.StdEncoding.EncodeToString([]byte("hello br"))), "br")
+
+			Expect(NewMiddlewareDecompress(config)(response, request)).To(BeNil())
+
+			body, err := ioutil.ReadAll(request.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("hello br"))
+		})
+
+		It("should undo multiple encodings in reverse order", func() {
+			request := newRequest(gzipped(string(deflated("hello both"))), "deflate, gzip")
+
+			Expect(NewMiddlewareDecompress(config)(response, request)).To(BeNil())
+
+			body, err := ioutil.ReadAll(request.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("hello both"))
+		})
+
+		It("should leave uncompressed bodies alone", func() {
+			request := newRequest([]byte("plain"), "identity")
+
+			Expect(NewMiddlewareDecompress(config)(response, request)).To(BeNil())
+
+			body, _ := ioutil.ReadAll(request.Body)
+			Expect(string(body)).To(Equal("plain"))
+		})
+
+		It("should reject brotli bodies with a 415 unless a decoder is plugged in", func() {
+			resp := NewMiddlewareDecompress(DecompressConfig{})(response, newRequest([]byte("data"), "br"))
+
+			Expect(resp).ToNot(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusUnsupportedMediaType))
+			Expect(resp.Error()).To(ContainSubstring("'br'"))
+		})
+
+		It("should reject malformed bodies with a 400", func() {
+			resp := NewMiddlewareDecompress(config)(response, newRequest([]byte("not gzip"), "gzip"))
+
+			Expect(resp).ToNot(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should fail reads past the decompressed size cap", func() {
+			config.MaxSize = 10
+			request := newRequest(gzipped(strings.Repeat("a", 100)), "gzip")
+
+			Expect(NewMiddlewareDecompress(config)(response, request)).To(BeNil())
+
+			body, err := ioutil.ReadAll(request.Body)
+			Expect(err).To(Equal(ErrDecompressedBodyTooLarge))
+			Expect(body).To(HaveLen(10))
+		})
+
+		It("should allow bodies exactly at the cap", func() {
+			config.MaxSize = 10
+			request := newRequest(gzipped(strings.Repeat("a", 10)), "gzip")
+
+			Expect(NewMiddlewareDecompress(config)(response, request)).To(BeNil())
+
+			body, err := ioutil.ReadAll(request.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(body).To(HaveLen(10))
+		})
+	})
+})
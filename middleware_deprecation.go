@@ -0,0 +1,122 @@
+package rye
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeprecatedEndpoint describes the lifecycle of a deprecated endpoint.
+type DeprecatedEndpoint struct {
+	// Path is the path prefix of the endpoint; the most specific (longest) matching prefix wins
+	Path string
+
+	// DeprecatedAt is when the endpoint was deprecated; when zero, the endpoint is
+	// only flagged as deprecated (`Deprecation: true`)
+	DeprecatedAt time.Time
+
+	// Sunset is when the endpoint goes away; from then on its requests get a 410.
+	// When zero, the endpoint is only ever deprecated.
+	Sunset time.Time
+
+	// Link, when set, points clients to documentation about the deprecation
+	Link string
+}
+
+// DeprecationConfig configures the deprecation middleware.
+type DeprecationConfig struct {
+	Endpoints []DeprecatedEndpoint
+
+	// Now returns the current time (defaults to time.Now)
+	Now func() time.Time
+}
+
+type deprecation struct {
+	endpoints []DeprecatedEndpoint
+	now       func() time.Time
+}
+
+/*
+NewMiddlewareDeprecation creates a new handler that lets clients of deprecated endpoints know about it: requests
+to the configured paths get a `Deprecation` header (and `Sunset` and `Link` headers when set) and carry on as
+usual. Once the sunset has passed, requests are stopped with a 410 (Gone) instead.
+
+Example usage:
+
+	routes.PathPrefix("/").Handler(a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareDeprecation(rye.DeprecationConfig{
+				Endpoints: []rye.DeprecatedEndpoint{{
+					Path:         "/v1/",
+					DeprecatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+					Sunset:       time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+					Link:         "https://example.com/docs/v2-migration",
+				}},
+			}),
+			yourHandler,
+		}))
+*/
+func NewMiddlewareDeprecation(cfg DeprecationConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	d := &deprecation{
+		endpoints: append([]DeprecatedEndpoint(nil), cfg.Endpoints...),
+		now:       cfg.Now,
+	}
+
+	// Most specific paths first, so matching is deterministic
+	sort.SliceStable(d.endpoints, func(i, j int) bool {
+		return len(d.endpoints[i].Path) > len(d.endpoints[j].Path)
+	})
+
+	return d.handle
+}
+
+func (d *deprecation) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	endpoint, ok := d.endpointFor(r.URL.Path)
+	if !ok {
+		return nil
+	}
+
+	header := rw.Header()
+
+	if endpoint.DeprecatedAt.IsZero() {
+		header.Set("Deprecation", "true")
+	} else {
+		header.Set("Deprecation", "@"+strconv.FormatInt(endpoint.DeprecatedAt.Unix(), 10))
+	}
+
+	if !endpoint.Sunset.IsZero() {
+		header.Set("Sunset", endpoint.Sunset.UTC().Format(http.TimeFormat))
+	}
+
+	if endpoint.Link != "" {
+		header.Add("Link", "<"+endpoint.Link+`>; rel="deprecation"`)
+	}
+
+	if !endpoint.Sunset.IsZero() && !d.now().Before(endpoint.Sunset) {
+		return &Response{
+			Err:           fmt.Errorf("This endpoint was removed on %v", endpoint.Sunset.UTC().Format(time.RFC3339)),
+			StatusCode:    http.StatusGone,
+			StopExecution: true,
+		}
+	}
+
+	return nil
+}
+
+// endpointFor returns the endpoint of the first matching path (if any)
+func (d *deprecation) endpointFor(path string) (DeprecatedEndpoint, bool) {
+	for _, endpoint := range d.endpoints {
+		if strings.HasPrefix(path, endpoint.Path) {
+			return endpoint, true
+		}
+	}
+
+	return DeprecatedEndpoint{}, false
+}
@@ -0,0 +1,105 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Deprecation Middleware", func() {
+
+	var (
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		now       time.Time
+		cfg       DeprecationConfig
+		ran       bool
+	)
+
+	deprecatedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		mwHandler = NewMWHandler(Config{})
+		now = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+		ran = false
+
+		cfg = DeprecationConfig{
+			Endpoints: []DeprecatedEndpoint{
+				{Path: "/v1/", DeprecatedAt: deprecatedAt, Sunset: sunset, Link: "https://example.com/migrate"},
+				{Path: "/v1/legacy", DeprecatedAt: deprecatedAt},
+			},
+			Now: func() time.Time { return now },
+		}
+	})
+
+	serve := func(path string) {
+		h := mwHandler.Handle([]Handler{NewMiddlewareDeprecation(cfg), func(rw http.ResponseWriter, r *http.Request) *Response {
+			ran = true
+			return nil
+		}})
+		h.ServeHTTP(response, httptest.NewRequest("GET", path, nil))
+	}
+
+	Describe("handle", func() {
+		Context("before the sunset", func() {
+			It("should warn clients and let the request through", func() {
+				serve("/v1/items")
+
+				Expect(ran).To(BeTrue())
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(response.Header().Get("Deprecation")).To(Equal("@1704067200"))
+				Expect(response.Header().Get("Sunset")).To(Equal("Mon, 01 Jul 2024 00:00:00 GMT"))
+				Expect(response.Header().Get("Link")).To(Equal(`<https://example.com/migrate>; rel="deprecation"`))
+			})
+		})
+
+		Context("after the sunset", func() {
+			It("should stop the request with a 410", func() {
+				now = sunset
+
+				serve("/v1/items")
+
+				Expect(ran).To(BeFalse())
+				Expect(response.Code).To(Equal(http.StatusGone))
+				Expect(response.Header().Get("Sunset")).To(Equal("Mon, 01 Jul 2024 00:00:00 GMT"))
+				Expect(response.Body.String()).To(ContainSubstring("removed on 2024-07-01T00:00:00Z"))
+			})
+		})
+
+		Context("when the endpoint has no sunset", func() {
+			It("should only ever warn clients", func() {
+				now = sunset.AddDate(1, 0, 0)
+
+				serve("/v1/legacy/items")
+
+				Expect(ran).To(BeTrue())
+				Expect(response.Header().Get("Deprecation")).To(Equal("@1704067200"))
+				Expect(response.Header().Get("Sunset")).To(BeEmpty())
+				Expect(response.Header().Get("Link")).To(BeEmpty())
+			})
+		})
+
+		Context("when the endpoint has no deprecation date", func() {
+			It("should flag it as deprecated", func() {
+				cfg.Endpoints = []DeprecatedEndpoint{{Path: "/old"}}
+
+				serve("/old")
+
+				Expect(response.Header().Get("Deprecation")).To(Equal("true"))
+			})
+		})
+
+		Context("when the endpoint is not deprecated", func() {
+			It("should leave the request alone", func() {
+				serve("/v2/items")
+
+				Expect(ran).To(BeTrue())
+				Expect(response.Header().Get("Deprecation")).To(BeEmpty())
+			})
+		})
+	})
+})
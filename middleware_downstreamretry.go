@@ -0,0 +1,173 @@
+package rye
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	DEFAULT_DOWNSTREAM_RETRY_ATTEMPTS = 3
+	DEFAULT_DOWNSTREAM_RETRY_BACKOFF  = 100 * time.Millisecond
+)
+
+var (
+	DEFAULT_DOWNSTREAM_RETRY_STATUSES = []int{
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+)
+
+// DownstreamRetryConfig configures the downstream retry middleware.
+type DownstreamRetryConfig struct {
+	// Handler is the downstream handler to run (and retry)
+	Handler Handler
+
+	// MaxAttempts is the total number of times Handler may run (defaults to DEFAULT_DOWNSTREAM_RETRY_ATTEMPTS)
+	MaxAttempts int
+
+	// Backoff before the first retry; it doubles on every subsequent retry
+	// (defaults to DEFAULT_DOWNSTREAM_RETRY_BACKOFF)
+	Backoff time.Duration
+
+	// RetryableStatusCodes lists the status codes that trigger a retry
+	// (defaults to DEFAULT_DOWNSTREAM_RETRY_STATUSES)
+	RetryableStatusCodes []int
+}
+
+type downstreamRetry struct {
+	config DownstreamRetryConfig
+}
+
+/*
+NewMiddlewareDownstreamRetry creates a new handler that masks transient backend failures by running
+the configured downstream handler and, if it fails with a retryable status code (502, 503 and 504
+by default), running it again with exponential backoff. The request body is buffered and replayed on
+every attempt, and the downstream response is buffered so that only the final attempt reaches the
+client. Once the attempts are exhausted the last response is returned as is: whatever the last
+attempt wrote is replayed and the *Response it returned (if any) is handed back to rye, unless it
+carries an error, in which case only the error is written out.
+
+Only idempotent methods (GET, HEAD, OPTIONS, TRACE, PUT and DELETE) are retried; other requests run
+the downstream handler exactly once.
+
+Since the downstream handler is run by the middleware itself, it should be the last one in the chain.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareJWT(secret),
+			rye.NewMiddlewareDownstreamRetry(rye.DownstreamRetryConfig{
+				Handler:     yourHandler,
+				MaxAttempts: 3,
+			}),
+		})).Methods("GET")
+*/
+func NewMiddlewareDownstreamRetry(cfg DownstreamRetryConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DEFAULT_DOWNSTREAM_RETRY_ATTEMPTS
+	}
+
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DEFAULT_DOWNSTREAM_RETRY_BACKOFF
+	}
+
+	if len(cfg.RetryableStatusCodes) == 0 {
+		cfg.RetryableStatusCodes = DEFAULT_DOWNSTREAM_RETRY_STATUSES
+	}
+
+	d := &downstreamRetry{config: cfg}
+	return d.handle
+}
+
+func (d *downstreamRetry) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	if d.config.Handler == nil {
+		return &Response{
+			Err:        fmt.Errorf("No downstream handler configured for retries"),
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+
+	if !isIdempotentMethod(r.Method) {
+		return d.config.Handler(rw, r)
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return bodyReadError(r, err)
+	}
+
+	var (
+		rec  *recordingResponseWriter
+		resp *Response
+	)
+
+	backoff := d.config.Backoff
+
+	for attempt := 1; ; attempt++ {
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		rec = newRecordingResponseWriter()
+
+		resp = d.config.Handler(rec, r)
+
+		// A status code returned in the Response wins over the one written
+		status := rec.status
+		if resp != nil && resp.StatusCode != 0 {
+			status = resp.StatusCode
+		}
+
+		if attempt >= d.config.MaxAttempts || !d.retryable(status) {
+			break
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-r.Context().Done():
+			return &Response{
+				Err:        fmt.Errorf("Request cancelled while retrying downstream: %v", r.Context().Err()),
+				StatusCode: http.StatusServiceUnavailable,
+			}
+		}
+
+		backoff *= 2
+	}
+
+	// An error takes precedence over anything the last attempt wrote
+	if resp != nil && resp.Err != nil {
+		return resp
+	}
+
+	rec.replay(rw)
+
+	// The last attempt's Response is handed back to rye as is (so its status
+	// code, headers, redirect and context apply as if it ran in the chain)
+	if resp != nil {
+		return resp
+	}
+
+	return &Response{StopExecution: true}
+}
+
+func (d *downstreamRetry) retryable(status int) bool {
+	for _, code := range d.config.RetryableStatusCodes {
+		if code == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isIdempotentMethod reports whether repeating a request with the given method is safe
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,198 @@
+package rye
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Downstream Retry Middleware", func() {
+
+	var (
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		attempts  int
+		bodies    []string
+	)
+
+	// flaky fails with a 503 for the first `failures` attempts
+	flaky := func(failures int) Handler {
+		return func(rw http.ResponseWriter, r *http.Request) *Response {
+			attempts++
+
+			body, _ := ioutil.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+
+			if attempts <= failures {
+				rw.Header().Set("X-Attempt", "failed")
+				rw.WriteHeader(http.StatusServiceUnavailable)
+				return nil
+			}
+
+			rw.WriteHeader(http.StatusOK)
+			rw.Write([]byte("ok"))
+			return nil
+		}
+	}
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		mwHandler = NewMWHandler(Config{})
+		attempts = 0
+		bodies = nil
+	})
+
+	Describe("handle", func() {
+		Context("when the downstream recovers before the attempts run out", func() {
+			It("should retry and only write the successful response", func() {
+				request := httptest.NewRequest("PUT", "/", strings.NewReader("payload"))
+
+				h := mwHandler.Handle([]Handler{NewMiddlewareDownstreamRetry(DownstreamRetryConfig{
+					Handler: flaky(2),
+					Backoff: time.Millisecond,
+				})})
+				h.ServeHTTP(response, request)
+
+				Expect(attempts).To(Equal(3))
+				Expect(bodies).To(Equal([]string{"payload", "payload", "payload"}))
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(response.Body.String()).To(Equal("ok"))
+				Expect(response.Header().Get("X-Attempt")).To(BeEmpty())
+			})
+		})
+
+		Context("when the attempts are exhausted", func() {
+			It("should return the last response", func() {
+				request := httptest.NewRequest("GET", "/", nil)
+
+				h := mwHandler.Handle([]Handler{NewMiddlewareDownstreamRetry(DownstreamRetryConfig{
+					Handler:     flaky(10),
+					MaxAttempts: 2,
+					Backoff:     time.Millisecond,
+				})})
+				h.ServeHTTP(response, request)
+
+				Expect(attempts).To(Equal(2))
+				Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+				Expect(response.Header().Get("X-Attempt")).To(Equal("failed"))
+			})
+
+			It("should return the last error response", func() {
+				request := httptest.NewRequest("GET", "/", nil)
+
+				h := mwHandler.Handle([]Handler{NewMiddlewareDownstreamRetry(DownstreamRetryConfig{
+					Handler: func(rw http.ResponseWriter, r *http.Request) *Response {
+						attempts++
+						return &Response{Err: errors.New("backend down"), StatusCode: http.StatusBadGateway}
+					},
+					Backoff: time.Millisecond,
+				})})
+				h.ServeHTTP(response, request)
+
+				Expect(attempts).To(Equal(DEFAULT_DOWNSTREAM_RETRY_ATTEMPTS))
+				Expect(response.Code).To(Equal(http.StatusBadGateway))
+				Expect(response.Body.String()).To(ContainSubstring("backend down"))
+			})
+		})
+
+		Context("when the downstream returns a Response", func() {
+			run := func(downstream Handler) {
+				request := httptest.NewRequest("GET", "/", nil)
+
+				h := mwHandler.Handle([]Handler{NewMiddlewareDownstreamRetry(DownstreamRetryConfig{
+					Handler: func(rw http.ResponseWriter, r *http.Request) *Response {
+						attempts++
+						return downstream(rw, r)
+					},
+					Backoff: time.Millisecond,
+				})})
+				h.ServeHTTP(response, request)
+			}
+
+			It("should keep its status code", func() {
+				run(func(rw http.ResponseWriter, r *http.Request) *Response {
+					return &Response{StatusCode: http.StatusNoContent}
+				})
+
+				Expect(attempts).To(Equal(1))
+				Expect(response.Code).To(Equal(http.StatusNoContent))
+			})
+
+			It("should keep its redirect and headers", func() {
+				run(func(rw http.ResponseWriter, r *http.Request) *Response {
+					return &Response{RedirectTo: "/elsewhere", Headers: http.Header{"X-Foo": []string{"bar"}}}
+				})
+
+				Expect(response.Code).To(Equal(http.StatusFound))
+				Expect(response.Header().Get("Location")).To(Equal("/elsewhere"))
+				Expect(response.Header().Get("X-Foo")).To(Equal("bar"))
+			})
+
+			It("should keep the error of a stopping error response", func() {
+				run(func(rw http.ResponseWriter, r *http.Request) *Response {
+					return &Response{Err: errors.New("backend down"), StatusCode: http.StatusBadGateway, StopExecution: true}
+				})
+
+				Expect(attempts).To(Equal(DEFAULT_DOWNSTREAM_RETRY_ATTEMPTS))
+				Expect(response.Code).To(Equal(http.StatusBadGateway))
+				Expect(response.Body.String()).To(ContainSubstring("backend down"))
+			})
+
+			It("should only write the error when the downstream wrote a body before failing", func() {
+				run(func(rw http.ResponseWriter, r *http.Request) *Response {
+					rw.Write([]byte("partial"))
+					return &Response{Err: errors.New("boom"), StatusCode: http.StatusInternalServerError}
+				})
+
+				Expect(response.Code).To(Equal(http.StatusInternalServerError))
+				Expect(response.Body.String()).ToNot(ContainSubstring("partial"))
+				Expect(response.Body.String()).To(ContainSubstring("boom"))
+			})
+		})
+
+		Context("when the status code is not retryable", func() {
+			It("should not retry", func() {
+				request := httptest.NewRequest("GET", "/", nil)
+
+				resp := NewMiddlewareDownstreamRetry(DownstreamRetryConfig{
+					Handler: func(rw http.ResponseWriter, r *http.Request) *Response {
+						attempts++
+						return &Response{Err: errors.New("boom"), StatusCode: http.StatusInternalServerError}
+					},
+				})(response, request)
+
+				Expect(attempts).To(Equal(1))
+				Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+			})
+		})
+
+		Context("when the method is not idempotent", func() {
+			It("should run the downstream handler once", func() {
+				request := httptest.NewRequest("POST", "/", strings.NewReader("payload"))
+
+				NewMiddlewareDownstreamRetry(DownstreamRetryConfig{
+					Handler: flaky(1),
+					Backoff: time.Millisecond,
+				})(response, request)
+
+				Expect(attempts).To(Equal(1))
+				Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+			})
+		})
+
+		Context("when no downstream handler is configured", func() {
+			It("should return a 500", func() {
+				request := httptest.NewRequest("GET", "/", nil)
+
+				resp := NewMiddlewareDownstreamRetry(DownstreamRetryConfig{})(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+			})
+		})
+	})
+})
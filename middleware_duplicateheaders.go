@@ -0,0 +1,57 @@
+package rye
+
+import (
+	"fmt"
+	"net/http"
+)
+
+var (
+	// Headers checked for duplicates when none are specified
+	DEFAULT_DUPLICATE_HEADERS = []string{"Authorization", "Content-Length", "Content-Type", "Transfer-Encoding"}
+)
+
+type rejectDuplicateHeaders struct {
+	headers []string
+}
+
+/*
+NewMiddlewareRejectDuplicateHeaders creates a new handler that rejects requests (with a 400) where any of
+the given security sensitive headers appear more than once, which mitigates some request smuggling and
+spoofing vectors. When no headers are given, DEFAULT_DUPLICATE_HEADERS are checked.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareRejectDuplicateHeaders(), // or ie. NewMiddlewareRejectDuplicateHeaders("Authorization", "X-Api-Key")
+			yourHandler,
+		})).Methods("POST")
+*/
+func NewMiddlewareRejectDuplicateHeaders(headers ...string) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if len(headers) == 0 {
+		headers = DEFAULT_DUPLICATE_HEADERS
+	}
+
+	d := &rejectDuplicateHeaders{
+		headers: make([]string, 0, len(headers)),
+	}
+
+	for _, header := range headers {
+		d.headers = append(d.headers, http.CanonicalHeaderKey(header))
+	}
+
+	return d.handle
+}
+
+func (d *rejectDuplicateHeaders) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	for _, header := range d.headers {
+		if len(r.Header[header]) > 1 {
+			return &Response{
+				Err:        fmt.Errorf("Header '%s' must not be sent more than once", header),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reject Duplicate Headers Middleware", func() {
+
+	var (
+		request  *http.Request
+		response *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		request = &http.Request{
+			Header: make(map[string][]string, 0),
+		}
+	})
+
+	Describe("handle", func() {
+		Context("when a configured header appears once", func() {
+			It("should return nil", func() {
+				request.Header.Add("X-Api-Key", "key1")
+
+				resp := NewMiddlewareRejectDuplicateHeaders("x-api-key")(response, request)
+				Expect(resp).To(BeNil())
+			})
+		})
+
+		Context("when a configured header appears more than once", func() {
+			It("should return a 400", func() {
+				request.Header.Add("X-Api-Key", "key1")
+				request.Header.Add("X-Api-Key", "key2")
+
+				resp := NewMiddlewareRejectDuplicateHeaders("x-api-key")(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(resp.Error()).To(ContainSubstring("X-Api-Key"))
+			})
+		})
+
+		Context("when no headers are configured", func() {
+			It("should check the default headers", func() {
+				request.Header.Add("Authorization", "Bearer a")
+				request.Header.Add("Authorization", "Bearer b")
+
+				resp := NewMiddlewareRejectDuplicateHeaders()(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			})
+
+			It("should ignore duplicates of other headers", func() {
+				request.Header.Add("Accept", "text/html")
+				request.Header.Add("Accept", "application/json")
+
+				resp := NewMiddlewareRejectDuplicateHeaders()(response, request)
+				Expect(resp).To(BeNil())
+			})
+		})
+	})
+})
@@ -0,0 +1,84 @@
+package rye
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"time"
+)
+
+type envelope struct{}
+
+// EnvelopeMeta holds the metadata added to every enveloped response.
+type EnvelopeMeta struct {
+	RequestID string `json:"request_id,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+// Envelope is the standard wrapper for successful JSON responses.
+type Envelope struct {
+	Data json.RawMessage `json:"data"`
+	Meta EnvelopeMeta    `json:"meta"`
+}
+
+/*
+NewMiddlewareEnvelope creates a new handler that wraps successful JSON responses written by
+downstream handlers in a standard envelope:
+
+	{"data": <original body>, "meta": {"request_id": "...", "timestamp": "..."}}
+
+The response is buffered until the chain finishes. Non-JSON responses and error responses
+(status codes outside of the 2xx range) are passed through untouched.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareEnvelope(),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareEnvelope() func(rw http.ResponseWriter, req *http.Request) *Response {
+	e := &envelope{}
+	return e.handle
+}
+
+func (e *envelope) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	requestID := requestIDOf(r, DEFAULT_REQUEST_ID_HEADER)
+
+	return &Response{
+		Writer: newBufferedResponseWriter(rw, func(b *bufferedResponseWriter) {
+			e.wrap(b, requestID)
+		}),
+	}
+}
+
+// wrap replaces the buffered body with the enveloped version (if applicable)
+func (e *envelope) wrap(b *bufferedResponseWriter, requestID string) {
+	if b.status < 200 || b.status > 299 {
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(b.Header().Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return
+	}
+
+	if !json.Valid(b.body.Bytes()) {
+		return
+	}
+
+	data, err := json.Marshal(&Envelope{
+		Data: json.RawMessage(b.body.Bytes()),
+		Meta: EnvelopeMeta{
+			RequestID: requestID,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	b.body.Reset()
+	b.body.Write(data)
+}
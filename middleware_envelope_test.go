@@ -0,0 +1,97 @@
+package rye
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Envelope Middleware", func() {
+
+	var (
+		request   *http.Request
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		request = &http.Request{
+			Header: make(map[string][]string, 0),
+		}
+		mwHandler = NewMWHandler(Config{})
+	})
+
+	Describe("handle", func() {
+		It("should return a response with a wrapping writer", func() {
+			resp := NewMiddlewareEnvelope()(response, request)
+			Expect(resp).ToNot(BeNil())
+			Expect(resp.Writer).ToNot(BeNil())
+		})
+
+		Context("when a downstream handler writes a JSON success response", func() {
+			It("should wrap the body in an envelope", func() {
+				request.Header.Set("X-Request-ID", "abc-123")
+
+				h := mwHandler.Handle([]Handler{NewMiddlewareEnvelope(), jsonHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+
+				envelope := &Envelope{}
+				err := json.Unmarshal(response.Body.Bytes(), envelope)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(envelope.Data)).To(Equal(`{"name":"rye"}`))
+				Expect(envelope.Meta.RequestID).To(Equal("abc-123"))
+				Expect(envelope.Meta.Timestamp).ToNot(BeEmpty())
+				Expect(response.Header().Get("Content-Length")).To(Equal(strconv.Itoa(response.Body.Len())))
+			})
+		})
+
+		Context("when a downstream handler writes a non-JSON response", func() {
+			It("should pass the body through untouched", func() {
+				h := mwHandler.Handle([]Handler{NewMiddlewareEnvelope(), textHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(response.Body.String()).To(Equal("plain text"))
+			})
+		})
+
+		Context("when a downstream handler returns an error", func() {
+			It("should not wrap the error response", func() {
+				h := mwHandler.Handle([]Handler{NewMiddlewareEnvelope(), envelopeErrorHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusBadRequest))
+
+				status := &JSONStatus{}
+				err := json.Unmarshal(response.Body.Bytes(), status)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(status.Message).To(Equal("bad input"))
+			})
+		})
+	})
+})
+
+func jsonHandler(rw http.ResponseWriter, r *http.Request) *Response {
+	WriteJSONResponse(rw, http.StatusOK, []byte(`{"name":"rye"}`))
+	return nil
+}
+
+func textHandler(rw http.ResponseWriter, r *http.Request) *Response {
+	rw.Header().Set("Content-Type", "text/plain")
+	rw.Write([]byte("plain text"))
+	return nil
+}
+
+func envelopeErrorHandler(rw http.ResponseWriter, r *http.Request) *Response {
+	return &Response{
+		StatusCode: http.StatusBadRequest,
+		Err:        errors.New("bad input"),
+	}
+}
@@ -0,0 +1,68 @@
+package rye
+
+import (
+	"net/http"
+	"time"
+)
+
+type errorLogger struct {
+	logger Logger
+}
+
+/*
+NewMiddlewareErrorLogger creates a new handler that logs every response of the chain with a 5xx status,
+at error level and exactly once per request, regardless of any other (possibly sampled) logging. The entry
+holds the request method, path and ID (see `rye.NewMiddlewareRequestID`, or else the `X-Request-ID` header),
+the final status, the name of the last handler that ran and the error it returned (if any).
+
+When `logger` is nil, entries are written through logrus (see `rye.LogrusLogger`).
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareErrorLogger(nil),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareErrorLogger(logger Logger) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if logger == nil {
+		logger = LogrusLogger{}
+	}
+
+	e := &errorLogger{logger: logger}
+	return e.handle
+}
+
+func (e *errorLogger) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	chain := chainFromRequest(r)
+	if chain == nil {
+		// Without a chain there is nothing to report on once the request is done
+		return nil
+	}
+
+	return &Response{
+		Writer: newStatusRecordingWriter(rw, func(status int) {
+			if status < 500 {
+				return
+			}
+
+			var handler string
+			if len(chain.timings) > 0 {
+				handler = chain.timings[len(chain.timings)-1].name
+			}
+
+			e.logger.Log(LogEntry{
+				Level:     LOG_LEVEL_ERROR,
+				Message:   "Request failed",
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Status:    status,
+				Duration:  time.Since(chain.start),
+				Handler:   handler,
+				RequestID: requestIDOf(r, DEFAULT_REQUEST_ID_HEADER),
+				Err:       chain.err,
+			})
+		}),
+	}
+}
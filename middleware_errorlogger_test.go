@@ -0,0 +1,91 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+type recordingLogger struct {
+	entries []LogEntry
+}
+
+func (l *recordingLogger) Log(entry LogEntry) {
+	l.entries = append(l.entries, entry)
+}
+
+var _ = Describe("Error Logger Middleware", func() {
+
+	var (
+		mwHandler *MWHandler
+		response  *httptest.ResponseRecorder
+		request   *http.Request
+		logger    *recordingLogger
+	)
+
+	BeforeEach(func() {
+		mwHandler = NewMWHandler(Config{})
+		response = httptest.NewRecorder()
+		request = httptest.NewRequest("POST", "/foo?bar=baz", nil)
+		request.Header.Set("X-Request-ID", "abc")
+		logger = &recordingLogger{}
+	})
+
+	Describe("handle", func() {
+		Context("when a handler returns a 5xx error", func() {
+			It("should log the failure once at error level", func() {
+				h := mwHandler.Handle([]Handler{NewMiddlewareErrorLogger(logger), failureHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(logger.entries).To(HaveLen(1))
+
+				entry := logger.entries[0]
+				Expect(entry.Level).To(Equal(LOG_LEVEL_ERROR))
+				Expect(entry.Method).To(Equal("POST"))
+				Expect(entry.Path).To(Equal("/foo"))
+				Expect(entry.Status).To(Equal(505))
+				Expect(entry.Handler).To(Equal("failureHandler"))
+				Expect(entry.RequestID).To(Equal("abc"))
+				Expect(entry.Err).To(MatchError("Foo"))
+			})
+		})
+
+		Context("when a handler writes a 5xx itself", func() {
+			It("should log the failure without an error", func() {
+				h := mwHandler.Handle([]Handler{NewMiddlewareErrorLogger(logger), unavailableHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(logger.entries).To(HaveLen(1))
+				Expect(logger.entries[0].Status).To(Equal(http.StatusServiceUnavailable))
+				Expect(logger.entries[0].Handler).To(Equal("unavailableHandler"))
+				Expect(logger.entries[0].Err).To(BeNil())
+			})
+		})
+
+		Context("when the chain succeeds", func() {
+			It("should not log", func() {
+				h := mwHandler.Handle([]Handler{NewMiddlewareErrorLogger(logger), successHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(logger.entries).To(BeEmpty())
+			})
+		})
+
+		Context("when a handler returns a 4xx error", func() {
+			It("should not log", func() {
+				h := mwHandler.Handle([]Handler{NewMiddlewareErrorLogger(logger), NewMiddlewareMaxURLLength(1)})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusRequestURITooLong))
+				Expect(logger.entries).To(BeEmpty())
+			})
+		})
+	})
+})
+
+func unavailableHandler(rw http.ResponseWriter, r *http.Request) *Response {
+	rw.WriteHeader(http.StatusServiceUnavailable)
+	return nil
+}
@@ -0,0 +1,80 @@
+package rye
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+const (
+	// Responses larger than this are sent without an ETag rather than buffered in full
+	DEFAULT_ETAG_MAX_SIZE = 10 << 20
+)
+
+type etag struct{}
+
+/*
+NewMiddlewareETag creates a new handler for cacheable GET endpoints: the 200 responses written by the rest of the
+chain are buffered and given an `ETag` computed over their body (a SHA-256), unless a handler set one itself.
+When the request's `If-None-Match` matches it, the body is dropped and a 304 is sent instead. Conditional
+requests are reported as `cache.hit` or `cache.miss` (see RecordCacheResult).
+
+The handlers still run in full; use NotModifiedIf to skip the work when the version of the content is known
+upfront. Bodies larger than DEFAULT_ETAG_MAX_SIZE are not buffered in full and are sent without an ETag.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareETag(),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareETag() func(rw http.ResponseWriter, req *http.Request) *Response {
+	e := &etag{}
+	return e.handle
+}
+
+func (e *etag) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	if r.Method != http.MethodGet {
+		return nil
+	}
+
+	writer := newBufferedResponseWriter(rw, func(b *bufferedResponseWriter) {
+		if b.status != http.StatusOK {
+			return
+		}
+
+		tag := b.Header().Get("ETag")
+		if tag == "" {
+			tag = computeETag(b.body.Bytes())
+			b.Header().Set("ETag", tag)
+		}
+
+		ifNoneMatch := r.Header.Get("If-None-Match")
+		if ifNoneMatch == "" {
+			return
+		}
+
+		if !etagMatches(ifNoneMatch, tag) {
+			RecordCacheResult(r, false)
+			return
+		}
+
+		RecordCacheResult(r, true)
+
+		b.status = http.StatusNotModified
+		b.body.Reset()
+	})
+	writer.maxSize = DEFAULT_ETAG_MAX_SIZE
+
+	return &Response{
+		Writer: writer,
+	}
+}
+
+// computeETag returns a strong ETag for the body
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
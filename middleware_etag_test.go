@@ -0,0 +1,103 @@
+package rye
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ETag Middleware", func() {
+
+	var (
+		request   *http.Request
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		reporter  *recordingReporter
+	)
+
+	expectedETag := func(body string) string {
+		sum := sha256.Sum256([]byte(body))
+		return `"` + hex.EncodeToString(sum[:]) + `"`
+	}
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		request = httptest.NewRequest("GET", "/", nil)
+		reporter = &recordingReporter{}
+		mwHandler = NewMWHandler(Config{Reporter: reporter})
+	})
+
+	serve := func(handler Handler) {
+		mwHandler.Handle([]Handler{NewMiddlewareETag(), handler}).ServeHTTP(response, request)
+	}
+
+	cacheStats := func() []string {
+		var names []string
+		for _, name := range reporter.recordedIncs() {
+			if name == "cache.hit" || name == "cache.miss" {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+
+	Describe("handle", func() {
+		It("should set an ETag computed over the body", func() {
+			serve(jsonHandler)
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(Equal(`{"name":"rye"}`))
+			Expect(response.Header().Get("ETag")).To(Equal(expectedETag(`{"name":"rye"}`)))
+			Consistently(cacheStats).Should(BeEmpty())
+		})
+
+		It("should answer a matching If-None-Match with an empty 304", func() {
+			request.Header.Set("If-None-Match", expectedETag(`{"name":"rye"}`))
+			serve(jsonHandler)
+
+			Expect(response.Code).To(Equal(http.StatusNotModified))
+			Expect(response.Body.Len()).To(Equal(0))
+			Expect(response.Header().Get("ETag")).To(Equal(expectedETag(`{"name":"rye"}`)))
+			Eventually(cacheStats).Should(Equal([]string{"cache.hit"}))
+		})
+
+		It("should send the body when If-None-Match does not match", func() {
+			request.Header.Set("If-None-Match", `"stale"`)
+			serve(jsonHandler)
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(Equal(`{"name":"rye"}`))
+			Eventually(cacheStats).Should(Equal([]string{"cache.miss"}))
+		})
+
+		It("should keep an ETag set by the handler", func() {
+			request.Header.Set("If-None-Match", `W/"v2"`)
+			serve(func(rw http.ResponseWriter, r *http.Request) *Response {
+				rw.Header().Set("ETag", `"v2"`)
+				rw.Write([]byte("version 2"))
+				return nil
+			})
+
+			Expect(response.Code).To(Equal(http.StatusNotModified))
+			Expect(response.Header().Get("ETag")).To(Equal(`"v2"`))
+		})
+
+		It("should leave error responses alone", func() {
+			serve(failureHandler)
+
+			Expect(response.Code).To(Equal(505))
+			Expect(response.Header().Get("ETag")).To(BeEmpty())
+		})
+
+		It("should leave other methods alone", func() {
+			request = httptest.NewRequest("POST", "/", nil)
+			serve(jsonHandler)
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("ETag")).To(BeEmpty())
+		})
+	})
+})
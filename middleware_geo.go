@@ -0,0 +1,86 @@
+package rye
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+const (
+	CONTEXT_GEO_COUNTRY = "rye-middlewaregeo-country"
+	CONTEXT_GEO_REGION  = "rye-middlewaregeo-region"
+
+	// Value stored in the context when no geo header is present
+	GEO_UNKNOWN = "unknown"
+)
+
+var (
+	// Default headers checked (in order) for geo information
+	DEFAULT_GEO_COUNTRY_HEADERS = []string{"CF-IPCountry", "X-AppEngine-Country", "CloudFront-Viewer-Country"}
+	DEFAULT_GEO_REGION_HEADERS  = []string{"X-AppEngine-Region", "CloudFront-Viewer-Country-Region"}
+)
+
+// GeoHeaderConfig lists the headers (checked in order) that carry the client's country and
+// region. Empty lists fall back to DEFAULT_GEO_COUNTRY_HEADERS and DEFAULT_GEO_REGION_HEADERS.
+type GeoHeaderConfig struct {
+	CountryHeaders []string
+	RegionHeaders  []string
+}
+
+type geoContext struct {
+	countryHeaders []string
+	regionHeaders  []string
+}
+
+/*
+NewMiddlewareGeoContext creates a new handler that reads geo headers set by a CDN or load balancer
+(ie. `CF-IPCountry` or `X-AppEngine-Country`) and adds the client country and region to the context.
+If none of the configured headers are present, the value is set to GEO_UNKNOWN.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareGeoContext(rye.GeoHeaderConfig{}), // use the default headers
+			yourHandler,
+		})).Methods("GET")
+
+The values can then be retrieved by other middlewares:
+
+	country := r.Context().Value(rye.CONTEXT_GEO_COUNTRY)
+	region := r.Context().Value(rye.CONTEXT_GEO_REGION)
+*/
+func NewMiddlewareGeoContext(headers GeoHeaderConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	g := &geoContext{
+		countryHeaders: headers.CountryHeaders,
+		regionHeaders:  headers.RegionHeaders,
+	}
+
+	if len(g.countryHeaders) == 0 {
+		g.countryHeaders = DEFAULT_GEO_COUNTRY_HEADERS
+	}
+
+	if len(g.regionHeaders) == 0 {
+		g.regionHeaders = DEFAULT_GEO_REGION_HEADERS
+	}
+
+	return g.handle
+}
+
+func (g *geoContext) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	ctx := context.WithValue(r.Context(), CONTEXT_GEO_COUNTRY, firstHeader(r, g.countryHeaders))
+	ctx = context.WithValue(ctx, CONTEXT_GEO_REGION, firstHeader(r, g.regionHeaders))
+
+	return &Response{Context: ctx}
+}
+
+// firstHeader returns the value of the first present header (or GEO_UNKNOWN)
+func firstHeader(r *http.Request, headers []string) string {
+	for _, header := range headers {
+		if value := strings.TrimSpace(r.Header.Get(header)); value != "" {
+			return value
+		}
+	}
+
+	return GEO_UNKNOWN
+}
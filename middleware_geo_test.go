@@ -0,0 +1,58 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Geo Context Middleware", func() {
+
+	var (
+		request  *http.Request
+		response *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		request = &http.Request{
+			Header: make(map[string][]string, 0),
+		}
+	})
+
+	Describe("handle", func() {
+		Context("when geo headers are present", func() {
+			It("should add the country and region to the context", func() {
+				request.Header.Set("CF-IPCountry", "BR")
+				request.Header.Set("X-AppEngine-Region", "sp")
+
+				resp := NewMiddlewareGeoContext(GeoHeaderConfig{})(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Context).ToNot(BeNil())
+				Expect(resp.Context.Value(CONTEXT_GEO_COUNTRY)).To(Equal("BR"))
+				Expect(resp.Context.Value(CONTEXT_GEO_REGION)).To(Equal("sp"))
+			})
+
+			It("should use the configured headers", func() {
+				request.Header.Set("CF-IPCountry", "BR")
+				request.Header.Set("X-Country", "PT")
+
+				resp := NewMiddlewareGeoContext(GeoHeaderConfig{
+					CountryHeaders: []string{"X-Country"},
+				})(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Context.Value(CONTEXT_GEO_COUNTRY)).To(Equal("PT"))
+			})
+		})
+
+		Context("when geo headers are absent", func() {
+			It("should set unknown values in the context", func() {
+				resp := NewMiddlewareGeoContext(GeoHeaderConfig{})(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Context.Value(CONTEXT_GEO_COUNTRY)).To(Equal(GEO_UNKNOWN))
+				Expect(resp.Context.Value(CONTEXT_GEO_REGION)).To(Equal(GEO_UNKNOWN))
+			})
+		})
+	})
+})
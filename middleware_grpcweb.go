@@ -0,0 +1,240 @@
+package rye
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Context key holding the gRPC metadata of the request (a map[string][]string)
+	CONTEXT_GRPC_WEB_METADATA = "rye-middlewaregrpcweb-metadata"
+
+	// Default maximum size of a message, as in gRPC
+	DEFAULT_GRPC_WEB_MAX_MESSAGE_SIZE = 4 << 20 // 4MB
+
+	// gRPC status codes used by the middleware
+	grpcStatusInvalidArgument   = 3
+	grpcStatusResourceExhausted = 8
+
+	grpcWebFrameHeaderSize = 5
+)
+
+var (
+	errGRPCWebMessageTooLarge = errors.New("gRPC-Web message too large")
+)
+
+// GRPCWebConfig configures the gRPC-Web middleware.
+type GRPCWebConfig struct {
+	// MaxMessageSize caps the size of each message in bytes
+	// (defaults to DEFAULT_GRPC_WEB_MAX_MESSAGE_SIZE)
+	MaxMessageSize int
+}
+
+type grpcWeb struct {
+	config GRPCWebConfig
+}
+
+/*
+NewMiddlewareGRPCWeb creates a new handler that lets rye sit in front of gRPC-Web handlers: requests with an
+`application/grpc-web` (or `application/grpc-web-text`) content type have their framing validated before they
+go any further. Malformed requests are rejected with a 400 (or a 413 when a message is larger than
+`MaxMessageSize`), along with the matching `grpc-status` and `grpc-message` headers; other requests are
+left alone.
+
+The gRPC metadata of valid requests (their headers, apart from the reserved `grpc-*` and content headers) is
+available to handlers through `rye.GRPCWebMetadataFromContext`. The body is left untouched for the gRPC-Web
+handler.
+
+Example usage:
+
+	routes.Handle("/my.Service/{method}", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareJWT(secret),
+			rye.NewMiddlewareGRPCWeb(rye.GRPCWebConfig{}),
+			yourGRPCWebHandler,
+		})).Methods("POST")
+*/
+func NewMiddlewareGRPCWeb(cfg GRPCWebConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if cfg.MaxMessageSize <= 0 {
+		cfg.MaxMessageSize = DEFAULT_GRPC_WEB_MAX_MESSAGE_SIZE
+	}
+
+	g := &grpcWeb{config: cfg}
+	return g.handle
+}
+
+func (g *grpcWeb) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	text, ok := grpcWebContentType(r.Header.Get("Content-Type"))
+	if !ok {
+		return nil
+	}
+
+	if r.Method != http.MethodPost {
+		return g.reject(rw, http.StatusMethodNotAllowed, grpcStatusInvalidArgument, fmt.Errorf("gRPC-Web requests must be POSTed"))
+	}
+
+	// A request is a single message (gRPC-Web has no client streaming); leave
+	// room for a frame header and the base64 overhead of text requests
+	limit := int64(g.config.MaxMessageSize) + 2*grpcWebFrameHeaderSize
+	if text {
+		limit = limit*4/3 + 4
+	}
+
+	body, err := readGRPCWebBody(r, limit)
+	if err == errGRPCWebMessageTooLarge {
+		return g.reject(rw, http.StatusRequestEntityTooLarge, grpcStatusResourceExhausted, err)
+	}
+	if err != nil {
+		return g.reject(rw, http.StatusBadRequest, grpcStatusInvalidArgument, fmt.Errorf("Unable to read gRPC-Web request: %v", err))
+	}
+
+	if text {
+		if body, err = base64.StdEncoding.DecodeString(string(body)); err != nil {
+			return g.reject(rw, http.StatusBadRequest, grpcStatusInvalidArgument, fmt.Errorf("Malformed gRPC-Web text request: %v", err))
+		}
+	}
+
+	encoding := r.Header.Get("Grpc-Encoding")
+	compressed := encoding != "" && encoding != "identity"
+
+	if err := validateGRPCWebFrames(body, g.config.MaxMessageSize, compressed); err != nil {
+		if err == errGRPCWebMessageTooLarge {
+			return g.reject(rw, http.StatusRequestEntityTooLarge, grpcStatusResourceExhausted, err)
+		}
+
+		return g.reject(rw, http.StatusBadRequest, grpcStatusInvalidArgument, err)
+	}
+
+	return &Response{
+		Context: context.WithValue(r.Context(), CONTEXT_GRPC_WEB_METADATA, grpcWebMetadata(r.Header)),
+	}
+}
+
+// reject stops the chain with the given HTTP status, carrying the gRPC status along
+func (g *grpcWeb) reject(rw http.ResponseWriter, status, grpcStatus int, err error) *Response {
+	rw.Header().Set("Grpc-Status", strconv.Itoa(grpcStatus))
+	rw.Header().Set("Grpc-Message", err.Error())
+
+	return &Response{
+		Err:        err,
+		StatusCode: status,
+	}
+}
+
+// grpcWebContentType reports whether the content type is a gRPC-Web one, and whether it is the text variant
+func grpcWebContentType(contentType string) (text bool, ok bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false, false
+	}
+
+	// Strip the message format (ie. `+proto`)
+	mediaType = strings.SplitN(mediaType, "+", 2)[0]
+
+	switch mediaType {
+	case "application/grpc-web":
+		return false, true
+	case "application/grpc-web-text":
+		return true, true
+	}
+
+	return false, false
+}
+
+// validateGRPCWebFrames checks that the body is made of complete data frames, each one a flags byte
+// (only the compressed flag may be set, and only if the request is compressed) followed by the big
+// endian length of the message and the message itself
+func validateGRPCWebFrames(body []byte, maxSize int, compressed bool) error {
+	if len(body) == 0 {
+		return fmt.Errorf("Empty gRPC-Web request")
+	}
+
+	for len(body) > 0 {
+		if len(body) < grpcWebFrameHeaderSize {
+			return fmt.Errorf("Truncated gRPC-Web frame header")
+		}
+
+		flags := body[0]
+		if flags&^0x01 != 0 {
+			return fmt.Errorf("Unexpected gRPC-Web frame flags %#x", flags)
+		}
+
+		if flags&0x01 != 0 && !compressed {
+			return fmt.Errorf("Compressed gRPC-Web frame without a grpc-encoding")
+		}
+
+		length := binary.BigEndian.Uint32(body[1:grpcWebFrameHeaderSize])
+		if length > uint32(maxSize) {
+			return errGRPCWebMessageTooLarge
+		}
+
+		body = body[grpcWebFrameHeaderSize:]
+		if uint32(len(body)) < length {
+			return fmt.Errorf("Truncated gRPC-Web message")
+		}
+
+		body = body[length:]
+	}
+
+	return nil
+}
+
+// grpcWebMetadata returns the gRPC metadata carried by the headers, keyed by lower case name
+func grpcWebMetadata(header http.Header) map[string][]string {
+	metadata := make(map[string][]string)
+
+	for key, values := range header {
+		key = strings.ToLower(key)
+
+		if strings.HasPrefix(key, "grpc-") {
+			continue
+		}
+
+		switch key {
+		case "content-type", "content-length", "content-encoding", "te", "connection", "x-grpc-web":
+			continue
+		}
+
+		metadata[key] = append([]string(nil), values...)
+	}
+
+	return metadata
+}
+
+// GRPCWebMetadataFromContext returns the gRPC metadata stored by NewMiddlewareGRPCWeb (if any)
+func GRPCWebMetadataFromContext(ctx context.Context) (map[string][]string, bool) {
+	metadata, ok := ctx.Value(CONTEXT_GRPC_WEB_METADATA).(map[string][]string)
+	return metadata, ok
+}
+
+// readGRPCWebBody reads the request body like readBody, failing with errGRPCWebMessageTooLarge
+// if it is larger than limit bytes
+func readGRPCWebBody(r *http.Request, limit int64) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	r.Body.Close()
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err != nil {
+		return body, err
+	}
+
+	if int64(len(body)) > limit {
+		return body, errGRPCWebMessageTooLarge
+	}
+
+	return body, nil
+}
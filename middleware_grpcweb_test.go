@@ -0,0 +1,162 @@
+package rye
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+
+	. "github.com/onsi/gomega"
+)
+
+// grpcWebFrame frames a message the way gRPC-Web clients do
+func grpcWebFrame(flags byte, message []byte) []byte {
+	frame := make([]byte, grpcWebFrameHeaderSize, grpcWebFrameHeaderSize+len(message))
+	frame[0] = flags
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+
+	return append(frame, message...)
+}
+
+var _ = Describe("gRPC-Web Middleware", func() {
+
+	var (
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		metadata  map[string][]string
+		received  []byte
+	)
+
+	// grpcHandler records what the gRPC-Web handler gets
+	grpcHandler := func(rw http.ResponseWriter, r *http.Request) *Response {
+		metadata, _ = GRPCWebMetadataFromContext(r.Context())
+		received, _ = io.ReadAll(r.Body)
+		return nil
+	}
+
+	newRequest := func(contentType string, body []byte) *http.Request {
+		request := httptest.NewRequest("POST", "/my.Service/Get", bytes.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+		request.Header.Set("X-Grpc-Web", "1")
+		request.Header.Set("Authorization", "Bearer token")
+		request.Header.Set("Grpc-Timeout", "1S")
+		return request
+	}
+
+	serve := func(cfg GRPCWebConfig, request *http.Request) {
+		mwHandler.Handle([]Handler{NewMiddlewareGRPCWeb(cfg), grpcHandler}).ServeHTTP(response, request)
+	}
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		mwHandler = NewMWHandler(Config{})
+		metadata, received = nil, nil
+	})
+
+	Describe("handle", func() {
+		Context("when the request is well formed", func() {
+			It("should pass it on with its metadata", func() {
+				body := grpcWebFrame(0, []byte("message"))
+
+				serve(GRPCWebConfig{}, newRequest("application/grpc-web+proto", body))
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(received).To(Equal(body))
+				Expect(metadata).To(HaveKeyWithValue("authorization", []string{"Bearer token"}))
+				Expect(metadata).ToNot(HaveKey("grpc-timeout"))
+				Expect(metadata).ToNot(HaveKey("content-type"))
+				Expect(metadata).ToNot(HaveKey("x-grpc-web"))
+			})
+
+			It("should accept text requests", func() {
+				body := base64.StdEncoding.EncodeToString(grpcWebFrame(0, []byte("message")))
+
+				serve(GRPCWebConfig{}, newRequest("application/grpc-web-text", []byte(body)))
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(string(received)).To(Equal(body))
+			})
+
+			It("should accept compressed frames of compressed requests", func() {
+				request := newRequest("application/grpc-web", grpcWebFrame(1, []byte("gzipped")))
+				request.Header.Set("Grpc-Encoding", "gzip")
+
+				serve(GRPCWebConfig{}, request)
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+			})
+		})
+
+		Context("when the request is malformed", func() {
+			expectRejected := func(status, grpcStatus int) {
+				Expect(response.Code).To(Equal(status))
+				Expect(response.Header().Get("Grpc-Status")).To(Equal(strconv.Itoa(grpcStatus)))
+				Expect(response.Header().Get("Grpc-Message")).ToNot(BeEmpty())
+				Expect(received).To(BeNil())
+			}
+
+			It("should reject a truncated message", func() {
+				body := grpcWebFrame(0, []byte("message"))
+
+				serve(GRPCWebConfig{}, newRequest("application/grpc-web", body[:len(body)-2]))
+				expectRejected(http.StatusBadRequest, grpcStatusInvalidArgument)
+			})
+
+			It("should reject a truncated frame header", func() {
+				serve(GRPCWebConfig{}, newRequest("application/grpc-web", []byte{0, 0, 0}))
+				expectRejected(http.StatusBadRequest, grpcStatusInvalidArgument)
+			})
+
+			It("should reject an empty body", func() {
+				serve(GRPCWebConfig{}, newRequest("application/grpc-web", nil))
+				expectRejected(http.StatusBadRequest, grpcStatusInvalidArgument)
+			})
+
+			It("should reject trailer frames", func() {
+				serve(GRPCWebConfig{}, newRequest("application/grpc-web", grpcWebFrame(0x80, []byte("grpc-status: 0"))))
+				expectRejected(http.StatusBadRequest, grpcStatusInvalidArgument)
+			})
+
+			It("should reject compressed frames of uncompressed requests", func() {
+				serve(GRPCWebConfig{}, newRequest("application/grpc-web", grpcWebFrame(1, []byte("gzipped"))))
+				expectRejected(http.StatusBadRequest, grpcStatusInvalidArgument)
+			})
+
+			It("should reject invalid base64 in text requests", func() {
+				serve(GRPCWebConfig{}, newRequest("application/grpc-web-text", []byte("not base64!")))
+				expectRejected(http.StatusBadRequest, grpcStatusInvalidArgument)
+			})
+
+			It("should reject messages over the maximum size", func() {
+				serve(GRPCWebConfig{MaxMessageSize: 4}, newRequest("application/grpc-web", grpcWebFrame(0, []byte("message"))))
+				expectRejected(http.StatusRequestEntityTooLarge, grpcStatusResourceExhausted)
+			})
+
+			It("should reject bodies far over the maximum size without a valid frame", func() {
+				serve(GRPCWebConfig{MaxMessageSize: 4}, newRequest("application/grpc-web", bytes.Repeat([]byte{0}, 100)))
+				expectRejected(http.StatusRequestEntityTooLarge, grpcStatusResourceExhausted)
+			})
+
+			It("should reject requests that are not POSTed", func() {
+				request := newRequest("application/grpc-web", nil)
+				request.Method = "GET"
+
+				serve(GRPCWebConfig{}, request)
+				expectRejected(http.StatusMethodNotAllowed, grpcStatusInvalidArgument)
+			})
+		})
+
+		Context("when the request is not a gRPC-Web request", func() {
+			It("should leave it alone", func() {
+				serve(GRPCWebConfig{}, newRequest("application/json", []byte(`{}`)))
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(string(received)).To(Equal(`{}`))
+				Expect(metadata).To(BeNil())
+			})
+		})
+	})
+})
@@ -0,0 +1,74 @@
+package rye
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	// Context key holding the body decoded by NewMiddlewareJSONBody
+	CONTEXT_JSON_BODY = "rye-middlewarejsonbody-body"
+)
+
+type jsonBody struct {
+	newTarget func() interface{}
+}
+
+/*
+NewMiddlewareJSONBody creates a new handler that decodes the JSON body of requests, for handlers that all start by
+decoding the same struct: `newTarget` returns a fresh value to decode each body into (a pointer, as for
+json.Unmarshal), which the rest of the chain gets through `rye.CtxJSONBody`. Requests whose `Content-Type` is not
+`application/json` (or a +json type) are stopped with a 415, and bodies that don't decode with a 400. The body is
+read in full and closed, then restored for downstream handlers.
+
+Example usage:
+
+	routes.Handle("/items", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareJSONBody(func() interface{} { return &CreateItemRequest{} }),
+			createItemHandler, // item := rye.CtxJSONBody(r).(*CreateItemRequest)
+		})).Methods("POST")
+*/
+func NewMiddlewareJSONBody(newTarget func() interface{}) func(rw http.ResponseWriter, req *http.Request) *Response {
+	j := &jsonBody{newTarget: newTarget}
+	return j.handle
+}
+
+func (j *jsonBody) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	contentType := r.Header.Get("Content-Type")
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return &Response{
+			Err:           fmt.Errorf("Unsupported Content-Type '%s'; request body must be JSON", contentType),
+			StatusCode:    http.StatusUnsupportedMediaType,
+			StopExecution: true,
+		}
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return bodyReadError(r, err)
+	}
+
+	target := j.newTarget()
+	if err := json.Unmarshal(body, target); err != nil {
+		return &Response{
+			Err:        fmt.Errorf("Unable to decode JSON request body: %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	return &Response{
+		Context: context.WithValue(r.Context(), CONTEXT_JSON_BODY, target),
+	}
+}
+
+// CtxJSONBody returns the body decoded by NewMiddlewareJSONBody (or nil if there is none)
+func CtxJSONBody(r *http.Request) interface{} {
+	return r.Context().Value(CONTEXT_JSON_BODY)
+}
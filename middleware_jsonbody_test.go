@@ -0,0 +1,105 @@
+package rye
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/gomega"
+)
+
+type jsonBodyItem struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+var _ = Describe("JSON Body Middleware", func() {
+
+	var (
+		request  *http.Request
+		response *httptest.ResponseRecorder
+		decoded  interface{}
+		body     string
+		reached  bool
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("POST", "/items", strings.NewReader(`{"name":"rye","count":2}`))
+		request.Header.Set("Content-Type", "application/json; charset=utf-8")
+		response = httptest.NewRecorder()
+		decoded, body, reached = nil, "", false
+	})
+
+	serve := func() {
+		h := NewMWHandler(Config{}).Handle([]Handler{
+			NewMiddlewareJSONBody(func() interface{} { return &jsonBodyItem{} }),
+			func(rw http.ResponseWriter, r *http.Request) *Response {
+				reached = true
+				decoded = CtxJSONBody(r)
+
+				b, _ := ioutil.ReadAll(r.Body)
+				body = string(b)
+				return nil
+			},
+		})
+		h.ServeHTTP(response, request)
+	}
+
+	Describe("handle", func() {
+		It("should store the decoded body in the context and restore the body", func() {
+			serve()
+
+			Expect(reached).To(BeTrue())
+			Expect(decoded).To(Equal(&jsonBodyItem{Name: "rye", Count: 2}))
+			Expect(body).To(Equal(`{"name":"rye","count":2}`))
+		})
+
+		It("should decode into a fresh target for every request", func() {
+			serve()
+			first := decoded
+
+			request = httptest.NewRequest("POST", "/items", strings.NewReader(`{"name":"other"}`))
+			request.Header.Set("Content-Type", "application/vnd.api+json")
+			serve()
+
+			Expect(first).To(Equal(&jsonBodyItem{Name: "rye", Count: 2}))
+			Expect(decoded).To(Equal(&jsonBodyItem{Name: "other"}))
+		})
+
+		It("should return a 400 when the body doesn't decode", func() {
+			request = httptest.NewRequest("POST", "/items", strings.NewReader(`{"name":`))
+			request.Header.Set("Content-Type", "application/json")
+
+			serve()
+
+			Expect(reached).To(BeFalse())
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(response.Body.String()).To(ContainSubstring("Unable to decode JSON request body"))
+		})
+
+		It("should return a 415 for other content types", func() {
+			request.Header.Set("Content-Type", "text/plain")
+
+			serve()
+
+			Expect(reached).To(BeFalse())
+			Expect(response.Code).To(Equal(http.StatusUnsupportedMediaType))
+		})
+
+		It("should return a 415 when the content type is missing", func() {
+			request.Header.Del("Content-Type")
+
+			serve()
+
+			Expect(reached).To(BeFalse())
+			Expect(response.Code).To(Equal(http.StatusUnsupportedMediaType))
+		})
+	})
+
+	Describe("CtxJSONBody", func() {
+		It("should return nil when no body was decoded", func() {
+			Expect(CtxJSONBody(request)).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,77 @@
+package rye
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type jsonDepthLimit struct {
+	maxDepth int
+}
+
+/*
+NewMiddlewareJSONDepthLimit creates a new handler that protects against deeply nested JSON payloads
+(which can exhaust parsers) by scanning the request body token by token and returning a 400 once
+the nesting depth exceeds `maxDepth`. The body is restored for downstream handlers.
+
+Bodies that are not valid JSON are left for downstream handlers to deal with.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareJSONDepthLimit(32),
+			yourHandler,
+		})).Methods("POST")
+*/
+func NewMiddlewareJSONDepthLimit(maxDepth int) func(rw http.ResponseWriter, req *http.Request) *Response {
+	j := &jsonDepthLimit{maxDepth: maxDepth}
+	return j.handle
+}
+
+func (j *jsonDepthLimit) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	body, err := readBody(r)
+	if err != nil {
+		return bodyReadError(r, err)
+	}
+
+	if depth := jsonDepthExceeds(body, j.maxDepth); depth > 0 {
+		return &Response{
+			Err:        fmt.Errorf("JSON nesting depth exceeds the maximum of %d", j.maxDepth),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	return nil
+}
+
+// jsonDepthExceeds scans the JSON document and returns the depth at which the
+// maximum was exceeded (or 0 if it never was, or the document is not valid JSON)
+func jsonDepthExceeds(body []byte, maxDepth int) int {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	depth := 0
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return 0
+		}
+
+		delim, ok := token.(json.Delim)
+		if !ok {
+			continue
+		}
+
+		switch delim {
+		case '{', '[':
+			depth++
+			if depth > maxDepth {
+				return depth
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+}
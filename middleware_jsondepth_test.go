@@ -0,0 +1,67 @@
+package rye
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSON Depth Limit Middleware", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+	})
+
+	Describe("handle", func() {
+		Context("when the payload is within the depth limit", func() {
+			It("should return nil and restore the body", func() {
+				payload := `{"a": {"b": [1, 2, {"c": true}]}}`
+				request := httptest.NewRequest("POST", "/", strings.NewReader(payload))
+
+				resp := NewMiddlewareJSONDepthLimit(4)(response, request)
+				Expect(resp).To(BeNil())
+
+				body, err := ioutil.ReadAll(request.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal(payload))
+			})
+		})
+
+		Context("when the payload exceeds the depth limit", func() {
+			It("should return a 400", func() {
+				payload := strings.Repeat("[", 10) + strings.Repeat("]", 10)
+				request := httptest.NewRequest("POST", "/", strings.NewReader(payload))
+
+				resp := NewMiddlewareJSONDepthLimit(5)(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(resp.Error()).To(ContainSubstring("maximum of 5"))
+			})
+
+			It("should detect the excess before the document is complete", func() {
+				payload := strings.Repeat(`{"a":`, 10)
+				request := httptest.NewRequest("POST", "/", strings.NewReader(payload))
+
+				resp := NewMiddlewareJSONDepthLimit(5)(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			})
+		})
+
+		Context("when the request has no body", func() {
+			It("should return nil", func() {
+				request := &http.Request{}
+
+				resp := NewMiddlewareJSONDepthLimit(5)(response, request)
+				Expect(resp).To(BeNil())
+			})
+		})
+	})
+})
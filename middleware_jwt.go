@@ -2,15 +2,21 @@ package rye
 
 import (
 	"context"
+	"crypto/rsa"
 	"fmt"
 	"net/http"
 	"regexp"
+	"strings"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
 const (
-	CONTEXT_JWT = "rye-middlewarejwt-jwt"
+	CONTEXT_JWT        = "rye-middlewarejwt-jwt"
+	CONTEXT_JWT_CLAIMS = "rye-middlewarejwt-claims"
+
+	DEFAULT_JWT_HEADER = "Authorization"
+	DEFAULT_JWT_SCHEME = "Bearer"
 )
 
 type jwtVerify struct {
@@ -90,3 +96,168 @@ func (j *jwtVerify) handle(rw http.ResponseWriter, req *http.Request) *Response
 
 	return &Response{Context: ctx}
 }
+
+// Claims are the claims of a JWT verified by NewMiddlewareJWTWithConfig.
+type Claims map[string]interface{}
+
+// JWTConfig configures the JWT handler created by NewMiddlewareJWTWithConfig. At least one
+// of Secret (for HMAC signed tokens) or PublicKey (for RSA signed tokens) must be set.
+type JWTConfig struct {
+	Secret    string
+	PublicKey *rsa.PublicKey
+
+	// Header carrying the token (defaults to DEFAULT_JWT_HEADER)
+	Header string
+
+	// Scheme prefixing the token in the header (defaults to DEFAULT_JWT_SCHEME)
+	Scheme string
+
+	// Audiences, when set, lists the accepted audiences: tokens whose `aud` claim
+	// names none of them are rejected (ie. tokens issued for another service)
+	Audiences []string
+
+	// Issuers, when set, lists the accepted issuers: tokens whose `iss` claim
+	// is none of them are rejected
+	Issuers []string
+}
+
+type jwtConfigVerify struct {
+	config JWTConfig
+}
+
+/*
+NewMiddlewareJWTWithConfig creates a new handler providing JWT verification against an HMAC secret and/or
+an RSA public key, reading the token from a configurable header and scheme. A missing or invalid token
+results in a 401, as does a validly signed token issued by an issuer or for an audience other than the
+configured ones (see JWTConfig.Issuers and JWTConfig.Audiences).
+
+On success, the token is put into the context under CONTEXT_JWT (like `rye.NewMiddlewareJWT`) and its
+claims under CONTEXT_JWT_CLAIMS; use `rye.CtxJWT` to read them.
+
+Example use case:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareJWTWithConfig(rye.JWTConfig{PublicKey: publicKey}),
+			yourHandler,
+		})).Methods("PUT", "OPTIONS")
+
+	func yourHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		claims, _ := rye.CtxJWT(r)
+		log.Infof("Subject: %v", claims["sub"])
+		...
+	}
+*/
+func NewMiddlewareJWTWithConfig(cfg JWTConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if cfg.Header == "" {
+		cfg.Header = DEFAULT_JWT_HEADER
+	}
+
+	if cfg.Scheme == "" {
+		cfg.Scheme = DEFAULT_JWT_SCHEME
+	}
+
+	j := &jwtConfigVerify{config: cfg}
+	return j.handle
+}
+
+func (j *jwtConfigVerify) handle(rw http.ResponseWriter, req *http.Request) *Response {
+	tokenHeader := req.Header.Get(j.config.Header)
+
+	prefix := j.config.Scheme + " "
+	if len(tokenHeader) <= len(prefix) || !strings.EqualFold(tokenHeader[:len(prefix)], prefix) {
+		return &Response{
+			Err:        fmt.Errorf("JWT token must be passed with %s header using the %s scheme", j.config.Header, j.config.Scheme),
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+
+	token := strings.TrimSpace(tokenHeader[len(prefix):])
+	claims := jwt.MapClaims{}
+
+	_, err := jwt.ParseWithClaims(token, claims, j.key)
+	if err != nil {
+		return &Response{
+			Err:        err,
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+
+	if err := j.verifyClaims(claims); err != nil {
+		return &Response{
+			Err:        err,
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+
+	ctx := context.WithValue(req.Context(), CONTEXT_JWT, token)
+	ctx = context.WithValue(ctx, CONTEXT_JWT_CLAIMS, Claims(claims))
+
+	return &Response{Context: ctx}
+}
+
+// key returns the key verifying the token, depending on its signing method
+func (j *jwtConfigVerify) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if j.config.Secret != "" {
+			return []byte(j.config.Secret), nil
+		}
+	case *jwt.SigningMethodRSA:
+		if j.config.PublicKey != nil {
+			return j.config.PublicKey, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Unexpected signing method")
+}
+
+// verifyClaims checks the audience and issuer of the token against the configured ones (if any)
+func (j *jwtConfigVerify) verifyClaims(claims jwt.MapClaims) error {
+	if len(j.config.Audiences) > 0 {
+		var audiences []string
+
+		// The audience is either a single string or an array of strings
+		switch aud := claims["aud"].(type) {
+		case string:
+			audiences = []string{aud}
+		case []interface{}:
+			for _, a := range aud {
+				if s, ok := a.(string); ok {
+					audiences = append(audiences, s)
+				}
+			}
+		}
+
+		if !stringListsIntersect(audiences, j.config.Audiences) {
+			return fmt.Errorf("Unauthorized request: token audience %v is not accepted", audiences)
+		}
+	}
+
+	if len(j.config.Issuers) > 0 {
+		iss, _ := claims["iss"].(string)
+
+		if !stringListContains(j.config.Issuers, iss) {
+			return fmt.Errorf("Unauthorized request: token issuer '%s' is not accepted", iss)
+		}
+	}
+
+	return nil
+}
+
+// stringListsIntersect returns whether the lists have any element in common
+func stringListsIntersect(a, b []string) bool {
+	for _, v := range a {
+		if stringListContains(b, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CtxJWT returns the claims of the JWT verified by NewMiddlewareJWTWithConfig (false if there are none)
+func CtxJWT(r *http.Request) (Claims, bool) {
+	claims, ok := r.Context().Value(CONTEXT_JWT_CLAIMS).(Claims)
+	return claims, ok
+}
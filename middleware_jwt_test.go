@@ -1,11 +1,14 @@
 package rye
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 
-	. "github.com/onsi/ginkgo"
+	"github.com/dgrijalva/jwt-go"
+
 	. "github.com/onsi/gomega"
 )
 
@@ -63,4 +66,182 @@ var _ = Describe("JWT Middleware", func() {
 			})
 		})
 	})
+
+	Describe("handle with config", func() {
+		var (
+			privateKey *rsa.PrivateKey
+			rsaToken   string
+		)
+
+		BeforeEach(func() {
+			var err error
+			privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).ToNot(HaveOccurred())
+
+			rsaToken, err = jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "rsa-user"}).SignedString(privateKey)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Context("when a valid HMAC token is passed", func() {
+			It("should put the token and its claims into the context", func() {
+				request.Header.Add("Authorization", fmt.Sprintf("bearer %s", hs256_jwt))
+				resp := NewMiddlewareJWTWithConfig(JWTConfig{Secret: shared_secret})(response, request)
+
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Err).To(BeNil())
+				Expect(resp.Context.Value(CONTEXT_JWT)).To(Equal(hs256_jwt))
+
+				claims, ok := CtxJWT(request.WithContext(resp.Context))
+				Expect(ok).To(BeTrue())
+				Expect(claims["name"]).To(Equal("John Doe"))
+				Expect(claims["admin"]).To(BeTrue())
+			})
+		})
+
+		Context("when a valid RSA token is passed", func() {
+			It("should verify it against the public key", func() {
+				request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", rsaToken))
+				resp := NewMiddlewareJWTWithConfig(JWTConfig{PublicKey: &privateKey.PublicKey})(response, request)
+
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Err).To(BeNil())
+
+				claims, ok := CtxJWT(request.WithContext(resp.Context))
+				Expect(ok).To(BeTrue())
+				Expect(claims["sub"]).To(Equal("rsa-user"))
+			})
+		})
+
+		Context("when the token is signed with a method that is not configured", func() {
+			It("should return a 401", func() {
+				request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", rsaToken))
+				resp := NewMiddlewareJWTWithConfig(JWTConfig{Secret: shared_secret})(response, request)
+
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+				Expect(resp.Error()).To(ContainSubstring("signing method"))
+			})
+		})
+
+		Context("when an invalid token is passed", func() {
+			It("should return a 401", func() {
+				request.Header.Add("Authorization", "Bearer foo")
+				resp := NewMiddlewareJWTWithConfig(JWTConfig{Secret: shared_secret})(response, request)
+
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			})
+		})
+
+		Context("when no token is passed", func() {
+			It("should return a 401", func() {
+				resp := NewMiddlewareJWTWithConfig(JWTConfig{Secret: shared_secret})(response, request)
+
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+				Expect(resp.Error()).To(ContainSubstring("Authorization header using the Bearer scheme"))
+			})
+		})
+
+		Context("when a custom header and scheme are configured", func() {
+			var config JWTConfig
+
+			BeforeEach(func() {
+				config = JWTConfig{Secret: shared_secret, Header: "X-Auth", Scheme: "Token"}
+			})
+
+			It("should read the token from them", func() {
+				request.Header.Add("X-Auth", fmt.Sprintf("Token %s", hs256_jwt))
+				resp := NewMiddlewareJWTWithConfig(config)(response, request)
+
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Err).To(BeNil())
+			})
+
+			It("should reject tokens using another scheme", func() {
+				request.Header.Add("X-Auth", fmt.Sprintf("Bearer %s", hs256_jwt))
+				resp := NewMiddlewareJWTWithConfig(config)(response, request)
+
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			})
+		})
+	})
+
+	Describe("handle with audience and issuer checks", func() {
+		var config JWTConfig
+
+		BeforeEach(func() {
+			config = JWTConfig{
+				Secret:    shared_secret,
+				Audiences: []string{"billing", "reports"},
+				Issuers:   []string{"https://auth.example.com", "https://sso.example.com"},
+			}
+		})
+
+		serve := func(claims jwt.MapClaims) *Response {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(shared_secret))
+			Expect(err).ToNot(HaveOccurred())
+
+			request.Header.Set("Authorization", "Bearer "+token)
+			return NewMiddlewareJWTWithConfig(config)(response, request)
+		}
+
+		Context("when the claims match", func() {
+			It("should accept any of the audiences and issuers", func() {
+				resp := serve(jwt.MapClaims{"aud": "reports", "iss": "https://sso.example.com"})
+
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Err).To(BeNil())
+				Expect(resp.Context).ToNot(BeNil())
+			})
+
+			It("should accept a list of audiences naming one of them", func() {
+				resp := serve(jwt.MapClaims{"aud": []string{"search", "billing"}, "iss": "https://auth.example.com"})
+
+				Expect(resp.Err).To(BeNil())
+			})
+		})
+
+		Context("when the audience is wrong", func() {
+			It("should return a 401", func() {
+				resp := serve(jwt.MapClaims{"aud": []string{"search"}, "iss": "https://auth.example.com"})
+
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+				Expect(resp.Error()).To(ContainSubstring("audience [search] is not accepted"))
+			})
+
+			It("should reject tokens without an audience", func() {
+				resp := serve(jwt.MapClaims{"iss": "https://auth.example.com"})
+
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			})
+		})
+
+		Context("when the issuer is wrong", func() {
+			It("should return a 401", func() {
+				resp := serve(jwt.MapClaims{"aud": "billing", "iss": "https://evil.example.com"})
+
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+				Expect(resp.Error()).To(ContainSubstring("issuer 'https://evil.example.com' is not accepted"))
+			})
+		})
+
+		Context("when no audiences or issuers are configured", func() {
+			It("should not check the claims", func() {
+				config.Audiences, config.Issuers = nil, nil
+
+				resp := serve(jwt.MapClaims{"aud": "search", "iss": "https://evil.example.com"})
+
+				Expect(resp.Err).To(BeNil())
+			})
+		})
+	})
+
+	Describe("CtxJWT", func() {
+		It("should return false without claims", func() {
+			_, ok := CtxJWT(request)
+			Expect(ok).To(BeFalse())
+		})
+	})
 })
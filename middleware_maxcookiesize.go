@@ -0,0 +1,43 @@
+package rye
+
+import (
+	"net/http"
+)
+
+type maxCookieSize struct {
+	max int
+}
+
+/*
+NewMiddlewareMaxCookieSize creates a new handler that stops the chain with a 400 when the `Cookie` header of the
+request is larger than `maxBytes`, protecting against oversized cookie abuse and downstream parsers with header
+size limits. Requests carrying several `Cookie` headers are limited on their combined size.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareMaxCookieSize(4096),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareMaxCookieSize(maxBytes int) func(rw http.ResponseWriter, req *http.Request) *Response {
+	m := &maxCookieSize{max: maxBytes}
+	return m.handle
+}
+
+func (m *maxCookieSize) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	size := 0
+	for _, cookie := range r.Header.Values("Cookie") {
+		size += len(cookie)
+	}
+
+	if size <= m.max {
+		return nil
+	}
+
+	return &Response{
+		StatusCode:    http.StatusBadRequest,
+		StopExecution: true,
+	}
+}
@@ -0,0 +1,54 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Max Cookie Size Middleware", func() {
+
+	var (
+		request  *http.Request
+		response *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("GET", "/", nil)
+		response = httptest.NewRecorder()
+	})
+
+	Describe("handle", func() {
+		Context("when the cookies are within the limit", func() {
+			It("should return nil", func() {
+				request.Header.Set("Cookie", "session=abc; theme=dark")
+
+				Expect(NewMiddlewareMaxCookieSize(23)(response, request)).To(BeNil())
+			})
+
+			It("should allow requests without cookies", func() {
+				Expect(NewMiddlewareMaxCookieSize(0)(response, request)).To(BeNil())
+			})
+		})
+
+		Context("when the cookies are over the limit", func() {
+			It("should stop the chain with a 400", func() {
+				request.Header.Set("Cookie", "session="+strings.Repeat("a", 100))
+
+				resp := NewMiddlewareMaxCookieSize(100)(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(resp.StopExecution).To(BeTrue())
+			})
+
+			It("should add up the size of several Cookie headers", func() {
+				request.Header.Add("Cookie", "session="+strings.Repeat("a", 40))
+				request.Header.Add("Cookie", "theme="+strings.Repeat("b", 40))
+
+				Expect(NewMiddlewareMaxCookieSize(60)(response, request)).ToNot(BeNil())
+			})
+		})
+	})
+})
@@ -0,0 +1,60 @@
+package rye
+
+import (
+	"net/http"
+	"strings"
+)
+
+type maxQueryParams struct {
+	max int
+}
+
+/*
+NewMiddlewareMaxQueryParams creates a new handler that stops the chain with a 400 when the request has
+more than `max` query parameters, mitigating hash-collision DoS and other abuse. Repeated keys count once
+per occurrence (`?a=1&a=2` has two parameters). Parameters are counted on the raw query string, before
+anything parses it.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareMaxQueryParams(50),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareMaxQueryParams(max int) func(rw http.ResponseWriter, req *http.Request) *Response {
+	m := &maxQueryParams{max: max}
+	return m.handle
+}
+
+func (m *maxQueryParams) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	if r.URL == nil || countQueryParams(r.URL.RawQuery) <= m.max {
+		return nil
+	}
+
+	return &Response{
+		StatusCode:    http.StatusBadRequest,
+		StopExecution: true,
+	}
+}
+
+// countQueryParams counts the non-empty parameters of a raw query string
+func countQueryParams(query string) int {
+	count := 0
+
+	for query != "" {
+		var param string
+		if i := strings.IndexByte(query, '&'); i >= 0 {
+			param, query = query[:i], query[i+1:]
+		} else {
+			param, query = query, ""
+		}
+
+		if param != "" {
+			count++
+		}
+	}
+
+	return count
+}
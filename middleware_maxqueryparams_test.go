@@ -0,0 +1,61 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Max Query Params Middleware", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+	})
+
+	Describe("handle", func() {
+		Context("when the request is within the limit", func() {
+			It("should return nil", func() {
+				request := httptest.NewRequest("GET", "/?a=1&b=2&c=3", nil)
+
+				Expect(NewMiddlewareMaxQueryParams(3)(response, request)).To(BeNil())
+			})
+
+			It("should ignore empty parameters", func() {
+				request := httptest.NewRequest("GET", "/?a=1&&b=2&", nil)
+
+				Expect(NewMiddlewareMaxQueryParams(2)(response, request)).To(BeNil())
+			})
+
+			It("should allow requests without a query string", func() {
+				request := httptest.NewRequest("GET", "/", nil)
+
+				Expect(NewMiddlewareMaxQueryParams(0)(response, request)).To(BeNil())
+			})
+		})
+
+		Context("when the request is over the limit", func() {
+			It("should stop the chain with a 400", func() {
+				request := httptest.NewRequest("GET", "/?"+strings.Repeat("a=1&", 10)+"b=2", nil)
+
+				resp := NewMiddlewareMaxQueryParams(10)(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(resp.StopExecution).To(BeTrue())
+			})
+
+			It("should count repeated keys once per occurrence", func() {
+				request := httptest.NewRequest("GET", "/?a=1&a=2&a=3", nil)
+
+				resp := NewMiddlewareMaxQueryParams(2)(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			})
+		})
+	})
+})
@@ -0,0 +1,45 @@
+package rye
+
+import (
+	"net/http"
+)
+
+type maxURLLength struct {
+	max int
+}
+
+/*
+NewMiddlewareMaxURLLength creates a new handler that stops the chain with a 414 (URI Too Long) when the
+request URI, query string included, is longer than `max` characters. This protects against abuse and
+against downstream parsers with URL length limits.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareMaxURLLength(2048),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareMaxURLLength(max int) func(rw http.ResponseWriter, req *http.Request) *Response {
+	m := &maxURLLength{max: max}
+	return m.handle
+}
+
+func (m *maxURLLength) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	length := len(r.RequestURI)
+	if r.URL != nil {
+		if l := len(r.URL.String()); l > length {
+			length = l
+		}
+	}
+
+	if length > m.max {
+		return &Response{
+			StatusCode:    http.StatusRequestURITooLong,
+			StopExecution: true,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Max URL Length Middleware", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+		handler  func(rw http.ResponseWriter, req *http.Request) *Response
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		handler = NewMiddlewareMaxURLLength(20)
+	})
+
+	Describe("handle", func() {
+		Context("when the URL is within the limit", func() {
+			It("should return nil", func() {
+				request := httptest.NewRequest("GET", "/items?page=2", nil)
+
+				resp := handler(response, request)
+				Expect(resp).To(BeNil())
+			})
+		})
+
+		Context("when the URL exceeds the limit", func() {
+			It("should stop execution with a 414", func() {
+				request := httptest.NewRequest("GET", "/"+strings.Repeat("a", 20), nil)
+
+				resp := handler(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusRequestURITooLong))
+				Expect(resp.StopExecution).To(BeTrue())
+			})
+
+			It("should count the query string", func() {
+				request := httptest.NewRequest("GET", "/items?filter="+strings.Repeat("a", 10), nil)
+
+				resp := handler(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusRequestURITooLong))
+			})
+		})
+	})
+})
@@ -0,0 +1,97 @@
+package rye
+
+import (
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_MEMORY_GUARD_SAMPLE_INTERVAL = time.Second
+)
+
+// MemoryGuardConfig configures the memory guard middleware.
+type MemoryGuardConfig struct {
+	// MaxHeapBytes is the heap usage above which requests are rejected
+	MaxHeapBytes uint64
+
+	// SampleInterval is how long a heap usage reading is reused for
+	// (defaults to DEFAULT_MEMORY_GUARD_SAMPLE_INTERVAL)
+	SampleInterval time.Duration
+
+	// HeapFunc returns the current heap usage in bytes (defaults to
+	// runtime.MemStats.HeapAlloc)
+	HeapFunc func() uint64
+}
+
+type memoryGuard struct {
+	config MemoryGuardConfig
+
+	mu         sync.Mutex
+	heap       uint64
+	lastSample time.Time
+}
+
+/*
+NewMiddlewareMemoryGuard creates a new handler that sheds load under memory pressure to avoid running out of
+memory: when heap usage exceeds `MaxHeapBytes`, the chain is stopped with a 503 and a `memguard.rejected` stat
+is emitted (through the MWHandler's statter).
+
+Reading memory stats is not free, so heap usage is sampled at most once per `SampleInterval` and the reading
+is shared by all requests in between.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareMemoryGuard(rye.MemoryGuardConfig{MaxHeapBytes: 2 << 30}),
+			yourHandler,
+		})).Methods("POST")
+*/
+func NewMiddlewareMemoryGuard(cfg MemoryGuardConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if cfg.SampleInterval <= 0 {
+		cfg.SampleInterval = DEFAULT_MEMORY_GUARD_SAMPLE_INTERVAL
+	}
+
+	if cfg.HeapFunc == nil {
+		cfg.HeapFunc = heapAlloc
+	}
+
+	m := &memoryGuard{config: cfg}
+	return m.handle
+}
+
+func (m *memoryGuard) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	if m.sample(time.Now()) <= m.config.MaxHeapBytes {
+		return nil
+	}
+
+	chainFromRequest(r).inc("memguard.rejected")
+
+	return &Response{
+		StatusCode:    http.StatusServiceUnavailable,
+		StopExecution: true,
+	}
+}
+
+// sample returns the heap usage, reading it again only once the sample interval has passed
+func (m *memoryGuard) sample(now time.Time) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lastSample.IsZero() || now.Sub(m.lastSample) >= m.config.SampleInterval {
+		m.heap = m.config.HeapFunc()
+		m.lastSample = now
+	}
+
+	return m.heap
+}
+
+// heapAlloc returns the bytes of allocated heap objects
+func heapAlloc() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	return stats.HeapAlloc
+}
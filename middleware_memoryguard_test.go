@@ -0,0 +1,93 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/InVisionApp/rye/fakes/statsdfakes"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Memory Guard Middleware", func() {
+
+	var (
+		mwHandler *MWHandler
+		incs      chan string
+		heap      uint64
+		samples   int
+		config    MemoryGuardConfig
+	)
+
+	serve := func() *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+
+		h := mwHandler.Handle([]Handler{NewMiddlewareMemoryGuard(config), textHandler})
+		h.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+
+		return response
+	}
+
+	BeforeEach(func() {
+		incs = make(chan string, 10)
+		heap = 0
+		samples = 0
+
+		fakeStatter := &statsdfakes.FakeStatter{}
+		fakeStatter.IncStub = func(name string, value int64, rate float32) error {
+			if name == "memguard.rejected" {
+				incs <- name
+			}
+			return nil
+		}
+
+		mwHandler = NewMWHandler(Config{Statter: fakeStatter, StatRate: 1})
+
+		config = MemoryGuardConfig{
+			MaxHeapBytes: 1000,
+			HeapFunc: func() uint64 {
+				samples++
+				return heap
+			},
+		}
+	})
+
+	Describe("handle", func() {
+		Context("when heap usage is below the threshold", func() {
+			It("should let the request through", func() {
+				heap = 1000
+
+				Expect(serve().Code).To(Equal(http.StatusOK))
+				Consistently(incs).ShouldNot(Receive())
+			})
+		})
+
+		Context("when heap usage exceeds the threshold", func() {
+			It("should stop the chain with a 503 and emit a stat", func() {
+				heap = 1001
+
+				response := serve()
+				Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+				Expect(response.Body.String()).To(BeEmpty())
+				Eventually(incs).Should(Receive())
+			})
+		})
+
+		Context("when requests arrive within the sample interval", func() {
+			It("should reuse the heap usage reading", func() {
+				guard := &memoryGuard{config: config}
+				guard.config.SampleInterval = time.Minute
+
+				now := time.Now()
+				guard.sample(now)
+				heap = 5000
+
+				Expect(guard.sample(now.Add(time.Second))).To(Equal(uint64(0)))
+				Expect(samples).To(Equal(1))
+
+				Expect(guard.sample(now.Add(time.Minute))).To(Equal(uint64(5000)))
+				Expect(samples).To(Equal(2))
+			})
+		})
+	})
+})
@@ -0,0 +1,60 @@
+package rye
+
+import (
+	"errors"
+	"net/http"
+)
+
+type multiAuth struct {
+	schemes []Handler
+}
+
+/*
+NewMiddlewareMultiAuth creates a new handler for endpoints accepting several kinds of credentials: it tries each
+auth handler (ie. JWT, access token, basic auth) in turn and lets the request through as soon as one of them
+authenticates it, without running the remaining ones. The response of that handler is passed on, so the identity
+it puts in the context (ie. the token under CONTEXT_JWT) is available to the rest of the chain.
+
+A handler fails when it returns an error; when they all do, the request is stopped with a 401 carrying the
+headers of every failure (so that clients get each `WWW-Authenticate` challenge).
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareMultiAuth(
+				rye.NewMiddlewareJWT(secret),
+				rye.NewMiddlewareAccessToken(tokenHeaderName, []string{token1, token2}),
+			),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareMultiAuth(schemes ...Handler) func(rw http.ResponseWriter, req *http.Request) *Response {
+	m := &multiAuth{schemes: schemes}
+	return m.handle
+}
+
+func (m *multiAuth) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	var headers http.Header
+
+	for _, scheme := range m.schemes {
+		resp := scheme(rw, r)
+		if resp == nil || resp.Err == nil {
+			return resp
+		}
+
+		for k, v := range resp.Headers {
+			if headers == nil {
+				headers = make(http.Header)
+			}
+			headers[k] = append(headers[k], v...)
+		}
+	}
+
+	return &Response{
+		Err:           errors.New("Unauthorized request: no auth scheme accepted the credentials"),
+		StatusCode:    http.StatusUnauthorized,
+		StopExecution: true,
+		Headers:       headers,
+	}
+}
@@ -0,0 +1,105 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Multi Auth Middleware", func() {
+
+	var (
+		request   *http.Request
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		schemes   []Handler
+		ranLast   bool
+		user      string
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("GET", "/reports", nil)
+		response = httptest.NewRecorder()
+		mwHandler = NewMWHandler(Config{})
+		ranLast = false
+		user = ""
+
+		schemes = []Handler{
+			NewMiddlewareAccessToken("X-Access-Token", []string{"token1"}),
+			NewMiddlewareBasicAuth(BasicAuthConfig{
+				Validator: BasicAuthCredentials(map[string]string{"admin": "s3cret"}),
+				StoreUser: true,
+			}),
+			func(rw http.ResponseWriter, r *http.Request) *Response {
+				ranLast = true
+				return nil
+			},
+		}
+	})
+
+	serve := func() {
+		h := mwHandler.Handle([]Handler{NewMiddlewareMultiAuth(schemes...), func(rw http.ResponseWriter, r *http.Request) *Response {
+			user, _ = BasicAuthUserFromContext(r.Context())
+			return nil
+		}})
+		h.ServeHTTP(response, request)
+	}
+
+	Describe("handle", func() {
+		Context("when a later scheme authenticates the request", func() {
+			It("should let the request through with its identity", func() {
+				request.SetBasicAuth("admin", "s3cret")
+
+				serve()
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(user).To(Equal("admin"))
+			})
+
+			It("should not run the remaining schemes", func() {
+				request.SetBasicAuth("admin", "s3cret")
+
+				serve()
+
+				Expect(ranLast).To(BeFalse())
+			})
+		})
+
+		Context("when the first scheme authenticates the request", func() {
+			It("should not run the others", func() {
+				request.Header.Set("X-Access-Token", "token1")
+
+				serve()
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(ranLast).To(BeFalse())
+				Expect(user).To(BeEmpty())
+			})
+		})
+
+		Context("when every scheme fails", func() {
+			It("should stop with a 401 carrying their challenges", func() {
+				schemes = schemes[:2]
+				request.SetBasicAuth("admin", "hunter2")
+
+				serve()
+
+				Expect(response.Code).To(Equal(http.StatusUnauthorized))
+				Expect(response.Header().Get("WWW-Authenticate")).To(Equal(`Basic realm="Restricted", charset="UTF-8"`))
+				Expect(response.Body.String()).To(ContainSubstring("no auth scheme accepted the credentials"))
+				Expect(user).To(BeEmpty())
+			})
+		})
+
+		Context("without schemes", func() {
+			It("should reject every request", func() {
+				schemes = nil
+
+				serve()
+
+				Expect(response.Code).To(Equal(http.StatusUnauthorized))
+			})
+		})
+	})
+})
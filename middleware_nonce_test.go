@@ -0,0 +1,86 @@
+package rye
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+type failingNonceStore struct{}
+
+func (f *failingNonceStore) Add(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return false, errors.New("store unavailable")
+}
+
+var _ = Describe("Nonce Guard Middleware", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+		handler  func(rw http.ResponseWriter, req *http.Request) *Response
+	)
+
+	newRequest := func(nonce string) *http.Request {
+		request := httptest.NewRequest("POST", "/", nil)
+		if nonce != "" {
+			request.Header.Set("X-Nonce", nonce)
+		}
+
+		return request
+	}
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		handler = NewMiddlewareNonceGuard(nil, "", time.Minute)
+	})
+
+	Describe("handle", func() {
+		Context("when the nonce is fresh", func() {
+			It("should accept the request", func() {
+				Expect(handler(response, newRequest("abc"))).To(BeNil())
+				Expect(handler(response, newRequest("def"))).To(BeNil())
+			})
+		})
+
+		Context("when the nonce is reused", func() {
+			It("should reject the request with a 401", func() {
+				Expect(handler(response, newRequest("abc"))).To(BeNil())
+
+				resp := handler(response, newRequest("abc"))
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+				Expect(resp.Error()).To(ContainSubstring("already been used"))
+			})
+
+			It("should accept it again once the ttl expired", func() {
+				handler = NewMiddlewareNonceGuard(nil, "", 10*time.Millisecond)
+
+				Expect(handler(response, newRequest("abc"))).To(BeNil())
+				time.Sleep(20 * time.Millisecond)
+				Expect(handler(response, newRequest("abc"))).To(BeNil())
+			})
+		})
+
+		Context("when the nonce is missing", func() {
+			It("should reject the request with a 401", func() {
+				resp := handler(response, newRequest(""))
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+				Expect(resp.Error()).To(ContainSubstring("X-Nonce header missing"))
+			})
+		})
+
+		Context("when the store fails", func() {
+			It("should return a 500", func() {
+				handler = NewMiddlewareNonceGuard(&failingNonceStore{}, "", time.Minute)
+
+				resp := handler(response, newRequest("abc"))
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+			})
+		})
+	})
+})
@@ -0,0 +1,67 @@
+package rye
+
+import (
+	"net/http"
+	"strings"
+)
+
+var (
+	// Headers whose values are trimmed when none are specified
+	DEFAULT_NORMALIZE_HEADERS = []string{"Accept", "Authorization", "Content-Type", "X-Request-Id"}
+)
+
+type normalizeHeaders struct {
+	trim map[string]bool
+}
+
+/*
+NewMiddlewareNormalizeHeaders creates a new handler that normalizes request headers for downstream handlers:
+header names are canonicalized (and stripped of stray whitespace) and the values of the given headers are
+trimmed of surrounding whitespace, preventing subtle bugs with clients sending ie. `Authorization:  Bearer x `.
+
+To stay conservative, only the values of the given headers are trimmed; when none are given,
+DEFAULT_NORMALIZE_HEADERS are trimmed.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareNormalizeHeaders(), // or ie. NewMiddlewareNormalizeHeaders("Authorization", "X-Api-Key")
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareNormalizeHeaders(headers ...string) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if len(headers) == 0 {
+		headers = DEFAULT_NORMALIZE_HEADERS
+	}
+
+	n := &normalizeHeaders{
+		trim: make(map[string]bool, len(headers)),
+	}
+
+	for _, header := range headers {
+		n.trim[http.CanonicalHeaderKey(header)] = true
+	}
+
+	return n.handle
+}
+
+func (n *normalizeHeaders) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	normalized := make(http.Header, len(r.Header))
+
+	for key, values := range r.Header {
+		key = http.CanonicalHeaderKey(strings.TrimSpace(key))
+
+		for _, value := range values {
+			if n.trim[key] {
+				value = strings.TrimSpace(value)
+			}
+
+			normalized[key] = append(normalized[key], value)
+		}
+	}
+
+	r.Header = normalized
+
+	return nil
+}
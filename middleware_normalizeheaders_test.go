@@ -0,0 +1,66 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Normalize Headers Middleware", func() {
+
+	var (
+		request   *http.Request
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		seen      http.Header
+	)
+
+	// headersHandler records the headers seen downstream
+	headersHandler := func(rw http.ResponseWriter, r *http.Request) *Response {
+		seen = r.Header
+		return nil
+	}
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		request = httptest.NewRequest("GET", "/", nil)
+		request.Header = http.Header{
+			"Authorization ": []string{"  Bearer x "},
+			"x-api-key":      []string{" key "},
+			"X-Custom":       []string{" kept as is "},
+		}
+		mwHandler = NewMWHandler(Config{})
+		seen = nil
+	})
+
+	Describe("handle", func() {
+		It("should canonicalize keys and trim the default headers for downstream handlers", func() {
+			h := mwHandler.Handle([]Handler{NewMiddlewareNormalizeHeaders(), headersHandler})
+			h.ServeHTTP(response, request)
+
+			Expect(seen.Get("Authorization")).To(Equal("Bearer x"))
+			Expect(seen["X-Api-Key"]).To(Equal([]string{" key "}))
+			Expect(seen.Get("X-Custom")).To(Equal(" kept as is "))
+		})
+
+		It("should only trim the configured headers", func() {
+			h := mwHandler.Handle([]Handler{NewMiddlewareNormalizeHeaders("x-api-key"), headersHandler})
+			h.ServeHTTP(response, request)
+
+			Expect(seen.Get("X-Api-Key")).To(Equal("key"))
+			Expect(seen.Get("Authorization")).To(Equal("  Bearer x "))
+		})
+
+		It("should merge values of keys that canonicalize to the same name", func() {
+			request.Header = http.Header{
+				"Accept": []string{"text/plain"},
+				"accept": []string{" application/json"},
+			}
+
+			resp := NewMiddlewareNormalizeHeaders()(response, request)
+			Expect(resp).To(BeNil())
+			Expect(request.Header["Accept"]).To(ConsistOf("text/plain", "application/json"))
+		})
+	})
+})
@@ -0,0 +1,140 @@
+package rye
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+const (
+	// Context key holding the path template matched by NewMiddlewarePathTemplate
+	CONTEXT_PATH_TEMPLATE = "rye-middlewarepathtemplate-template"
+
+	// UNMATCHED_PATH_TEMPLATE is the template of paths matching none of the templates
+	UNMATCHED_PATH_TEMPLATE = "unmatched"
+)
+
+// pathTemplate is a parsed path template (ie. `/users/:id/*`)
+type pathTemplate struct {
+	template string
+	segments []string
+	wildcard bool
+	static   int
+}
+
+type pathTemplates struct {
+	templates []pathTemplate
+}
+
+/*
+NewMiddlewarePathTemplate creates a new handler that matches the request path against path templates and stores the
+template it matched in the context (`/users/123` is stored as `/users/:id`). Raw paths make for stats and logs of
+unbounded cardinality as soon as they carry IDs; templates don't, so they are safe to name stats after. Paths matching
+no template are stored as UNMATCHED_PATH_TEMPLATE.
+
+In templates, a `:name` segment matches any single segment and a trailing `*` matches the rest of the path. When
+several templates match, the one with the most literal segments wins (`/users/me` over `/users/:id`).
+
+The template is available to the rest of the chain through `rye.PathTemplateFromContext`, and is added to the
+entries of `Config.Logger` as their route.
+
+Example usage:
+
+	routes.PathPrefix("/").Handler(a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewarePathTemplate([]string{"/users/:id", "/users/:id/posts/:post", "/static/*"}),
+			yourHandler,
+		}))
+*/
+func NewMiddlewarePathTemplate(patterns []string) func(rw http.ResponseWriter, req *http.Request) *Response {
+	p := &pathTemplates{}
+
+	for _, pattern := range patterns {
+		p.templates = append(p.templates, parsePathTemplate(pattern))
+	}
+
+	return p.handle
+}
+
+func (p *pathTemplates) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	return &Response{
+		Context: context.WithValue(r.Context(), CONTEXT_PATH_TEMPLATE, p.match(r.URL.Path)),
+	}
+}
+
+// match returns the template matching the path (the most literal one when several do)
+func (p *pathTemplates) match(path string) string {
+	segments := splitPath(path)
+
+	var best *pathTemplate
+	for i := range p.templates {
+		t := &p.templates[i]
+
+		if t.matches(segments) && (best == nil || t.static > best.static) {
+			best = t
+		}
+	}
+
+	if best == nil {
+		return UNMATCHED_PATH_TEMPLATE
+	}
+
+	return best.template
+}
+
+func parsePathTemplate(template string) pathTemplate {
+	t := pathTemplate{
+		template: template,
+		segments: splitPath(template),
+	}
+
+	if n := len(t.segments); n > 0 && t.segments[n-1] == "*" {
+		t.segments = t.segments[:n-1]
+		t.wildcard = true
+	}
+
+	for _, segment := range t.segments {
+		if !strings.HasPrefix(segment, ":") {
+			t.static++
+		}
+	}
+
+	return t
+}
+
+func (t *pathTemplate) matches(segments []string) bool {
+	if len(segments) < len(t.segments) || (!t.wildcard && len(segments) != len(t.segments)) {
+		return false
+	}
+
+	for i, segment := range t.segments {
+		if strings.HasPrefix(segment, ":") {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+
+		if segment != segments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// splitPath splits a path into its segments, ignoring leading and trailing slashes
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+
+	return strings.Split(path, "/")
+}
+
+// PathTemplateFromContext returns the path template stored by NewMiddlewarePathTemplate (if any)
+func PathTemplateFromContext(ctx context.Context) (string, bool) {
+	template, ok := ctx.Value(CONTEXT_PATH_TEMPLATE).(string)
+	return template, ok
+}
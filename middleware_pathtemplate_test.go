@@ -0,0 +1,91 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Path Template Middleware", func() {
+
+	var (
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		patterns  []string
+		template  string
+		stored    bool
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		mwHandler = NewMWHandler(Config{})
+		patterns = []string{"/users/:id", "/users/me", "/users/:id/posts/:post", "/static/*"}
+		template, stored = "", false
+	})
+
+	serve := func(path string) {
+		h := mwHandler.Handle([]Handler{NewMiddlewarePathTemplate(patterns), func(rw http.ResponseWriter, r *http.Request) *Response {
+			template, stored = PathTemplateFromContext(r.Context())
+			return nil
+		}})
+		h.ServeHTTP(response, httptest.NewRequest("GET", path, nil))
+	}
+
+	Describe("handle", func() {
+		It("should store the template matching the path", func() {
+			serve("/users/123")
+
+			Expect(stored).To(BeTrue())
+			Expect(template).To(Equal("/users/:id"))
+		})
+
+		It("should match every parameter", func() {
+			serve("/users/123/posts/456/")
+
+			Expect(template).To(Equal("/users/:id/posts/:post"))
+		})
+
+		It("should prefer the most literal template", func() {
+			serve("/users/me")
+
+			Expect(template).To(Equal("/users/me"))
+		})
+
+		It("should match the rest of the path with a wildcard", func() {
+			serve("/static/css/site.css")
+
+			Expect(template).To(Equal("/static/*"))
+		})
+
+		It("should store unmatched paths under the catch-all template", func() {
+			serve("/users/123/comments")
+
+			Expect(template).To(Equal(UNMATCHED_PATH_TEMPLATE))
+		})
+
+		It("should not match empty parameters", func() {
+			serve("/users//posts/456")
+
+			Expect(template).To(Equal(UNMATCHED_PATH_TEMPLATE))
+		})
+
+		It("should add the template to the log entries of Config.Logger", func() {
+			logger := &recordingLogger{}
+			mwHandler = NewMWHandler(Config{Logger: logger})
+
+			serve("/users/123")
+
+			Expect(logger.entries).To(HaveLen(2))
+			Expect(logger.entries[1].Path).To(Equal("/users/123"))
+			Expect(logger.entries[1].Route).To(Equal("/users/:id"))
+		})
+	})
+
+	Describe("PathTemplateFromContext", func() {
+		It("should report a missing template", func() {
+			_, ok := PathTemplateFromContext(httptest.NewRequest("GET", "/", nil).Context())
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,93 @@
+package rye
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+type perIPConcurrency struct {
+	max int
+
+	mu       sync.Mutex
+	inflight map[string]int
+}
+
+/*
+NewMiddlewarePerIPConcurrency creates a new handler that caps the number of requests from a single client IP
+running the rest of the chain at the same time, so that one client can't monopolize the capacity of the
+service. Requests past the cap are rejected right away with a 429.
+
+Counters are dropped as soon as a client has no request in flight, so idle clients take no memory. The slot
+is released once the chain finishes, so this middleware must be used within `MWHandler.Handle`.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewarePerIPConcurrency(5),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewarePerIPConcurrency(max int) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if max <= 0 {
+		max = 1
+	}
+
+	p := &perIPConcurrency{
+		max:      max,
+		inflight: make(map[string]int),
+	}
+
+	return p.handle
+}
+
+func (p *perIPConcurrency) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	if chainFromRequest(r) == nil {
+		// Without a chain there is nothing to release the slot when the request is done
+		return nil
+	}
+
+	ip := remoteHost(r)
+
+	if !p.acquire(ip) {
+		return &Response{
+			Err:           fmt.Errorf("Too many concurrent requests from %v", ip),
+			StatusCode:    http.StatusTooManyRequests,
+			StopExecution: true,
+		}
+	}
+
+	return &Response{
+		Writer: newStatusRecordingWriter(rw, func(status int) {
+			p.release(ip)
+		}),
+	}
+}
+
+// acquire takes a slot for the IP, reporting whether one was free
+func (p *perIPConcurrency) acquire(ip string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.inflight[ip] >= p.max {
+		return false
+	}
+
+	p.inflight[ip]++
+
+	return true
+}
+
+// release frees a slot of the IP, dropping its counter once it has none in flight
+func (p *perIPConcurrency) release(ip string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.inflight[ip] <= 1 {
+		delete(p.inflight, ip)
+		return
+	}
+
+	p.inflight[ip]--
+}
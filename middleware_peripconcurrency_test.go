@@ -0,0 +1,123 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Per IP Concurrency Middleware", func() {
+
+	var (
+		mwHandler       *MWHandler
+		release         chan struct{}
+		started         chan struct{}
+		inflight        sync.WaitGroup
+		blockingHandler Handler
+	)
+
+	serve := func(handlers []Handler, remoteAddr string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", "/", nil)
+		request.RemoteAddr = remoteAddr
+
+		response := httptest.NewRecorder()
+		mwHandler.Handle(handlers).ServeHTTP(response, request)
+		return response
+	}
+
+	// serveInBackground serves a request holding its slot until release is closed
+	serveInBackground := func(limit Handler, remoteAddr string) {
+		inflight.Add(1)
+		go func() {
+			defer GinkgoRecover()
+			defer inflight.Done()
+			serve([]Handler{limit, blockingHandler}, remoteAddr)
+		}()
+		Eventually(started).Should(Receive())
+	}
+
+	BeforeEach(func() {
+		mwHandler = NewMWHandler(Config{})
+
+		// Requests may outlive the spec, so the handler holds on
+		// to this spec's channels rather than the shared variables
+		specRelease, specStarted := make(chan struct{}), make(chan struct{}, 10)
+		release, started = specRelease, specStarted
+
+		// blockingHandler holds its slot until released
+		blockingHandler = func(rw http.ResponseWriter, r *http.Request) *Response {
+			specStarted <- struct{}{}
+			<-specRelease
+			return nil
+		}
+	})
+
+	Describe("handle", func() {
+		It("should admit requests up to the limit", func() {
+			limit := NewMiddlewarePerIPConcurrency(2)
+
+			serveInBackground(limit, "10.0.0.1:1234")
+			defer close(release)
+
+			response := serve([]Handler{limit, textHandler}, "10.0.0.1:5678")
+			Expect(response.Code).To(Equal(http.StatusOK))
+		})
+
+		It("should reject requests past the limit from the same IP", func() {
+			limit := NewMiddlewarePerIPConcurrency(1)
+
+			serveInBackground(limit, "10.0.0.1:1234")
+			defer close(release)
+
+			response := serve([]Handler{limit, textHandler}, "10.0.0.1:5678")
+			Expect(response.Code).To(Equal(http.StatusTooManyRequests))
+			Expect(response.Body.String()).ToNot(Equal("plain text"))
+		})
+
+		It("should keep IPs isolated from one another", func() {
+			limit := NewMiddlewarePerIPConcurrency(1)
+
+			serveInBackground(limit, "10.0.0.1:1234")
+			defer close(release)
+
+			response := serve([]Handler{limit, textHandler}, "10.0.0.2:1234")
+			Expect(response.Code).To(Equal(http.StatusOK))
+		})
+
+		It("should free the slot and drop the counter once the request is done", func() {
+			p := &perIPConcurrency{max: 1, inflight: make(map[string]int)}
+			limit := p.handle
+
+			serveInBackground(limit, "10.0.0.1:1234")
+			close(release)
+			inflight.Wait()
+
+			p.mu.Lock()
+			Expect(p.inflight).To(BeEmpty())
+			p.mu.Unlock()
+
+			release = make(chan struct{})
+			response := serve([]Handler{limit, textHandler}, "10.0.0.1:5678")
+			Expect(response.Code).To(Equal(http.StatusOK))
+		})
+
+		It("should free the slot of failed requests", func() {
+			p := &perIPConcurrency{max: 1, inflight: make(map[string]int)}
+
+			serve([]Handler{p.handle, failureHandler}, "10.0.0.1:1234")
+
+			p.mu.Lock()
+			Expect(p.inflight).To(BeEmpty())
+			p.mu.Unlock()
+		})
+
+		Context("when used outside of a chain", func() {
+			It("should return nil", func() {
+				limit := NewMiddlewarePerIPConcurrency(1)
+				Expect(limit(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))).To(BeNil())
+			})
+		})
+	})
+})
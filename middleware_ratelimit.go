@@ -0,0 +1,183 @@
+package rye
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// Context key holding the RateLimit of the current request
+	CONTEXT_RATE_LIMIT = "rye-middlewareratelimit-ratelimit"
+
+	DEFAULT_RATE_LIMIT_INTERVAL = time.Minute
+)
+
+// RateLimitConfig configures the rate limit middleware.
+type RateLimitConfig struct {
+	// Limit is the number of requests a client may make per Interval (and the size of its burst)
+	Limit int
+
+	// Interval over which Limit requests are allowed (defaults to DEFAULT_RATE_LIMIT_INTERVAL)
+	Interval time.Duration
+
+	// KeyFunc returns the key of the client making the request (defaults to its remote IP)
+	KeyFunc func(r *http.Request) string
+}
+
+// RateLimit describes a client's rate limit budget after the current request.
+type RateLimit struct {
+	// Limit is the maximum number of requests allowed per interval
+	Limit int
+
+	// Remaining is the number of requests the client can still make right away
+	Remaining int
+
+	// Reset is when the client's budget will be fully replenished
+	Reset time.Time
+
+	// RetryAfter is how long the client has to wait before its next request is
+	// allowed (zero if it can make one right away)
+	RetryAfter time.Duration
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimit struct {
+	config RateLimitConfig
+	rate   float64 // tokens per second
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+/*
+NewMiddlewareRateLimit creates a new handler that limits each client (by remote IP, or by the key returned
+by `KeyFunc`) to `Limit` requests per `Interval` using a token bucket. Once the budget is exhausted the chain
+is stopped with a 429 and a `Retry-After` header, and the `ratelimit.exceeded` stat is incremented. Buckets
+of idle clients are dropped over time so memory stays bounded.
+
+The client's budget is reported on every response through the `X-RateLimit-Limit`, `X-RateLimit-Remaining`
+and `X-RateLimit-Reset` (unix time at which the budget is fully replenished) headers, and is available to
+handlers through `rye.RateLimitFromContext`.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareRateLimit(rye.RateLimitConfig{Limit: 100, Interval: time.Minute}),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareRateLimit(cfg RateLimitConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if cfg.Limit <= 0 {
+		cfg.Limit = 1
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = DEFAULT_RATE_LIMIT_INTERVAL
+	}
+
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = remoteHost
+	}
+
+	l := &rateLimit{
+		config:  cfg,
+		rate:    float64(cfg.Limit) / cfg.Interval.Seconds(),
+		buckets: make(map[string]*tokenBucket),
+	}
+
+	return l.handle
+}
+
+func (l *rateLimit) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	limit, allowed := l.take(l.config.KeyFunc(r), time.Now())
+
+	rw.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit.Limit))
+	rw.Header().Set("X-RateLimit-Remaining", strconv.Itoa(limit.Remaining))
+	rw.Header().Set("X-RateLimit-Reset", strconv.FormatInt(limit.Reset.Unix(), 10))
+
+	if !allowed {
+		chainFromRequest(r).inc("ratelimit.exceeded")
+
+		// Retry-After is in whole seconds; round up so the client doesn't come back too early
+		rw.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(limit.RetryAfter.Seconds())), 10))
+
+		return &Response{
+			StatusCode:    http.StatusTooManyRequests,
+			StopExecution: true,
+		}
+	}
+
+	return &Response{
+		Context: context.WithValue(r.Context(), CONTEXT_RATE_LIMIT, limit),
+	}
+}
+
+// take tries to take a token from the key's bucket, returning the resulting budget
+func (l *rateLimit) take(key string, now time.Time) (RateLimit, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	capacity := float64(l.config.Limit)
+
+	l.prune(now)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, last: now}
+		l.buckets[key] = bucket
+	}
+
+	// Refill for the time elapsed since the bucket was last used
+	bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+
+	refill := time.Duration((capacity - bucket.tokens) / l.rate * float64(time.Second))
+
+	var retryAfter time.Duration
+	if !allowed {
+		retryAfter = time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	}
+
+	return RateLimit{
+		Limit:      l.config.Limit,
+		Remaining:  int(bucket.tokens),
+		Reset:      now.Add(refill),
+		RetryAfter: retryAfter,
+	}, allowed
+}
+
+// prune drops (at most once per interval) the buckets of clients idle long enough for them to be
+// full again, which are no different from new buckets; this bounds the memory used by the limiter
+func (l *rateLimit) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < l.config.Interval {
+		return
+	}
+	l.lastPrune = now
+
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= l.config.Interval {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RateLimitFromContext returns the rate limit budget stored by NewMiddlewareRateLimit (if any)
+func RateLimitFromContext(ctx context.Context) (RateLimit, bool) {
+	limit, ok := ctx.Value(CONTEXT_RATE_LIMIT).(RateLimit)
+	return limit, ok
+}
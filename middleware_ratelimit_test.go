@@ -0,0 +1,193 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/InVisionApp/rye/fakes/statsdfakes"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rate Limit Middleware", func() {
+
+	var (
+		mwHandler *MWHandler
+		limit     func(rw http.ResponseWriter, req *http.Request) *Response
+		seen      RateLimit
+	)
+
+	// budgetHandler records the budget it finds in the context
+	budgetHandler := func(rw http.ResponseWriter, r *http.Request) *Response {
+		seen, _ = RateLimitFromContext(r.Context())
+		return nil
+	}
+
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", "/", nil)
+		request.RemoteAddr = remoteAddr
+
+		mwHandler.Handle([]Handler{limit, budgetHandler}).ServeHTTP(response, request)
+		return response
+	}
+
+	BeforeEach(func() {
+		mwHandler = NewMWHandler(Config{})
+		limit = NewMiddlewareRateLimit(RateLimitConfig{Limit: 2, Interval: time.Minute})
+		seen = RateLimit{}
+	})
+
+	Describe("handle", func() {
+		Context("when the client is within its budget", func() {
+			It("should set the rate limit headers", func() {
+				before := time.Now()
+				response := serve("10.0.0.1:1234")
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(response.Header().Get("X-RateLimit-Limit")).To(Equal("2"))
+				Expect(response.Header().Get("X-RateLimit-Remaining")).To(Equal("1"))
+
+				// A single token takes half the interval to come back
+				reset, err := strconv.ParseInt(response.Header().Get("X-RateLimit-Reset"), 10, 64)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reset).To(BeNumerically("~", before.Add(30*time.Second).Unix(), 1))
+
+				Expect(serve("10.0.0.1:1234").Header().Get("X-RateLimit-Remaining")).To(Equal("0"))
+			})
+
+			It("should expose the budget through the context", func() {
+				serve("10.0.0.1:1234")
+
+				Expect(seen.Limit).To(Equal(2))
+				Expect(seen.Remaining).To(Equal(1))
+				Expect(seen.Reset).To(BeTemporally("~", time.Now().Add(30*time.Second), time.Second))
+			})
+		})
+
+		Context("when the client exhausted its budget", func() {
+			It("should return a 429 with the rate limit headers", func() {
+				serve("10.0.0.1:1234")
+				serve("10.0.0.1:1234")
+
+				response := serve("10.0.0.1:1234")
+				Expect(response.Code).To(Equal(http.StatusTooManyRequests))
+				Expect(response.Header().Get("X-RateLimit-Remaining")).To(Equal("0"))
+			})
+
+			It("should tell the client when to retry", func() {
+				serve("10.0.0.1:1234")
+				serve("10.0.0.1:1234")
+
+				// A single token takes half the interval to come back
+				response := serve("10.0.0.1:1234")
+				Expect(response.Header().Get("Retry-After")).To(Equal("30"))
+			})
+
+			It("should stop the chain", func() {
+				serve("10.0.0.1:1234")
+				serve("10.0.0.1:1234")
+
+				seen = RateLimit{}
+				serve("10.0.0.1:1234")
+				Expect(seen).To(Equal(RateLimit{}))
+			})
+
+			It("should emit the ratelimit.exceeded stat", func() {
+				fakeStatter := &statsdfakes.FakeStatter{}
+				exceeded := make(chan string, 10)
+				fakeStatter.IncStub = func(name string, value int64, rate float32) error {
+					if name == "ratelimit.exceeded" {
+						exceeded <- name
+					}
+					return nil
+				}
+				mwHandler = NewMWHandler(Config{Statter: fakeStatter, StatRate: 1})
+
+				serve("10.0.0.1:1234")
+				serve("10.0.0.1:1234")
+				Consistently(exceeded).ShouldNot(Receive())
+
+				serve("10.0.0.1:1234")
+				Eventually(exceeded).Should(Receive())
+			})
+
+			It("should not affect other clients", func() {
+				serve("10.0.0.1:1234")
+				serve("10.0.0.1:1234")
+
+				Expect(serve("10.0.0.2:1234").Code).To(Equal(http.StatusOK))
+			})
+		})
+
+		Context("when a KeyFunc is set", func() {
+			It("should limit clients by their key", func() {
+				limit = NewMiddlewareRateLimit(RateLimitConfig{
+					Limit:    1,
+					Interval: time.Minute,
+					KeyFunc: func(r *http.Request) string {
+						return "everyone"
+					},
+				})
+
+				Expect(serve("10.0.0.1:1234").Code).To(Equal(http.StatusOK))
+				Expect(serve("10.0.0.2:1234").Code).To(Equal(http.StatusTooManyRequests))
+			})
+		})
+	})
+
+	Describe("take", func() {
+		It("should refill the bucket over time", func() {
+			l := &rateLimit{
+				config:  RateLimitConfig{Limit: 2, Interval: time.Minute},
+				rate:    2.0 / 60,
+				buckets: make(map[string]*tokenBucket),
+			}
+
+			now := time.Now()
+			l.take("key", now)
+			l.take("key", now)
+
+			_, allowed := l.take("key", now)
+			Expect(allowed).To(BeFalse())
+
+			budget, allowed := l.take("key", now.Add(30*time.Second))
+			Expect(allowed).To(BeTrue())
+			Expect(budget.Remaining).To(Equal(0))
+		})
+
+		It("should report how long until the next request is allowed", func() {
+			l := &rateLimit{
+				config:  RateLimitConfig{Limit: 2, Interval: time.Minute},
+				rate:    2.0 / 60,
+				buckets: make(map[string]*tokenBucket),
+			}
+
+			now := time.Now()
+			budget, _ := l.take("key", now)
+			Expect(budget.RetryAfter).To(BeZero())
+
+			l.take("key", now)
+			budget, _ = l.take("key", now.Add(10*time.Second))
+			Expect(budget.RetryAfter).To(BeNumerically("~", 20*time.Second, time.Millisecond))
+		})
+
+		It("should drop the buckets of idle clients", func() {
+			l := &rateLimit{
+				config:  RateLimitConfig{Limit: 2, Interval: time.Minute},
+				rate:    2.0 / 60,
+				buckets: make(map[string]*tokenBucket),
+			}
+
+			now := time.Now()
+			l.take("idle", now)
+			l.take("active", now.Add(50*time.Second))
+			Expect(l.buckets).To(HaveLen(2))
+
+			l.take("active", now.Add(61*time.Second))
+			Expect(l.buckets).To(HaveLen(1))
+			Expect(l.buckets).To(HaveKey("active"))
+		})
+	})
+})
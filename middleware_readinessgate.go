@@ -0,0 +1,90 @@
+package rye
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var (
+	// Paths let through by the readiness gate while the service is not ready
+	DEFAULT_READINESS_GATE_HEALTH_PATHS = []string{"/health", "/healthz", "/ready", "/readyz", "/live", "/livez"}
+)
+
+// ReadinessGateConfig configures the readiness gate middleware.
+type ReadinessGateConfig struct {
+	// IsReady reports whether the service is ready to serve traffic
+	IsReady func() bool
+
+	// HealthPaths are let through while the service is not ready, along with the paths
+	// below them (defaults to DEFAULT_READINESS_GATE_HEALTH_PATHS)
+	HealthPaths []string
+}
+
+type readinessGate struct {
+	config ReadinessGateConfig
+}
+
+/*
+NewMiddlewareReadinessGate creates a new handler that keeps traffic away from a service that is not ready to serve
+it yet (ie. during startup warmup or while reconnecting to a dependency): while `isReady` returns false, requests
+are stopped with a 503, except for the health endpoints (see DEFAULT_READINESS_GATE_HEALTH_PATHS) so that
+orchestrators can still probe the service. Use NewMiddlewareReadinessGateWithConfig to name the health endpoints.
+
+Example usage:
+
+	routes.PathPrefix("/").Handler(a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareReadinessGate(cache.IsWarm),
+			yourHandler,
+		}))
+*/
+func NewMiddlewareReadinessGate(isReady func() bool) func(rw http.ResponseWriter, req *http.Request) *Response {
+	return NewMiddlewareReadinessGateWithConfig(ReadinessGateConfig{IsReady: isReady})
+}
+
+/*
+NewMiddlewareReadinessGateWithConfig works like NewMiddlewareReadinessGate, with more options.
+
+Example usage:
+
+	routes.PathPrefix("/").Handler(a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareReadinessGateWithConfig(rye.ReadinessGateConfig{
+				IsReady:     cache.IsWarm,
+				HealthPaths: []string{"/status"},
+			}),
+			yourHandler,
+		}))
+*/
+func NewMiddlewareReadinessGateWithConfig(cfg ReadinessGateConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if len(cfg.HealthPaths) == 0 {
+		cfg.HealthPaths = DEFAULT_READINESS_GATE_HEALTH_PATHS
+	}
+
+	g := &readinessGate{config: cfg}
+	return g.handle
+}
+
+func (g *readinessGate) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	if g.config.IsReady == nil || g.config.IsReady() || g.isHealthPath(r.URL.Path) {
+		return nil
+	}
+
+	return &Response{
+		Err:           errors.New("Service is not ready"),
+		StatusCode:    http.StatusServiceUnavailable,
+		StopExecution: true,
+	}
+}
+
+// isHealthPath tells whether the path is (or is below) one of the health paths
+func (g *readinessGate) isHealthPath(path string) bool {
+	for _, healthPath := range g.config.HealthPaths {
+		if path == healthPath || strings.HasPrefix(path, strings.TrimSuffix(healthPath, "/")+"/") {
+			return true
+		}
+	}
+
+	return false
+}
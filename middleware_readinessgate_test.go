@@ -0,0 +1,68 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Readiness Gate Middleware", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+		ready    bool
+	)
+
+	isReady := func() bool {
+		return ready
+	}
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		ready = false
+	})
+
+	Describe("handle", func() {
+		Context("when the service is not ready", func() {
+			It("should reject business paths with a 503", func() {
+				resp := NewMiddlewareReadinessGate(isReady)(response, httptest.NewRequest("GET", "/items", nil))
+
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+				Expect(resp.StopExecution).To(BeTrue())
+				Expect(resp.Error()).To(Equal("Service is not ready"))
+			})
+
+			It("should let the health checks through", func() {
+				for _, path := range []string{"/healthz", "/readyz", "/health/db"} {
+					Expect(NewMiddlewareReadinessGate(isReady)(response, httptest.NewRequest("GET", path, nil))).To(BeNil(), path)
+				}
+			})
+
+			It("should not mistake paths sharing a health path's prefix for health checks", func() {
+				Expect(NewMiddlewareReadinessGate(isReady)(response, httptest.NewRequest("GET", "/healthcare", nil))).ToNot(BeNil())
+			})
+
+			It("should let the configured health paths through instead of the default ones", func() {
+				handler := NewMiddlewareReadinessGateWithConfig(ReadinessGateConfig{
+					IsReady:     isReady,
+					HealthPaths: []string{"/status"},
+				})
+
+				Expect(handler(response, httptest.NewRequest("GET", "/status", nil))).To(BeNil())
+				Expect(handler(response, httptest.NewRequest("GET", "/healthz", nil))).ToNot(BeNil())
+			})
+		})
+
+		Context("when the service is ready", func() {
+			It("should let everything through", func() {
+				ready = true
+
+				for _, path := range []string{"/items", "/healthz"} {
+					Expect(NewMiddlewareReadinessGate(isReady)(response, httptest.NewRequest("GET", path, nil))).To(BeNil(), path)
+				}
+			})
+		})
+	})
+})
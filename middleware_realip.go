@@ -0,0 +1,73 @@
+package rye
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+type realIP struct {
+	trustedProxies []string
+}
+
+/*
+NewMiddlewareRealIP creates a new handler for services behind proxies or load balancers: requests coming from
+one of the trusted proxies (given in CIDR notation) have their `RemoteAddr` replaced with the address of the
+client, taken from `X-Forwarded-For` (the right-most address that is not a trusted proxy) or else `X-Real-IP`.
+The rest of the chain (ie. NewMiddlewareCIDR or NewMiddlewarePerIPConcurrency) then sees the client's address.
+
+Requests from other addresses are left alone, as their headers could be spoofed by the client.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareRealIP([]string{"10.0.0.0/8"}),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareRealIP(trustedProxies []string) func(rw http.ResponseWriter, req *http.Request) *Response {
+	i := &realIP{trustedProxies: trustedProxies}
+	return i.handle
+}
+
+func (i *realIP) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	host, port, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil || !i.trusted(host) {
+		return nil
+	}
+
+	if client := i.clientIP(r); client != "" {
+		r.RemoteAddr = net.JoinHostPort(client, port)
+	}
+
+	return nil
+}
+
+// clientIP returns the address of the client according to the forwarding headers (if any)
+func (i *realIP) clientIP(r *http.Request) string {
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+
+	for j := len(forwarded) - 1; j >= 0; j-- {
+		addr := strings.TrimSpace(forwarded[j])
+		if net.ParseIP(addr) == nil {
+			continue
+		}
+
+		if !i.trusted(addr) {
+			return addr
+		}
+	}
+
+	if addr := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(addr) != nil {
+		return addr
+	}
+
+	return ""
+}
+
+// trusted reports whether the address is one of the trusted proxies
+func (i *realIP) trusted(addr string) bool {
+	included, err := inCIDRs(addr, i.trustedProxies)
+	return err == nil && included
+}
@@ -0,0 +1,60 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Real IP Middleware", func() {
+
+	var (
+		request  *http.Request
+		response *httptest.ResponseRecorder
+		handler  func(rw http.ResponseWriter, req *http.Request) *Response
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("GET", "/", nil)
+		request.RemoteAddr = "10.0.0.5:4321"
+		response = httptest.NewRecorder()
+		handler = NewMiddlewareRealIP([]string{"10.0.0.0/8"})
+	})
+
+	Describe("handle", func() {
+		It("should take the client address from X-Forwarded-For", func() {
+			request.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.9")
+
+			Expect(handler(response, request)).To(BeNil())
+			Expect(request.RemoteAddr).To(Equal("203.0.113.7:4321"))
+		})
+
+		It("should skip addresses spoofed by the client in X-Forwarded-For", func() {
+			request.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.7")
+
+			handler(response, request)
+			Expect(request.RemoteAddr).To(Equal("203.0.113.7:4321"))
+		})
+
+		It("should fall back to X-Real-IP", func() {
+			request.Header.Set("X-Real-IP", "203.0.113.7")
+
+			handler(response, request)
+			Expect(request.RemoteAddr).To(Equal("203.0.113.7:4321"))
+		})
+
+		It("should leave the address alone without forwarding headers", func() {
+			handler(response, request)
+			Expect(request.RemoteAddr).To(Equal("10.0.0.5:4321"))
+		})
+
+		It("should ignore the headers of requests not coming from a trusted proxy", func() {
+			request.RemoteAddr = "198.51.100.1:4321"
+			request.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+			handler(response, request)
+			Expect(request.RemoteAddr).To(Equal("198.51.100.1:4321"))
+		})
+	})
+})
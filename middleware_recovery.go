@@ -0,0 +1,64 @@
+package rye
+
+import (
+	"errors"
+	"net/http"
+	"runtime/debug"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+/*
+MiddlewareRecover turns panics in the handlers that follow it into a 500 Response instead of a
+dropped connection. The stack trace is logged and the panicking handler is accounted for like any
+other failing handler (its runtime, `.500` and `errors` stats are still emitted).
+
+The panic value is only logged; the client receives a generic error message.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.MiddlewareRecover,
+			yourHandler,
+		})).Methods("GET")
+*/
+func MiddlewareRecover(rw http.ResponseWriter, r *http.Request) *Response {
+	if c := chainFromRequest(r); c != nil {
+		c.recoverPanics = true
+	}
+
+	return nil
+}
+
+// callHandler runs a handler of the chain, recovering from a panic
+// if MiddlewareRecover ran earlier in the chain
+func callHandler(handler Handler, rw http.ResponseWriter, r *http.Request, state *chainState) (resp *Response) {
+	if !state.recoverPanics {
+		return handler(rw, r)
+	}
+
+	defer func() {
+		p := recover()
+		if p == nil {
+			return
+		}
+
+		// net/http uses this panic to abort the response on purpose
+		if p == http.ErrAbortHandler {
+			panic(p)
+		}
+
+		log.WithFields(log.Fields{
+			"handler": handlerName(handler),
+			"stack":   string(debug.Stack()),
+		}).Errorf("Recovered from panic: %v", p)
+
+		resp = &Response{
+			Err:        errors.New("Internal server error"),
+			StatusCode: http.StatusInternalServerError,
+		}
+	}()
+
+	return handler(rw, r)
+}
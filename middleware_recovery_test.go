@@ -0,0 +1,127 @@
+package rye
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/InVisionApp/rye/fakes/statsdfakes"
+	log "github.com/Sirupsen/logrus"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Recover Middleware", func() {
+
+	var (
+		mwHandler *MWHandler
+		response  *httptest.ResponseRecorder
+		request   *http.Request
+		incs      chan string
+		timings   chan string
+		output    *bytes.Buffer
+		level     log.Level
+	)
+
+	BeforeEach(func() {
+		// Stats are sent asynchronously and may outlive the spec, so the stubs
+		// hold on to this spec's channels rather than the shared variables
+		specIncs, specTimings := make(chan string, 10), make(chan string, 10)
+		incs, timings = specIncs, specTimings
+
+		fakeStatter := &statsdfakes.FakeStatter{}
+		fakeStatter.IncStub = func(name string, value int64, rate float32) error {
+			specIncs <- name
+			return nil
+		}
+		fakeStatter.TimingDurationStub = func(name string, d time.Duration, rate float32) error {
+			specTimings <- name
+			return nil
+		}
+
+		mwHandler = NewMWHandler(Config{Statter: fakeStatter, StatRate: 1})
+		response = httptest.NewRecorder()
+		request = httptest.NewRequest("GET", "/", nil)
+
+		output = &bytes.Buffer{}
+		log.SetOutput(output)
+
+		level = log.GetLevel()
+		log.SetLevel(log.ErrorLevel)
+	})
+
+	AfterEach(func() {
+		log.SetOutput(GinkgoWriter)
+		log.SetLevel(level)
+	})
+
+	Describe("handle", func() {
+		Context("when a later handler panics", func() {
+			It("should return a 500 and stop the chain", func() {
+				h := mwHandler.Handle([]Handler{MiddlewareRecover, panicHandler, successHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusInternalServerError))
+				Expect(response.Body.String()).To(ContainSubstring("Internal server error"))
+				Expect(response.Body.String()).ToNot(ContainSubstring("boom"))
+			})
+
+			It("should log the panic with its stack trace", func() {
+				h := mwHandler.Handle([]Handler{MiddlewareRecover, panicHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(output.String()).To(ContainSubstring("Recovered from panic: boom"))
+				Expect(output.String()).To(ContainSubstring("handler=panicHandler"))
+				Expect(output.String()).To(ContainSubstring("runtime/debug.Stack"))
+			})
+
+			It("should still emit stats for the panicking handler", func() {
+				h := mwHandler.Handle([]Handler{MiddlewareRecover, panicHandler})
+				h.ServeHTTP(response, request)
+
+				var names []string
+				for i := 0; i < 4; i++ {
+					var name string
+					Eventually(incs).Should(Receive(&name))
+					names = append(names, name)
+				}
+				Expect(names).To(ContainElement("handlers.MiddlewareRecover.2xx"))
+				Expect(names).To(ContainElement("handlers.panicHandler.500"))
+				Expect(names).To(ContainElement("handlers.panicHandler.5xx"))
+				Expect(names).To(ContainElement("errors"))
+
+				var timed []string
+				for i := 0; i < 3; i++ {
+					var name string
+					Eventually(timings).Should(Receive(&name))
+					timed = append(timed, name)
+				}
+				Expect(timed).To(ContainElement("handlers.panicHandler.runtime"))
+			})
+		})
+
+		Context("when the handler aborts the response on purpose", func() {
+			It("should not recover", func() {
+				h := mwHandler.Handle([]Handler{MiddlewareRecover, abortHandler})
+
+				Expect(func() { h.ServeHTTP(response, request) }).To(PanicWith(http.ErrAbortHandler))
+			})
+		})
+
+		Context("when the middleware is not in the chain", func() {
+			It("should let the panic through", func() {
+				h := mwHandler.Handle([]Handler{panicHandler})
+
+				Expect(func() { h.ServeHTTP(response, request) }).To(Panic())
+			})
+		})
+	})
+})
+
+func panicHandler(rw http.ResponseWriter, r *http.Request) *Response {
+	panic("boom")
+}
+
+func abortHandler(rw http.ResponseWriter, r *http.Request) *Response {
+	panic(http.ErrAbortHandler)
+}
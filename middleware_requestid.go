@@ -0,0 +1,101 @@
+package rye
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+const (
+	CONTEXT_REQUEST_ID = "rye-middlewarerequestid-requestid"
+
+	// Incoming request IDs longer than this are replaced with a generated one
+	MAX_REQUEST_ID_LENGTH = 128
+)
+
+// RequestIDConfig configures the request ID middleware.
+type RequestIDConfig struct {
+	// Header carrying the ID of the request (defaults to DEFAULT_REQUEST_ID_HEADER)
+	Header string
+
+	// Generator generates the ID of requests arriving without one (defaults to random UUIDs)
+	Generator func() string
+}
+
+type requestID struct {
+	config RequestIDConfig
+}
+
+/*
+NewMiddlewareRequestID creates a new handler that gives every request a correlation ID: the ID of the
+incoming `X-Request-ID` header (or the configured header) is kept, and requests arriving without one (or
+with one longer than MAX_REQUEST_ID_LENGTH characters) get a newly generated UUID. The ID is echoed back
+on the response header and available to handlers through `rye.CtxRequestID`; rye's own middleware
+(ie. the error logger) use it as well.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareRequestID(rye.RequestIDConfig{}),
+			yourHandler,
+		})).Methods("GET")
+
+	func yourHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		log.WithField("request_id", rye.CtxRequestID(r)).Info("Handling request")
+		...
+	}
+*/
+func NewMiddlewareRequestID(cfg RequestIDConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if cfg.Header == "" {
+		cfg.Header = DEFAULT_REQUEST_ID_HEADER
+	}
+
+	if cfg.Generator == nil {
+		cfg.Generator = newUUID
+	}
+
+	i := &requestID{config: cfg}
+	return i.handle
+}
+
+func (i *requestID) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	id := r.Header.Get(i.config.Header)
+	if id == "" || len(id) > MAX_REQUEST_ID_LENGTH {
+		id = i.config.Generator()
+	}
+
+	rw.Header().Set(i.config.Header, id)
+
+	return &Response{
+		Context: context.WithValue(r.Context(), CONTEXT_REQUEST_ID, id),
+	}
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// CtxRequestID returns the ID given to the request by NewMiddlewareRequestID (or "" if none was)
+func CtxRequestID(r *http.Request) string {
+	id, _ := r.Context().Value(CONTEXT_REQUEST_ID).(string)
+	return id
+}
+
+// requestIDOf returns the ID of the request: the one given by NewMiddlewareRequestID,
+// or else the one of the given header
+func requestIDOf(r *http.Request, header string) string {
+	if id := CtxRequestID(r); id != "" {
+		return id
+	}
+
+	return r.Header.Get(header)
+}
@@ -0,0 +1,110 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Request ID Middleware", func() {
+
+	var (
+		request   *http.Request
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		seen      string
+		generated int
+		generator func() string
+	)
+
+	// requestIDHandler records the request ID seen by handlers
+	requestIDHandler := func(rw http.ResponseWriter, r *http.Request) *Response {
+		seen = CtxRequestID(r)
+		return nil
+	}
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("GET", "/", nil)
+		response = httptest.NewRecorder()
+		mwHandler = NewMWHandler(Config{})
+		seen, generated = "", 0
+
+		generator = func() string {
+			generated++
+			return "generated-id"
+		}
+	})
+
+	Describe("handle", func() {
+		It("should keep the ID of the incoming request", func() {
+			request.Header.Set("X-Request-ID", "abc-123")
+
+			h := mwHandler.Handle([]Handler{NewMiddlewareRequestID(RequestIDConfig{Generator: generator}), requestIDHandler})
+			h.ServeHTTP(response, request)
+
+			Expect(seen).To(Equal("abc-123"))
+			Expect(response.Header().Get("X-Request-ID")).To(Equal("abc-123"))
+			Expect(generated).To(BeZero())
+		})
+
+		It("should generate an ID for requests without one", func() {
+			h := mwHandler.Handle([]Handler{NewMiddlewareRequestID(RequestIDConfig{Generator: generator}), requestIDHandler})
+			h.ServeHTTP(response, request)
+
+			Expect(seen).To(Equal("generated-id"))
+			Expect(response.Header().Get("X-Request-ID")).To(Equal("generated-id"))
+			Expect(generated).To(Equal(1))
+		})
+
+		It("should replace IDs that are too long", func() {
+			request.Header.Set("X-Request-ID", strings.Repeat("a", MAX_REQUEST_ID_LENGTH+1))
+
+			h := mwHandler.Handle([]Handler{NewMiddlewareRequestID(RequestIDConfig{Generator: generator}), requestIDHandler})
+			h.ServeHTTP(response, request)
+
+			Expect(seen).To(Equal("generated-id"))
+		})
+
+		It("should use the configured header", func() {
+			request.Header.Set("X-Correlation-ID", "abc-123")
+
+			h := mwHandler.Handle([]Handler{NewMiddlewareRequestID(RequestIDConfig{Header: "X-Correlation-ID"}), requestIDHandler})
+			h.ServeHTTP(response, request)
+
+			Expect(seen).To(Equal("abc-123"))
+			Expect(response.Header().Get("X-Correlation-ID")).To(Equal("abc-123"))
+			Expect(response.Header().Get("X-Request-ID")).To(BeEmpty())
+		})
+
+		It("should generate UUIDs by default", func() {
+			h := mwHandler.Handle([]Handler{NewMiddlewareRequestID(RequestIDConfig{}), requestIDHandler})
+			h.ServeHTTP(response, request)
+
+			Expect(seen).To(MatchRegexp(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`))
+		})
+
+		It("should share the ID with rye's middleware", func() {
+			logger := &recordingLogger{}
+
+			h := mwHandler.Handle([]Handler{NewMiddlewareRequestID(RequestIDConfig{Generator: generator}), NewMiddlewareErrorLogger(logger), failureHandler})
+			h.ServeHTTP(response, request)
+
+			Expect(logger.entries).To(HaveLen(1))
+			Expect(logger.entries[0].RequestID).To(Equal("generated-id"))
+		})
+	})
+
+	Describe("CtxRequestID", func() {
+		It("should return nothing for requests without an ID", func() {
+			Expect(CtxRequestID(request)).To(BeEmpty())
+		})
+	})
+
+	Describe("newUUID", func() {
+		It("should return a different UUID each time", func() {
+			Expect(newUUID()).ToNot(Equal(newUUID()))
+		})
+	})
+})
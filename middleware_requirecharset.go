@@ -0,0 +1,107 @@
+package rye
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	DEFAULT_REQUIRED_CHARSET = "utf-8"
+)
+
+// RequireCharsetConfig configures the charset middleware.
+type RequireCharsetConfig struct {
+	// Charsets lists the accepted charsets, compared case insensitively
+	// (defaults to DEFAULT_REQUIRED_CHARSET)
+	Charsets []string
+
+	// RejectMissing rejects requests whose `Content-Type` has no charset
+	// parameter; they are let through by default
+	RejectMissing bool
+}
+
+type requireCharset struct {
+	config RequireCharsetConfig
+}
+
+/*
+NewMiddlewareRequireCharset creates a new handler for endpoints that can't handle arbitrary encodings: requests
+whose `Content-Type` declares a charset other than the given ones (UTF-8 if none are given) are stopped with a 415.
+Requests without a `Content-Type`, or without a charset in it, are let through; use
+NewMiddlewareRequireCharsetWithConfig to reject the latter.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareRequireCharset("utf-8", "us-ascii"),
+			yourHandler,
+		})).Methods("POST")
+*/
+func NewMiddlewareRequireCharset(charsets ...string) func(rw http.ResponseWriter, req *http.Request) *Response {
+	return NewMiddlewareRequireCharsetWithConfig(RequireCharsetConfig{Charsets: charsets})
+}
+
+/*
+NewMiddlewareRequireCharsetWithConfig works like NewMiddlewareRequireCharset, with more options.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareRequireCharsetWithConfig(rye.RequireCharsetConfig{
+				RejectMissing: true,
+			}),
+			yourHandler,
+		})).Methods("POST")
+*/
+func NewMiddlewareRequireCharsetWithConfig(cfg RequireCharsetConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if len(cfg.Charsets) == 0 {
+		cfg.Charsets = []string{DEFAULT_REQUIRED_CHARSET}
+	}
+
+	c := &requireCharset{config: cfg}
+	return c.handle
+}
+
+func (c *requireCharset) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return &Response{
+			Err:        fmt.Errorf("Invalid Content-Type '%s': %v", contentType, err),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	charset, ok := params["charset"]
+	if !ok {
+		if c.config.RejectMissing {
+			return c.unsupported(fmt.Errorf("Content-Type must declare a charset; accepted charsets are %s", strings.Join(c.config.Charsets, ", ")))
+		}
+		return nil
+	}
+
+	for _, accepted := range c.config.Charsets {
+		if strings.EqualFold(charset, accepted) {
+			return nil
+		}
+	}
+
+	return c.unsupported(fmt.Errorf("Unsupported charset '%s'; accepted charsets are %s", charset, strings.Join(c.config.Charsets, ", ")))
+}
+
+// unsupported stops the chain with a 415
+func (c *requireCharset) unsupported(err error) *Response {
+	return &Response{
+		Err:           err,
+		StatusCode:    http.StatusUnsupportedMediaType,
+		StopExecution: true,
+	}
+}
@@ -0,0 +1,91 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Require Charset Middleware", func() {
+
+	var (
+		request  *http.Request
+		response *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("POST", "/items", strings.NewReader(`{"name":"rye"}`))
+		response = httptest.NewRecorder()
+	})
+
+	Describe("handle", func() {
+		Context("with the default charset", func() {
+			It("should accept UTF-8", func() {
+				request.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+				Expect(NewMiddlewareRequireCharset()(response, request)).To(BeNil())
+			})
+
+			It("should reject other charsets with a 415", func() {
+				request.Header.Set("Content-Type", "application/json; charset=ISO-8859-1")
+
+				resp := NewMiddlewareRequireCharset()(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusUnsupportedMediaType))
+				Expect(resp.StopExecution).To(BeTrue())
+				Expect(resp.Error()).To(ContainSubstring("Unsupported charset 'ISO-8859-1'"))
+			})
+		})
+
+		Context("with given charsets", func() {
+			It("should accept any of them", func() {
+				request.Header.Set("Content-Type", "text/plain; charset=us-ascii")
+
+				Expect(NewMiddlewareRequireCharset("utf-8", "US-ASCII")(response, request)).To(BeNil())
+			})
+
+			It("should reject the others", func() {
+				request.Header.Set("Content-Type", "text/plain; charset=utf-16")
+
+				resp := NewMiddlewareRequireCharset("utf-8", "us-ascii")(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Error()).To(ContainSubstring("accepted charsets are utf-8, us-ascii"))
+			})
+		})
+
+		Context("when the charset is missing", func() {
+			BeforeEach(func() {
+				request.Header.Set("Content-Type", "application/json")
+			})
+
+			It("should let the request through by default", func() {
+				Expect(NewMiddlewareRequireCharset()(response, request)).To(BeNil())
+			})
+
+			It("should reject the request with a 415 when configured to", func() {
+				resp := NewMiddlewareRequireCharsetWithConfig(RequireCharsetConfig{RejectMissing: true})(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusUnsupportedMediaType))
+				Expect(resp.Error()).To(ContainSubstring("must declare a charset"))
+			})
+		})
+
+		Context("when the Content-Type is missing", func() {
+			It("should let the request through", func() {
+				Expect(NewMiddlewareRequireCharsetWithConfig(RequireCharsetConfig{RejectMissing: true})(response, request)).To(BeNil())
+			})
+		})
+
+		Context("when the Content-Type is invalid", func() {
+			It("should return a 400", func() {
+				request.Header.Set("Content-Type", "application/json; charset")
+
+				resp := NewMiddlewareRequireCharset()(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			})
+		})
+	})
+})
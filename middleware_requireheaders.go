@@ -0,0 +1,42 @@
+package rye
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type requireHeaders struct {
+	names []string
+}
+
+/*
+NewMiddlewareRequireHeaders creates a new handler that requires the request to carry each of the named headers
+(with a non-empty value), ie. `X-Api-Version`. Requests missing one are stopped with a 400 naming the first
+missing header. Header names are case insensitive.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareRequireHeaders("X-Api-Version", "X-Client-Id"),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareRequireHeaders(names ...string) func(rw http.ResponseWriter, req *http.Request) *Response {
+	h := &requireHeaders{names: names}
+	return h.handle
+}
+
+func (h *requireHeaders) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	for _, name := range h.names {
+		if r.Header.Get(name) == "" {
+			return &Response{
+				Err:           fmt.Errorf("missing header %q", name),
+				StatusCode:    http.StatusBadRequest,
+				StopExecution: true,
+			}
+		}
+	}
+
+	return nil
+}
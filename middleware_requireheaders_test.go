@@ -0,0 +1,55 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Require Headers Middleware", func() {
+
+	var (
+		request  *http.Request
+		response *httptest.ResponseRecorder
+		handler  func(rw http.ResponseWriter, req *http.Request) *Response
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("GET", "/items", nil)
+		response = httptest.NewRecorder()
+		handler = NewMiddlewareRequireHeaders("X-Api-Version", "X-Client-Id")
+	})
+
+	Describe("handle", func() {
+		Context("when every header is present", func() {
+			It("should let the request through", func() {
+				request.Header.Set("x-api-version", "2")
+				request.Header.Set("X-Client-Id", "web")
+
+				Expect(handler(response, request)).To(BeNil())
+			})
+		})
+
+		Context("when a header is missing", func() {
+			It("should stop with a 400 naming the first missing header", func() {
+				resp := handler(response, request)
+
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(resp.StopExecution).To(BeTrue())
+				Expect(resp.Error()).To(Equal(`missing header "X-Api-Version"`))
+			})
+
+			It("should treat an empty header as missing", func() {
+				request.Header.Set("X-Api-Version", "2")
+				request.Header.Set("X-Client-Id", "")
+
+				resp := handler(response, request)
+
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Error()).To(Equal(`missing header "X-Client-Id"`))
+			})
+		})
+	})
+})
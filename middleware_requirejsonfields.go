@@ -0,0 +1,88 @@
+package rye
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type requireJSONFields struct {
+	fields []string
+}
+
+/*
+NewMiddlewareRequireJSONFields creates a new handler that decodes the request body as a JSON
+object and returns a 400 listing any of the required `fields` that are missing. Nested fields
+can be required by using dotted paths (ie. `user.email`). The body is restored for downstream
+handlers.
+
+This is a lightweight alternative to full JSON Schema validation for common cases.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareRequireJSONFields("name", "user.email"),
+			yourHandler,
+		})).Methods("POST")
+*/
+func NewMiddlewareRequireJSONFields(fields ...string) func(rw http.ResponseWriter, req *http.Request) *Response {
+	j := &requireJSONFields{fields: fields}
+	return j.handle
+}
+
+func (j *requireJSONFields) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	body, err := readBody(r)
+	if err != nil {
+		return bodyReadError(r, err)
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return &Response{
+			Err:        fmt.Errorf("Request body must be a JSON object: %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	missing := []string{}
+	for _, field := range j.fields {
+		if !hasJSONField(document, field) {
+			missing = append(missing, field)
+		}
+	}
+
+	if len(missing) > 0 {
+		return &Response{
+			Err:        fmt.Errorf("Missing required fields: %v", strings.Join(missing, ", ")),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	return nil
+}
+
+// hasJSONField reports whether the (possibly dotted) path exists in the document
+func hasJSONField(document map[string]interface{}, path string) bool {
+	parts := strings.Split(path, ".")
+
+	current := document
+	for i, part := range parts {
+		value, ok := current[part]
+		if !ok {
+			return false
+		}
+
+		if i == len(parts)-1 {
+			return true
+		}
+
+		current, ok = value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,68 @@
+package rye
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Require JSON Fields Middleware", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+	})
+
+	Describe("handle", func() {
+		Context("when all required fields are present", func() {
+			It("should return nil and restore the body", func() {
+				payload := `{"name": "rye", "user": {"email": "a@b.c"}}`
+				request := httptest.NewRequest("POST", "/", strings.NewReader(payload))
+
+				resp := NewMiddlewareRequireJSONFields("name", "user.email")(response, request)
+				Expect(resp).To(BeNil())
+
+				body, err := ioutil.ReadAll(request.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal(payload))
+			})
+		})
+
+		Context("when some required fields are missing", func() {
+			It("should return a 400 listing the missing fields", func() {
+				payload := `{"name": "rye", "user": {"id": 1}}`
+				request := httptest.NewRequest("POST", "/", strings.NewReader(payload))
+
+				resp := NewMiddlewareRequireJSONFields("name", "user.email", "age")(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(resp.Error()).To(Equal("Missing required fields: user.email, age"))
+			})
+
+			It("should treat a non-object parent as missing", func() {
+				request := httptest.NewRequest("POST", "/", strings.NewReader(`{"user": "bob"}`))
+
+				resp := NewMiddlewareRequireJSONFields("user.email")(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Error()).To(ContainSubstring("user.email"))
+			})
+		})
+
+		Context("when the body is not a JSON object", func() {
+			It("should return a 400", func() {
+				request := httptest.NewRequest("POST", "/", strings.NewReader(`[1, 2]`))
+
+				resp := NewMiddlewareRequireJSONFields("name")(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(resp.Error()).To(ContainSubstring("must be a JSON object"))
+			})
+		})
+	})
+})
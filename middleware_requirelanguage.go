@@ -0,0 +1,131 @@
+package rye
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// Context key holding the language selected by NewMiddlewareRequireLanguage
+	CONTEXT_LANGUAGE = "rye-middlewarerequirelanguage-language"
+)
+
+// RequireLanguageConfig configures the language middleware.
+type RequireLanguageConfig struct {
+	// Supported lists the language tags the endpoint is localized in (ie. `en-US`),
+	// compared case insensitively
+	Supported []string
+
+	// Default is selected when none of the languages the client asks for is supported;
+	// when empty, such requests are stopped with a 406
+	Default string
+}
+
+type requireLanguage struct {
+	config RequireLanguageConfig
+}
+
+/*
+NewMiddlewareRequireLanguage creates a new handler for strictly localized endpoints: it selects the supported
+language the client prefers according to its `Accept-Language` header, and stops the request with a 406 when it
+asks for none of them. A requested language matches the supported tags it is a prefix of (`en` matches `en-US`)
+and the other way around (`en-US` matches `en`). Requests without an `Accept-Language` header get the first
+supported language. Use NewMiddlewareRequireLanguageWithConfig to fall back on a default language instead of
+failing.
+
+The selected language is set as the `Content-Language` of the response and is available to the rest of the
+chain through `rye.LanguageFromContext`.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareRequireLanguage("en-US", "fr-FR"),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareRequireLanguage(supported ...string) func(rw http.ResponseWriter, req *http.Request) *Response {
+	return NewMiddlewareRequireLanguageWithConfig(RequireLanguageConfig{Supported: supported})
+}
+
+/*
+NewMiddlewareRequireLanguageWithConfig works like NewMiddlewareRequireLanguage, with more options.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareRequireLanguageWithConfig(rye.RequireLanguageConfig{
+				Supported: []string{"en-US", "fr-FR"},
+				Default:   "en-US",
+			}),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareRequireLanguageWithConfig(cfg RequireLanguageConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	l := &requireLanguage{config: cfg}
+	return l.handle
+}
+
+func (l *requireLanguage) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	language := l.selectLanguage(strings.Join(r.Header.Values("Accept-Language"), ","))
+	if language == "" {
+		language = l.config.Default
+	}
+
+	rw.Header().Add("Vary", "Accept-Language")
+
+	if language == "" {
+		return &Response{
+			Err:           fmt.Errorf("None of the requested languages is supported; supported languages are %s", strings.Join(l.config.Supported, ", ")),
+			StatusCode:    http.StatusNotAcceptable,
+			StopExecution: true,
+		}
+	}
+
+	rw.Header().Set("Content-Language", language)
+
+	return &Response{
+		Context: context.WithValue(r.Context(), CONTEXT_LANGUAGE, language),
+	}
+}
+
+// selectLanguage returns the supported language the client prefers, or "" if it asks for none of them
+func (l *requireLanguage) selectLanguage(header string) string {
+	if strings.TrimSpace(header) == "" && len(l.config.Supported) > 0 {
+		return l.config.Supported[0]
+	}
+
+	for _, requested := range parseAccept(header) {
+		if requested == "*" && len(l.config.Supported) > 0 {
+			return l.config.Supported[0]
+		}
+
+		// An exact match wins over a prefix match
+		var prefixed string
+		for _, supported := range l.config.Supported {
+			tag := strings.ToLower(supported)
+			if tag == requested {
+				return supported
+			}
+
+			if prefixed == "" && (strings.HasPrefix(tag, requested+"-") || strings.HasPrefix(requested, tag+"-")) {
+				prefixed = supported
+			}
+		}
+
+		if prefixed != "" {
+			return prefixed
+		}
+	}
+
+	return ""
+}
+
+// LanguageFromContext returns the language selected by NewMiddlewareRequireLanguage (if any)
+func LanguageFromContext(ctx context.Context) (string, bool) {
+	language, ok := ctx.Value(CONTEXT_LANGUAGE).(string)
+	return language, ok
+}
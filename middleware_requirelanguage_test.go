@@ -0,0 +1,98 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Require Language Middleware", func() {
+
+	var (
+		request  *http.Request
+		response *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("GET", "/articles", nil)
+		response = httptest.NewRecorder()
+	})
+
+	selected := func(resp *Response) string {
+		Expect(resp).ToNot(BeNil())
+		Expect(resp.Context).ToNot(BeNil())
+
+		language, ok := LanguageFromContext(resp.Context)
+		Expect(ok).To(BeTrue())
+		return language
+	}
+
+	Describe("handle", func() {
+		Context("when a requested language is supported", func() {
+			It("should select the one the client prefers", func() {
+				request.Header.Set("Accept-Language", "de;q=0.9, fr-FR;q=0.8, en-US;q=0.7")
+
+				resp := NewMiddlewareRequireLanguage("en-US", "fr-FR")(response, request)
+				Expect(selected(resp)).To(Equal("fr-FR"))
+				Expect(response.Header().Get("Content-Language")).To(Equal("fr-FR"))
+				Expect(response.Header().Get("Vary")).To(Equal("Accept-Language"))
+			})
+
+			It("should match languages by prefix, preferring exact matches", func() {
+				request.Header.Set("Accept-Language", "EN")
+
+				Expect(selected(NewMiddlewareRequireLanguage("en-GB", "fr")(response, request))).To(Equal("en-GB"))
+				Expect(selected(NewMiddlewareRequireLanguage("en-GB", "en")(response, request))).To(Equal("en"))
+
+				request.Header.Set("Accept-Language", "fr-CA")
+				Expect(selected(NewMiddlewareRequireLanguage("en", "fr")(response, request))).To(Equal("fr"))
+			})
+
+			It("should select the first supported language for a wildcard", func() {
+				request.Header.Set("Accept-Language", "de, *;q=0.5")
+
+				Expect(selected(NewMiddlewareRequireLanguage("en-US", "fr-FR")(response, request))).To(Equal("en-US"))
+			})
+		})
+
+		Context("when the header is missing", func() {
+			It("should select the first supported language", func() {
+				Expect(selected(NewMiddlewareRequireLanguage("en-US", "fr-FR")(response, request))).To(Equal("en-US"))
+			})
+		})
+
+		Context("when no requested language is supported", func() {
+			BeforeEach(func() {
+				request.Header.Set("Accept-Language", "de-DE, ja;q=0.5, en;q=0")
+			})
+
+			It("should select the default language when one is set", func() {
+				resp := NewMiddlewareRequireLanguageWithConfig(RequireLanguageConfig{
+					Supported: []string{"en-US", "fr-FR"},
+					Default:   "en-US",
+				})(response, request)
+
+				Expect(selected(resp)).To(Equal("en-US"))
+				Expect(response.Header().Get("Content-Language")).To(Equal("en-US"))
+			})
+
+			It("should stop the request with a 406 otherwise", func() {
+				resp := NewMiddlewareRequireLanguage("en-US", "fr-FR")(response, request)
+
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusNotAcceptable))
+				Expect(resp.StopExecution).To(BeTrue())
+				Expect(resp.Error()).To(ContainSubstring("supported languages are en-US, fr-FR"))
+				Expect(response.Header().Get("Content-Language")).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("LanguageFromContext", func() {
+		It("should report a missing language", func() {
+			_, ok := LanguageFromContext(request.Context())
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
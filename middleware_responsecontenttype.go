@@ -0,0 +1,201 @@
+package rye
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ResponseContentTypeGuardConfig configures the response content type guard middleware.
+type ResponseContentTypeGuardConfig struct {
+	// Allowed are the content types responses may have (parameters such as `charset` are
+	// ignored); an entry ending with a "/" matches any content type starting with it
+	Allowed []string
+
+	// Strict rewrites a disallowed content type to Default
+	Strict bool
+
+	// Default is the content type disallowed content types are rewritten to in strict
+	// mode (defaults to the first allowed content type)
+	Default string
+
+	// Logger logs every violation at warn level (defaults to LogrusLogger)
+	Logger Logger
+}
+
+type responseContentTypeGuard struct {
+	config ResponseContentTypeGuardConfig
+}
+
+/*
+NewMiddlewareResponseContentTypeGuard creates a new handler that checks the `Content-Type` of the responses
+written by the rest of the chain against an allowlist, to help enforce API content contracts. A response
+with a body and a content type that is not allowed (including one sniffed by Go because no content type was
+set) increments the `content_type.violation` stat and is logged at warn level; the response itself is left
+alone.
+
+Use `rye.NewMiddlewareResponseContentTypeGuardWithConfig` to rewrite disallowed content types instead.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareResponseContentTypeGuard("application/json", "application/problem+json"),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareResponseContentTypeGuard(allowed ...string) func(rw http.ResponseWriter, req *http.Request) *Response {
+	return NewMiddlewareResponseContentTypeGuardWithConfig(ResponseContentTypeGuardConfig{Allowed: allowed})
+}
+
+/*
+NewMiddlewareResponseContentTypeGuardWithConfig works like NewMiddlewareResponseContentTypeGuard; in strict
+mode, disallowed content types are also rewritten to `Default`.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareResponseContentTypeGuardWithConfig(rye.ResponseContentTypeGuardConfig{
+				Allowed: []string{"application/json"},
+				Strict:  true,
+			}),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareResponseContentTypeGuardWithConfig(cfg ResponseContentTypeGuardConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if cfg.Default == "" && len(cfg.Allowed) > 0 {
+		cfg.Default = cfg.Allowed[0]
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger = LogrusLogger{}
+	}
+
+	g := &responseContentTypeGuard{config: cfg}
+	return g.handle
+}
+
+func (g *responseContentTypeGuard) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	return &Response{
+		Writer: &contentTypeGuardWriter{
+			ResponseWriter: rw,
+			guard:          g,
+			request:        r,
+		},
+	}
+}
+
+// allowed reports whether the content type is in the allowlist
+func (g *responseContentTypeGuard) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range g.config.Allowed {
+		allowed = strings.ToLower(allowed)
+
+		if strings.HasSuffix(allowed, "/") && strings.HasPrefix(mediaType, allowed) {
+			return true
+		}
+
+		if mediaType == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// contentTypeGuardWriter holds back the status code until the first write, so that the
+// content type can be checked (and rewritten) before it is sent
+type contentTypeGuardWriter struct {
+	http.ResponseWriter
+
+	guard   *responseContentTypeGuard
+	request *http.Request
+
+	status      int
+	wroteHeader bool
+}
+
+// writeHeader writes the status code held back by WriteHeader (if any), checking the
+// content type first when there is a body
+func (cw *contentTypeGuardWriter) writeHeader(body []byte) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	if len(body) > 0 {
+		cw.check(body)
+	}
+
+	if cw.status != 0 {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+}
+
+// check reports a violation if the content type is not allowed, rewriting it in strict mode
+func (cw *contentTypeGuardWriter) check(body []byte) {
+	header := cw.Header()
+
+	// Sniff the content type the way net/http would
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+		header.Set("Content-Type", contentType)
+	}
+
+	if cw.guard.allowed(contentType) {
+		return
+	}
+
+	chain := chainFromRequest(cw.request)
+	chain.inc("content_type.violation")
+
+	var handler string
+	if chain != nil && len(chain.timings) > 0 {
+		handler = chain.timings[len(chain.timings)-1].name
+	}
+
+	cw.guard.config.Logger.Log(LogEntry{
+		Level:     LOG_LEVEL_WARN,
+		Message:   "Response has a disallowed content type: " + contentType,
+		Method:    cw.request.Method,
+		Path:      cw.request.URL.Path,
+		Handler:   handler,
+		RequestID: requestIDOf(cw.request, DEFAULT_REQUEST_ID_HEADER),
+	})
+
+	if cw.guard.config.Strict && cw.guard.config.Default != "" {
+		header.Set("Content-Type", cw.guard.config.Default)
+	}
+}
+
+func (cw *contentTypeGuardWriter) WriteHeader(statusCode int) {
+	if cw.status == 0 {
+		cw.status = statusCode
+	}
+}
+
+func (cw *contentTypeGuardWriter) Write(p []byte) (int, error) {
+	cw.writeHeader(p)
+
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush passes through to the wrapped writer (if it supports flushing)
+func (cw *contentTypeGuardWriter) Flush() {
+	cw.writeHeader(nil)
+
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finalize makes sure the status code is written even if the chain wrote no body
+func (cw *contentTypeGuardWriter) finalize() {
+	cw.writeHeader(nil)
+}
@@ -0,0 +1,124 @@
+package rye
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Response Content Type Guard Middleware", func() {
+
+	var (
+		request   *http.Request
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		reporter  *recordingReporter
+		logger    *recordingLogger
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("GET", "/items", nil)
+		response = httptest.NewRecorder()
+		reporter = &recordingReporter{}
+		mwHandler = NewMWHandler(Config{Reporter: reporter, StatRate: 1})
+		logger = &recordingLogger{}
+	})
+
+	writeBody := func(contentType, body string) Handler {
+		return func(rw http.ResponseWriter, r *http.Request) *Response {
+			if contentType != "" {
+				rw.Header().Set("Content-Type", contentType)
+			}
+			rw.WriteHeader(http.StatusCreated)
+			io.WriteString(rw, body)
+			return nil
+		}
+	}
+
+	guard := func(strict bool) Handler {
+		return NewMiddlewareResponseContentTypeGuardWithConfig(ResponseContentTypeGuardConfig{
+			Allowed: []string{"application/json", "text/"},
+			Strict:  strict,
+			Logger:  logger,
+		})
+	}
+
+	Describe("handle", func() {
+		Context("when the content type is allowed", func() {
+			It("should leave the response alone", func() {
+				h := mwHandler.Handle([]Handler{guard(true), writeBody("application/json; charset=utf-8", `{"ok":true}`)})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusCreated))
+				Expect(response.Header().Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+				Expect(response.Body.String()).To(Equal(`{"ok":true}`))
+				Expect(logger.entries).To(BeEmpty())
+
+				Eventually(reporter.recordedIncs).Should(HaveLen(2))
+				Consistently(reporter.recordedIncs).ShouldNot(ContainElement("content_type.violation"))
+			})
+
+			It("should match content types by prefix", func() {
+				h := mwHandler.Handle([]Handler{guard(false), writeBody("text/csv", "a,b")})
+				h.ServeHTTP(response, request)
+
+				Expect(logger.entries).To(BeEmpty())
+			})
+		})
+
+		Context("when the content type is not allowed", func() {
+			It("should log and record the violation", func() {
+				h := mwHandler.Handle([]Handler{guard(false), writeBody("application/xml", "<ok/>")})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusCreated))
+				Expect(response.Header().Get("Content-Type")).To(Equal("application/xml"))
+
+				Expect(logger.entries).To(HaveLen(1))
+				Expect(logger.entries[0].Level).To(Equal(LOG_LEVEL_WARN))
+				Expect(logger.entries[0].Message).To(ContainSubstring("application/xml"))
+				Expect(logger.entries[0].Path).To(Equal("/items"))
+
+				Eventually(reporter.recordedIncs).Should(ContainElement("content_type.violation"))
+			})
+
+			It("should rewrite it to the default in strict mode", func() {
+				h := mwHandler.Handle([]Handler{guard(true), writeBody("application/xml", "<ok/>")})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+				Expect(response.Body.String()).To(Equal("<ok/>"))
+				Expect(logger.entries).To(HaveLen(1))
+			})
+
+			It("should check the sniffed content type when none is set", func() {
+				h := mwHandler.Handle([]Handler{
+					NewMiddlewareResponseContentTypeGuardWithConfig(ResponseContentTypeGuardConfig{
+						Allowed: []string{"application/json"},
+						Logger:  logger,
+					}),
+					writeBody("", "<html><body>oops</body></html>"),
+				})
+				h.ServeHTTP(response, request)
+
+				Expect(logger.entries).To(HaveLen(1))
+				Expect(logger.entries[0].Message).To(ContainSubstring("text/html"))
+			})
+		})
+
+		Context("when the response has no body", func() {
+			It("should only write the status code", func() {
+				h := mwHandler.Handle([]Handler{guard(true), func(rw http.ResponseWriter, r *http.Request) *Response {
+					rw.WriteHeader(http.StatusNoContent)
+					return nil
+				}})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusNoContent))
+				Expect(logger.entries).To(BeEmpty())
+			})
+		})
+	})
+})
@@ -0,0 +1,167 @@
+package rye
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// Context key holding the ID of the original request a retry correlates to
+	CONTEXT_RETRY_OF = "rye-middlewareretrycorrelation-retryof"
+
+	DEFAULT_IDEMPOTENCY_KEY_HEADER = "Idempotency-Key"
+	DEFAULT_REQUEST_ID_HEADER      = "X-Request-ID"
+	DEFAULT_IDEMPOTENCY_KEY_TTL    = 24 * time.Hour
+)
+
+// IdempotencyKeyStore remembers which request first used an idempotency key. Remember must
+// atomically record the request ID for an unseen key (for the given TTL) and otherwise return
+// the request ID it was first seen with, so that multiple instances of a service can share a
+// store. Implementations must be safe for concurrent use.
+type IdempotencyKeyStore interface {
+	Remember(ctx context.Context, key, requestID string, ttl time.Duration) (original string, seen bool, err error)
+}
+
+// RetryCorrelationConfig configures the retry correlation middleware.
+type RetryCorrelationConfig struct {
+	// Store shared by the instances of a service (defaults to an in-memory store)
+	Store IdempotencyKeyStore
+
+	// Header carrying the idempotency key (defaults to DEFAULT_IDEMPOTENCY_KEY_HEADER)
+	Header string
+
+	// RequestIDHeader carrying the ID of the request (defaults to DEFAULT_REQUEST_ID_HEADER)
+	RequestIDHeader string
+
+	// TTL for which keys are remembered (defaults to DEFAULT_IDEMPOTENCY_KEY_TTL)
+	TTL time.Duration
+}
+
+type retryCorrelation struct {
+	config RetryCorrelationConfig
+}
+
+/*
+NewMiddlewareRetryCorrelation creates a new handler that correlates client retries (requests reusing an
+idempotency key) with the original request, which helps debugging client retry storms. When a key is seen
+again, a `retry.detected` stat is emitted, a warning annotated with the original request ID is logged and
+the original request ID is stored in the context under CONTEXT_RETRY_OF. The chain carries on either way.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareRetryCorrelation(rye.RetryCorrelationConfig{}),
+			yourHandler,
+		})).Methods("POST")
+*/
+func NewMiddlewareRetryCorrelation(cfg RetryCorrelationConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryIdempotencyKeyStore()
+	}
+
+	if cfg.Header == "" {
+		cfg.Header = DEFAULT_IDEMPOTENCY_KEY_HEADER
+	}
+
+	if cfg.RequestIDHeader == "" {
+		cfg.RequestIDHeader = DEFAULT_REQUEST_ID_HEADER
+	}
+
+	if cfg.TTL <= 0 {
+		cfg.TTL = DEFAULT_IDEMPOTENCY_KEY_TTL
+	}
+
+	c := &retryCorrelation{config: cfg}
+	return c.handle
+}
+
+func (c *retryCorrelation) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	key := r.Header.Get(c.config.Header)
+	if key == "" {
+		return nil
+	}
+
+	requestID := requestIDOf(r, c.config.RequestIDHeader)
+
+	original, seen, err := c.config.Store.Remember(r.Context(), key, requestID, c.config.TTL)
+	if err != nil {
+		log.Warnf("Unable to check idempotency key for retries: %v", err)
+		return nil
+	}
+
+	if !seen {
+		return nil
+	}
+
+	chainFromRequest(r).inc("retry.detected")
+
+	log.WithFields(log.Fields{
+		"idempotency_key":     key,
+		"request_id":          requestID,
+		"original_request_id": original,
+	}).Warn("Retry detected")
+
+	return &Response{
+		Context: context.WithValue(r.Context(), CONTEXT_RETRY_OF, original),
+	}
+}
+
+type idempotencyKey struct {
+	requestID string
+	expires   time.Time
+}
+
+// MemoryIdempotencyKeyStore is an IdempotencyKeyStore local to the current process.
+type MemoryIdempotencyKeyStore struct {
+	mu        sync.Mutex
+	keys      map[string]idempotencyKey
+	lastPrune time.Time
+}
+
+// NewMemoryIdempotencyKeyStore creates an empty in-memory IdempotencyKeyStore.
+func NewMemoryIdempotencyKeyStore() *MemoryIdempotencyKeyStore {
+	return &MemoryIdempotencyKeyStore{
+		keys: make(map[string]idempotencyKey),
+	}
+}
+
+// Remember records the request ID for an unseen key, or returns the one it was first seen with.
+func (m *MemoryIdempotencyKeyStore) Remember(ctx context.Context, key, requestID string, ttl time.Duration) (string, bool, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prune(now, ttl)
+
+	if existing, ok := m.keys[key]; ok && now.Before(existing.expires) {
+		return existing.requestID, true, nil
+	}
+
+	m.keys[key] = idempotencyKey{
+		requestID: requestID,
+		expires:   now.Add(ttl),
+	}
+
+	return requestID, false, nil
+}
+
+// prune drops expired keys, at most once per ttl; must be called with the lock held
+func (m *MemoryIdempotencyKeyStore) prune(now time.Time, ttl time.Duration) {
+	if now.Sub(m.lastPrune) < ttl {
+		return
+	}
+
+	for key, existing := range m.keys {
+		if !now.Before(existing.expires) {
+			delete(m.keys, key)
+		}
+	}
+
+	m.lastPrune = now
+}
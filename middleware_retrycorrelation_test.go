@@ -0,0 +1,99 @@
+package rye
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/InVisionApp/rye/fakes/statsdfakes"
+	log "github.com/Sirupsen/logrus"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retry Correlation Middleware", func() {
+
+	var (
+		mwHandler  *MWHandler
+		correlate  func(rw http.ResponseWriter, req *http.Request) *Response
+		incs       chan string
+		output     *bytes.Buffer
+		level      log.Level
+		retryOf    interface{}
+		retryOfSet bool
+	)
+
+	// retryHandler records the original request ID found in the context
+	retryHandler := func(rw http.ResponseWriter, r *http.Request) *Response {
+		retryOf = r.Context().Value(CONTEXT_RETRY_OF)
+		retryOfSet = retryOf != nil
+		return nil
+	}
+
+	serve := func(key, requestID string) {
+		request := httptest.NewRequest("POST", "/", nil)
+		request.Header.Set("Idempotency-Key", key)
+		request.Header.Set("X-Request-ID", requestID)
+
+		mwHandler.Handle([]Handler{correlate, retryHandler}).ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	BeforeEach(func() {
+		// Stats are sent asynchronously and may outlive the spec, so the
+		// stub holds on to this spec's channel rather than the shared one
+		specIncs := make(chan string, 10)
+		incs = specIncs
+
+		fakeStatter := &statsdfakes.FakeStatter{}
+		fakeStatter.IncStub = func(name string, value int64, rate float32) error {
+			specIncs <- name
+			return nil
+		}
+
+		mwHandler = NewMWHandler(Config{Statter: fakeStatter, StatRate: 1})
+		correlate = NewMiddlewareRetryCorrelation(RetryCorrelationConfig{})
+		retryOf, retryOfSet = nil, false
+
+		output = &bytes.Buffer{}
+		log.SetOutput(output)
+
+		level = log.GetLevel()
+		log.SetLevel(log.WarnLevel)
+	})
+
+	AfterEach(func() {
+		log.SetOutput(GinkgoWriter)
+		log.SetLevel(level)
+	})
+
+	Describe("handle", func() {
+		Context("when the idempotency key is new", func() {
+			It("should not annotate the request", func() {
+				serve("key-1", "req-1")
+
+				Expect(retryOfSet).To(BeFalse())
+				Expect(output.Len()).To(Equal(0))
+				Consistently(incs).ShouldNot(Receive(Equal("retry.detected")))
+			})
+		})
+
+		Context("when the idempotency key is repeated", func() {
+			It("should annotate the retry with the original request", func() {
+				serve("key-1", "req-1")
+				serve("key-1", "req-2")
+
+				Expect(retryOf).To(Equal("req-1"))
+				Expect(output.String()).To(ContainSubstring("Retry detected"))
+				Expect(output.String()).To(ContainSubstring("original_request_id=req-1"))
+				Expect(output.String()).To(ContainSubstring("request_id=req-2"))
+				Eventually(incs).Should(Receive(Equal("retry.detected")))
+			})
+		})
+
+		Context("when there is no idempotency key", func() {
+			It("should return nil", func() {
+				resp := correlate(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+				Expect(resp).To(BeNil())
+			})
+		})
+	})
+})
@@ -8,7 +8,7 @@ import (
 
 /*
 MiddlewareRouteLogger creates a new handler to provide simple logging output for the specific route. You can use this middleware by specifying `rye.MiddlewareRouteLogger`
-when defining your routes.
+when defining your routes. With `Config.UnifiedSampling`, only sampled requests are logged.
 
 Example use case:
 
@@ -20,6 +20,10 @@ Example use case:
 */
 func MiddlewareRouteLogger() func(rw http.ResponseWriter, req *http.Request) *Response {
 	return func(rw http.ResponseWriter, r *http.Request) *Response {
+		if !Sampled(r.Context()) {
+			return nil
+		}
+
 		log.Infof("%s \"%s %s %s\"", r.RemoteAddr, r.Method, r.RequestURI, r.Proto)
 		return nil
 	}
@@ -4,7 +4,6 @@ import (
 	"net/http"
 	"net/http/httptest"
 
-	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
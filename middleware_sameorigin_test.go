@@ -0,0 +1,94 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Same Origin Middleware", func() {
+
+	var (
+		request  *http.Request
+		response *httptest.ResponseRecorder
+		handler  func(rw http.ResponseWriter, req *http.Request) *Response
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("POST", "https://app.example.com/orders", nil)
+		response = httptest.NewRecorder()
+		handler = NewMiddlewareSameOrigin(SameOriginConfig{TrustedOrigins: []string{"https://Admin.example.com/"}})
+	})
+
+	expectForbidden := func(resp *Response) {
+		Expect(resp).ToNot(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(resp.StopExecution).To(BeTrue())
+	}
+
+	Describe("handle", func() {
+		Context("with a same-origin request", func() {
+			It("should let it through based on Origin", func() {
+				request.Header.Set("Origin", "https://app.example.com")
+
+				Expect(handler(response, request)).To(BeNil())
+			})
+
+			It("should let it through based on Referer", func() {
+				request.Header.Set("Referer", "https://app.example.com/cart?step=2")
+
+				Expect(handler(response, request)).To(BeNil())
+			})
+		})
+
+		Context("with a cross-origin request", func() {
+			It("should reject it with a 403", func() {
+				request.Header.Set("Origin", "https://evil.example.net")
+
+				resp := handler(response, request)
+				expectForbidden(resp)
+				Expect(resp.Error()).To(ContainSubstring("'https://evil.example.net' does not match 'app.example.com'"))
+			})
+
+			It("should reject a cross-origin Referer", func() {
+				request.Header.Set("Referer", "https://evil.example.net/page")
+
+				expectForbidden(handler(response, request))
+			})
+
+			It("should reject an opaque origin", func() {
+				request.Header.Set("Origin", "null")
+
+				expectForbidden(handler(response, request))
+			})
+
+			It("should let trusted origins through", func() {
+				request.Header.Set("Origin", "https://admin.example.com")
+
+				Expect(handler(response, request)).To(BeNil())
+			})
+
+			It("should let safe methods through", func() {
+				request.Method = "GET"
+				request.Header.Set("Origin", "https://evil.example.net")
+
+				Expect(handler(response, request)).To(BeNil())
+			})
+		})
+
+		Context("with a request missing its origin", func() {
+			It("should let it through by default", func() {
+				Expect(handler(response, request)).To(BeNil())
+			})
+
+			It("should reject it in strict mode", func() {
+				handler = NewMiddlewareSameOrigin(SameOriginConfig{RejectMissing: true})
+
+				resp := handler(response, request)
+				expectForbidden(resp)
+				Expect(resp.Error()).To(Equal("Request origin is missing"))
+			})
+		})
+	})
+})
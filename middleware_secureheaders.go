@@ -0,0 +1,111 @@
+package rye
+
+import (
+	"net/http"
+)
+
+const (
+	// Default values of the headers set by NewMiddlewareSecureHeaders
+	DEFAULT_CONTENT_TYPE_OPTIONS      = "nosniff"
+	DEFAULT_FRAME_OPTIONS             = "DENY"
+	DEFAULT_STRICT_TRANSPORT_SECURITY = "max-age=63072000; includeSubDomains"
+	DEFAULT_CONTENT_SECURITY_POLICY   = "default-src 'none'; frame-ancestors 'none'"
+	DEFAULT_REFERRER_POLICY           = "no-referrer"
+)
+
+// SecureHeadersConfig configures the security headers middleware. Each field is the value of a
+// header; a header whose field is empty is not set at all.
+type SecureHeadersConfig struct {
+	// ContentTypeOptions is the `X-Content-Type-Options` header
+	ContentTypeOptions string
+
+	// FrameOptions is the `X-Frame-Options` header
+	FrameOptions string
+
+	// StrictTransportSecurity is the `Strict-Transport-Security` header, only set on
+	// responses to TLS requests unless ForceStrictTransportSecurity is set (ie. behind
+	// a load balancer terminating TLS)
+	StrictTransportSecurity      string
+	ForceStrictTransportSecurity bool
+
+	// ContentSecurityPolicy is the `Content-Security-Policy` header
+	ContentSecurityPolicy string
+
+	// ReferrerPolicy is the `Referrer-Policy` header
+	ReferrerPolicy string
+}
+
+// DefaultSecureHeadersConfig returns the configuration used by NewMiddlewareSecureHeaders, to start
+// from when only some of the headers need to be changed (see NewMiddlewareSecureHeadersWithConfig).
+func DefaultSecureHeadersConfig() SecureHeadersConfig {
+	return SecureHeadersConfig{
+		ContentTypeOptions:      DEFAULT_CONTENT_TYPE_OPTIONS,
+		FrameOptions:            DEFAULT_FRAME_OPTIONS,
+		StrictTransportSecurity: DEFAULT_STRICT_TRANSPORT_SECURITY,
+		ContentSecurityPolicy:   DEFAULT_CONTENT_SECURITY_POLICY,
+		ReferrerPolicy:          DEFAULT_REFERRER_POLICY,
+	}
+}
+
+type secureHeaders struct {
+	config SecureHeadersConfig
+}
+
+/*
+NewMiddlewareSecureHeaders creates a new handler that sets common security headers on responses (see
+DefaultSecureHeadersConfig); `Strict-Transport-Security` is only set on responses to TLS requests. The headers
+are set before the rest of the chain runs, so handlers can still override them. The default
+`Content-Security-Policy` suits APIs; endpoints serving pages need a policy of their own (see
+NewMiddlewareSecureHeadersWithConfig).
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareSecureHeaders(),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareSecureHeaders() func(rw http.ResponseWriter, req *http.Request) *Response {
+	return NewMiddlewareSecureHeadersWithConfig(DefaultSecureHeadersConfig())
+}
+
+/*
+NewMiddlewareSecureHeadersWithConfig works like NewMiddlewareSecureHeaders, with the given header values; empty
+ones are left out.
+
+Example usage:
+
+	cfg := rye.DefaultSecureHeadersConfig()
+	cfg.FrameOptions = "SAMEORIGIN"
+	cfg.ContentSecurityPolicy = "default-src 'self'"
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareSecureHeadersWithConfig(cfg),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareSecureHeadersWithConfig(cfg SecureHeadersConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	s := &secureHeaders{config: cfg}
+	return s.handle
+}
+
+func (s *secureHeaders) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	set := func(key, value string) {
+		if value != "" {
+			rw.Header().Set(key, value)
+		}
+	}
+
+	set("X-Content-Type-Options", s.config.ContentTypeOptions)
+	set("X-Frame-Options", s.config.FrameOptions)
+	set("Content-Security-Policy", s.config.ContentSecurityPolicy)
+	set("Referrer-Policy", s.config.ReferrerPolicy)
+
+	if r.TLS != nil || s.config.ForceStrictTransportSecurity {
+		set("Strict-Transport-Security", s.config.StrictTransportSecurity)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package rye
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Secure Headers Middleware", func() {
+
+	var (
+		request  *http.Request
+		response *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("GET", "/", nil)
+		response = httptest.NewRecorder()
+	})
+
+	Describe("handle", func() {
+		It("should set the security headers", func() {
+			Expect(NewMiddlewareSecureHeaders()(response, request)).To(BeNil())
+
+			Expect(response.Header().Get("X-Content-Type-Options")).To(Equal("nosniff"))
+			Expect(response.Header().Get("X-Frame-Options")).To(Equal("DENY"))
+			Expect(response.Header().Get("Referrer-Policy")).To(Equal("no-referrer"))
+			Expect(response.Header().Get("Content-Security-Policy")).To(Equal(DEFAULT_CONTENT_SECURITY_POLICY))
+			Expect(response.Header()).ToNot(HaveKey("Strict-Transport-Security"))
+		})
+
+		It("should set Strict-Transport-Security on TLS requests", func() {
+			request.TLS = &tls.ConnectionState{}
+
+			NewMiddlewareSecureHeaders()(response, request)
+			Expect(response.Header().Get("Strict-Transport-Security")).To(Equal(DEFAULT_STRICT_TRANSPORT_SECURITY))
+		})
+
+		Context("with a config", func() {
+			It("should set the given values", func() {
+				cfg := DefaultSecureHeadersConfig()
+				cfg.FrameOptions = "SAMEORIGIN"
+				cfg.ContentSecurityPolicy = "default-src 'self'"
+
+				NewMiddlewareSecureHeadersWithConfig(cfg)(response, request)
+
+				Expect(response.Header().Get("X-Frame-Options")).To(Equal("SAMEORIGIN"))
+				Expect(response.Header().Get("Content-Security-Policy")).To(Equal("default-src 'self'"))
+				Expect(response.Header().Get("X-Content-Type-Options")).To(Equal("nosniff"))
+			})
+
+			It("should leave out the headers whose value is empty", func() {
+				NewMiddlewareSecureHeadersWithConfig(SecureHeadersConfig{
+					FrameOptions:                 "DENY",
+					ForceStrictTransportSecurity: true,
+				})(response, request)
+
+				Expect(response.Header()).To(HaveLen(1))
+				Expect(response.Header().Get("X-Frame-Options")).To(Equal("DENY"))
+			})
+
+			It("should set Strict-Transport-Security on plain HTTP requests when forced to", func() {
+				cfg := DefaultSecureHeadersConfig()
+				cfg.ForceStrictTransportSecurity = true
+
+				NewMiddlewareSecureHeadersWithConfig(cfg)(response, request)
+				Expect(response.Header().Get("Strict-Transport-Security")).To(Equal(DEFAULT_STRICT_TRANSPORT_SECURITY))
+			})
+		})
+
+		It("should let handlers override the headers", func() {
+			h := NewMWHandler(Config{}).Handle([]Handler{NewMiddlewareSecureHeaders(), func(rw http.ResponseWriter, r *http.Request) *Response {
+				rw.Header().Set("X-Frame-Options", "SAMEORIGIN")
+				return nil
+			}})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Header().Get("X-Frame-Options")).To(Equal("SAMEORIGIN"))
+		})
+	})
+})
@@ -0,0 +1,60 @@
+package rye
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type serverTiming struct{}
+
+/*
+NewMiddlewareServerTiming creates a new handler that adds a `Server-Timing` header to the response
+breaking down how long each handler in the chain took, in milliseconds (ie. `handle;dur=2.1, getItems;dur=15.32`).
+This makes backend phases visible in the browser's developer tools.
+
+The response is buffered until the chain finishes so that the header can include every handler.
+Handlers that ran before this middleware are included as well, so place it early in the chain.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareServerTiming(),
+			rye.NewMiddlewareJWT(secret),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareServerTiming() func(rw http.ResponseWriter, req *http.Request) *Response {
+	s := &serverTiming{}
+	return s.handle
+}
+
+func (s *serverTiming) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	c := chainFromRequest(r)
+	if c == nil {
+		return nil
+	}
+
+	return &Response{
+		Writer: newBufferedResponseWriter(rw, func(b *bufferedResponseWriter) {
+			if header := formatServerTiming(c.timings); header != "" {
+				b.Header().Set("Server-Timing", header)
+			}
+		}),
+	}
+}
+
+// formatServerTiming renders the handler timings as a Server-Timing header value
+func formatServerTiming(timings []handlerTiming) string {
+	metrics := make([]string, 0, len(timings))
+
+	for _, t := range timings {
+		ms := math.Round(float64(t.duration)/float64(time.Microsecond)/10) / 100
+		metrics = append(metrics, t.name+";dur="+strconv.FormatFloat(ms, 'f', -1, 64))
+	}
+
+	return strings.Join(metrics, ", ")
+}
@@ -0,0 +1,75 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server Timing Middleware", func() {
+
+	var (
+		request   *http.Request
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		request = httptest.NewRequest("GET", "/", nil)
+		mwHandler = NewMWHandler(Config{})
+	})
+
+	Describe("handle", func() {
+		Context("when used outside of a chain", func() {
+			It("should return nil", func() {
+				resp := NewMiddlewareServerTiming()(response, request)
+				Expect(resp).To(BeNil())
+			})
+		})
+
+		Context("when used in a chain", func() {
+			It("should list each executed handler's duration", func() {
+				h := mwHandler.Handle([]Handler{NewMiddlewareServerTiming(), slowHandler, textHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Body.String()).To(Equal("plain text"))
+
+				metrics := strings.Split(response.Header().Get("Server-Timing"), ", ")
+				Expect(metrics).To(HaveLen(3))
+				Expect(metrics[0]).To(MatchRegexp(`^serverTiming\.handle;dur=`))
+				Expect(metrics[1]).To(HavePrefix("slowHandler;dur="))
+				Expect(metrics[2]).To(HavePrefix("textHandler;dur="))
+
+				dur, err := strconv.ParseFloat(strings.TrimPrefix(metrics[1], "slowHandler;dur="), 64)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(dur).To(BeNumerically(">=", 150))
+			})
+
+			It("should include the header on error responses", func() {
+				h := mwHandler.Handle([]Handler{NewMiddlewareServerTiming(), failureHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(505))
+				Expect(response.Header().Get("Server-Timing")).To(ContainSubstring("failureHandler;dur="))
+			})
+		})
+	})
+
+	Describe("formatServerTiming", func() {
+		It("should render durations in milliseconds", func() {
+			Expect(formatServerTiming([]handlerTiming{
+				{name: "auth", duration: 2 * time.Millisecond},
+				{name: "db", duration: 15340 * time.Microsecond},
+			})).To(Equal("auth;dur=2, db;dur=15.34"))
+		})
+
+		It("should return an empty string when there are no timings", func() {
+			Expect(formatServerTiming(nil)).To(BeEmpty())
+		})
+	})
+})
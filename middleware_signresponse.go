@@ -0,0 +1,67 @@
+package rye
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+const (
+	DEFAULT_SIGN_RESPONSE_HEADER = "X-Signature"
+
+	// Responses larger than this are sent unsigned rather than buffered in full
+	DEFAULT_SIGN_RESPONSE_MAX_SIZE = 10 << 20
+)
+
+type signResponse struct {
+	secret []byte
+	header string
+}
+
+/*
+NewMiddlewareSignResponse creates a new handler that signs response bodies so that clients (ie. webhook
+receivers) can verify their integrity: the body is buffered, its HMAC-SHA256 is computed with `secret`
+and set hex encoded in the given header (`X-Signature` by default).
+
+Bodies larger than DEFAULT_SIGN_RESPONSE_MAX_SIZE are not buffered in full and are sent without a signature.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareSignResponse([]byte("secret"), "X-Webhook-Signature"),
+			yourHandler,
+		})).Methods("POST")
+*/
+func NewMiddlewareSignResponse(secret []byte, header string) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if header == "" {
+		header = DEFAULT_SIGN_RESPONSE_HEADER
+	}
+
+	s := &signResponse{
+		secret: secret,
+		header: header,
+	}
+
+	return s.handle
+}
+
+func (s *signResponse) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	writer := newBufferedResponseWriter(rw, func(b *bufferedResponseWriter) {
+		b.Header().Set(s.header, s.sign(b.body.Bytes()))
+	})
+	writer.maxSize = DEFAULT_SIGN_RESPONSE_MAX_SIZE
+
+	return &Response{
+		Writer: writer,
+	}
+}
+
+// sign returns the hex encoded HMAC-SHA256 of the body
+func (s *signResponse) sign(body []byte) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
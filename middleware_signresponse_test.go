@@ -0,0 +1,68 @@
+package rye
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sign Response Middleware", func() {
+
+	var (
+		request   *http.Request
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		secret    []byte
+	)
+
+	expectedSignature := func(body []byte) string {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(body)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		request = httptest.NewRequest("GET", "/", nil)
+		mwHandler = NewMWHandler(Config{})
+		secret = []byte("webhook-secret")
+	})
+
+	Describe("handle", func() {
+		It("should set the signature header to the HMAC of the body", func() {
+			h := mwHandler.Handle([]Handler{NewMiddlewareSignResponse(secret, "X-Webhook-Signature"), jsonHandler})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(Equal(`{"name":"rye"}`))
+			Expect(response.Header().Get("X-Webhook-Signature")).To(Equal(expectedSignature(response.Body.Bytes())))
+		})
+
+		It("should default to the X-Signature header", func() {
+			h := mwHandler.Handle([]Handler{NewMiddlewareSignResponse(secret, ""), textHandler})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Header().Get("X-Signature")).To(Equal(expectedSignature([]byte("plain text"))))
+		})
+
+		It("should sign error responses", func() {
+			h := mwHandler.Handle([]Handler{NewMiddlewareSignResponse(secret, ""), failureHandler})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(505))
+			Expect(response.Header().Get("X-Signature")).To(Equal(expectedSignature(response.Body.Bytes())))
+		})
+
+		It("should cap the buffered body", func() {
+			resp := NewMiddlewareSignResponse(secret, "")(response, request)
+
+			writer, ok := resp.Writer.(*bufferedResponseWriter)
+			Expect(ok).To(BeTrue())
+			Expect(writer.maxSize).To(Equal(DEFAULT_SIGN_RESPONSE_MAX_SIZE))
+		})
+	})
+})
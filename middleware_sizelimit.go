@@ -0,0 +1,109 @@
+package rye
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type sizeLimitByPath struct {
+	patterns     []string
+	limits       map[string]int64
+	defaultLimit int64
+}
+
+/*
+NewMiddlewareSizeLimitByPath creates a new handler that enforces a maximum request body size that
+depends on the request path. `limits` maps path prefixes to a maximum body size in bytes; the most
+specific (longest) matching prefix wins and `defaultLimit` is used when no prefix matches. A limit
+of 0 (or less) means unlimited.
+
+Requests declaring a `Content-Length` over the limit are rejected straight away with a 413 (and a
+`request.too_large` stat); otherwise the body is wrapped in an `http.MaxBytesReader` so reads past the
+limit fail with ErrRequestBodyTooLarge. The handlers bundled with rye that read the body (ie. the JSON body
+middleware) answer those with a 413 and the `request.too_large` stat as well.
+
+Example usage:
+
+	routes.PathPrefix("/").Handler(a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareSizeLimitByPath(map[string]int64{
+				"/upload": 10 << 20, // 10MB
+				"/login":  1 << 10,  // 1KB
+			}, 64<<10),
+			yourHandler,
+		}))
+*/
+func NewMiddlewareSizeLimitByPath(limits map[string]int64, defaultLimit int64) func(rw http.ResponseWriter, req *http.Request) *Response {
+	s := &sizeLimitByPath{
+		patterns:     make([]string, 0, len(limits)),
+		limits:       limits,
+		defaultLimit: defaultLimit,
+	}
+
+	for pattern := range limits {
+		s.patterns = append(s.patterns, pattern)
+	}
+
+	// Most specific patterns first, so matching is deterministic
+	sort.Slice(s.patterns, func(i, j int) bool {
+		if len(s.patterns[i]) != len(s.patterns[j]) {
+			return len(s.patterns[i]) > len(s.patterns[j])
+		}
+		return s.patterns[i] < s.patterns[j]
+	})
+
+	return s.handle
+}
+
+/*
+NewMiddlewareMaxBody creates a new handler that enforces a maximum request body size of `maxBytes` for every
+request, the way NewMiddlewareSizeLimitByPath does: requests declaring a larger `Content-Length` are rejected
+with a 413 and the body is wrapped in an `http.MaxBytesReader`, so that the handlers further down the chain
+fail cleanly when reading past the limit.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareMaxBody(1 << 20), // 1MB
+			yourHandler,
+		})).Methods("POST")
+*/
+func NewMiddlewareMaxBody(maxBytes int64) func(rw http.ResponseWriter, req *http.Request) *Response {
+	return NewMiddlewareSizeLimitByPath(nil, maxBytes)
+}
+
+func (s *sizeLimitByPath) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	limit := s.limitFor(r.URL.Path)
+	if limit <= 0 {
+		return nil
+	}
+
+	if r.ContentLength > limit {
+		chainFromRequest(r).inc("request.too_large")
+
+		return &Response{
+			Err:        fmt.Errorf("Request body too large; limit is %d bytes", limit),
+			StatusCode: http.StatusRequestEntityTooLarge,
+		}
+	}
+
+	if r.Body != nil {
+		r.Body = &maxBodyReader{ReadCloser: http.MaxBytesReader(rw, r.Body, limit), limit: limit}
+	}
+
+	return nil
+}
+
+// limitFor returns the limit of the first matching pattern (or the default limit)
+func (s *sizeLimitByPath) limitFor(path string) int64 {
+	for _, pattern := range s.patterns {
+		if strings.HasPrefix(path, pattern) {
+			return s.limits[pattern]
+		}
+	}
+
+	return s.defaultLimit
+}
@@ -0,0 +1,157 @@
+package rye
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Size Limit By Path Middleware", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+		handler  func(rw http.ResponseWriter, req *http.Request) *Response
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		handler = NewMiddlewareSizeLimitByPath(map[string]int64{
+			"/upload":       100,
+			"/upload/small": 5,
+			"/login":        10,
+		}, 20)
+	})
+
+	Describe("handle", func() {
+		Context("when the declared content length exceeds the path limit", func() {
+			It("should return a 413 for the login path", func() {
+				request := httptest.NewRequest("POST", "/login", strings.NewReader(strings.Repeat("a", 15)))
+
+				resp := handler(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+				Expect(resp.Error()).To(ContainSubstring("limit is 10 bytes"))
+			})
+
+			It("should use the most specific pattern", func() {
+				request := httptest.NewRequest("POST", "/upload/small/file", strings.NewReader(strings.Repeat("a", 15)))
+
+				resp := handler(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Error()).To(ContainSubstring("limit is 5 bytes"))
+			})
+
+			It("should fall back to the default limit", func() {
+				request := httptest.NewRequest("POST", "/other", strings.NewReader(strings.Repeat("a", 25)))
+
+				resp := handler(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.Error()).To(ContainSubstring("limit is 20 bytes"))
+			})
+		})
+
+		Context("when the body is within the path limit", func() {
+			It("should return nil and leave the body readable", func() {
+				request := httptest.NewRequest("POST", "/upload", strings.NewReader(strings.Repeat("a", 50)))
+
+				resp := handler(response, request)
+				Expect(resp).To(BeNil())
+
+				body, err := ioutil.ReadAll(request.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(body).To(HaveLen(50))
+			})
+		})
+
+		Context("when the body exceeds the limit without a declared content length", func() {
+			It("should fail reads past the limit", func() {
+				request := httptest.NewRequest("POST", "/login", strings.NewReader(strings.Repeat("a", 15)))
+				request.ContentLength = -1
+
+				resp := handler(response, request)
+				Expect(resp).To(BeNil())
+
+				_, err := ioutil.ReadAll(request.Body)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+})
+
+var _ = Describe("Max Body Middleware", func() {
+
+	var (
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		reporter  *recordingReporter
+		readErr   error
+		read      []byte
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		reporter = &recordingReporter{}
+		mwHandler = NewMWHandler(Config{Reporter: reporter})
+		readErr, read = nil, nil
+	})
+
+	serve := func(request *http.Request) {
+		h := mwHandler.Handle([]Handler{NewMiddlewareMaxBody(10), NamedHandler("upload", func(rw http.ResponseWriter, r *http.Request) *Response {
+			read, readErr = ioutil.ReadAll(r.Body)
+			return nil
+		})})
+		h.ServeHTTP(response, request)
+	}
+
+	Describe("handle", func() {
+		It("should reject a declared content length over the limit with a 413", func() {
+			serve(httptest.NewRequest("POST", "/upload", strings.NewReader(strings.Repeat("a", 15))))
+
+			Expect(response.Code).To(Equal(http.StatusRequestEntityTooLarge))
+			Expect(response.Body.String()).To(ContainSubstring("limit is 10 bytes"))
+			Expect(read).To(BeNil())
+			Eventually(reporter.recordedIncs).Should(ContainElement("request.too_large"))
+		})
+
+		It("should hand the limited body to the rest of the chain", func() {
+			request := httptest.NewRequest("POST", "/upload", strings.NewReader(strings.Repeat("a", 15)))
+			request.ContentLength = -1
+
+			serve(request)
+
+			Expect(errors.Is(readErr, ErrRequestBodyTooLarge)).To(BeTrue())
+			Expect(readErr.Error()).To(ContainSubstring("limit is 10 bytes"))
+			Expect(read).To(HaveLen(10))
+		})
+
+		It("should have body reading middlewares reject a chunked body over the limit with a 413", func() {
+			request := httptest.NewRequest("POST", "/upload", strings.NewReader(`{"name":"`+strings.Repeat("a", 15)+`"}`))
+			request.Header.Set("Content-Type", "application/json")
+			request.ContentLength = -1
+
+			h := mwHandler.Handle([]Handler{
+				NewMiddlewareMaxBody(10),
+				NewMiddlewareJSONBody(func() interface{} { return &map[string]string{} }),
+				successHandler,
+			})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusRequestEntityTooLarge))
+			Expect(response.Body.String()).To(ContainSubstring("limit is 10 bytes"))
+			Eventually(reporter.recordedIncs).Should(ContainElement("request.too_large"))
+		})
+
+		It("should let bodies within the limit through", func() {
+			serve(httptest.NewRequest("POST", "/upload", strings.NewReader("hello")))
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(readErr).ToNot(HaveOccurred())
+			Expect(string(read)).To(Equal("hello"))
+			Consistently(reporter.recordedIncs).ShouldNot(ContainElement("request.too_large"))
+		})
+	})
+})
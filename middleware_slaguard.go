@@ -0,0 +1,135 @@
+package rye
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SLAGuardConfig configures the SLA guard middleware.
+type SLAGuardConfig struct {
+	// Threshold is how long a request may take before it violates its SLA
+	Threshold time.Duration
+
+	// PathThresholds maps path prefixes to their own threshold; the most specific
+	// (longest) matching prefix wins and Threshold is used when no prefix matches
+	PathThresholds map[string]time.Duration
+
+	// Logger, when set, logs every violation at error level
+	Logger Logger
+}
+
+type slaGuard struct {
+	config   SLAGuardConfig
+	patterns []string
+}
+
+/*
+NewMiddlewareSLAGuard creates a new handler that checks how long each request takes against an SLA
+`threshold`, from the start of the chain until it is done. Each request exceeding it increments the
+`sla.violation` stat, making SLA breaches a signal of their own rather than something derived from the
+handler timings later on. A threshold of 0 (or less) disables the check.
+
+Use `rye.NewMiddlewareSLAGuardWithConfig` for per-path thresholds or to log violations.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareSLAGuard(250 * time.Millisecond),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareSLAGuard(threshold time.Duration) func(rw http.ResponseWriter, req *http.Request) *Response {
+	return NewMiddlewareSLAGuardWithConfig(SLAGuardConfig{Threshold: threshold})
+}
+
+/*
+NewMiddlewareSLAGuardWithConfig works like NewMiddlewareSLAGuard, with thresholds depending on the request
+path (see `PathThresholds`) and violations logged at error level through `Logger` when it is set.
+
+Example usage:
+
+	routes.PathPrefix("/").Handler(a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareSLAGuardWithConfig(rye.SLAGuardConfig{
+				Threshold: 250 * time.Millisecond,
+				PathThresholds: map[string]time.Duration{
+					"/reports": 2 * time.Second,
+				},
+				Logger: rye.LogrusLogger{},
+			}),
+			yourHandler,
+		}))
+*/
+func NewMiddlewareSLAGuardWithConfig(cfg SLAGuardConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	s := &slaGuard{
+		config:   cfg,
+		patterns: make([]string, 0, len(cfg.PathThresholds)),
+	}
+
+	for pattern := range cfg.PathThresholds {
+		s.patterns = append(s.patterns, pattern)
+	}
+
+	// Most specific patterns first, so matching is deterministic
+	sort.Slice(s.patterns, func(i, j int) bool {
+		if len(s.patterns[i]) != len(s.patterns[j]) {
+			return len(s.patterns[i]) > len(s.patterns[j])
+		}
+		return s.patterns[i] < s.patterns[j]
+	})
+
+	return s.handle
+}
+
+func (s *slaGuard) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	chain := chainFromRequest(r)
+	if chain == nil {
+		// Without a chain there is no telling when the request is done
+		return nil
+	}
+
+	threshold := s.thresholdFor(r.URL.Path)
+	if threshold <= 0 {
+		return nil
+	}
+
+	return &Response{
+		Writer: newStatusRecordingWriter(rw, func(status int) {
+			elapsed := time.Since(chain.start)
+			if elapsed <= threshold {
+				return
+			}
+
+			chain.inc("sla.violation")
+
+			if s.config.Logger == nil {
+				return
+			}
+
+			s.config.Logger.Log(LogEntry{
+				Level:     LOG_LEVEL_ERROR,
+				Message:   fmt.Sprintf("Request exceeded its SLA of %v", threshold),
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Status:    status,
+				Duration:  elapsed,
+				RequestID: requestIDOf(r, DEFAULT_REQUEST_ID_HEADER),
+			})
+		}),
+	}
+}
+
+// thresholdFor returns the threshold of the first matching pattern (or the default threshold)
+func (s *slaGuard) thresholdFor(path string) time.Duration {
+	for _, pattern := range s.patterns {
+		if strings.HasPrefix(path, pattern) {
+			return s.config.PathThresholds[pattern]
+		}
+	}
+
+	return s.config.Threshold
+}
@@ -0,0 +1,103 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SLA Guard Middleware", func() {
+
+	var (
+		request   *http.Request
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		reporter  *recordingReporter
+		logger    *recordingLogger
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("GET", "/reports/daily", nil)
+		response = httptest.NewRecorder()
+		reporter = &recordingReporter{}
+		mwHandler = NewMWHandler(Config{Reporter: reporter, StatRate: 1})
+		logger = &recordingLogger{}
+	})
+
+	Describe("handle", func() {
+		Context("when the request exceeds the threshold", func() {
+			It("should emit the sla.violation stat", func() {
+				h := mwHandler.Handle([]Handler{NewMiddlewareSLAGuard(50 * time.Millisecond), slowHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Eventually(reporter.recordedIncs).Should(ContainElement("sla.violation"))
+			})
+
+			It("should log the violation at error level when a logger is set", func() {
+				h := mwHandler.Handle([]Handler{NewMiddlewareSLAGuardWithConfig(SLAGuardConfig{
+					Threshold: 50 * time.Millisecond,
+					Logger:    logger,
+				}), slowHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(logger.entries).To(HaveLen(1))
+
+				entry := logger.entries[0]
+				Expect(entry.Level).To(Equal(LOG_LEVEL_ERROR))
+				Expect(entry.Message).To(ContainSubstring("50ms"))
+				Expect(entry.Path).To(Equal("/reports/daily"))
+				Expect(entry.Status).To(Equal(http.StatusOK))
+				Expect(entry.Duration).To(BeNumerically(">", 50*time.Millisecond))
+			})
+		})
+
+		Context("when the request is within the threshold", func() {
+			It("should not emit anything", func() {
+				h := mwHandler.Handle([]Handler{NewMiddlewareSLAGuardWithConfig(SLAGuardConfig{
+					Threshold: time.Second,
+					Logger:    logger,
+				}), successHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(reporter.recordedIncs).Should(ContainElement("handlers.successHandler.2xx"))
+				Consistently(reporter.recordedIncs).ShouldNot(ContainElement("sla.violation"))
+				Expect(logger.entries).To(BeEmpty())
+			})
+		})
+
+		Context("when the path has its own threshold", func() {
+			It("should use the most specific one", func() {
+				h := mwHandler.Handle([]Handler{NewMiddlewareSLAGuardWithConfig(SLAGuardConfig{
+					Threshold: 50 * time.Millisecond,
+					PathThresholds: map[string]time.Duration{
+						"/reports":       10 * time.Millisecond,
+						"/reports/daily": time.Second,
+					},
+					Logger: logger,
+				}), slowHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(logger.entries).To(BeEmpty())
+
+				request = httptest.NewRequest("GET", "/reports/weekly", nil)
+				h.ServeHTTP(httptest.NewRecorder(), request)
+
+				Expect(logger.entries).To(HaveLen(1))
+				Expect(logger.entries[0].Message).To(ContainSubstring("10ms"))
+			})
+		})
+
+		Context("when the threshold is disabled", func() {
+			It("should not check the request", func() {
+				h := mwHandler.Handle([]Handler{NewMiddlewareSLAGuard(0), slowHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(reporter.recordedIncs).Should(ContainElement("handlers.slowHandler.2xx"))
+				Consistently(reporter.recordedIncs).ShouldNot(ContainElement("sla.violation"))
+			})
+		})
+	})
+})
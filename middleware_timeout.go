@@ -0,0 +1,106 @@
+package rye
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type timeout struct {
+	timeout time.Duration
+}
+
+/*
+NewMiddlewareTimeout creates a new handler that gives the rest of the chain a deadline: the request context
+is replaced with one timing out after `d`, so downstream handlers (and the calls they make with the context)
+are cut off once it passes. The chain then stops and, unless a response was already started, a 503 is
+written instead of anything the remaining handlers would write. The context is cancelled once the chain is
+done so no resources leak.
+
+Handlers run one after the other, so a handler ignoring its context can't be interrupted; it only finds out
+that its writes are dropped.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareTimeout(5 * time.Second),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareTimeout(d time.Duration) func(rw http.ResponseWriter, req *http.Request) *Response {
+	t := &timeout{timeout: d}
+	return t.handle
+}
+
+func (t *timeout) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	chain := chainFromRequest(r)
+	if chain == nil {
+		// Without a chain there is nothing to cancel the context when the request is done
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), t.timeout)
+	chain.deadline = ctx
+
+	return &Response{
+		Context: ctx,
+		Writer: &timeoutWriter{
+			ResponseWriter: rw,
+			ctx:            ctx,
+			cancel:         cancel,
+		},
+	}
+}
+
+// timeoutWriter drops writes once its context is done, unless the response was started before
+type timeoutWriter struct {
+	http.ResponseWriter
+
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (t *timeoutWriter) WriteHeader(statusCode int) {
+	if t.expired() {
+		return
+	}
+
+	t.wroteHeader = true
+	t.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (t *timeoutWriter) Write(p []byte) (int, error) {
+	if t.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	t.wroteHeader = true
+	return t.ResponseWriter.Write(p)
+}
+
+func (t *timeoutWriter) Flush() {
+	if f, ok := t.ResponseWriter.(http.Flusher); ok && !t.expired() {
+		f.Flush()
+	}
+}
+
+// finalize writes out the 503 if the deadline passed before the response was started
+func (t *timeoutWriter) finalize() {
+	if t.expired() {
+		WriteJSONStatus(t.ResponseWriter, "error", "Request timed out", http.StatusServiceUnavailable)
+	}
+
+	t.cancel()
+}
+
+// expired returns whether the deadline passed before the response was started
+func (t *timeoutWriter) expired() bool {
+	if !t.timedOut && !t.wroteHeader && t.ctx.Err() != nil {
+		t.timedOut = true
+	}
+
+	return t.timedOut
+}
@@ -0,0 +1,108 @@
+package rye
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Timeout Middleware", func() {
+
+	var (
+		mwHandler *MWHandler
+		response  *httptest.ResponseRecorder
+		request   *http.Request
+		ran       bool
+	)
+
+	nextHandler := func(rw http.ResponseWriter, r *http.Request) *Response {
+		ran = true
+		return nil
+	}
+
+	BeforeEach(func() {
+		mwHandler = NewMWHandler(Config{})
+		response = httptest.NewRecorder()
+		request = httptest.NewRequest("GET", "/", nil)
+		ran = false
+	})
+
+	Describe("handle", func() {
+		Context("when a downstream handler is too slow", func() {
+			It("should cut it off with a 503 and stop the chain", func() {
+				var ctx context.Context
+
+				slow := func(rw http.ResponseWriter, r *http.Request) *Response {
+					ctx = r.Context()
+
+					select {
+					case <-time.After(time.Second):
+					case <-r.Context().Done():
+					}
+
+					rw.WriteHeader(http.StatusOK)
+					rw.Write([]byte("too late"))
+					return nil
+				}
+
+				start := time.Now()
+				h := mwHandler.Handle([]Handler{NewMiddlewareTimeout(20 * time.Millisecond), slow, nextHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(time.Since(start)).To(BeNumerically("<", 500*time.Millisecond))
+				Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+				Expect(response.Body.String()).To(ContainSubstring("Request timed out"))
+				Expect(response.Body.String()).ToNot(ContainSubstring("too late"))
+				Expect(ran).To(BeFalse())
+				Expect(ctx.Err()).To(Equal(context.DeadlineExceeded))
+			})
+		})
+
+		Context("when the response was started before the deadline", func() {
+			It("should keep it", func() {
+				slow := func(rw http.ResponseWriter, r *http.Request) *Response {
+					rw.WriteHeader(http.StatusAccepted)
+					<-r.Context().Done()
+					return nil
+				}
+
+				h := mwHandler.Handle([]Handler{NewMiddlewareTimeout(20 * time.Millisecond), slow})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusAccepted))
+			})
+		})
+
+		Context("when the chain finishes in time", func() {
+			It("should pass the deadline on and cancel the context once done", func() {
+				var ctx context.Context
+
+				fast := func(rw http.ResponseWriter, r *http.Request) *Response {
+					ctx = r.Context()
+					rw.Write([]byte("ok"))
+					return nil
+				}
+
+				h := mwHandler.Handle([]Handler{NewMiddlewareTimeout(time.Second), fast, nextHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(response.Body.String()).To(Equal("ok"))
+				Expect(ran).To(BeTrue())
+
+				_, ok := ctx.Deadline()
+				Expect(ok).To(BeTrue())
+				Expect(ctx.Err()).To(Equal(context.Canceled))
+			})
+		})
+
+		Context("when used outside a chain", func() {
+			It("should return nil", func() {
+				Expect(NewMiddlewareTimeout(time.Second)(response, request)).To(BeNil())
+			})
+		})
+	})
+})
@@ -0,0 +1,193 @@
+package rye
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	UNIQUE_CLIENTS_STAT = "unique_clients"
+
+	DEFAULT_UNIQUE_CLIENTS_WINDOW = time.Minute
+
+	// Number of sketches the window is split into
+	UNIQUE_CLIENTS_SLICES = 4
+
+	// Registers of a sketch are addressed with this many bits of the hash, giving
+	// 1024 registers (1KB) per sketch and a standard error of about 3%
+	hllPrecision = 10
+	hllRegisters = 1 << hllPrecision
+)
+
+// UniqueClientsConfig configures the unique clients middleware.
+type UniqueClientsConfig struct {
+	// Window over which unique clients are counted (defaults to DEFAULT_UNIQUE_CLIENTS_WINDOW)
+	Window time.Duration
+
+	// KeyFunc identifies the client of a request (defaults to its remote IP)
+	KeyFunc func(r *http.Request) string
+}
+
+type uniqueClientsSlice struct {
+	slice  int64
+	sketch hyperLogLog
+}
+
+type uniqueClients struct {
+	config UniqueClientsConfig
+
+	mu          sync.Mutex
+	slices      [UNIQUE_CLIENTS_SLICES]uniqueClientsSlice
+	lastEmitted int64
+}
+
+/*
+NewMiddlewareUniqueClients creates a new handler for rough unique visitor counting: it records the
+approximate number of unique clients (by remote IP, or the key returned by `KeyFunc`) seen over the
+rolling `Window` as the `unique_clients` gauge (through the MWHandler's statter).
+
+Clients are counted with HyperLogLog sketches rather than stored, so memory use is small and fixed
+(about 4KB) however many clients there are, at the cost of a ~3% error. The gauge is recorded at
+most once every quarter of the window.
+
+Example usage:
+
+	routes.PathPrefix("/").Handler(a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareUniqueClients(rye.UniqueClientsConfig{Window: time.Hour}),
+			yourHandler,
+		}))
+*/
+func NewMiddlewareUniqueClients(cfg UniqueClientsConfig) func(rw http.ResponseWriter, req *http.Request) *Response {
+	if cfg.Window <= 0 {
+		cfg.Window = DEFAULT_UNIQUE_CLIENTS_WINDOW
+	}
+
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = remoteHost
+	}
+
+	u := &uniqueClients{config: cfg}
+	return u.handle
+}
+
+func (u *uniqueClients) handle(rw http.ResponseWriter, r *http.Request) *Response {
+	estimate, emit := u.add(u.config.KeyFunc(r), time.Now())
+
+	if emit {
+		chainFromRequest(r).gauge(UNIQUE_CLIENTS_STAT, int64(estimate))
+	}
+
+	return nil
+}
+
+// add records the client and returns the estimate of unique clients over the window,
+// along with whether it is due to be emitted
+func (u *uniqueClients) add(key string, now time.Time) (uint64, bool) {
+	hash := hashKey(key)
+	current := now.UnixNano() / int64(u.config.Window/UNIQUE_CLIENTS_SLICES)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	slice := &u.slices[current%UNIQUE_CLIENTS_SLICES]
+	if slice.slice != current {
+		*slice = uniqueClientsSlice{slice: current}
+	}
+	slice.sketch.add(hash)
+
+	if u.lastEmitted == current {
+		return 0, false
+	}
+	u.lastEmitted = current
+
+	return u.estimate(current), true
+}
+
+// estimate merges the sketches of the window ending with the current slice
+func (u *uniqueClients) estimate(current int64) uint64 {
+	var merged hyperLogLog
+
+	for i := range u.slices {
+		if u.slices[i].slice > current-UNIQUE_CLIENTS_SLICES {
+			merged.merge(&u.slices[i].sketch)
+		}
+	}
+
+	return merged.estimate()
+}
+
+// hyperLogLog is a sketch estimating the number of distinct hashes added to it
+type hyperLogLog struct {
+	registers [hllRegisters]uint8
+}
+
+func (h *hyperLogLog) add(hash uint64) {
+	index := hash >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(hash<<hllPrecision|1<<(hllPrecision-1)) + 1)
+
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+func (h *hyperLogLog) merge(other *hyperLogLog) {
+	for i, rank := range other.registers {
+		if rank > h.registers[i] {
+			h.registers[i] = rank
+		}
+	}
+}
+
+func (h *hyperLogLog) estimate() uint64 {
+	const m = float64(hllRegisters)
+	alpha := 0.7213 / (1 + 1.079/m)
+
+	var sum float64
+	var zeros int
+	for _, rank := range h.registers {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha * m * m / sum
+
+	// Linear counting is more accurate for small cardinalities
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(estimate + 0.5)
+}
+
+// hashKey hashes a key, mixing the bits so that similar keys (ie. IPs) spread over the registers
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	x := h.Sum64()
+
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
+
+// remoteHost returns the IP of the client of the request (or its remote address if it has no port)
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,130 @@
+package rye
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/InVisionApp/rye/fakes/statsdfakes"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Unique Clients Middleware", func() {
+
+	clientIP := func(i int) string {
+		return fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+	}
+
+	Describe("hyperLogLog", func() {
+		It("should estimate large sets within tolerance", func() {
+			var sketch hyperLogLog
+			for i := 0; i < 20000; i++ {
+				sketch.add(hashKey(clientIP(i)))
+			}
+
+			Expect(float64(sketch.estimate())).To(BeNumerically("~", 20000, 20000*0.1))
+		})
+
+		It("should estimate small sets within tolerance", func() {
+			var sketch hyperLogLog
+			for i := 0; i < 100; i++ {
+				sketch.add(hashKey(clientIP(i)))
+			}
+
+			Expect(float64(sketch.estimate())).To(BeNumerically("~", 100, 5))
+		})
+
+		It("should not count repeated clients twice", func() {
+			var sketch hyperLogLog
+			for i := 0; i < 1000; i++ {
+				sketch.add(hashKey(clientIP(i % 10)))
+			}
+
+			Expect(sketch.estimate()).To(Equal(uint64(10)))
+		})
+	})
+
+	Describe("add", func() {
+		var (
+			u   *uniqueClients
+			now time.Time
+		)
+
+		BeforeEach(func() {
+			u = &uniqueClients{config: UniqueClientsConfig{Window: 4 * time.Minute}}
+			now = time.Unix(6000, 0)
+		})
+
+		It("should count clients over the rolling window", func() {
+			for i := 0; i < 500; i++ {
+				u.add(clientIP(i), now)
+			}
+			for i := 250; i < 1000; i++ {
+				u.add(clientIP(i), now.Add(time.Minute))
+			}
+
+			estimate, _ := u.add(clientIP(0), now.Add(2*time.Minute))
+			Expect(float64(estimate)).To(BeNumerically("~", 1000, 1000*0.1))
+		})
+
+		It("should forget clients that fell out of the window", func() {
+			for i := 0; i < 500; i++ {
+				u.add(clientIP(i), now)
+			}
+
+			estimate, _ := u.add(clientIP(0), now.Add(4*time.Minute))
+			Expect(estimate).To(Equal(uint64(1)))
+		})
+
+		It("should be due to emit once per slice", func() {
+			_, emit := u.add(clientIP(1), now)
+			Expect(emit).To(BeTrue())
+
+			_, emit = u.add(clientIP(2), now.Add(time.Second))
+			Expect(emit).To(BeFalse())
+
+			_, emit = u.add(clientIP(3), now.Add(time.Minute))
+			Expect(emit).To(BeTrue())
+		})
+
+		It("should be safe for concurrent use", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					u.add(clientIP(i), now)
+				}(i)
+			}
+			wg.Wait()
+
+			Expect(u.estimate(now.UnixNano() / int64(time.Minute))).To(Equal(uint64(50)))
+		})
+	})
+
+	Describe("handle", func() {
+		It("should record the unique_clients gauge", func() {
+			gauges := make(chan int64, 10)
+
+			fakeStatter := &statsdfakes.FakeStatter{}
+			fakeStatter.GaugeStub = func(name string, value int64, rate float32) error {
+				if name == UNIQUE_CLIENTS_STAT {
+					gauges <- value
+				}
+				return nil
+			}
+
+			h := NewMWHandler(Config{Statter: fakeStatter, StatRate: 1}).Handle([]Handler{
+				NewMiddlewareUniqueClients(UniqueClientsConfig{}),
+				successHandler,
+			})
+
+			request := httptest.NewRequest("GET", "/", nil)
+			request.RemoteAddr = "10.0.0.1:1234"
+			h.ServeHTTP(httptest.NewRecorder(), request)
+
+			Eventually(gauges).Should(Receive(Equal(int64(1))))
+		})
+	})
+})
@@ -0,0 +1,73 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("User Agent Filter Middleware", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+		request  *http.Request
+		config   UAFilterConfig
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		request = httptest.NewRequest("GET", "/", nil)
+		config = UAFilterConfig{
+			Allow: []string{`(?i)googlebot`},
+			Deny:  []string{`(?i)bot|crawler`, `^curl/`},
+		}
+	})
+
+	Describe("handle", func() {
+		Context("when the user agent is allowed", func() {
+			It("should return nil", func() {
+				request.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7)")
+
+				Expect(NewMiddlewareUserAgentFilter(config)(response, request)).To(BeNil())
+			})
+
+			It("should let allowlisted agents bypass the denylist", func() {
+				request.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Googlebot/2.1)")
+
+				Expect(NewMiddlewareUserAgentFilter(config)(response, request)).To(BeNil())
+			})
+		})
+
+		Context("when the user agent is denied", func() {
+			It("should stop the chain with a 403", func() {
+				request.Header.Set("User-Agent", "curl/7.64.1")
+
+				resp := NewMiddlewareUserAgentFilter(config)(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+				Expect(resp.StopExecution).To(BeTrue())
+			})
+		})
+
+		Context("when the user agent is empty", func() {
+			It("should let the request through by default", func() {
+				Expect(NewMiddlewareUserAgentFilter(config)(response, request)).To(BeNil())
+			})
+
+			It("should stop the chain with a 403 if configured", func() {
+				config.DenyEmpty = true
+
+				resp := NewMiddlewareUserAgentFilter(config)(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+			})
+		})
+
+		Context("when an expression is invalid", func() {
+			It("should panic", func() {
+				Expect(func() { NewMiddlewareUserAgentFilter(UAFilterConfig{Deny: []string{"("}}) }).To(Panic())
+			})
+		})
+	})
+})
@@ -0,0 +1,87 @@
+package rye
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"unicode"
+)
+
+/*
+NewMiddlewareValidateURL creates a new handler that stops the chain with a 400 when the request URL is
+malformed: when its path or query string contain invalid percent-encoding (ie. `%zz`), null bytes or other
+non-printable characters (whether raw or percent-encoded), so downstream handlers never have to deal with them.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareValidateURL(),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NewMiddlewareValidateURL() func(rw http.ResponseWriter, req *http.Request) *Response {
+	return validateURL
+}
+
+func validateURL(rw http.ResponseWriter, r *http.Request) *Response {
+	if r.URL == nil {
+		return nil
+	}
+
+	if err := checkURL(r.URL); err != nil {
+		return &Response{
+			Err:           err,
+			StatusCode:    http.StatusBadRequest,
+			StopExecution: true,
+		}
+	}
+
+	return nil
+}
+
+// checkURL returns an error describing what is wrong with the URL's path or query (if anything)
+func checkURL(u *url.URL) error {
+	// Without a raw path, the path was decoded from a valid encoding
+	path := u.Path
+	if u.RawPath != "" {
+		var err error
+		if path, err = url.PathUnescape(u.RawPath); err != nil {
+			return fmt.Errorf("Invalid escape in URL path")
+		}
+	}
+
+	if err := checkPrintable(path); err != nil {
+		return fmt.Errorf("Invalid URL path: %v", err)
+	}
+
+	if err := checkPrintable(u.RawQuery); err != nil {
+		return fmt.Errorf("Invalid URL query: %v", err)
+	}
+
+	query, err := url.QueryUnescape(u.RawQuery)
+	if err != nil {
+		return fmt.Errorf("Invalid escape in URL query")
+	}
+
+	if err := checkPrintable(query); err != nil {
+		return fmt.Errorf("Invalid URL query: %v", err)
+	}
+
+	return nil
+}
+
+// checkPrintable returns an error if the string contains a null byte or any other non-printable character
+func checkPrintable(s string) error {
+	for _, c := range s {
+		if c == 0 {
+			return fmt.Errorf("null byte")
+		}
+
+		if !unicode.IsPrint(c) {
+			return fmt.Errorf("non-printable character %q", c)
+		}
+	}
+
+	return nil
+}
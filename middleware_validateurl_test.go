@@ -0,0 +1,93 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validate URL Middleware", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+		handler  func(rw http.ResponseWriter, req *http.Request) *Response
+	)
+
+	// requestFor builds a request for a raw URL, as a client sending it on the wire would
+	requestFor := func(rawPath, rawQuery string) *http.Request {
+		request := httptest.NewRequest("GET", "/", nil)
+		request.URL = &url.URL{Path: "/", RawQuery: rawQuery}
+
+		if rawPath != "" {
+			path, err := url.PathUnescape(rawPath)
+			if err != nil {
+				// Keep the invalid escape as is, the way a raw path would be
+				path = rawPath
+			}
+			request.URL.Path, request.URL.RawPath = path, rawPath
+		}
+
+		return request
+	}
+
+	expectBadRequest := func(resp *Response) {
+		Expect(resp).ToNot(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		Expect(resp.StopExecution).To(BeTrue())
+		Expect(resp.Err).To(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		handler = NewMiddlewareValidateURL()
+	})
+
+	Describe("handle", func() {
+		Context("when the URL is valid", func() {
+			It("should return nil", func() {
+				Expect(handler(response, httptest.NewRequest("GET", "/items/caf%C3%A9?q=a%20b&page=2", nil))).To(BeNil())
+				Expect(handler(response, httptest.NewRequest("GET", "/items/a%2Fb?q=%E2%9C%93", nil))).To(BeNil())
+			})
+		})
+
+		Context("when the query has an invalid escape", func() {
+			It("should stop execution with a 400", func() {
+				expectBadRequest(handler(response, requestFor("", "q=%zz")))
+				expectBadRequest(handler(response, requestFor("", "q=100%")))
+			})
+		})
+
+		Context("when the path has an invalid escape", func() {
+			It("should stop execution with a 400", func() {
+				expectBadRequest(handler(response, requestFor("/items/%zz", "")))
+			})
+		})
+
+		Context("when the URL contains a null byte", func() {
+			It("should stop execution with a 400", func() {
+				expectBadRequest(handler(response, requestFor("/items/%00", "")))
+				expectBadRequest(handler(response, requestFor("", "q=a%00b")))
+			})
+		})
+
+		Context("when the URL contains non-printable characters", func() {
+			It("should stop execution with a 400", func() {
+				expectBadRequest(handler(response, requestFor("/items/%1B", "")))
+				expectBadRequest(handler(response, requestFor("", "q=a%7Fb")))
+				expectBadRequest(handler(response, requestFor("", "q=a\tb")))
+			})
+		})
+
+		Context("when used in a chain", func() {
+			It("should not run the rest of the chain", func() {
+				h := NewMWHandler(Config{}).Handle([]Handler{handler, textHandler})
+				h.ServeHTTP(response, requestFor("", "q=%zz"))
+
+				Expect(response.Code).To(Equal(http.StatusBadRequest))
+				Expect(response.Body.String()).To(ContainSubstring("Invalid escape in URL query"))
+			})
+		})
+	})
+})
@@ -0,0 +1,65 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WebSocket Origin Middleware", func() {
+
+	var (
+		request  *http.Request
+		response *httptest.ResponseRecorder
+		allowed  = []string{"https://app.example.com"}
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		request = &http.Request{
+			Header: make(map[string][]string, 0),
+		}
+	})
+
+	Describe("handle", func() {
+		Context("when an upgrade request comes from an allowed origin", func() {
+			It("should return nil", func() {
+				request.Header.Set("Upgrade", "websocket")
+				request.Header.Set("Origin", "https://APP.example.com")
+
+				resp := NewMiddlewareWebSocketOrigin(allowed)(response, request)
+				Expect(resp).To(BeNil())
+			})
+		})
+
+		Context("when an upgrade request comes from a disallowed origin", func() {
+			It("should return a 403", func() {
+				request.Header.Set("Upgrade", "WebSocket")
+				request.Header.Set("Origin", "https://evil.example.com")
+
+				resp := NewMiddlewareWebSocketOrigin(allowed)(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+				Expect(resp.Error()).To(ContainSubstring("https://evil.example.com"))
+			})
+
+			It("should return a 403 when the origin is missing", func() {
+				request.Header.Set("Upgrade", "websocket")
+
+				resp := NewMiddlewareWebSocketOrigin(allowed)(response, request)
+				Expect(resp).ToNot(BeNil())
+				Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+			})
+		})
+
+		Context("when the request is not an upgrade", func() {
+			It("should return nil", func() {
+				request.Header.Set("Origin", "https://evil.example.com")
+
+				resp := NewMiddlewareWebSocketOrigin(allowed)(response, request)
+				Expect(resp).To(BeNil())
+			})
+		})
+	})
+})
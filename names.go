@@ -0,0 +1,116 @@
+package rye
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// handlerMeta is the metadata (name and priority) wrappers such as NamedHandler attach to a
+// handler. Rather than being registered anywhere, it travels with the handler: the wrapped
+// handler is a method value of its handlerMeta, which hands itself back when probed (see metaOf).
+type handlerMeta struct {
+	handler  Handler
+	name     string
+	priority int
+}
+
+// metaServeCode is the code pointer shared by every handler returned by describe
+var metaServeCode = reflect.ValueOf((&handlerMeta{}).serve).Pointer()
+
+// metaProbe is the ResponseWriter metaOf calls described handlers with to get their metadata
+type metaProbe struct {
+	http.ResponseWriter
+	meta *handlerMeta
+}
+
+func (hm *handlerMeta) serve(rw http.ResponseWriter, r *http.Request) *Response {
+	if probe, ok := rw.(*metaProbe); ok {
+		probe.meta = hm
+		return nil
+	}
+
+	return hm.handler(rw, r)
+}
+
+// describe attaches the metadata to the handler
+func describe(handler Handler, meta handlerMeta) Handler {
+	meta.handler = handler
+	return meta.serve
+}
+
+// describeAs attaches the metadata of another handler (ie. the one it wraps) to the handler,
+// so that wrappers keep recording stats under the name (and the priority) of what they wrap
+func describeAs(wrapped, h Handler) Handler {
+	meta := handlerMeta{name: handlerName(h)}
+	if inner := metaOf(h); inner != nil {
+		meta.priority = inner.priority
+	}
+
+	return describe(wrapped, meta)
+}
+
+// metaOf returns the metadata attached to the handler (see describe), or nil for bare handlers;
+// only described handlers are ever probed, bare ones are told apart by their code pointer
+func metaOf(h Handler) *handlerMeta {
+	if h == nil || reflect.ValueOf(h).Pointer() != metaServeCode {
+		return nil
+	}
+
+	probe := &metaProbe{}
+	h(probe, nil)
+
+	return probe.meta
+}
+
+/*
+NamedHandler wraps a handler with an explicit name, used verbatim in its stats (ie. `handlers.<name>.2xx`)
+instead of the name of its Go function. This gives closures and anonymous middleware, which would otherwise
+be recorded as `func1`, a meaningful name. Named and bare handlers can be mixed in a chain.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NamedHandler("auth", func(rw http.ResponseWriter, r *http.Request) *rye.Response {
+				...
+			}),
+			yourHandler,
+		})).Methods("GET")
+*/
+func NamedHandler(name string, handler Handler) Handler {
+	meta := handlerMeta{name: name}
+	if inner := metaOf(handler); inner != nil {
+		meta.priority = inner.priority
+	}
+
+	return describe(handler, meta)
+}
+
+// handlerName returns the name given to the handler by NamedHandler (or kept by a wrapper)
+// or, for bare handlers, the name of its function (see getFuncName)
+func handlerName(h Handler) string {
+	if meta := metaOf(h); meta != nil {
+		return meta.name
+	}
+
+	return getFuncName(h)
+}
+
+// chainHandler is a handler of a chain along with its name, resolved when the chain is built
+type chainHandler struct {
+	handler Handler
+	name    string
+}
+
+// resolveNames resolves the names of the before and after handlers of a chain (see handlerName)
+func resolveNames(handlers, after []Handler) ([]chainHandler, []chainHandler) {
+	resolve := func(handlers []Handler) []chainHandler {
+		chain := make([]chainHandler, len(handlers))
+		for i, h := range handlers {
+			chain[i] = chainHandler{handler: h, name: handlerName(h)}
+		}
+		return chain
+	}
+
+	return resolve(handlers), resolve(after)
+}
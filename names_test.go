@@ -0,0 +1,127 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/InVisionApp/rye/fakes/statsdfakes"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NamedHandler", func() {
+
+	var (
+		mwHandler *MWHandler
+		incs      chan string
+		names     []string
+	)
+
+	collect := func() []string {
+		for {
+			select {
+			case name := <-incs:
+				names = append(names, name)
+			default:
+				return names
+			}
+		}
+	}
+
+	serve := func(handlers []Handler) {
+		mwHandler.Handle(handlers).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+
+	BeforeEach(func() {
+		incs = make(chan string, 10)
+		names = nil
+
+		fakeStatter := &statsdfakes.FakeStatter{}
+		fakeStatter.IncStub = func(name string, value int64, rate float32) error {
+			if strings.HasSuffix(name, ".2xx") {
+				incs <- name
+			}
+			return nil
+		}
+		fakeStatter.TimingDurationStub = func(name string, d time.Duration, rate float32) error {
+			return nil
+		}
+
+		mwHandler = NewMWHandler(Config{Statter: fakeStatter, StatRate: 1})
+	})
+
+	It("should record stats under the explicit name", func() {
+		serve([]Handler{
+			NamedHandler("auth", func(rw http.ResponseWriter, r *http.Request) *Response {
+				return nil
+			}),
+			successHandler,
+		})
+
+		Eventually(collect).Should(ConsistOf("handlers.auth.2xx", "handlers.successHandler.2xx"))
+	})
+
+	It("should keep the explicit name when marked as middleware", func() {
+		serve([]Handler{AsMiddleware(NamedHandler("auth", successHandler))})
+
+		Eventually(collect).Should(ConsistOf("middleware.auth.2xx"))
+	})
+
+	It("should keep the name of prioritized handlers", func() {
+		serve([]Handler{PrioritizedHandler(-1, successHandler), PrioritizedHandler(1, NamedHandler("auth", successHandler))})
+
+		Eventually(collect).Should(ConsistOf("handlers.successHandler.2xx", "handlers.auth.2xx"))
+	})
+
+	It("should fall back to the function name of bare handlers", func() {
+		Expect(handlerName(NamedHandler("entry", successHandler))).To(Equal("entry"))
+		Expect(handlerName(successHandler)).To(Equal("successHandler"))
+	})
+
+	Describe("metaOf", func() {
+		It("should not call bare handlers", func() {
+			called := false
+			bare := func(rw http.ResponseWriter, r *http.Request) *Response {
+				called = true
+				return nil
+			}
+
+			Expect(metaOf(bare)).To(BeNil())
+			Expect(metaOf(nil)).To(BeNil())
+			Expect(called).To(BeFalse())
+		})
+
+		It("should return the metadata carried by the handler", func() {
+			meta := metaOf(NamedHandler("auth", PrioritizedHandler(-1, successHandler)))
+
+			Expect(meta).ToNot(BeNil())
+			Expect(meta.name).To(Equal("auth"))
+			Expect(meta.priority).To(Equal(-1))
+		})
+
+		It("should be kept by wrappers", func() {
+			wrapped := When(OnMethods("POST"), PrioritizedHandler(-1, NamedHandler("auth", successHandler)))
+
+			Expect(handlerName(wrapped)).To(Equal("auth"))
+			Expect(handlerPriority(wrapped)).To(Equal(-1))
+		})
+	})
+
+	Describe("resolveNames", func() {
+		It("should cache the names the handlers would be resolved to", func() {
+			closure := func(rw http.ResponseWriter, r *http.Request) *Response { return nil }
+			named := NamedHandler("auth", closure)
+
+			chain, after := resolveNames([]Handler{successHandler, named, closure}, []Handler{failureHandler})
+
+			Expect(chain).To(HaveLen(3))
+			Expect(chain[0].name).To(Equal(getFuncName(successHandler)))
+			Expect(chain[1].name).To(Equal("auth"))
+			Expect(chain[2].name).To(Equal(getFuncName(closure)))
+
+			Expect(after).To(HaveLen(1))
+			Expect(after[0].name).To(Equal(getFuncName(failureHandler)))
+		})
+	})
+})
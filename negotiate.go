@@ -0,0 +1,208 @@
+package rye
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// Media type Negotiate falls back to when the client accepts none of the registered ones
+	DEFAULT_NEGOTIATED_MEDIA_TYPE = "application/json"
+
+	// Content types of the errors written by rye (see NegotiateErrorContentType)
+	ERROR_CONTENT_TYPE_JSON = "application/json"
+	ERROR_CONTENT_TYPE_TEXT = "text/plain; charset=utf-8"
+)
+
+// ResponseEncoder encodes a value into a response body (see RegisterEncoder)
+type ResponseEncoder func(v interface{}) ([]byte, error)
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]ResponseEncoder{
+		"application/json": json.Marshal,
+		"application/xml":  encodeXML,
+		"text/xml":         encodeXML,
+	}
+)
+
+/*
+RegisterEncoder registers the encoder Negotiate uses for the media type, replacing any encoder already registered
+for it. JSON (`application/json`) and XML (`application/xml`, `text/xml`) are registered out of the box; other
+formats (ie. msgpack or protobuf) are registered by the application, so that rye doesn't depend on their libraries.
+
+Example usage:
+
+	rye.RegisterEncoder("application/x-protobuf", func(v interface{}) ([]byte, error) {
+		return proto.Marshal(v.(proto.Message))
+	})
+*/
+func RegisterEncoder(mediaType string, enc ResponseEncoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+
+	encoders[strings.ToLower(mediaType)] = enc
+}
+
+/*
+Negotiate returns a *Response that writes `v` with a 200, stopping the chain, encoded in the format the client
+prefers among those registered (see RegisterEncoder), based on the `Accept` header of the request. When the
+client accepts none of them, `v` is encoded as JSON. If `v` cannot be encoded, an error *Response with a 500 is
+returned instead.
+
+Example usage:
+
+	func invoiceHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		return rye.Negotiate(r, &Invoice{ID: 1})
+	}
+*/
+func Negotiate(r *http.Request, v interface{}) *Response {
+	mediaType, enc := negotiateEncoder(strings.Join(r.Header.Values("Accept"), ","))
+
+	data, err := enc(v)
+	if err != nil {
+		return &Response{
+			Err:        fmt.Errorf("Unable to encode %s response: %v", mediaType, err),
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+
+	return &Response{
+		StatusCode:    http.StatusOK,
+		StopExecution: true,
+		StatusContent: string(data),
+		ContentType:   mediaType,
+		Headers: http.Header{
+			"Vary": []string{"Accept"},
+		},
+	}
+}
+
+/*
+NegotiateErrorContentType returns the content type rye writes errors in (unless Config.ErrorRenderer is set), based
+on the `Accept` header of the request: ERROR_CONTENT_TYPE_JSON when the client accepts JSON (or sends no `Accept`
+header), or ERROR_CONTENT_TYPE_TEXT when it prefers plain text or doesn't accept JSON at all. The status code and
+stats of the error are the same either way.
+*/
+func NegotiateErrorContentType(r *http.Request) string {
+	accept := strings.Join(r.Header.Values("Accept"), ",")
+	if strings.TrimSpace(accept) == "" {
+		return ERROR_CONTENT_TYPE_JSON
+	}
+
+	for _, mediaRange := range parseAccept(accept) {
+		switch mediaRange {
+		case "application/json", "application/*", "*/*":
+			return ERROR_CONTENT_TYPE_JSON
+		case "text/plain", "text/*":
+			return ERROR_CONTENT_TYPE_TEXT
+		}
+	}
+
+	return ERROR_CONTENT_TYPE_TEXT
+}
+
+// negotiateEncoder picks the registered encoder the Accept header prefers, falling back to JSON
+func negotiateEncoder(accept string) (string, ResponseEncoder) {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+
+	for _, mediaRange := range parseAccept(accept) {
+		if enc, ok := encoders[mediaRange]; ok {
+			return mediaRange, enc
+		}
+
+		// Wildcards (`*/*`, `application/*`) prefer the default media
+		// type, then the registered ones in alphabetical order
+		if !strings.HasSuffix(mediaRange, "/*") {
+			continue
+		}
+
+		prefix := strings.TrimSuffix(mediaRange, "*")
+		if prefix == "*/" {
+			prefix = ""
+		}
+
+		if strings.HasPrefix(DEFAULT_NEGOTIATED_MEDIA_TYPE, prefix) {
+			return DEFAULT_NEGOTIATED_MEDIA_TYPE, encoders[DEFAULT_NEGOTIATED_MEDIA_TYPE]
+		}
+
+		var mediaTypes []string
+		for mediaType := range encoders {
+			if strings.HasPrefix(mediaType, prefix) {
+				mediaTypes = append(mediaTypes, mediaType)
+			}
+		}
+
+		if len(mediaTypes) > 0 {
+			sort.Strings(mediaTypes)
+			return mediaTypes[0], encoders[mediaTypes[0]]
+		}
+	}
+
+	return DEFAULT_NEGOTIATED_MEDIA_TYPE, encoders[DEFAULT_NEGOTIATED_MEDIA_TYPE]
+}
+
+// parseAccept returns the media ranges of an Accept header, lower cased and ordered by decreasing
+// quality (ranges of the same quality keep their order); ranges ruled out (a quality of 0) are left out
+func parseAccept(header string) []string {
+	type mediaRange struct {
+		name string
+		q    float64
+	}
+
+	var ranges []mediaRange
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if len(param) < 2 || !strings.EqualFold(param[:2], "q=") {
+				continue
+			}
+
+			var err error
+			if q, err = strconv.ParseFloat(param[2:], 64); err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+		}
+
+		if q > 0 {
+			ranges = append(ranges, mediaRange{name: name, q: q})
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	names := make([]string, len(ranges))
+	for i, r := range ranges {
+		names[i] = r.name
+	}
+
+	return names
+}
+
+// encodeXML encodes a value as an XML document (see XML)
+func encodeXML(v interface{}) ([]byte, error) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
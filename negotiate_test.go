@@ -0,0 +1,162 @@
+package rye
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+type negotiatedInvoice struct {
+	XMLName xml.Name `json:"-" xml:"invoice"`
+	ID      int      `json:"id" xml:"id"`
+}
+
+var _ = Describe("Negotiate", func() {
+
+	var (
+		request  *http.Request
+		response *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("GET", "/invoices/1", nil)
+		response = httptest.NewRecorder()
+	})
+
+	serve := func(v interface{}) {
+		NewMWHandler(Config{}).Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+			return Negotiate(r, v)
+		}}).ServeHTTP(response, request)
+	}
+
+	It("should encode JSON when the client asks for it", func() {
+		request.Header.Set("Accept", "application/json")
+		serve(&negotiatedInvoice{ID: 1})
+
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(response.Header().Get("Vary")).To(Equal("Accept"))
+		Expect(response.Body.String()).To(MatchJSON(`{"id":1}`))
+	})
+
+	It("should encode XML when the client prefers it", func() {
+		request.Header.Set("Accept", "application/json;q=0.5, application/xml")
+		serve(&negotiatedInvoice{ID: 1})
+
+		Expect(response.Header().Get("Content-Type")).To(Equal("application/xml"))
+		Expect(response.Body.String()).To(Equal(xml.Header + "<invoice><id>1</id></invoice>"))
+	})
+
+	It("should fall back to JSON when the client accepts no registered format", func() {
+		request.Header.Set("Accept", "text/csv")
+		serve(&negotiatedInvoice{ID: 1})
+
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(response.Body.String()).To(MatchJSON(`{"id":1}`))
+	})
+
+	It("should prefer JSON for wildcards", func() {
+		request.Header.Set("Accept", "text/html;q=0.9, */*;q=0.8")
+		serve(&negotiatedInvoice{ID: 1})
+
+		Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+	})
+
+	It("should match wildcards of a type against the registered media types", func() {
+		request.Header.Set("Accept", "text/*")
+		serve(&negotiatedInvoice{ID: 1})
+
+		Expect(response.Header().Get("Content-Type")).To(Equal("text/xml"))
+	})
+
+	It("should use registered encoders", func() {
+		RegisterEncoder("application/x-test", func(v interface{}) ([]byte, error) {
+			return []byte("test encoding"), nil
+		})
+
+		request.Header.Set("Accept", "application/x-test")
+		serve(&negotiatedInvoice{ID: 1})
+
+		Expect(response.Header().Get("Content-Type")).To(Equal("application/x-test"))
+		Expect(response.Body.String()).To(Equal("test encoding"))
+	})
+
+	It("should return a 500 when the value cannot be encoded", func() {
+		RegisterEncoder("application/x-failing", func(v interface{}) ([]byte, error) {
+			return nil, errors.New("unsupported value")
+		})
+
+		request.Header.Set("Accept", "application/x-failing")
+		serve(&negotiatedInvoice{ID: 1})
+
+		Expect(response.Code).To(Equal(http.StatusInternalServerError))
+		Expect(response.Body.String()).To(ContainSubstring("Unable to encode application/x-failing response: unsupported value"))
+	})
+})
+
+var _ = Describe("NegotiateErrorContentType", func() {
+
+	var request *http.Request
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("GET", "/", nil)
+	})
+
+	It("should pick JSON without an Accept header", func() {
+		Expect(NegotiateErrorContentType(request)).To(Equal(ERROR_CONTENT_TYPE_JSON))
+	})
+
+	It("should pick JSON when the client accepts it", func() {
+		for _, accept := range []string{"application/json", "text/html, */*;q=0.8", "text/plain;q=0.5, application/json"} {
+			request.Header.Set("Accept", accept)
+			Expect(NegotiateErrorContentType(request)).To(Equal(ERROR_CONTENT_TYPE_JSON), accept)
+		}
+	})
+
+	It("should pick plain text when the client prefers it or does not accept JSON", func() {
+		for _, accept := range []string{"text/plain", "text/plain, application/json;q=0.5", "text/html", "application/json;q=0"} {
+			request.Header.Set("Accept", accept)
+			Expect(NegotiateErrorContentType(request)).To(Equal(ERROR_CONTENT_TYPE_TEXT), accept)
+		}
+	})
+
+	Context("when a handler returns an error", func() {
+		var (
+			response *httptest.ResponseRecorder
+			reporter *recordingReporter
+		)
+
+		BeforeEach(func() {
+			response = httptest.NewRecorder()
+			reporter = &recordingReporter{}
+		})
+
+		serve := func() {
+			NewMWHandler(Config{Reporter: reporter}).Handle([]Handler{failureHandler}).ServeHTTP(response, request)
+		}
+
+		It("should write it as JSON to clients accepting JSON", func() {
+			request.Header.Set("Accept", "application/json")
+			serve()
+
+			Expect(response.Code).To(Equal(505))
+			Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(response.Body.String()).To(MatchJSON(`{"status":"error","message":"Foo"}`))
+			Eventually(reporter.recordedIncs).Should(ContainElements("errors", "handlers.failureHandler.505"))
+		})
+
+		It("should write it as plain text to clients preferring it", func() {
+			request.Header.Set("Accept", "text/plain")
+			serve()
+
+			Expect(response.Code).To(Equal(505))
+			Expect(response.Header().Get("Content-Type")).To(Equal(ERROR_CONTENT_TYPE_TEXT))
+			Expect(response.Body.String()).To(Equal("Foo"))
+			Eventually(reporter.recordedIncs).Should(ContainElements("errors", "handlers.failureHandler.505"))
+		})
+	})
+})
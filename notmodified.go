@@ -0,0 +1,70 @@
+package rye
+
+import (
+	"net/http"
+	"strings"
+)
+
+/*
+NotModifiedIf compares a caller computed version (ie. a DB row version or a content hash)
+against the request's `If-None-Match` header. When they match, it returns a *Response with
+a 304 and StopExecution set; otherwise it returns nil and the handler should carry on
+(and set the `ETag` header itself).
+
+Conditional requests are reported as `cache.hit` or `cache.miss` (see RecordCacheResult).
+
+Example usage:
+
+	func itemHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		item := loadItem(r)
+
+		if resp := rye.NotModifiedIf(r, item.Version); resp != nil {
+			return resp
+		}
+
+		rw.Header().Set("ETag", `"`+item.Version+`"`)
+		...
+	}
+*/
+func NotModifiedIf(r *http.Request, version string) *Response {
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == "" {
+		return nil
+	}
+
+	if !etagMatches(ifNoneMatch, version) {
+		RecordCacheResult(r, false)
+		return nil
+	}
+
+	RecordCacheResult(r, true)
+
+	return &Response{
+		StatusCode:    http.StatusNotModified,
+		StopExecution: true,
+	}
+}
+
+// etagMatches reports whether an `If-None-Match` header value matches the given etag.
+// Both weak (W/"...") and unquoted forms are compared on their opaque value.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+
+	etag = normalizeETag(etag)
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || normalizeETag(candidate) == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func normalizeETag(etag string) string {
+	return strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+}
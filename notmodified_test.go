@@ -0,0 +1,103 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/InVisionApp/rye/fakes/statsdfakes"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NotModifiedIf", func() {
+
+	var (
+		request *http.Request
+	)
+
+	BeforeEach(func() {
+		request = &http.Request{
+			Header: make(map[string][]string, 0),
+		}
+	})
+
+	Context("when the version matches If-None-Match", func() {
+		It("should return a 304 response that stops execution", func() {
+			request.Header.Set("If-None-Match", `"v5"`)
+
+			resp := NotModifiedIf(request, "v5")
+			Expect(resp).ToNot(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusNotModified))
+			Expect(resp.StopExecution).To(BeTrue())
+		})
+
+		It("should match weak etags within a list", func() {
+			request.Header.Set("If-None-Match", `"v4", W/"v5"`)
+
+			resp := NotModifiedIf(request, `"v5"`)
+			Expect(resp).ToNot(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusNotModified))
+		})
+	})
+
+	Context("when the version does not match", func() {
+		It("should return nil", func() {
+			request.Header.Set("If-None-Match", `"v4"`)
+
+			resp := NotModifiedIf(request, "v5")
+			Expect(resp).To(BeNil())
+		})
+	})
+
+	Context("when If-None-Match is not set", func() {
+		It("should return nil", func() {
+			resp := NotModifiedIf(request, "v5")
+			Expect(resp).To(BeNil())
+		})
+	})
+
+	Context("when running in a chain with a statter", func() {
+		var (
+			incs      chan string
+			mwHandler *MWHandler
+		)
+
+		BeforeEach(func() {
+			// Stats are sent asynchronously and may outlive the spec, so the
+			// stub holds on to this spec's channel rather than the shared one
+			specIncs := make(chan string, 10)
+			incs = specIncs
+
+			fakeStatter := &statsdfakes.FakeStatter{}
+			fakeStatter.IncStub = func(name string, value int64, rate float32) error {
+				specIncs <- name
+				return nil
+			}
+
+			mwHandler = NewMWHandler(Config{Statter: fakeStatter, StatRate: 1})
+		})
+
+		serve := func(ifNoneMatch string) int {
+			response := httptest.NewRecorder()
+			request = httptest.NewRequest("GET", "/", nil)
+			request.Header.Set("If-None-Match", ifNoneMatch)
+
+			h := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+				return NotModifiedIf(r, "v5")
+			}})
+			h.ServeHTTP(response, request)
+
+			return response.Code
+		}
+
+		It("should count a matching version as a cache hit", func() {
+			Expect(serve(`"v5"`)).To(Equal(http.StatusNotModified))
+			Eventually(incs).Should(Receive(Equal("cache.hit")))
+		})
+
+		It("should count a mismatching version as a cache miss", func() {
+			serve(`"v4"`)
+			Eventually(incs).Should(Receive(Equal("cache.miss")))
+		})
+	})
+})
@@ -0,0 +1,79 @@
+package rye
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Outcome describes how a handler call ended as far as stats are concerned.
+type Outcome int
+
+const (
+	OUTCOME_SUCCESS Outcome = iota
+	OUTCOME_CLIENT_ERROR
+	OUTCOME_SERVER_ERROR
+	OUTCOME_STOPPED
+)
+
+// String returns a readable name for the outcome
+func (o Outcome) String() string {
+	switch o {
+	case OUTCOME_SUCCESS:
+		return "success"
+	case OUTCOME_CLIENT_ERROR:
+		return "client_error"
+	case OUTCOME_SERVER_ERROR:
+		return "server_error"
+	case OUTCOME_STOPPED:
+		return "stopped"
+	}
+
+	return "unknown"
+}
+
+// DefaultOutcomeClassifier is the classifier used when Config.OutcomeClassifier is not set:
+// a Response carrying an error is a server error when the final status is 5xx and a client
+// error when it is 4xx, even if it also stops execution; any other stopping Response (an
+// error written with a non-error status included) is stopped and anything else is a success.
+func DefaultOutcomeClassifier(resp *Response, finalStatus int) Outcome {
+	switch {
+	case resp == nil:
+		return OUTCOME_SUCCESS
+	case resp.Err != nil && StatusClass(finalStatus) == "5xx":
+		return OUTCOME_SERVER_ERROR
+	case resp.Err != nil && StatusClass(finalStatus) == "4xx":
+		return OUTCOME_CLIENT_ERROR
+	case resp.Err != nil, resp.StopExecution:
+		return OUTCOME_STOPPED
+	}
+
+	return OUTCOME_SUCCESS
+}
+
+// classify runs the configured (or default) outcome classifier for a handler's Response,
+// passing it the status actually written: errors without a status code are written as a 500
+func (m *MWHandler) classify(resp *Response) Outcome {
+	finalStatus := http.StatusOK
+	switch {
+	case resp != nil && resp.StatusCode != 0:
+		finalStatus = resp.StatusCode
+	case resp != nil && resp.Err != nil:
+		finalStatus = http.StatusInternalServerError
+	}
+
+	if m.Config.OutcomeClassifier != nil {
+		return m.Config.OutcomeClassifier(resp, finalStatus)
+	}
+
+	return DefaultOutcomeClassifier(resp, finalStatus)
+}
+
+// StatusClass returns the class of an HTTP status code (ie. `4xx` for a 404), or an
+// empty string if it is not a valid status code
+func StatusClass(statusCode int) string {
+	if statusCode < 100 || statusCode > 599 {
+		return ""
+	}
+
+	return strconv.Itoa(statusCode/100) + "xx"
+}
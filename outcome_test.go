@@ -0,0 +1,95 @@
+package rye
+
+import (
+	"errors"
+	"net/http"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Outcome", func() {
+
+	Describe("DefaultOutcomeClassifier", func() {
+		It("should classify a nil response as a success", func() {
+			Expect(DefaultOutcomeClassifier(nil, http.StatusOK)).To(Equal(OUTCOME_SUCCESS))
+		})
+
+		It("should classify a context response as a success", func() {
+			Expect(DefaultOutcomeClassifier(&Response{}, http.StatusOK)).To(Equal(OUTCOME_SUCCESS))
+		})
+
+		It("should classify a stopping response as stopped", func() {
+			resp := &Response{StopExecution: true}
+			Expect(DefaultOutcomeClassifier(resp, http.StatusOK)).To(Equal(OUTCOME_STOPPED))
+		})
+
+		It("should classify a stopping response carrying an error as an error", func() {
+			resp := &Response{Err: errors.New("boom"), StatusCode: http.StatusInternalServerError, StopExecution: true}
+			Expect(DefaultOutcomeClassifier(resp, http.StatusInternalServerError)).To(Equal(OUTCOME_SERVER_ERROR))
+		})
+
+		It("should classify 4xx errors as client errors", func() {
+			resp := &Response{Err: errors.New("bad"), StatusCode: http.StatusBadRequest}
+			Expect(DefaultOutcomeClassifier(resp, http.StatusBadRequest)).To(Equal(OUTCOME_CLIENT_ERROR))
+		})
+
+		It("should classify 5xx errors as server errors", func() {
+			resp := &Response{Err: errors.New("boom"), StatusCode: http.StatusBadGateway}
+			Expect(DefaultOutcomeClassifier(resp, http.StatusBadGateway)).To(Equal(OUTCOME_SERVER_ERROR))
+		})
+
+		It("should classify errors written with a non-error status as stopped", func() {
+			resp := &Response{Err: errors.New("moved"), StatusCode: http.StatusFound}
+			Expect(DefaultOutcomeClassifier(resp, http.StatusFound)).To(Equal(OUTCOME_STOPPED))
+		})
+	})
+
+	Describe("classify", func() {
+		It("should classify an error without a status code as the 500 it is written as", func() {
+			var status int
+			mwHandler := NewMWHandler(Config{OutcomeClassifier: func(resp *Response, finalStatus int) Outcome {
+				status = finalStatus
+				return DefaultOutcomeClassifier(resp, finalStatus)
+			}})
+
+			Expect(mwHandler.classify(&Response{Err: errors.New("no status")})).To(Equal(OUTCOME_SERVER_ERROR))
+			Expect(status).To(Equal(http.StatusInternalServerError))
+		})
+
+		It("should classify a response without an error or status code as a 200", func() {
+			var status int
+			mwHandler := NewMWHandler(Config{OutcomeClassifier: func(resp *Response, finalStatus int) Outcome {
+				status = finalStatus
+				return DefaultOutcomeClassifier(resp, finalStatus)
+			}})
+
+			Expect(mwHandler.classify(&Response{StopExecution: true})).To(Equal(OUTCOME_STOPPED))
+			Expect(status).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("StatusClass", func() {
+		It("should return the class of valid status codes", func() {
+			Expect(StatusClass(http.StatusOK)).To(Equal("2xx"))
+			Expect(StatusClass(http.StatusNotModified)).To(Equal("3xx"))
+			Expect(StatusClass(http.StatusBadRequest)).To(Equal("4xx"))
+			Expect(StatusClass(499)).To(Equal("4xx"))
+			Expect(StatusClass(505)).To(Equal("5xx"))
+		})
+
+		It("should return an empty string for invalid status codes", func() {
+			Expect(StatusClass(0)).To(BeEmpty())
+			Expect(StatusClass(99)).To(BeEmpty())
+			Expect(StatusClass(600)).To(BeEmpty())
+		})
+	})
+
+	Describe("String", func() {
+		It("should return readable names", func() {
+			Expect(OUTCOME_SUCCESS.String()).To(Equal("success"))
+			Expect(OUTCOME_CLIENT_ERROR.String()).To(Equal("client_error"))
+			Expect(OUTCOME_SERVER_ERROR.String()).To(Equal("server_error"))
+			Expect(OUTCOME_STOPPED.String()).To(Equal("stopped"))
+		})
+	})
+})
@@ -0,0 +1,89 @@
+package rye
+
+import (
+	"net/http"
+)
+
+// MiddlewarePair bundles the two steps of a concern that wraps the rest of the chain (ie. opening and
+// closing a transaction); see MWHandler.HandlePairs. Either step may be nil.
+type MiddlewarePair struct {
+	Before Handler
+	After  Handler
+}
+
+/*
+HandlePairs works like Handle, but wraps the handlers in middleware pairs: the Before steps run in order, then
+the handlers, then the After steps in reverse order, so that the first pair entered is the last one left. After
+steps run however the chain ended; use FinalResponse to find out how.
+
+A pair is entered once its Before step went through: when a Before step stops the chain (or returns an error),
+the handlers and the remaining pairs are skipped, and only the After steps of the pairs entered before it run.
+Skipped steps record no stats.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.HandlePairs(
+		[]rye.MiddlewarePair{
+			{Before: beginTransaction, After: commitOrRollback},
+		},
+		[]rye.Handler{
+			yourHandler,
+		})).Methods("POST")
+*/
+func (m *MWHandler) HandlePairs(pairs []MiddlewarePair, handlers []Handler) http.Handler {
+	var before, after []Handler
+
+	for i, pair := range pairs {
+		before = append(before, pairBefore(i, pair.Before))
+	}
+	before = append(before, handlers...)
+
+	for i := len(pairs) - 1; i >= 0; i-- {
+		if pairs[i].After != nil {
+			after = append(after, pairAfter(i, pairs[i].After))
+		}
+	}
+
+	return m.handle(before, after, m.Config.StatPrefix)
+}
+
+// pairBefore wraps the Before step of the i-th pair to record the pair as entered once it went through;
+// a missing Before step enters the pair as soon as the chain reaches it
+func pairBefore(i int, h Handler) Handler {
+	if h == nil {
+		return func(rw http.ResponseWriter, r *http.Request) *Response {
+			if c := chainFromRequest(r); c != nil {
+				c.pairsEntered = i + 1
+				c.skipped = true
+			}
+			return nil
+		}
+	}
+
+	wrapped := Handler(func(rw http.ResponseWriter, r *http.Request) *Response {
+		resp := h(rw, r)
+
+		if c := chainFromRequest(r); c != nil && (resp == nil || (!resp.StopExecution && resp.Err == nil)) {
+			c.pairsEntered = i + 1
+		}
+
+		return resp
+	})
+
+	// Keep recording stats under the name of the wrapped handler
+	return describeAs(wrapped, h)
+}
+
+// pairAfter wraps the After step of the i-th pair to skip it unless the pair was entered
+func pairAfter(i int, h Handler) Handler {
+	wrapped := Handler(func(rw http.ResponseWriter, r *http.Request) *Response {
+		if c := chainFromRequest(r); c != nil && c.pairsEntered <= i {
+			c.skipped = true
+			return nil
+		}
+
+		return h(rw, r)
+	})
+
+	return describeAs(wrapped, h)
+}
@@ -0,0 +1,100 @@
+package rye
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HandlePairs", func() {
+
+	var (
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+		reporter  *recordingReporter
+		steps     []string
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		reporter = &recordingReporter{}
+		mwHandler = NewMWHandler(Config{Reporter: reporter})
+		steps = nil
+	})
+
+	step := func(name string, resp *Response) Handler {
+		return NamedHandler(name, func(rw http.ResponseWriter, r *http.Request) *Response {
+			steps = append(steps, name)
+			return resp
+		})
+	}
+
+	// transaction opens a transaction before the chain and commits or rolls it back after
+	transaction := func(name string) MiddlewarePair {
+		return MiddlewarePair{
+			Before: step("begin."+name, nil),
+			After: NamedHandler("end."+name, func(rw http.ResponseWriter, r *http.Request) *Response {
+				if resp := FinalResponse(r); resp != nil && resp.Err != nil {
+					steps = append(steps, "rollback."+name)
+				} else {
+					steps = append(steps, "commit."+name)
+				}
+				return nil
+			}),
+		}
+	}
+
+	serve := func(pairs []MiddlewarePair, handlers ...Handler) {
+		mwHandler.HandlePairs(pairs, handlers).ServeHTTP(response, httptest.NewRequest("POST", "/orders", nil))
+	}
+
+	It("should run the Before steps in order and the After steps in reverse order", func() {
+		serve([]MiddlewarePair{transaction("outer"), transaction("inner")}, step("handler", nil))
+
+		Expect(steps).To(Equal([]string{"begin.outer", "begin.inner", "handler", "commit.inner", "commit.outer"}))
+	})
+
+	It("should run the After steps with the final response when a handler fails", func() {
+		serve([]MiddlewarePair{transaction("outer"), transaction("inner")},
+			step("handler", &Response{Err: errors.New("boom"), StatusCode: http.StatusInternalServerError}),
+			step("skipped", nil),
+		)
+
+		Expect(steps).To(Equal([]string{"begin.outer", "begin.inner", "handler", "rollback.inner", "rollback.outer"}))
+		Expect(response.Code).To(Equal(http.StatusInternalServerError))
+	})
+
+	It("should only unwind the pairs entered when a Before step stops the chain", func() {
+		serve([]MiddlewarePair{
+			transaction("outer"),
+			{Before: step("deny", &Response{StatusCode: http.StatusForbidden, StopExecution: true}), After: step("after.deny", nil)},
+			transaction("inner"),
+		}, step("handler", nil))
+
+		Expect(steps).To(Equal([]string{"begin.outer", "deny", "commit.outer"}))
+		Expect(response.Code).To(Equal(http.StatusForbidden))
+
+		Consistently(reporter.recordedIncs).ShouldNot(ContainElement(ContainSubstring("after.deny")))
+	})
+
+	It("should accept pairs with a single step", func() {
+		serve([]MiddlewarePair{
+			{After: step("after.only", nil)},
+			{Before: step("before.only", nil)},
+		}, step("handler", nil))
+
+		Expect(steps).To(Equal([]string{"before.only", "handler", "after.only"}))
+	})
+
+	It("should record stats under the names of the steps", func() {
+		serve([]MiddlewarePair{transaction("tx")}, step("handler", nil))
+
+		Eventually(reporter.recordedIncs).Should(ContainElements(
+			"handlers.begin.tx.2xx",
+			"handlers.handler.2xx",
+			"handlers.end.tx.2xx",
+		))
+	})
+})
@@ -0,0 +1,46 @@
+package rye
+
+import (
+	"sort"
+)
+
+/*
+PrioritizedHandler wraps a handler with a priority used by SortChain to order chains assembled from many
+sources (ie. plugins). Handlers with a lower priority run first; handlers that aren't wrapped have a priority of 0.
+
+Example usage:
+
+	handlers := []rye.Handler{
+		yourHandler,
+		rye.PrioritizedHandler(-100, rye.NewMiddlewareCORS(origin, methods, headers)),
+		rye.PrioritizedHandler(-50, rye.NewMiddlewareJWT(secret)),
+	}
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(rye.SortChain(handlers))).Methods("GET")
+*/
+func PrioritizedHandler(priority int, handler Handler) Handler {
+	// Keep recording stats under the name of the wrapped handler
+	return describe(handler, handlerMeta{name: handlerName(handler), priority: priority})
+}
+
+// SortChain returns a copy of the handlers ordered by priority (see PrioritizedHandler).
+// The sort is stable: handlers of equal priority, ie. those without one, keep their order.
+func SortChain(handlers []Handler) []Handler {
+	sorted := make([]Handler, len(handlers))
+	copy(sorted, handlers)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return handlerPriority(sorted[i]) < handlerPriority(sorted[j])
+	})
+
+	return sorted
+}
+
+// handlerPriority returns the priority given to the handler by PrioritizedHandler (or 0)
+func handlerPriority(h Handler) int {
+	if meta := metaOf(h); meta != nil {
+		return meta.priority
+	}
+
+	return 0
+}
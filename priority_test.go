@@ -0,0 +1,63 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SortChain", func() {
+
+	var (
+		order []string
+	)
+
+	named := func(name string) Handler {
+		return func(rw http.ResponseWriter, r *http.Request) *Response {
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	run := func(handlers []Handler) []string {
+		order = nil
+		NewMWHandler(Config{}).Handle(handlers).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		return order
+	}
+
+	It("should order mixed-priority handlers deterministically", func() {
+		handlers := []Handler{
+			named("a"),
+			PrioritizedHandler(10, named("late")),
+			named("b"),
+			PrioritizedHandler(-10, named("first")),
+			PrioritizedHandler(-5, named("second")),
+			PrioritizedHandler(10, named("later")),
+		}
+
+		Expect(run(SortChain(handlers))).To(Equal([]string{"first", "second", "a", "b", "late", "later"}))
+	})
+
+	It("should keep the insertion order of handlers without a priority", func() {
+		handlers := []Handler{named("a"), named("b"), named("c")}
+
+		Expect(run(SortChain(handlers))).To(Equal([]string{"a", "b", "c"}))
+	})
+
+	It("should not modify the given chain", func() {
+		handlers := []Handler{named("a"), PrioritizedHandler(-1, named("b"))}
+		SortChain(handlers)
+
+		Expect(run(handlers)).To(Equal([]string{"a", "b"}))
+	})
+
+	It("should tell apart handlers created from the same function", func() {
+		low := PrioritizedHandler(-1, named("low"))
+		high := PrioritizedHandler(1, named("high"))
+
+		Expect(handlerPriority(low)).To(Equal(-1))
+		Expect(handlerPriority(high)).To(Equal(1))
+		Expect(handlerPriority(named("none"))).To(Equal(0))
+	})
+})
@@ -0,0 +1,35 @@
+package rye
+
+import (
+	"net/http"
+)
+
+/*
+NewRedirect returns a Response redirecting the client to the given URL (which may be relative to the request)
+with the given 3xx redirect status code; codes that aren't redirect codes (including 0) fall back to a 302. As
+with any Response carrying a 3xx status code, execution is stopped and the status code is recorded in the stats
+(ie. `handlers.<name>.302`).
+
+Example usage:
+
+	func legacyHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		return rye.NewRedirect("/v2"+r.URL.Path, http.StatusMovedPermanently)
+	}
+*/
+func NewRedirect(url string, code int) *Response {
+	return &Response{
+		RedirectTo:   url,
+		RedirectCode: code,
+	}
+}
+
+// redirectCode returns the code if it is a redirect status code, or a 302
+func redirectCode(code int) int {
+	switch code {
+	case http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return code
+	}
+
+	return http.StatusFound
+}
@@ -0,0 +1,80 @@
+package rye
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Redirects", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+		reporter *recordingReporter
+		reached  bool
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		reporter = &recordingReporter{}
+		reached = false
+	})
+
+	serve := func(resp *Response) {
+		NewMWHandler(Config{Reporter: reporter, SyncStats: true}).Handle([]Handler{
+			NamedHandler("legacy", func(rw http.ResponseWriter, r *http.Request) *Response {
+				return resp
+			}),
+			func(rw http.ResponseWriter, r *http.Request) *Response {
+				reached = true
+				return nil
+			},
+		}).ServeHTTP(response, httptest.NewRequest("GET", "/v1/items", nil))
+	}
+
+	It("should redirect the client and stop the chain", func() {
+		serve(NewRedirect("/v2/items", http.StatusMovedPermanently))
+
+		Expect(reached).To(BeFalse())
+		Expect(response.Code).To(Equal(http.StatusMovedPermanently))
+		Expect(response.Header().Get("Location")).To(Equal("/v2/items"))
+		Expect(reporter.recordedIncs()).To(ContainElement("handlers.legacy.301"))
+	})
+
+	It("should resolve relative URLs against the request", func() {
+		serve(NewRedirect("items", http.StatusSeeOther))
+
+		Expect(response.Code).To(Equal(http.StatusSeeOther))
+		Expect(response.Header().Get("Location")).To(Equal("/v1/items"))
+	})
+
+	It("should write out the headers of the Response", func() {
+		serve(&Response{
+			RedirectTo:   "/login",
+			RedirectCode: http.StatusTemporaryRedirect,
+			Headers:      http.Header{"Cache-Control": []string{"no-store"}},
+		})
+
+		Expect(response.Code).To(Equal(http.StatusTemporaryRedirect))
+		Expect(response.Header().Get("Cache-Control")).To(Equal("no-store"))
+	})
+
+	It("should fall back to a 302 for codes that aren't redirect codes", func() {
+		for _, code := range []int{0, http.StatusOK, http.StatusNotModified, http.StatusBadRequest} {
+			response = httptest.NewRecorder()
+			serve(NewRedirect("/v2/items", code))
+
+			Expect(response.Code).To(Equal(http.StatusFound))
+			Expect(response.Header().Get("Location")).To(Equal("/v2/items"))
+		}
+	})
+
+	It("should write out the error instead when there is one", func() {
+		serve(&Response{RedirectTo: "/v2/items", Err: errors.New("Foo"), StatusCode: http.StatusBadRequest})
+
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Header()).ToNot(HaveKey("Location"))
+	})
+})
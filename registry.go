@@ -0,0 +1,77 @@
+package rye
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+/*
+Register names a chain of handlers so that it can be composed with other registered chains through Chain,
+instead of repeating shared middleware slices across routes. Registering a name twice is an error.
+
+Example usage:
+
+	mwHandler.Register("auth", []rye.Handler{
+		rye.NewMiddlewareRequestID(rye.RequestIDConfig{}),
+		rye.NewMiddlewareJWT(secret),
+	})
+	mwHandler.Register("items", []rye.Handler{itemsHandler})
+
+	routes.Handle("/items", mwHandler.Chain("auth", "items")).Methods("GET")
+*/
+func (m *MWHandler) Register(name string, handlers []Handler) error {
+	m.registryMu.Lock()
+	defer m.registryMu.Unlock()
+
+	if _, ok := m.registry[name]; ok {
+		return fmt.Errorf("Chain '%s' is already registered", name)
+	}
+
+	if m.registry == nil {
+		m.registry = make(map[string][]Handler)
+	}
+
+	m.registry[name] = append([]Handler(nil), handlers...)
+
+	return nil
+}
+
+// Chain concatenates the handlers of the given registered chains, in order, into a single chain
+// (see Handle). As chains are composed when routes are set up, referencing a chain that isn't
+// registered is a programming error: Chain panics; use ChainE to get an error instead.
+func (m *MWHandler) Chain(names ...string) http.HandlerFunc {
+	h, err := m.ChainE(names...)
+	if err != nil {
+		panic(err)
+	}
+
+	return h
+}
+
+// ChainE works like Chain, but returns an error when a chain isn't registered.
+func (m *MWHandler) ChainE(names ...string) (http.HandlerFunc, error) {
+	m.registryMu.RLock()
+	defer m.registryMu.RUnlock()
+
+	var (
+		handlers []Handler
+		unknown  []string
+	)
+
+	for _, name := range names {
+		chain, ok := m.registry[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		handlers = append(handlers, chain...)
+	}
+
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("Unknown chains '%s'; register them with MWHandler.Register first", strings.Join(unknown, "', '"))
+	}
+
+	return m.Handle(handlers).ServeHTTP, nil
+}
@@ -0,0 +1,100 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Chain registry", func() {
+
+	var (
+		mwHandler *MWHandler
+		response  *httptest.ResponseRecorder
+		calls     []string
+	)
+
+	BeforeEach(func() {
+		mwHandler = NewMWHandler(Config{})
+		response = httptest.NewRecorder()
+		calls = nil
+	})
+
+	step := func(name string) Handler {
+		return func(rw http.ResponseWriter, r *http.Request) *Response {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	serve := func(h http.HandlerFunc) {
+		h.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	}
+
+	Describe("Register", func() {
+		It("should refuse to register a name twice", func() {
+			Expect(mwHandler.Register("auth", []Handler{step("jwt")})).To(Succeed())
+
+			err := mwHandler.Register("auth", []Handler{step("basic")})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Chain 'auth' is already registered"))
+
+			serve(mwHandler.Chain("auth"))
+			Expect(calls).To(Equal([]string{"jwt"}))
+		})
+
+		It("should not be affected by later changes to the given slice", func() {
+			handlers := []Handler{step("jwt")}
+			Expect(mwHandler.Register("auth", handlers)).To(Succeed())
+
+			handlers[0] = step("basic")
+
+			serve(mwHandler.Chain("auth"))
+			Expect(calls).To(Equal([]string{"jwt"}))
+		})
+	})
+
+	Describe("Chain", func() {
+		BeforeEach(func() {
+			Expect(mwHandler.Register("base", []Handler{step("requestID"), step("log")})).To(Succeed())
+			Expect(mwHandler.Register("auth", []Handler{step("jwt")})).To(Succeed())
+			Expect(mwHandler.Register("items", []Handler{step("items")})).To(Succeed())
+		})
+
+		It("should run the registered chains in the given order", func() {
+			serve(mwHandler.Chain("base", "auth", "items"))
+			Expect(calls).To(Equal([]string{"requestID", "log", "jwt", "items"}))
+
+			calls = nil
+			serve(mwHandler.Chain("auth", "base"))
+			Expect(calls).To(Equal([]string{"jwt", "requestID", "log"}))
+		})
+
+		It("should stop the composed chain like any other", func() {
+			Expect(mwHandler.Register("deny", []Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+				return &Response{StatusCode: http.StatusForbidden, StopExecution: true}
+			}})).To(Succeed())
+
+			serve(mwHandler.Chain("base", "deny", "items"))
+
+			Expect(calls).To(Equal([]string{"requestID", "log"}))
+			Expect(response.Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("should panic on unknown chains", func() {
+			Expect(func() {
+				mwHandler.Chain("base", "admin", "audit")
+			}).To(PanicWith(MatchError(ContainSubstring("Unknown chains 'admin', 'audit'"))))
+		})
+	})
+
+	Describe("ChainE", func() {
+		It("should return an error on unknown chains", func() {
+			h, err := mwHandler.ChainE("admin")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unknown chains 'admin'"))
+			Expect(h).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,76 @@
+package rye
+
+import (
+	"time"
+
+	"github.com/cactus/go-statsd-client/statsd"
+)
+
+// MetricsReporter is where rye sends the counters and timings it records; set
+// Config.Reporter to send them somewhere other than statsd (ie. PrometheusReporter).
+//
+// The stat rate is the rate the stat was sampled at: reporters that do their own
+// sampling (like statsd) may use it, others are free to ignore it.
+type MetricsReporter interface {
+	Inc(stat string, value int64, rate float32) error
+	TimingDuration(stat string, d time.Duration, rate float32) error
+}
+
+// GaugeReporter is implemented by the MetricsReporters that support gauges (ie. the
+// in-flight gauge, see Config.InflightGauge). It is optional: rye checks for it with a
+// type assertion, so reporters only sending counters and timings keep working.
+type GaugeReporter interface {
+	Gauge(stat string, value int64, rate float32) error
+}
+
+// statsdReporter sends stats through a statsd.Statter
+type statsdReporter struct {
+	statter statsd.Statter
+}
+
+// NewStatsdReporter returns a MetricsReporter sending stats through the given statter, which
+// is what rye does when only Config.Statter is set.
+func NewStatsdReporter(statter statsd.Statter) MetricsReporter {
+	return statsdReporter{statter: statter}
+}
+
+func (s statsdReporter) Inc(stat string, value int64, rate float32) error {
+	return s.statter.Inc(stat, value, rate)
+}
+
+func (s statsdReporter) TimingDuration(stat string, d time.Duration, rate float32) error {
+	return s.statter.TimingDuration(stat, d, rate)
+}
+
+func (s statsdReporter) Gauge(stat string, value int64, rate float32) error {
+	return s.statter.Gauge(stat, value, rate)
+}
+
+// reporter returns where counters and timings are sent: Config.Reporter if set, else
+// Config.Statter (or nil if neither is set)
+func (m *MWHandler) reporter() MetricsReporter {
+	if m.Config.Reporter != nil {
+		return m.Config.Reporter
+	}
+
+	if m.Config.Statter != nil {
+		return statsdReporter{statter: m.Config.Statter}
+	}
+
+	return nil
+}
+
+// gaugeReporter returns where gauges are sent: Config.Reporter if it is set (or nil if it
+// doesn't support them), else Config.Statter
+func (m *MWHandler) gaugeReporter() GaugeReporter {
+	if m.Config.Reporter != nil {
+		g, _ := m.Config.Reporter.(GaugeReporter)
+		return g
+	}
+
+	if m.Config.Statter != nil {
+		return statsdReporter{statter: m.Config.Statter}
+	}
+
+	return nil
+}
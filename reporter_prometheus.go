@@ -0,0 +1,294 @@
+package rye
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_PROMETHEUS_NAMESPACE = "rye"
+
+	// Metric names (after the namespace)
+	PROMETHEUS_HANDLER_REQUESTS = "handler_requests_total"
+	PROMETHEUS_HANDLER_DURATION = "handler_duration_seconds"
+	PROMETHEUS_EVENTS           = "events_total"
+	PROMETHEUS_TIMINGS          = "timings_seconds"
+)
+
+// DEFAULT_PROMETHEUS_BUCKETS are the upper bounds (in seconds) of the histogram buckets
+var DEFAULT_PROMETHEUS_BUCKETS = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var prometheusHelp = map[string]string{
+	PROMETHEUS_HANDLER_REQUESTS: "Calls to each handler by status.",
+	PROMETHEUS_HANDLER_DURATION: "Runtime of each handler.",
+	PROMETHEUS_EVENTS:           "Other counters recorded by rye, by stat name.",
+	PROMETHEUS_TIMINGS:          "Other timings recorded by rye, by stat name.",
+}
+
+// PrometheusConfig configures a PrometheusReporter.
+type PrometheusConfig struct {
+	// Namespace prefixes the metric names (defaults to DEFAULT_PROMETHEUS_NAMESPACE)
+	Namespace string
+
+	// Buckets are the upper bounds (in seconds) of the histogram buckets
+	// (defaults to DEFAULT_PROMETHEUS_BUCKETS)
+	Buckets []float64
+}
+
+// PrometheusReporter is a MetricsReporter keeping the stats in memory and serving them in the
+// Prometheus text format, so they can be scraped without a statsd exporter in between.
+type PrometheusReporter struct {
+	namespace string
+	buckets   []float64
+
+	mu         sync.Mutex
+	counters   map[string]map[string]float64
+	histograms map[string]map[string]*prometheusHistogram
+}
+
+// prometheusHistogram holds the cumulative bucket counts of a single series (counts are
+// floats as sampled observations are scaled up by their stat rate)
+type prometheusHistogram struct {
+	buckets []float64
+	count   float64
+	sum     float64
+}
+
+/*
+NewPrometheusReporter creates a MetricsReporter for Prometheus-based infrastructures. Handler stats are
+exposed as `rye_handler_requests_total{handler, status}` (exact status codes, or `2xx` for successful calls)
+and as the `rye_handler_duration_seconds{handler}` histogram, both with a `prefix` label when a stat prefix
+is used. Every other stat is exposed by name through `rye_events_total{name}` or `rye_timings_seconds{name}`.
+
+The stat rate is taken as the rate the stat was sampled at, so counters and histogram observations are scaled
+up by 1/rate (ie. a call recorded at a 0.1 rate counts as 10). rye only drops stats itself with
+Config.UnifiedSampling, so leave StatRate at 1 (or use UnifiedSampling) to get exact counts.
+
+The reporter renders the text format itself rather than being a client_golang `prometheus.Collector`, so it
+can't be registered in a `prometheus.Registry` next to other collectors: it is an `http.Handler` serving its
+own metrics to Prometheus, to be mounted on a route of its own.
+
+Example usage:
+
+	reporter := rye.NewPrometheusReporter(rye.PrometheusConfig{})
+
+	mwHandler := rye.NewMWHandler(rye.Config{
+		Reporter: reporter,
+	})
+
+	routes.Handle("/metrics", reporter).Methods("GET")
+*/
+func NewPrometheusReporter(cfg PrometheusConfig) *PrometheusReporter {
+	if cfg.Namespace == "" {
+		cfg.Namespace = DEFAULT_PROMETHEUS_NAMESPACE
+	}
+
+	if len(cfg.Buckets) == 0 {
+		cfg.Buckets = DEFAULT_PROMETHEUS_BUCKETS
+	}
+
+	buckets := append([]float64(nil), cfg.Buckets...)
+	sort.Float64s(buckets)
+
+	return &PrometheusReporter{
+		namespace:  cfg.Namespace,
+		buckets:    buckets,
+		counters:   make(map[string]map[string]float64),
+		histograms: make(map[string]map[string]*prometheusHistogram),
+	}
+}
+
+// Inc records a handler status stat (ie. `handlers.loginHandler.2xx`) as a handler request,
+// and any other stat as an event
+func (p *PrometheusReporter) Inc(stat string, value int64, rate float32) error {
+	prefix, handler, suffix, ok := parseHandlerStat(stat)
+
+	switch {
+	case ok && isStatusSuffix(suffix):
+		p.add(PROMETHEUS_HANDLER_REQUESTS, prometheusLabels("prefix", prefix, "handler", handler, "status", suffix), float64(value)*sampleWeight(rate))
+	case ok && (suffix == "4xx" || suffix == "5xx"):
+		// Rollups always come along with the exact status code, which can be summed in Prometheus
+	default:
+		p.add(PROMETHEUS_EVENTS, prometheusLabels("name", stat), float64(value)*sampleWeight(rate))
+	}
+
+	return nil
+}
+
+// TimingDuration records a handler runtime stat (ie. `handlers.loginHandler.runtime`) in the
+// handler duration histogram, and any other stat in the timings histogram
+func (p *PrometheusReporter) TimingDuration(stat string, d time.Duration, rate float32) error {
+	if prefix, handler, suffix, ok := parseHandlerStat(stat); ok && suffix == "runtime" {
+		p.observe(PROMETHEUS_HANDLER_DURATION, prometheusLabels("prefix", prefix, "handler", handler), d.Seconds(), sampleWeight(rate))
+	} else {
+		p.observe(PROMETHEUS_TIMINGS, prometheusLabels("name", stat), d.Seconds(), sampleWeight(rate))
+	}
+
+	return nil
+}
+
+func (p *PrometheusReporter) add(metric, labels string, value float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	series, ok := p.counters[metric]
+	if !ok {
+		series = make(map[string]float64)
+		p.counters[metric] = series
+	}
+
+	series[labels] += value
+}
+
+// observe records an observation counting as `weight` observations
+func (p *PrometheusReporter) observe(metric, labels string, value, weight float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	series, ok := p.histograms[metric]
+	if !ok {
+		series = make(map[string]*prometheusHistogram)
+		p.histograms[metric] = series
+	}
+
+	h, ok := series[labels]
+	if !ok {
+		h = &prometheusHistogram{buckets: make([]float64, len(p.buckets))}
+		series[labels] = h
+	}
+
+	for i, bound := range p.buckets {
+		if value <= bound {
+			h.buckets[i] += weight
+		}
+	}
+
+	h.count += weight
+	h.sum += value * weight
+}
+
+// sampleWeight is how many events a stat sampled at the given rate stands for
+func sampleWeight(rate float32) float64 {
+	if rate <= 0 || rate >= 1 {
+		return 1
+	}
+
+	return float64(1 / rate)
+}
+
+// ServeHTTP writes out the metrics in the Prometheus text format
+func (p *PrometheusReporter) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	rw.Write([]byte(p.expose()))
+}
+
+// expose renders the metrics in the Prometheus text format, sorted by metric and series
+func (p *PrometheusReporter) expose() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var b strings.Builder
+
+	for _, metric := range sortedKeys(p.counters) {
+		name := p.namespace + "_" + metric
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n", name, prometheusHelp[metric], name)
+
+		for _, labels := range sortedKeys(p.counters[metric]) {
+			fmt.Fprintf(&b, "%s{%s} %s\n", name, labels, formatPrometheusValue(p.counters[metric][labels]))
+		}
+	}
+
+	for _, metric := range sortedKeys(p.histograms) {
+		name := p.namespace + "_" + metric
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s histogram\n", name, prometheusHelp[metric], name)
+
+		for _, labels := range sortedKeys(p.histograms[metric]) {
+			h := p.histograms[metric][labels]
+
+			for i, bound := range p.buckets {
+				fmt.Fprintf(&b, "%s_bucket{%s} %s\n", name, joinPrometheusLabels(labels, prometheusLabels("le", formatPrometheusValue(bound))), formatPrometheusValue(h.buckets[i]))
+			}
+			fmt.Fprintf(&b, "%s_bucket{%s} %s\n", name, joinPrometheusLabels(labels, `le="+Inf"`), formatPrometheusValue(h.count))
+			fmt.Fprintf(&b, "%s_sum{%s} %s\n", name, labels, formatPrometheusValue(h.sum))
+			fmt.Fprintf(&b, "%s_count{%s} %s\n", name, labels, formatPrometheusValue(h.count))
+		}
+	}
+
+	return b.String()
+}
+
+// parseHandlerStat splits a handler stat (`[<prefix>.]handlers.<handler>.<suffix>`) into its parts
+func parseHandlerStat(stat string) (prefix, handler, suffix string, ok bool) {
+	rest := strings.TrimPrefix(stat, "handlers.")
+	if rest == stat {
+		i := strings.Index(stat, ".handlers.")
+		if i < 0 {
+			return "", "", "", false
+		}
+
+		prefix, rest = stat[:i], stat[i+len(".handlers."):]
+	}
+
+	i := strings.LastIndex(rest, ".")
+	if i <= 0 {
+		return "", "", "", false
+	}
+
+	return prefix, rest[:i], rest[i+1:], true
+}
+
+// isStatusSuffix reports whether the stat suffix is a status code (or the 2xx of successful calls)
+func isStatusSuffix(suffix string) bool {
+	if suffix == "2xx" {
+		return true
+	}
+
+	code, err := strconv.Atoi(suffix)
+	return err == nil && len(suffix) == 3 && code >= 100
+}
+
+// prometheusLabels renders the given name/value pairs as labels, leaving out empty values
+func prometheusLabels(pairs ...string) string {
+	var labels []string
+
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == "" {
+			continue
+		}
+
+		labels = append(labels, pairs[i]+`="`+escapePrometheusLabel(pairs[i+1])+`"`)
+	}
+
+	return strings.Join(labels, ",")
+}
+
+func joinPrometheusLabels(labels, more string) string {
+	if labels == "" {
+		return more
+	}
+
+	return labels + "," + more
+}
+
+func escapePrometheusLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatPrometheusValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,118 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PrometheusReporter", func() {
+
+	var reporter *PrometheusReporter
+
+	BeforeEach(func() {
+		reporter = NewPrometheusReporter(PrometheusConfig{Buckets: []float64{0.1, 1}})
+	})
+
+	Describe("Inc", func() {
+		It("should count handler calls by handler and status", func() {
+			reporter.Inc("handlers.loginHandler.2xx", 1, 1)
+			reporter.Inc("handlers.loginHandler.2xx", 1, 1)
+			reporter.Inc("handlers.loginHandler.404", 1, 1)
+			reporter.Inc("handlers.loginHandler.4xx", 1, 1)
+
+			Expect(reporter.expose()).To(ContainSubstring("# TYPE rye_handler_requests_total counter\n"))
+			Expect(reporter.expose()).To(ContainSubstring(`rye_handler_requests_total{handler="loginHandler",status="2xx"} 2` + "\n"))
+			Expect(reporter.expose()).To(ContainSubstring(`rye_handler_requests_total{handler="loginHandler",status="404"} 1` + "\n"))
+			Expect(reporter.expose()).ToNot(ContainSubstring(`status="4xx"`))
+		})
+
+		It("should label handler calls with the stat prefix", func() {
+			reporter.Inc("myservice.v2.handlers.loginHandler.2xx", 1, 1)
+
+			Expect(reporter.expose()).To(ContainSubstring(`rye_handler_requests_total{prefix="myservice.v2",handler="loginHandler",status="2xx"} 1`))
+		})
+
+		It("should scale sampled stats up by their stat rate", func() {
+			reporter.Inc("handlers.loginHandler.2xx", 1, 0.1)
+			reporter.Inc("errors", 1, 0.5)
+
+			Expect(reporter.expose()).To(ContainSubstring(`rye_handler_requests_total{handler="loginHandler",status="2xx"} 10` + "\n"))
+			Expect(reporter.expose()).To(ContainSubstring(`rye_events_total{name="errors"} 2` + "\n"))
+		})
+
+		It("should count other stats by name", func() {
+			reporter.Inc("errors", 1, 1)
+			reporter.Inc("handlers.loginHandler.latency_bucket.le_100ms", 1, 1)
+
+			Expect(reporter.expose()).To(ContainSubstring(`rye_events_total{name="errors"} 1`))
+			Expect(reporter.expose()).To(ContainSubstring(`rye_events_total{name="handlers.loginHandler.latency_bucket.le_100ms"} 1`))
+		})
+	})
+
+	Describe("TimingDuration", func() {
+		It("should record handler runtimes in a histogram", func() {
+			reporter.TimingDuration("handlers.loginHandler.runtime", 50*time.Millisecond, 1)
+			reporter.TimingDuration("handlers.loginHandler.runtime", 500*time.Millisecond, 1)
+
+			output := reporter.expose()
+			Expect(output).To(ContainSubstring("# TYPE rye_handler_duration_seconds histogram\n"))
+			Expect(output).To(ContainSubstring(`rye_handler_duration_seconds_bucket{handler="loginHandler",le="0.1"} 1` + "\n"))
+			Expect(output).To(ContainSubstring(`rye_handler_duration_seconds_bucket{handler="loginHandler",le="1"} 2` + "\n"))
+			Expect(output).To(ContainSubstring(`rye_handler_duration_seconds_bucket{handler="loginHandler",le="+Inf"} 2` + "\n"))
+			Expect(output).To(ContainSubstring(`rye_handler_duration_seconds_sum{handler="loginHandler"} 0.55` + "\n"))
+			Expect(output).To(ContainSubstring(`rye_handler_duration_seconds_count{handler="loginHandler"} 2` + "\n"))
+		})
+
+		It("should scale sampled observations up by their stat rate", func() {
+			reporter.TimingDuration("handlers.loginHandler.runtime", 50*time.Millisecond, 0.5)
+
+			output := reporter.expose()
+			Expect(output).To(ContainSubstring(`rye_handler_duration_seconds_bucket{handler="loginHandler",le="0.1"} 2` + "\n"))
+			Expect(output).To(ContainSubstring(`rye_handler_duration_seconds_sum{handler="loginHandler"} 0.1` + "\n"))
+			Expect(output).To(ContainSubstring(`rye_handler_duration_seconds_count{handler="loginHandler"} 2` + "\n"))
+		})
+
+		It("should record other timings by name", func() {
+			reporter.TimingDuration("concurrency.wait", 2*time.Second, 1)
+
+			Expect(reporter.expose()).To(ContainSubstring(`rye_timings_seconds_bucket{name="concurrency.wait",le="1"} 0`))
+			Expect(reporter.expose()).To(ContainSubstring(`rye_timings_seconds_count{name="concurrency.wait"} 1`))
+		})
+	})
+
+	Describe("ServeHTTP", func() {
+		It("should serve the metrics in the Prometheus text format", func() {
+			mwHandler := NewMWHandler(Config{Reporter: reporter, StatRate: 1})
+			mwHandler.Handle([]Handler{NamedHandler("login", successHandler)}).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			response := httptest.NewRecorder()
+			Eventually(func() string {
+				response = httptest.NewRecorder()
+				reporter.ServeHTTP(response, httptest.NewRequest("GET", "/metrics", nil))
+				return response.Body.String()
+			}).Should(ContainSubstring(`rye_handler_requests_total{handler="login",status="2xx"} 1`))
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("Content-Type")).To(HavePrefix("text/plain; version=0.0.4"))
+		})
+
+		It("should escape label values", func() {
+			reporter.Inc(`quote"d\back`+"\nslash", 1, 1)
+
+			Expect(reporter.expose()).To(ContainSubstring(`rye_events_total{name="quote\"d\\back\nslash"} 1`))
+		})
+	})
+
+	Describe("NewPrometheusReporter", func() {
+		It("should use the namespace and default buckets", func() {
+			reporter = NewPrometheusReporter(PrometheusConfig{Namespace: "myservice"})
+			reporter.TimingDuration("handlers.loginHandler.runtime", time.Millisecond, 1)
+
+			Expect(reporter.expose()).To(ContainSubstring(`myservice_handler_duration_seconds_bucket{handler="loginHandler",le="0.005"} 1`))
+			Expect(reporter.expose()).To(ContainSubstring(`myservice_handler_duration_seconds_bucket{handler="loginHandler",le="10"} 1`))
+		})
+	})
+})
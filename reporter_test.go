@@ -0,0 +1,140 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/InVisionApp/rye/fakes/statsdfakes"
+	. "github.com/onsi/gomega"
+)
+
+// recordingReporter keeps the names of the stats it was sent
+type recordingReporter struct {
+	mu      sync.Mutex
+	incs    []string
+	timings []string
+}
+
+func (r *recordingReporter) Inc(stat string, value int64, rate float32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.incs = append(r.incs, stat)
+	return nil
+}
+
+func (r *recordingReporter) TimingDuration(stat string, d time.Duration, rate float32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.timings = append(r.timings, stat)
+	return nil
+}
+
+func (r *recordingReporter) recordedIncs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.incs...)
+}
+
+func (r *recordingReporter) recordedTimings() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.timings...)
+}
+
+// gaugeRecordingReporter is a recordingReporter supporting gauges, keeping their values
+type gaugeRecordingReporter struct {
+	recordingReporter
+	gauges []int64
+}
+
+func (r *gaugeRecordingReporter) Gauge(stat string, value int64, rate float32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.gauges = append(r.gauges, value)
+	return nil
+}
+
+func (r *gaugeRecordingReporter) recordedGauges() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]int64(nil), r.gauges...)
+}
+
+var _ = Describe("MetricsReporter", func() {
+
+	var (
+		request     *http.Request
+		response    *httptest.ResponseRecorder
+		fakeStatter *statsdfakes.FakeStatter
+		reporter    *recordingReporter
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest("GET", "/", nil)
+		response = httptest.NewRecorder()
+		fakeStatter = &statsdfakes.FakeStatter{}
+		reporter = &recordingReporter{}
+	})
+
+	Describe("NewStatsdReporter", func() {
+		It("should send stats through the statter", func() {
+			statsdReporter := NewStatsdReporter(fakeStatter)
+
+			Expect(statsdReporter.Inc("foo", 2, 0.5)).To(Succeed())
+			Expect(statsdReporter.TimingDuration("bar", time.Second, 0.5)).To(Succeed())
+
+			Expect(fakeStatter.IncCallCount()).To(Equal(1))
+			stat, value, rate := fakeStatter.IncArgsForCall(0)
+			Expect(stat).To(Equal("foo"))
+			Expect(value).To(Equal(int64(2)))
+			Expect(rate).To(Equal(float32(0.5)))
+
+			Expect(fakeStatter.TimingDurationCallCount()).To(Equal(1))
+			stat, d, _ := fakeStatter.TimingDurationArgsForCall(0)
+			Expect(stat).To(Equal("bar"))
+			Expect(d).To(Equal(time.Second))
+		})
+	})
+
+	Describe("Config.Reporter", func() {
+		It("should receive the handler stats", func() {
+			mwHandler := NewMWHandler(Config{Reporter: reporter, StatRate: 1})
+			mwHandler.Handle([]Handler{successHandler}).ServeHTTP(response, request)
+
+			Eventually(reporter.recordedIncs).Should(ContainElement("handlers.successHandler.2xx"))
+			Eventually(reporter.recordedTimings).Should(ContainElement("handlers.successHandler.runtime"))
+		})
+
+		It("should receive the stats recorded within the chain", func() {
+			mwHandler := NewMWHandler(Config{Reporter: reporter, StatRate: 1})
+			mwHandler.Handle([]Handler{successHandler, Checkpoint("auth")}).ServeHTTP(response, request)
+
+			Eventually(reporter.recordedTimings).Should(ContainElement("handlers.successHandler.checkpoint.auth"))
+		})
+
+		It("should take precedence over the statter", func() {
+			mwHandler := NewMWHandler(Config{Reporter: reporter, Statter: fakeStatter, StatRate: 1})
+			mwHandler.Handle([]Handler{failureHandler}).ServeHTTP(response, request)
+
+			Eventually(reporter.recordedIncs).Should(ContainElements("errors", "handlers.failureHandler.505"))
+			Consistently(fakeStatter.IncCallCount).Should(BeZero())
+			Consistently(fakeStatter.TimingDurationCallCount).Should(BeZero())
+		})
+
+		It("should fall back to the statter", func() {
+			mwHandler := NewMWHandler(Config{Statter: fakeStatter, StatRate: 1})
+			mwHandler.Handle([]Handler{successHandler}).ServeHTTP(response, request)
+
+			Eventually(fakeStatter.IncCallCount).Should(Equal(1))
+			Eventually(fakeStatter.TimingDurationCallCount).Should(Equal(2))
+		})
+	})
+})
@@ -0,0 +1,46 @@
+package rye
+
+import (
+	"fmt"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+/*
+RequireContextValue creates a guard handler asserting that a prior handler in the chain has set a
+value for `key` in the request context (ie. a scope check that relies on `rye.NewMiddlewareJWT`
+having run first). This catches chain ordering bugs early.
+
+When the value is missing, a warning is logged and the chain carries on; in debug builds
+(`-tags ryedebug`) a 500 is returned instead so the bug cannot go unnoticed.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareJWT(secret),
+			rye.RequireContextValue(rye.CONTEXT_JWT),
+			yourScopeCheck,
+			yourHandler,
+		})).Methods("GET")
+*/
+func RequireContextValue(key interface{}) Handler {
+	return func(rw http.ResponseWriter, r *http.Request) *Response {
+		if r.Context().Value(key) != nil {
+			return nil
+		}
+
+		err := fmt.Errorf("Required context value '%v' is missing; check the order of the handler chain", key)
+
+		if debugMode {
+			return &Response{
+				Err:        err,
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		log.Warn(err.Error())
+		return nil
+	}
+}
@@ -0,0 +1,70 @@
+package rye
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	log "github.com/Sirupsen/logrus"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RequireContextValue", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+		request  *http.Request
+		output   *bytes.Buffer
+		debug    bool
+		level    log.Level
+	)
+
+	BeforeEach(func() {
+		debug = debugMode
+
+		response = httptest.NewRecorder()
+		request = httptest.NewRequest("GET", "/", nil)
+
+		output = &bytes.Buffer{}
+		log.SetOutput(output)
+
+		level = log.GetLevel()
+		log.SetLevel(log.WarnLevel)
+	})
+
+	AfterEach(func() {
+		debugMode = debug
+		log.SetOutput(GinkgoWriter)
+		log.SetLevel(level)
+	})
+
+	Context("when the value has been set by a prior handler", func() {
+		It("should return nil without logging", func() {
+			request = request.WithContext(context.WithValue(request.Context(), CONTEXT_JWT, "token"))
+
+			resp := RequireContextValue(CONTEXT_JWT)(response, request)
+			Expect(resp).To(BeNil())
+			Expect(output.Len()).To(Equal(0))
+		})
+	})
+
+	Context("when the value is missing", func() {
+		It("should log a warning and carry on", func() {
+			debugMode = false
+
+			resp := RequireContextValue(CONTEXT_JWT)(response, request)
+			Expect(resp).To(BeNil())
+			Expect(output.String()).To(ContainSubstring("Required context value 'rye-middlewarejwt-jwt' is missing"))
+		})
+
+		It("should return a 500 in debug builds", func() {
+			debugMode = true
+
+			resp := RequireContextValue(CONTEXT_JWT)(response, request)
+			Expect(resp).ToNot(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+			Expect(resp.Error()).To(ContainSubstring("check the order of the handler chain"))
+		})
+	})
+})
@@ -0,0 +1,372 @@
+package rye
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// finalizer is implemented by writers (returned via Response.Writer) that need
+// to do some work once the handler chain has finished executing.
+type finalizer interface {
+	finalize()
+}
+
+// bufferedResponseWriter holds on to the status code and body written by downstream
+// handlers so a middleware can inspect or rewrite them before they reach the client.
+// Headers are written straight through to the wrapped writer.
+//
+// If maxSize is set and the body grows past it, buffering stops: what was buffered is
+// flushed, the rest of the body is written straight through and the transform is skipped.
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+
+	status    int
+	body      bytes.Buffer
+	transform func(b *bufferedResponseWriter)
+
+	maxSize    int
+	overflowed bool
+}
+
+func newBufferedResponseWriter(rw http.ResponseWriter, transform func(b *bufferedResponseWriter)) *bufferedResponseWriter {
+	return &bufferedResponseWriter{
+		ResponseWriter: rw,
+		transform:      transform,
+	}
+}
+
+func (b *bufferedResponseWriter) WriteHeader(statusCode int) {
+	if b.status == 0 {
+		b.status = statusCode
+	}
+}
+
+func (b *bufferedResponseWriter) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+
+	if b.overflowed {
+		return b.ResponseWriter.Write(p)
+	}
+
+	if b.maxSize > 0 && b.body.Len()+len(p) > b.maxSize {
+		b.overflowed = true
+
+		b.ResponseWriter.WriteHeader(b.status)
+		if _, err := b.ResponseWriter.Write(b.body.Bytes()); err != nil {
+			return 0, err
+		}
+		b.body.Reset()
+
+		return b.ResponseWriter.Write(p)
+	}
+
+	return b.body.Write(p)
+}
+
+// written reports whether downstream handlers wrote anything at all
+func (b *bufferedResponseWriter) written() bool {
+	return b.status != 0
+}
+
+// finalize runs the transform (if any) and flushes the buffered response
+func (b *bufferedResponseWriter) finalize() {
+	if !b.written() || b.overflowed {
+		return
+	}
+
+	if b.transform != nil {
+		b.transform(b)
+	}
+
+	// We have the full body, so tell the client exactly how big it is
+	// rather than falling back to chunked encoding
+	if bodyAllowedForStatus(b.status) {
+		b.Header().Set("Content-Length", strconv.Itoa(b.body.Len()))
+	}
+
+	b.ResponseWriter.WriteHeader(b.status)
+	b.ResponseWriter.Write(b.body.Bytes())
+}
+
+// bodyAllowedForStatus reports whether a given response status code permits a body
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent:
+		return false
+	case status == http.StatusNotModified:
+		return false
+	}
+
+	return true
+}
+
+// responseDefaultsWriter applies the response defaults configured on the MWHandler:
+// default headers are added right before the status code is written (unless handlers
+// have set headers of the same name) and the default content type is set when a body
+// is written without one. Writing the status code is held back until the first write
+// so that it's known whether there is a body.
+type responseDefaultsWriter struct {
+	http.ResponseWriter
+
+	defaults    http.Header
+	contentType string
+
+	status      int
+	wroteHeader bool
+}
+
+func newResponseDefaultsWriter(rw http.ResponseWriter, defaults http.Header, contentType string) *responseDefaultsWriter {
+	return &responseDefaultsWriter{
+		ResponseWriter: rw,
+		defaults:       defaults,
+		contentType:    contentType,
+	}
+}
+
+// writeHeader adds the default headers and writes the status code held back by WriteHeader (if any)
+func (d *responseDefaultsWriter) writeHeader() {
+	if d.wroteHeader {
+		return
+	}
+	d.wroteHeader = true
+
+	header := d.Header()
+
+	for key, values := range d.defaults {
+		key = http.CanonicalHeaderKey(key)
+
+		if _, ok := header[key]; !ok {
+			header[key] = append([]string(nil), values...)
+		}
+	}
+
+	if d.status != 0 {
+		d.ResponseWriter.WriteHeader(d.status)
+	}
+}
+
+func (d *responseDefaultsWriter) WriteHeader(statusCode int) {
+	if d.status == 0 {
+		d.status = statusCode
+	}
+}
+
+func (d *responseDefaultsWriter) Write(p []byte) (int, error) {
+	if !d.wroteHeader && d.contentType != "" && len(p) > 0 && d.Header().Get("Content-Type") == "" {
+		d.Header().Set("Content-Type", d.contentType)
+	}
+
+	d.writeHeader()
+
+	return d.ResponseWriter.Write(p)
+}
+
+// Flush passes through to the wrapped writer (if it supports flushing)
+func (d *responseDefaultsWriter) Flush() {
+	d.writeHeader()
+
+	if f, ok := d.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack passes through to the wrapped writer, so that handlers can still take over
+// the connection (the defaults are left unwritten, as nothing is written through http)
+func (d *responseDefaultsWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := d.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("The response writer does not support hijacking")
+	}
+
+	return h.Hijack()
+}
+
+// Unwrap returns the wrapped writer (for http.ResponseController)
+func (d *responseDefaultsWriter) Unwrap() http.ResponseWriter {
+	return d.ResponseWriter
+}
+
+// finalize makes sure the defaults and status code are written even if the chain wrote no body
+func (d *responseDefaultsWriter) finalize() {
+	d.writeHeader()
+}
+
+// recordingResponseWriter captures everything written to it (headers included) without
+// touching the client, so that a response can be discarded or replayed later on.
+type recordingResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newRecordingResponseWriter() *recordingResponseWriter {
+	return &recordingResponseWriter{
+		header: make(http.Header),
+	}
+}
+
+func (rec *recordingResponseWriter) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recordingResponseWriter) WriteHeader(statusCode int) {
+	if rec.status == 0 {
+		rec.status = statusCode
+	}
+}
+
+func (rec *recordingResponseWriter) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	return rec.body.Write(p)
+}
+
+// DryRunResult describes the response a chain would have written (see Config.DryRun).
+type DryRunResult struct {
+	StatusCode int
+	Header     http.Header
+	BodyLength int
+}
+
+// dryRunResult describes the recorded response; a response nothing was written to is sent as a 200
+func (rec *recordingResponseWriter) dryRunResult() DryRunResult {
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	return DryRunResult{
+		StatusCode: status,
+		Header:     rec.header,
+		BodyLength: rec.body.Len(),
+	}
+}
+
+// replay writes the recorded response (if anything was recorded) to the given writer
+func (rec *recordingResponseWriter) replay(rw http.ResponseWriter) {
+	if rec.status == 0 {
+		return
+	}
+
+	for key, values := range rec.header {
+		rw.Header()[key] = values
+	}
+
+	rw.WriteHeader(rec.status)
+	rw.Write(rec.body.Bytes())
+}
+
+// statusRecordingWriter passes everything straight through to the wrapped writer and
+// reports the final status code to onFinish once the chain is done.
+type statusRecordingWriter struct {
+	http.ResponseWriter
+
+	status   int
+	onFinish func(status int)
+}
+
+func newStatusRecordingWriter(rw http.ResponseWriter, onFinish func(status int)) *statusRecordingWriter {
+	return &statusRecordingWriter{
+		ResponseWriter: rw,
+		onFinish:       onFinish,
+	}
+}
+
+func (s *statusRecordingWriter) WriteHeader(statusCode int) {
+	if s.status == 0 {
+		s.status = statusCode
+	}
+
+	s.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (s *statusRecordingWriter) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+
+	return s.ResponseWriter.Write(p)
+}
+
+// Flush passes through to the wrapped writer (if it supports flushing)
+func (s *statusRecordingWriter) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finalize reports the status; a response nothing was written to is sent as a 200
+func (s *statusRecordingWriter) finalize() {
+	status := s.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if s.onFinish != nil {
+		s.onFinish(status)
+	}
+}
+
+// statusCapturingResponseWriter passes everything straight through to the wrapped writer and
+// records the first status code written, so that handlers writing their response themselves
+// (rather than returning a Response) are recorded in stats with the status they wrote.
+type statusCapturingResponseWriter struct {
+	http.ResponseWriter
+
+	status int
+}
+
+func newStatusCapturingResponseWriter(rw http.ResponseWriter) *statusCapturingResponseWriter {
+	return &statusCapturingResponseWriter{
+		ResponseWriter: rw,
+	}
+}
+
+func (s *statusCapturingResponseWriter) WriteHeader(statusCode int) {
+	if s.status == 0 {
+		s.status = statusCode
+	}
+
+	s.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (s *statusCapturingResponseWriter) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+
+	return s.ResponseWriter.Write(p)
+}
+
+// Flush passes through to the wrapped writer (if it supports flushing)
+func (s *statusCapturingResponseWriter) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack passes through to the wrapped writer, so that handlers can still take over
+// the connection (ie. to upgrade it to a websocket)
+func (s *statusCapturingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("The response writer does not support hijacking")
+	}
+
+	return h.Hijack()
+}
+
+// Unwrap returns the wrapped writer (for http.ResponseController)
+func (s *statusCapturingResponseWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,177 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("bufferedResponseWriter", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+	})
+
+	Describe("finalize", func() {
+		It("should flush the buffered status and body", func() {
+			b := newBufferedResponseWriter(response, nil)
+			b.WriteHeader(http.StatusCreated)
+			b.Write([]byte("created"))
+
+			Expect(response.Body.Len()).To(Equal(0))
+
+			b.finalize()
+			Expect(response.Code).To(Equal(http.StatusCreated))
+			Expect(response.Body.String()).To(Equal("created"))
+		})
+
+		It("should set an accurate Content-Length", func() {
+			b := newBufferedResponseWriter(response, func(b *bufferedResponseWriter) {
+				b.body.WriteString(" and transformed")
+			})
+			b.Header().Set("Content-Length", "1")
+			b.Write([]byte("buffered"))
+			b.finalize()
+
+			Expect(response.Header().Get("Content-Length")).To(Equal("24"))
+			Expect(response.Body.String()).To(Equal("buffered and transformed"))
+		})
+
+		It("should not set Content-Length for statuses without a body", func() {
+			b := newBufferedResponseWriter(response, nil)
+			b.WriteHeader(http.StatusNoContent)
+			b.finalize()
+
+			Expect(response.Code).To(Equal(http.StatusNoContent))
+			Expect(response.Header().Get("Content-Length")).To(BeEmpty())
+		})
+
+		It("should not write anything if nothing was written", func() {
+			b := newBufferedResponseWriter(response, nil)
+			b.finalize()
+
+			Expect(response.Header().Get("Content-Length")).To(BeEmpty())
+			Expect(response.Body.Len()).To(Equal(0))
+		})
+	})
+})
+
+var _ = Describe("bufferedResponseWriter with a maxSize", func() {
+
+	var (
+		response    *httptest.ResponseRecorder
+		transformed bool
+		b           *bufferedResponseWriter
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		transformed = false
+
+		b = newBufferedResponseWriter(response, func(b *bufferedResponseWriter) {
+			transformed = true
+		})
+		b.maxSize = 10
+	})
+
+	It("should buffer bodies within the limit", func() {
+		b.Write([]byte("0123456789"))
+		Expect(response.Body.Len()).To(Equal(0))
+
+		b.finalize()
+		Expect(transformed).To(BeTrue())
+		Expect(response.Body.String()).To(Equal("0123456789"))
+	})
+
+	It("should write bodies past the limit straight through, skipping the transform", func() {
+		b.WriteHeader(http.StatusCreated)
+		b.Write([]byte("01234"))
+		b.Write([]byte("56789ab"))
+		Expect(response.Code).To(Equal(http.StatusCreated))
+		Expect(response.Body.String()).To(Equal("0123456789ab"))
+
+		b.Write([]byte("cd"))
+		b.finalize()
+		Expect(transformed).To(BeFalse())
+		Expect(response.Body.String()).To(Equal("0123456789abcd"))
+	})
+})
+
+var _ = Describe("recordingResponseWriter", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+	})
+
+	Describe("replay", func() {
+		It("should write the recorded headers, status and body", func() {
+			rec := newRecordingResponseWriter()
+			rec.Header().Set("X-Foo", "bar")
+			rec.WriteHeader(http.StatusAccepted)
+			rec.Write([]byte("accepted"))
+
+			Expect(response.Header().Get("X-Foo")).To(BeEmpty())
+
+			rec.replay(response)
+			Expect(response.Code).To(Equal(http.StatusAccepted))
+			Expect(response.Header().Get("X-Foo")).To(Equal("bar"))
+			Expect(response.Body.String()).To(Equal("accepted"))
+		})
+
+		It("should not write anything if nothing was recorded", func() {
+			rec := newRecordingResponseWriter()
+			rec.replay(response)
+
+			Expect(response.Flushed).To(BeFalse())
+			Expect(response.Body.Len()).To(Equal(0))
+		})
+	})
+})
+
+var _ = Describe("statusCapturingResponseWriter", func() {
+
+	var (
+		response *httptest.ResponseRecorder
+		capture  *statusCapturingResponseWriter
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		capture = newStatusCapturingResponseWriter(response)
+	})
+
+	It("should record the first status code written", func() {
+		capture.WriteHeader(http.StatusNotFound)
+		capture.WriteHeader(http.StatusInternalServerError)
+
+		Expect(capture.status).To(Equal(http.StatusNotFound))
+		Expect(response.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should record a 200 when the body is written first", func() {
+		capture.Write([]byte("hello"))
+
+		Expect(capture.status).To(Equal(http.StatusOK))
+		Expect(response.Body.String()).To(Equal("hello"))
+	})
+
+	It("should not record anything until something is written", func() {
+		Expect(capture.status).To(BeZero())
+	})
+
+	It("should report when the wrapped writer can't be hijacked", func() {
+		_, _, err := capture.Hijack()
+
+		Expect(err).To(HaveOccurred())
+		Expect(capture.Unwrap()).To(BeIdenticalTo(response))
+	})
+})
@@ -0,0 +1,121 @@
+package rye
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+)
+
+// NewErrorResponse returns a *Response carrying the error, written out with the given status code
+func NewErrorResponse(code int, err error) *Response {
+	return &Response{
+		Err:        err,
+		StatusCode: code,
+	}
+}
+
+// NewStopResponse returns a *Response stopping the chain with the given status code
+// (or without writing one if the code is 0)
+func NewStopResponse(code int) *Response {
+	return &Response{
+		StatusCode:    code,
+		StopExecution: true,
+	}
+}
+
+// NewContextResponse returns a *Response passing the context on to the rest of the chain
+func NewContextResponse(ctx context.Context) *Response {
+	return &Response{
+		Context: ctx,
+	}
+}
+
+/*
+WithHeader adds a header to the response (see Response.Headers) and returns it, so that it can be
+chained onto the other helpers.
+
+Example usage:
+
+	func movedHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		return rye.NewStopResponse(http.StatusMovedPermanently).WithHeader("Location", "/new/path")
+	}
+*/
+func (r *Response) WithHeader(key, value string) *Response {
+	if r.Headers == nil {
+		r.Headers = make(http.Header)
+	}
+
+	r.Headers.Add(key, value)
+
+	return r
+}
+
+/*
+Accepted returns a *Response for endpoints that kick off background work: it writes a 202
+with a `Location` header pointing to a resource the client can poll for the status of the
+work, and stops the chain. The handler is expected to have enqueued the work already.
+
+Example usage:
+
+	func createExportHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		jobID := enqueueExport(r)
+		return rye.Accepted("/exports/" + jobID)
+	}
+*/
+func Accepted(statusURL string) *Response {
+	return &Response{
+		StatusCode:    http.StatusAccepted,
+		StopExecution: true,
+		Headers: http.Header{
+			"Location": []string{statusURL},
+		},
+	}
+}
+
+/*
+XML returns a *Response that writes `v` marshalled as XML with the given status code (and an
+`application/xml` content type), stopping the chain. If `v` cannot be marshalled, an error
+*Response with a 500 is returned instead.
+
+Example usage:
+
+	func legacyHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		return rye.XML(http.StatusOK, &Invoice{ID: 1})
+	}
+*/
+func XML(status int, v interface{}) *Response {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return &Response{
+			Err:        fmt.Errorf("Unable to marshal XML response: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+
+	return &Response{
+		StatusCode:    status,
+		StopExecution: true,
+		StatusContent: xml.Header + string(data),
+		ContentType:   "application/xml",
+	}
+}
+
+/*
+Text returns a *Response that writes `body` as plain text (`text/plain; charset=utf-8`) with the
+given status code, stopping the chain. This is handy for health checks and simple messages.
+
+Example usage:
+
+	func healthHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		return rye.Text(http.StatusOK, "ok")
+	}
+*/
+func Text(status int, body string) *Response {
+	return &Response{
+		StatusCode:    status,
+		StopExecution: true,
+		StatusContent: body,
+		ContentType:   "text/plain; charset=utf-8",
+	}
+}
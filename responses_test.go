@@ -0,0 +1,157 @@
+package rye
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Response helpers", func() {
+
+	var (
+		request   *http.Request
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		request = &http.Request{
+			Header: make(map[string][]string, 0),
+		}
+		mwHandler = NewMWHandler(Config{})
+	})
+
+	Describe("NewErrorResponse", func() {
+		It("should write out the error with the status code", func() {
+			h := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+				return NewErrorResponse(http.StatusBadGateway, errors.New("upstream failed"))
+			}})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusBadGateway))
+			Expect(response.Body.String()).To(ContainSubstring("upstream failed"))
+		})
+	})
+
+	Describe("NewStopResponse", func() {
+		It("should return a stopping response with the status code", func() {
+			resp := NewStopResponse(http.StatusNotModified)
+			Expect(resp).To(Equal(&Response{StatusCode: http.StatusNotModified, StopExecution: true}))
+		})
+	})
+
+	Describe("NewContextResponse", func() {
+		It("should pass the context on to the rest of the chain", func() {
+			var value interface{}
+
+			h := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+				return NewContextResponse(context.WithValue(r.Context(), "key", "value"))
+			}, func(rw http.ResponseWriter, r *http.Request) *Response {
+				value = r.Context().Value("key")
+				return nil
+			}})
+			h.ServeHTTP(response, request)
+
+			Expect(value).To(Equal("value"))
+		})
+	})
+
+	Describe("WithHeader", func() {
+		It("should add headers to the response", func() {
+			resp := NewStopResponse(http.StatusMovedPermanently).
+				WithHeader("Location", "/new").
+				WithHeader("Vary", "Accept").
+				WithHeader("Vary", "Origin")
+
+			Expect(resp.Headers.Get("Location")).To(Equal("/new"))
+			Expect(resp.Headers.Values("Vary")).To(Equal([]string{"Accept", "Origin"}))
+		})
+
+		It("should write the headers when used in a chain", func() {
+			h := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+				return NewStopResponse(http.StatusMovedPermanently).WithHeader("Location", "/new")
+			}})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusMovedPermanently))
+			Expect(response.Header().Get("Location")).To(Equal("/new"))
+		})
+	})
+
+	Describe("Accepted", func() {
+		It("should return a stopping 202 response with a Location header", func() {
+			resp := Accepted("/jobs/1")
+			Expect(resp.StatusCode).To(Equal(http.StatusAccepted))
+			Expect(resp.StopExecution).To(BeTrue())
+			Expect(resp.Headers.Get("Location")).To(Equal("/jobs/1"))
+		})
+
+		It("should write the 202 and Location header when used in a chain", func() {
+			h := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+				return Accepted("/jobs/1")
+			}, successHandler})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusAccepted))
+			Expect(response.Header().Get("Location")).To(Equal("/jobs/1"))
+		})
+	})
+
+	Describe("XML", func() {
+		type item struct {
+			Name string `xml:"name"`
+		}
+
+		It("should return a stopping response with the marshalled XML", func() {
+			resp := XML(http.StatusCreated, &item{Name: "rye"})
+			Expect(resp.Err).To(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+			Expect(resp.StopExecution).To(BeTrue())
+			Expect(resp.ContentType).To(Equal("application/xml"))
+			Expect(resp.StatusContent).To(ContainSubstring("<item><name>rye</name></item>"))
+		})
+
+		It("should write the XML body when used in a chain", func() {
+			h := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+				return XML(http.StatusOK, &item{Name: "rye"})
+			}})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("Content-Type")).To(Equal("application/xml"))
+			Expect(response.Body.String()).To(ContainSubstring("<item><name>rye</name></item>"))
+		})
+
+		It("should return a 500 when the value can't be marshalled", func() {
+			resp := XML(http.StatusOK, make(chan int))
+			Expect(resp.StopExecution).To(BeFalse())
+			Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+			Expect(resp.Error()).To(ContainSubstring("Unable to marshal XML"))
+		})
+	})
+
+	Describe("Text", func() {
+		It("should return a stopping plain text response", func() {
+			resp := Text(http.StatusServiceUnavailable, "down for maintenance")
+			Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+			Expect(resp.StopExecution).To(BeTrue())
+			Expect(resp.ContentType).To(Equal("text/plain; charset=utf-8"))
+			Expect(resp.StatusContent).To(Equal("down for maintenance"))
+		})
+
+		It("should write the text body when used in a chain", func() {
+			h := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+				return Text(http.StatusOK, "ok")
+			}})
+			h.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("Content-Type")).To(Equal("text/plain; charset=utf-8"))
+			Expect(response.Body.String()).To(Equal("ok"))
+		})
+	})
+})
@@ -9,24 +9,196 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	//log "github.com/Sirupsen/logrus"
 	"github.com/cactus/go-statsd-client/statsd"
 )
 
+const (
+	// Default name of the counter of server errors (see Config.ErrorStatName)
+	DEFAULT_ERROR_STAT_NAME = "errors"
+
+	// Default namespace of the handler stats (see Config.HandlerStatNamespace)
+	DEFAULT_HANDLER_STAT_NAMESPACE = "handlers"
+)
+
 //go:generate counterfeiter -o fakes/statsdfakes/fake_statter.go $GOPATH/src/github.com/cactus/go-statsd-client/statsd/client.go Statter
 //go:generate perl -pi -e 's/$GOPATH\/src\///g' fakes/statsdfakes/fake_statter.go
 
 // MWHandler struct is used to configure and access rye's basic functionality.
 type MWHandler struct {
 	Config Config
+
+	closeMu    sync.Mutex
+	closeHooks []func(ctx context.Context) error
+
+	registryMu sync.RWMutex
+	registry   map[string][]Handler
+
+	// draining is set to 1 by Drain (see Drain); accessed atomically
+	draining int32
+
+	errorRates errorRateTracker
 }
 
 // Config struct allows you to set a reference to a statsd.Statter and include it's stats rate.
+// A StatRate left at 0 while a Statter (or Reporter) is set defaults to 1 (every stat is sent).
 type Config struct {
 	Statter  statsd.Statter
 	StatRate float32
+
+	// Reporter, when set, receives the counters and timings instead of Statter (ie. a
+	// PrometheusReporter). Gauges (see InflightGauge) are only sent to it if it
+	// implements GaugeReporter.
+	Reporter MetricsReporter
+
+	// SampleFunc decides the stat rate of each request based on its attributes (ie. to
+	// always sample `/checkout` but only sample 1% of `/ping`), overriding StatRate
+	SampleFunc func(r *http.Request) float32
+
+	// SeparateHeadStats records stats for HEAD requests under `handlers.<name>.head`
+	// so they do not skew the stats of the GET handlers they usually mirror
+	SeparateHeadStats bool
+
+	// OutcomeClassifier decides whether the Response returned by a handler is a success,
+	// a client/server error or a stopped chain, which drives the status stat and the
+	// `errors` counter. Defaults to DefaultOutcomeClassifier.
+	OutcomeClassifier func(resp *Response, finalStatus int) Outcome
+
+	// DefaultResponseHeaders are added to every response unless a handler
+	// sets a header with the same name (ie. `X-Service-Version`)
+	DefaultResponseHeaders http.Header
+
+	// DefaultContentType is set on responses whose handlers write a body without a
+	// `Content-Type` (ie. `application/json`). When empty, Go sniffs the content type.
+	DefaultContentType string
+
+	// LatencyBuckets enables bucketed latency counters (`handlers.<name>.latency_bucket.le_100ms`)
+	// for statsd backends without native histograms. Each request increments the smallest
+	// bucket its runtime fits in (or `le_inf`).
+	LatencyBuckets []time.Duration
+
+	// DisableTiming turns off timing stats (ie. `handlers.<name>.runtime`) while
+	// keeping counters; DisableCount does the opposite. Both are finer-grained
+	// than sampling through StatRate.
+	DisableTiming bool
+	DisableCount  bool
+
+	// InflightGauge records the number of requests each chain is currently executing
+	// as the `handlers.<name>.inflight` gauge, where `<name>` is the name of the
+	// first handler in the chain
+	InflightGauge bool
+
+	// DryRun enables a mode in which chains run in full but nothing is written to the
+	// client; instead, what would have been written is reported to this callback.
+	// Stats are still recorded.
+	DryRun func(result DryRunResult)
+
+	// MaxErrorMessageLength truncates error messages written to the client to the given
+	// number of characters (followed by an ellipsis). The full error is left untouched on
+	// the Response. Zero means no limit.
+	MaxErrorMessageLength int
+
+	// ErrorRateWindow enables an in-process rolling window of the server errors of each
+	// handler, exposed through MWHandler.ErrorRate (ie. for admin or health endpoints)
+	ErrorRateWindow time.Duration
+
+	// StatPrefix is prepended to the stats of every chain (ie. `myservice.v2` records
+	// `myservice.v2.handlers.<name>.2xx`); see HandleWithStatPrefix to override it per chain
+	StatPrefix string
+
+	// ErrorStatName names the counter of server errors (defaults to DEFAULT_ERROR_STAT_NAME)
+	ErrorStatName string
+
+	// HandlerStatNamespace replaces `handlers` in the handler stats (ie. `api` records
+	// `api.<name>.2xx`; defaults to DEFAULT_HANDLER_STAT_NAMESPACE). PrometheusReporter
+	// only recognizes handler stats under the default namespace.
+	HandlerStatNamespace string
+
+	// ErrorRenderer, when set, writes out the Responses carrying an error instead of
+	// the default JSONStatus body (ie. JSONErrorRenderer). MaxErrorMessageLength
+	// only applies to the default body.
+	ErrorRenderer func(rw http.ResponseWriter, resp *Response)
+
+	// OnError, when set, is called with every Response carrying an error (including the
+	// 500 answering a malformed Response, see StrictResponses) before the error is
+	// written out, ie. to report errors to an error tracker in a single place
+	OnError func(r *http.Request, resp *Response)
+
+	// Tracer enables tracing: each request gets a span covering the whole chain (carrying
+	// the baggage items of the incoming `baggage` header), with a child span per handler.
+	Tracer Tracer
+
+	// PreHandlers run before the handlers of every chain and PostHandlers after them
+	// (ie. logging or auth middleware that every route needs)
+	PreHandlers  []Handler
+	PostHandlers []Handler
+
+	// AlwaysRunPostHandlers runs PostHandlers even when the chain was stopped or failed
+	// before reaching them, the way the after handlers of HandleWithAfter run
+	AlwaysRunPostHandlers bool
+
+	// UnifiedSampling makes a single sampling decision per request (see SamplingDecision)
+	// which drives stats, tracing and logging alike: unsampled requests send no stats,
+	// start no spans and skip the route logger (the error logger still logs every failed
+	// request). As rye samples the stats itself, the sampler of
+	// the Statter (if it has one) is set to keep every stat.
+	UnifiedSampling bool
+
+	// SamplingDebugHeader names a header forcing requests carrying it to be sampled
+	// (ie. `X-Debug`) when UnifiedSampling is set
+	SamplingDebugHeader string
+
+	// MaxChainDepth caps how deeply chains can be nested (ie. a handler serving the
+	// request through another chain) so that chains accidentally nested in a cycle
+	// fail with a 500 instead of overflowing the stack. Defaults to
+	// DEFAULT_MAX_CHAIN_DEPTH; a negative value disables the check.
+	MaxChainDepth int
+
+	// Logger, when set, logs every handler invocation (its name, status, runtime and
+	// error) at a level matching its outcome; unsampled requests are not logged (see
+	// UnifiedSampling). StdLogger is a simple Logger backed by the standard log package.
+	Logger Logger
+
+	// BodyReadTiming records the time each handler spent blocked reading the request
+	// body as `handlers.<name>.body_read_time`, telling slow uploads apart from slow
+	// processing. Handlers that don't read the body record nothing.
+	BodyReadTiming bool
+
+	// ChainRuntimeStat names the timing stat recording the runtime of the whole chain,
+	// middleware overhead included (defaults to `handlers.<name>.chain.runtime`, where
+	// `<name>` is the name of the first handler in the chain)
+	ChainRuntimeStat string
+
+	// ExecutedCount records how many handlers of the chain ran before it finished
+	// (or was stopped) as the `handlers.<name>.executed_count` counter, where `<name>`
+	// is the name of the first handler in the chain. After handlers and handlers
+	// skipped by When are not counted.
+	ExecutedCount bool
+
+	// StrictResponses answers a Response setting none of its fields (neither `Err`, `StopExecution`,
+	// a successful `StatusCode`, `Context` nor `Writer`) with a 500, as it is most likely a bug in the
+	// handler that returned it. It defaults to true; when set to false, such a Response is only logged
+	// as a warning (through Logger, or logrus if unset) and the chain carries on. Beware that this
+	// lets handler bugs go unnoticed by clients (ie. a handler that meant to stop the chain).
+	StrictResponses *bool
+
+	// SyncStats sends stats inline, in the order they are recorded, instead of in the
+	// background: they have all been sent by the time ServeHTTP returns, which makes
+	// them easy to assert on in tests. Every request then waits on the statter (ie. a
+	// statsd client flushing its buffer), so it is best left off in production.
+	SyncStats bool
+
+	// DrainRetryAfter is the `Retry-After` sent along with the 503 answering requests
+	// while draining (see MWHandler.Drain); defaults to DEFAULT_DRAIN_RETRY_AFTER
+	DrainRetryAfter time.Duration
+
+	// ChainSpanName names the chain span (defaults to `chain.<name>`, where `<name>`
+	// is the name of the first handler in the chain)
+	ChainSpanName string
 }
 
 // JSONStatus is a simple container used for conveying status messages.
@@ -39,11 +211,31 @@ type JSONStatus struct {
 // ie. a middleware can return a *Response as a way to indicate
 // that further middleware execution should stop (without an error) or return a
 // a hard error by setting `Err` + `StatusCode`.
+//
+// A Response carrying a 2xx or 3xx `StatusCode` (and no error) stops execution as well,
+// so a handler can finish the request with a 204 without setting `StopExecution`.
+//
+// A middleware may also return a `Writer` to replace the http.ResponseWriter that is
+// passed to the remaining handlers in the chain (ie. to buffer or transform the response).
+// `Headers` are added to the response before the status code is written (whether
+// execution is stopped or an error is returned) and, when stopping execution, `StatusContent` is written out as the body (with `ContentType`),
+// unless `Err` is also set, in which case the error is written out instead.
+// A `Context` returned along with `StopExecution` still applies to the request seen by
+// the after handlers (see HandleWithAfter) and the Logger.
+//
+// A Response with a `RedirectTo` URL (and no error) redirects the client there with
+// `RedirectCode` (a 302 unless it is a 3xx redirect code) and stops execution; see NewRedirect.
 type Response struct {
 	Err           error
 	StatusCode    int
 	StopExecution bool
 	Context       context.Context
+	Writer        http.ResponseWriter
+	Headers       http.Header
+	StatusContent string
+	ContentType   string
+	RedirectTo    string
+	RedirectCode  int
 }
 
 // Error bubbles a response error providing an implementation of the Error interface.
@@ -52,6 +244,37 @@ func (r *Response) Error() string {
 	return r.Err.Error()
 }
 
+// write writes out the headers, status code and content of a stopping response
+func (r *Response) write(rw http.ResponseWriter) {
+	r.writeHeaders(rw)
+
+	if r.ContentType != "" {
+		rw.Header().Set("Content-Type", r.ContentType)
+	}
+
+	statusCode := r.StatusCode
+	if statusCode == 0 && r.StatusContent != "" {
+		statusCode = http.StatusOK
+	}
+
+	if statusCode != 0 {
+		rw.WriteHeader(statusCode)
+	}
+
+	if r.StatusContent != "" {
+		rw.Write([]byte(r.StatusContent))
+	}
+}
+
+// writeHeaders copies the response headers onto the ResponseWriter
+func (r *Response) writeHeaders(rw http.ResponseWriter) {
+	for key, values := range r.Headers {
+		for _, value := range values {
+			rw.Header().Add(key, value)
+		}
+	}
+}
+
 // Handler is the primary type that any rye middleware must implement to be called in the Handle() function.
 // In order to use this you must return a *rye.Response.
 type Handler func(w http.ResponseWriter, r *http.Request) *Response
@@ -59,82 +282,470 @@ type Handler func(w http.ResponseWriter, r *http.Request) *Response
 // Constructor for new instantiating new rye instances
 // It returns a constructed *MWHandler instance.
 func NewMWHandler(config Config) *MWHandler {
-	return &MWHandler{
+	// A zero stat rate is almost certainly an oversight; statsd servers
+	// interpret it inconsistently, so send everything instead
+	if config.StatRate == 0 && (config.Statter != nil || config.Reporter != nil) {
+		config.StatRate = 1
+	}
+
+	if config.ErrorStatName == "" {
+		config.ErrorStatName = DEFAULT_ERROR_STAT_NAME
+	}
+
+	if config.HandlerStatNamespace == "" {
+		config.HandlerStatNamespace = DEFAULT_HANDLER_STAT_NAMESPACE
+	}
+
+	m := &MWHandler{
 		Config: config,
 	}
+
+	if config.UnifiedSampling {
+		m.useUnifiedSampling()
+	}
+
+	return m
 }
 
 // The Handle function is the primary way to set up your chain of middlewares to be called by rye.
 // It returns a http.HandlerFunc from net/http that can be set as a route in your http server.
 func (m *MWHandler) Handle(handlers []Handler) http.Handler {
+	return m.handle(handlers, nil, m.Config.StatPrefix)
+}
+
+// HandleF works exactly like Handle, taking the handlers as arguments rather than as a slice:
+//
+//	routes.Handle("/some/route", mwHandler.HandleF(rye.NewMiddlewareJWT(secret), yourHandler))
+func (m *MWHandler) HandleF(handlers ...Handler) http.HandlerFunc {
+	return m.handle(handlers, nil, m.Config.StatPrefix).ServeHTTP
+}
+
+// HandleWithStatPrefix works like Handle, but prefixes the stats of the chain with the given
+// prefix instead of Config.StatPrefix (ie. to tell apart a handler mounted on several routes).
+func (m *MWHandler) HandleWithStatPrefix(prefix string, handlers []Handler) http.Handler {
+	return m.handle(handlers, nil, prefix)
+}
+
+// HandleWithAfter works like Handle, but the after handlers always run (in order) once the
+// before chain is done, however it ended - even when a handler stopped the chain or returned
+// an error. This makes them a good fit for cleanup and logging; use FinalResponse to find out
+// how the chain ended. After handlers are recorded in stats just like the rest of the chain.
+func (m *MWHandler) HandleWithAfter(before []Handler, after []Handler) http.Handler {
+	return m.handle(before, after, m.Config.StatPrefix)
+}
+
+// HandlerFor works exactly like Handle; it reads better where the chain is passed on to a
+// router or wrapped by other http.Handler middleware.
+func (m *MWHandler) HandlerFor(handlers []Handler) http.Handler {
+	return m.handle(handlers, nil, m.Config.StatPrefix)
+}
+
+func (m *MWHandler) handle(handlers []Handler, after []Handler, statPrefix string) http.Handler {
+	var chainName string
+	if len(handlers) > 0 {
+		chainName = handlerName(handlers[0])
+	}
+
+	// Handler names are resolved once, rather than on every request
+	chain, afterChain := resolveNames(m.withGlobalHandlers(handlers, after))
+
+	if statPrefix != "" && !strings.HasSuffix(statPrefix, ".") {
+		statPrefix += "."
+	}
+
+	namespace := m.Config.HandlerStatNamespace + "."
+
+	inflight := &inflightGauge{name: statPrefix + namespace + chainName + ".inflight"}
+
+	chainRuntimeStat := m.Config.ChainRuntimeStat
+	if chainRuntimeStat == "" {
+		chainRuntimeStat = namespace + chainName + ".chain.runtime"
+	}
+	chainRuntimeStat = statPrefix + chainRuntimeStat
+
+	executedCountStat := statPrefix + namespace + chainName + ".executed_count"
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		for _, handler := range handlers {
+		depth := chainDepth(r)
+		if max := m.maxChainDepth(); max > 0 && depth > max {
+			m.rejectTooDeep(w, r, max)
+			return
+		}
+
+		var (
+			finalizers []finalizer
+			guard      *stopGuardWriter
+		)
+
+		// In dry run mode nothing reaches the client; the recorded
+		// response is reported once the chain (and finalizers) are done
+		if m.Config.DryRun != nil {
+			recorder := newRecordingResponseWriter()
+			w = recorder
+
+			defer func() {
+				m.Config.DryRun(recorder.dryRunResult())
+			}()
+		}
+
+		// Only new requests are turned away; chains nested in one already
+		// being handled let it complete
+		if depth == 1 && m.Draining() {
+			m.rejectDraining(w, r, statPrefix)
+			return
+		}
+
+		if m.Config.InflightGauge {
+			m.trackInflight(inflight, 1)
+			defer m.trackInflight(inflight, -1)
+		}
+
+		if debugMode {
+			guard = newStopGuardWriter(w)
+			w = guard
+		}
+
+		state := &chainState{
+			name:       chainName,
+			depth:      depth,
+			start:      time.Now(),
+			mw:         m,
+			statRate:   m.statRate(r),
+			statPrefix: statPrefix,
+		}
+		if debugMode {
+			state.emitted = &emittedMetrics{}
+		}
+
+		r = withChainState(r, state)
+
+		if m.Config.BodyReadTiming && r.Body != nil && r.Body != http.NoBody {
+			r.Body = &timedBodyReader{ReadCloser: r.Body, chain: state}
+		}
+
+		if m.Config.UnifiedSampling {
+			decision := m.decideSampling(r)
+			state.sampling = &decision
+			state.statRate = decision.Rate
+		}
+
+		// The request cache only lives as long as the chain
+		defer state.cache.clear()
+
+		if m.Config.Tracer != nil && state.sampled() {
+			state.chainSpan = m.startChainSpan(chainName, r)
+			state.span = state.chainSpan
+			defer state.chainSpan.Finish()
+		}
+
+		// Give wrapped writers a chance to flush once the chain is done,
+		// starting with the innermost one
+		defer func() {
+			guard.setFinalizing(true)
+			defer guard.setFinalizing(false)
+
+			for i := len(finalizers) - 1; i >= 0; i-- {
+				finalizers[i].finalize()
+			}
+		}()
+
+		if len(m.Config.DefaultResponseHeaders) > 0 || m.Config.DefaultContentType != "" {
+			dw := newResponseDefaultsWriter(w, m.Config.DefaultResponseHeaders, m.Config.DefaultContentType)
+			finalizers = append(finalizers, dw)
+			w = dw
+		}
+
+		capture := newStatusCapturingResponseWriter(w)
+		w = capture
+
+		// run executes a single handler and records its stats
+		run := func(handler chainHandler) *Response {
 			var resp *Response
 
 			// Record handler runtime
 			func() {
-				statusCode := "2xx"
+				name := handler.name
 				startTime := time.Now()
+				readTime := atomic.LoadInt64(&state.bodyReadTime)
+				unwritten := capture.status == 0
+				state.handler = name
+				state.statName = ""
+				state.skipped = false
+
+				if state.chainSpan != nil {
+					state.startHandlerSpan(name)
+					defer state.finishHandlerSpan()
+				}
 
-				if resp = handler(w, r); resp != nil {
+				if resp = callHandler(handler.handler, w, r, state); resp != nil {
 					func() {
-						// Stop execution if it's passed
-						if resp.StopExecution {
-							return
+						// A redirect stops execution with a valid redirect status code
+						if resp.RedirectTo != "" && resp.Err == nil {
+							resp.StatusCode = redirectCode(resp.RedirectCode)
+						}
+
+						// A successful (2xx or 3xx) status code finishes the
+						// request, as if execution was stopped
+						if resp.Err == nil && resp.StatusCode >= 200 && resp.StatusCode < 400 {
+							resp.StopExecution = true
 						}
 
-						// If a context is returned, we will
-						// replace the current request with a new request
+						// A returned context applies even when execution is
+						// stopped, so the after handlers and loggers see it
 						if resp.Context != nil {
 							r = r.WithContext(resp.Context)
+						}
+
+						// Stop execution if it's passed (writing out the status
+						// code and content if given); an error takes precedence
+						if resp.StopExecution && resp.Err == nil {
+							if resp.RedirectTo != "" {
+								resp.writeHeaders(w)
+								http.Redirect(w, r, resp.RedirectTo, resp.StatusCode)
+							} else {
+								resp.write(w)
+							}
+							guard.stop(name)
+							return
+						}
+
+						// If a writer is returned, the rest of
+						// the chain will write to it instead
+						if resp.Writer != nil {
+							w = resp.Writer
+
+							if f, ok := resp.Writer.(finalizer); ok {
+								finalizers = append(finalizers, f)
+							}
+						}
+
+						// If a context is returned, the current request
+						// has been replaced with a new request
+						if resp.Context != nil {
+							return
+						}
+
+						if resp.Writer != nil {
 							return
 						}
 
 						// If there's no error but we have a response
 						if resp.Err == nil {
+							if !m.strictResponses() {
+								m.warnMalformedResponse(r, name)
+								return
+							}
+
 							resp.Err = errors.New("Problem with middleware; neither Err or StopExecution is set")
 							resp.StatusCode = http.StatusInternalServerError
 						}
 
-						// Now assume we have an error.
-						if m.Config.Statter != nil && resp.StatusCode >= 500 {
-							go m.Config.Statter.Inc("errors", 1, m.Config.StatRate)
+						if m.Config.OnError != nil {
+							m.Config.OnError(r, resp)
 						}
 
-						// Write the error out
-						statusCode = strconv.Itoa(resp.StatusCode)
-						WriteJSONStatus(w, "error", resp.Error(), resp.StatusCode)
+						// Now assume we have an error; write it out
+						// (along with the response headers)
+						resp.writeHeaders(w)
+
+						if m.Config.ErrorRenderer != nil {
+							m.Config.ErrorRenderer(w, resp)
+						} else {
+							writeErrorStatus(w, r, truncateMessage(resp.Error(), m.Config.MaxErrorMessageLength), resp.StatusCode)
+						}
 					}()
 				}
 
-				handlerName := getFuncName(handler)
+				// Handlers skipped by When leave no trace
+				if state.skipped {
+					return
+				}
+
+				elapsed := time.Since(startTime)
+
+				// The status this handler wrote itself (if it is the one that wrote it)
+				var written int
+				if unwritten {
+					written = capture.status
+				}
+
+				state.timings = append(state.timings, handlerTiming{
+					name:     name,
+					duration: elapsed,
+				})
+
+				if m.Config.ErrorRateWindow > 0 {
+					m.errorRates.record(name, m.classify(resp) == OUTCOME_SERVER_ERROR, m.Config.ErrorRateWindow, time.Now())
+				}
+
+				if m.Config.Logger != nil && state.sampled() {
+					m.logHandler(r, name, resp, elapsed)
+				}
+
+				if m.reporter() != nil && state.sampled() {
+					statName := state.statName
+					if statName == "" {
+						statName = namespace + name
+					}
+					statName = statPrefix + statName
+
+					if m.Config.SeparateHeadStats && r.Method == http.MethodHead {
+						statName += ".head"
+					}
+
+					outcome := m.classify(resp)
+
+					// Successful calls are recorded as 2xx, anything else with its exact
+					// status code (if there is one), rolled up as 4xx or 5xx as well
+					var status int
+					statusCode, statusClass := "2xx", ""
+					switch {
+					case resp != nil && resp.StatusCode != 0:
+						if outcome != OUTCOME_SUCCESS {
+							status = resp.StatusCode
+						}
+					case resp != nil && resp.Err != nil && outcome != OUTCOME_SUCCESS:
+						// Errors without a status code have always
+						// been recorded as such (ie. `handlers.<name>.0`)
+						statusCode = "0"
+					case StatusClass(written) != "2xx":
+						// Handlers writing the response themselves are
+						// recorded with the status they wrote
+						status = written
+					}
+
+					if status != 0 {
+						statusCode = strconv.Itoa(status)
+
+						if class := StatusClass(status); class == "4xx" || class == "5xx" {
+							statusClass = class
+						}
+					}
+
+					if outcome == OUTCOME_SERVER_ERROR {
+						state.inc(m.Config.ErrorStatName)
+					}
+
+					if outcome == OUTCOME_CLIENT_ERROR {
+						state.inc("client_errors")
+					}
 
-				if m.Config.Statter != nil {
 					// Record runtime metric
-					go m.Config.Statter.TimingDuration(
-						"handlers."+handlerName+".runtime",
-						time.Since(startTime), // delta
-						m.Config.StatRate,
-					)
+					state.timing(statName+".runtime", elapsed)
+
+					// Record body read time metric (if enabled)
+					if m.Config.BodyReadTiming {
+						if read := time.Duration(atomic.LoadInt64(&state.bodyReadTime) - readTime); read > 0 {
+							state.timing(statName+".body_read_time", read)
+						}
+					}
+
+					// Record latency bucket metric (if enabled)
+					if len(m.Config.LatencyBuckets) > 0 {
+						state.inc(statName + ".latency_bucket." + latencyBucket(m.Config.LatencyBuckets, elapsed))
+					}
 
 					// Record status code metric (default 2xx)
-					go m.Config.Statter.Inc(
-						"handlers."+handlerName+"."+statusCode,
-						1,
-						m.Config.StatRate,
-					)
+					state.inc(statName + "." + statusCode)
+
+					// Record rolled-up status code metric (if 4xx or 5xx)
+					if statusClass != "" {
+						state.inc(statName + "." + statusClass)
+					}
 				}
 			}()
 
+			return resp
+		}
+
+		chainStart := time.Now()
+		executed := 0
+
+		for _, handler := range chain {
+			// Stop if the deadline set by a timeout middleware has passed
+			if state.deadline != nil && state.deadline.Err() != nil {
+				break
+			}
+
+			resp := run(handler)
+
+			if !state.skipped {
+				executed++
+			}
+
+			if resp != nil && resp.Err != nil {
+				state.err = resp.Err
+			}
+
 			// stop executing rest of the
 			// handlers if we encounter an error
 			if resp != nil && (resp.StopExecution || resp.Err != nil) {
-				return
+				state.final = resp
+				break
 			}
 		}
+
+		// After handlers run no matter how the chain ended
+		for _, handler := range afterChain {
+			run(handler)
+		}
+
+		// Record chain runtime
+		if m.reporter() != nil && state.sampled() {
+			state.timing(chainRuntimeStat, time.Since(chainStart))
+		}
+
+		if m.Config.ExecutedCount {
+			state.count(executedCountStat, int64(executed))
+		}
 	})
 }
 
+// strictResponses reports whether malformed Responses fail the request (see Config.StrictResponses)
+func (m *MWHandler) strictResponses() bool {
+	return m.Config.StrictResponses == nil || *m.Config.StrictResponses
+}
+
+// warnMalformedResponse logs a Response setting none of its fields (see Config.StrictResponses)
+func (m *MWHandler) warnMalformedResponse(r *http.Request, name string) {
+	logger := m.Config.Logger
+	if logger == nil {
+		logger = LogrusLogger{}
+	}
+
+	entry := LogEntry{
+		Level:     LOG_LEVEL_WARN,
+		Message:   "Problem with middleware; neither Err or StopExecution is set",
+		Method:    r.Method,
+		Handler:   name,
+		RequestID: requestIDOf(r, DEFAULT_REQUEST_ID_HEADER),
+	}
+
+	if r.URL != nil {
+		entry.Path = r.URL.Path
+	}
+
+	logger.Log(entry)
+}
+
+// withGlobalHandlers surrounds the handlers of a chain with Config.PreHandlers and Config.PostHandlers;
+// the latter go to the after handlers when Config.AlwaysRunPostHandlers is set
+func (m *MWHandler) withGlobalHandlers(handlers, after []Handler) ([]Handler, []Handler) {
+	if len(m.Config.PreHandlers) == 0 && len(m.Config.PostHandlers) == 0 {
+		return handlers, after
+	}
+
+	chain := make([]Handler, 0, len(m.Config.PreHandlers)+len(handlers)+len(m.Config.PostHandlers))
+	chain = append(chain, m.Config.PreHandlers...)
+	chain = append(chain, handlers...)
+
+	if !m.Config.AlwaysRunPostHandlers {
+		return append(chain, m.Config.PostHandlers...), after
+	}
+
+	return chain, append(append([]Handler(nil), m.Config.PostHandlers...), after...)
+}
+
 // WriteJSONStatus is a wrapper for WriteJSONResponse that returns a marshalled JSONStatus blob
 func WriteJSONStatus(rw http.ResponseWriter, status, message string, statusCode int) {
 	jsonData, _ := json.Marshal(&JSONStatus{
@@ -145,6 +756,41 @@ func WriteJSONStatus(rw http.ResponseWriter, status, message string, statusCode
 	WriteJSONResponse(rw, statusCode, jsonData)
 }
 
+// writeErrorStatus writes out an error in the content type the client prefers (see
+// NegotiateErrorContentType): as a JSONStatus blob, or as plain text
+func writeErrorStatus(rw http.ResponseWriter, r *http.Request, message string, statusCode int) {
+	if NegotiateErrorContentType(r) != ERROR_CONTENT_TYPE_TEXT {
+		WriteJSONStatus(rw, "error", message, statusCode)
+		return
+	}
+
+	rw.Header().Set("Content-Type", ERROR_CONTENT_TYPE_TEXT)
+	rw.WriteHeader(statusCode)
+	rw.Write([]byte(message))
+}
+
+// JSONError is the body written by JSONErrorRenderer.
+type JSONError struct {
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+// JSONErrorRenderer is an ErrorRenderer writing the error as a JSONError blob
+// (ie. `{"status":505,"error":"Foo"}`); a missing status code is rendered as a 500
+func JSONErrorRenderer(rw http.ResponseWriter, resp *Response) {
+	statusCode := resp.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusInternalServerError
+	}
+
+	jsonData, _ := json.Marshal(&JSONError{
+		Status: statusCode,
+		Error:  resp.Error(),
+	})
+
+	WriteJSONResponse(rw, statusCode, jsonData)
+}
+
 // WriteJSONResponse writes data and status code to the ResponseWriter
 func WriteJSONResponse(rw http.ResponseWriter, statusCode int, content []byte) {
 	rw.Header().Set("Content-Type", "application/json")
@@ -153,14 +799,75 @@ func WriteJSONResponse(rw http.ResponseWriter, statusCode int, content []byte) {
 }
 
 // getFuncName uses reflection to determine a given function name
-// It returns a string version of the function name (and performs string cleanup)
+// It returns a string version of the function name (see normalizeFuncName)
 func getFuncName(i interface{}) string {
-	fullName := runtime.FuncForPC(reflect.ValueOf(i).Pointer()).Name()
-	ns := strings.Split(fullName, ".")
+	return normalizeFuncName(runtime.FuncForPC(reflect.ValueOf(i).Pointer()).Name())
+}
+
+// normalizeFuncName cleans up the name the runtime gives a function: the package path is
+// stripped (`github.com/foo/bar.List` becomes `List`), methods are named after their receiver
+// type (`bar.(*Controller).List-fm` becomes `Controller.List`) and closures keep their
+// compiler given name from the first `funcN` on (`bar.outer.func1` becomes `func1`).
+func normalizeFuncName(fullName string) string {
+	// Type parameters may contain package paths too; only look for the
+	// package path up to them
+	path := fullName
+	if i := strings.Index(path, "["); i >= 0 {
+		path = path[:i]
+	}
+
+	name := fullName[strings.LastIndex(path, "/")+1:]
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
 
 	// when we get a method (not a raw function) it comes attached to whatever struct is in its
 	// method receiver via a function closure, this is not precisely the same as that method itself
 	// so the compiler appends "-fm" so the name of the closure does not conflict with the actual function
 	// http://grokbase.com/t/gg/golang-nuts/153jyb5b7p/go-nuts-fm-suffix-in-function-name-what-does-it-mean#20150318ssinqqzrmhx2ep45wjkxsa4rua
-	return strings.TrimSuffix(ns[len(ns)-1], ")-fm")
+	name = strings.TrimSuffix(name, "-fm")
+
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if isClosureName(part) {
+			return strings.Join(parts[i:], ".")
+		}
+	}
+
+	// Pointer receivers come as `(*Controller)`
+	parts[0] = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(parts[0], "("), "*"), ")")
+
+	return strings.Join(parts, ".")
+}
+
+// isClosureName reports whether a part of a function name is the name the compiler
+// gives closures (`func1`, or just `1` when nested)
+func isClosureName(part string) bool {
+	digits := strings.TrimPrefix(part, "func")
+	if digits == "" {
+		return false
+	}
+
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// truncateMessage shortens the message to max characters followed by an ellipsis
+// (a max of zero or less leaves the message untouched)
+func truncateMessage(msg string, max int) string {
+	if max <= 0 {
+		return msg
+	}
+
+	runes := []rune(msg)
+	if len(runes) <= max {
+		return msg
+	}
+
+	return string(runes[:max]) + "..."
 }
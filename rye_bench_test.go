@@ -0,0 +1,40 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cactus/go-statsd-client/statsd"
+)
+
+func benchmarkHandler(rw http.ResponseWriter, r *http.Request) *Response {
+	return nil
+}
+
+// benchmarkHandle serves requests through a chain of a few handlers built with the given config
+func benchmarkHandle(b *testing.B, config Config) {
+	h := NewMWHandler(config).Handle([]Handler{benchmarkHandler, benchmarkHandler, benchmarkHandler})
+	request := httptest.NewRequest("GET", "/", nil)
+	response := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		h.ServeHTTP(response, request)
+	}
+}
+
+func BenchmarkHandle_NoStatter(b *testing.B) {
+	benchmarkHandle(b, Config{})
+}
+
+func BenchmarkHandle_WithStatter(b *testing.B) {
+	statter, err := statsd.NewNoopClient()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	benchmarkHandle(b, Config{Statter: statter, SyncStats: true})
+}
@@ -5,14 +5,26 @@ import (
 
 	"github.com/Sirupsen/logrus"
 
-	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
+// rye declares a When of its own, which rules out dot-importing ginkgo; the specs of the
+// package use its DSL through these instead
+var (
+	Describe      = ginkgo.Describe
+	Context       = ginkgo.Context
+	It            = ginkgo.It
+	BeforeEach    = ginkgo.BeforeEach
+	AfterEach     = ginkgo.AfterEach
+	GinkgoRecover = ginkgo.GinkgoRecover
+	GinkgoWriter  = ginkgo.GinkgoWriter
+)
+
 func TestAPISuite(t *testing.T) {
 	// reduce the noise when testing
 	logrus.SetLevel(logrus.FatalLevel)
 
-	RegisterFailHandler(Fail)
-	RunSpecs(t, "Rye Suite")
+	RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Rye Suite")
 }
@@ -1,17 +1,21 @@
 package rye
 
 import (
-	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/InVisionApp/rye/fakes/statsdfakes"
 	"github.com/onsi/gomega/types"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -99,67 +103,910 @@ var _ = Describe("Rye", func() {
 				Expect(handler.Config.Statter).To(BeNil())
 				Expect(handler.Config.StatRate).To(Equal(float32(0.0)))
 			})
+
+			It("should default the StatRate to 1 when a Statter is set", func() {
+				handler := NewMWHandler(Config{Statter: fakeStatter})
+				Expect(handler.Config.StatRate).To(Equal(float32(1.0)))
+
+				h := handler.Handle([]Handler{successHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(inc).Should(Receive(&statsInc{"handlers.successHandler.2xx", 1, float32(1.0)}))
+			})
+
+			It("should default the StatRate to 1 when a Reporter is set", func() {
+				handler := NewMWHandler(Config{Reporter: &recordingReporter{}})
+				Expect(handler.Config.StatRate).To(Equal(float32(1.0)))
+			})
+
+			It("should keep an explicit StatRate", func() {
+				handler := NewMWHandler(Config{Statter: fakeStatter, StatRate: 0.25})
+				Expect(handler.Config.StatRate).To(Equal(float32(0.25)))
+			})
+		})
+	})
+
+	Describe("Handle", func() {
+		Context("when adding a valid handler", func() {
+			It("should return valid HandlerFunc", func() {
+
+				h := mwHandler.Handle([]Handler{successHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(h).ToNot(BeNil())
+				Expect(h).To(BeAssignableToTypeOf(func(http.ResponseWriter, *http.Request) {}))
+				Expect(os.Getenv(RYE_TEST_HANDLER_ENV_VAR)).To(Equal("1"))
+
+				Eventually(inc).Should(Receive(&statsInc{"handlers.successHandler.2xx", 1, float32(STATRATE)}))
+				Eventually(timing).Should(Receive(HaveTiming("handlers.successHandler.runtime", float32(STATRATE))))
+			})
+		})
+
+		Context("when a handler returns a response with StopExecution", func() {
+			It("should not execute any further handlers", func() {
+				request.Method = "OPTIONS"
+
+				h := mwHandler.Handle([]Handler{stopExecutionHandler, successHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(os.Getenv(RYE_TEST_HANDLER_ENV_VAR)).ToNot(Equal("1"))
+			})
+		})
+
+		Context("when a handler returns a response with StopExecution and a StatusCode", func() {
+			It("should write the status code and record it in the stats", func() {
+				h := mwHandler.Handle([]Handler{stopWithStatusHandler, successHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(os.Getenv(RYE_TEST_HANDLER_ENV_VAR)).ToNot(Equal("1"))
+				Expect(response.Code).To(Equal(http.StatusNotModified))
+				Eventually(inc).Should(Receive(&statsInc{"handlers.stopWithStatusHandler.304", 1, float32(STATRATE)}))
+			})
+		})
+
+		Context("when a handler returns a response with StopExecution and StatusContent", func() {
+			It("should write the content with its content type and status code", func() {
+				h := mwHandler.Handle([]Handler{contentHandler, successHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(os.Getenv(RYE_TEST_HANDLER_ENV_VAR)).ToNot(Equal("1"))
+				Expect(response.Code).To(Equal(http.StatusCreated))
+				Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+				Expect(response.Body.String()).To(Equal(`{"ok":true}`))
+			})
+		})
+
+		Context("when a stopping response carries Headers", func() {
+			It("should set the headers before writing the status code", func() {
+				h := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+					return &Response{
+						StopExecution: true,
+						StatusCode:    http.StatusMovedPermanently,
+						Headers:       http.Header{"Location": []string{"/new"}},
+					}
+				}, successHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(os.Getenv(RYE_TEST_HANDLER_ENV_VAR)).ToNot(Equal("1"))
+				Expect(response.Code).To(Equal(http.StatusMovedPermanently))
+				Expect(response.Header().Get("Location")).To(Equal("/new"))
+			})
+		})
+
+		Context("when an error response carries Headers", func() {
+			It("should set the headers along with the error", func() {
+				h := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+					return &Response{
+						Err:        errors.New("slow down"),
+						StatusCode: http.StatusServiceUnavailable,
+						Headers:    http.Header{"Retry-After": []string{"10"}},
+					}
+				}})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+				Expect(response.Header().Get("Retry-After")).To(Equal("10"))
+				Expect(response.Body.String()).To(ContainSubstring("slow down"))
+			})
+		})
+
+		Context("when a stopping response carries both StatusContent and an error", func() {
+			It("should write the error instead of the content and record it in the stats", func() {
+				h := mwHandler.Handle([]Handler{contentWithErrorHandler, successHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(os.Getenv(RYE_TEST_HANDLER_ENV_VAR)).ToNot(Equal("1"))
+				Expect(response.Code).To(Equal(http.StatusInternalServerError))
+				Expect(response.Body.String()).ToNot(ContainSubstring(`"ok"`))
+				Expect(response.Body.String()).To(ContainSubstring("content failed"))
+
+				var names []string
+				Eventually(func() []string {
+					select {
+					case stat := <-inc:
+						names = append(names, stat.Name)
+					default:
+					}
+					return names
+				}).Should(ContainElements("handlers.contentWithErrorHandler.500", "errors"))
+			})
+		})
+
+		Context("when a handler returns a response with Context", func() {
+			It("should add that new context to the next passed request", func() {
+				h := mwHandler.Handle([]Handler{contextHandler, checkContextHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(os.Getenv(RYE_TEST_HANDLER_ENV_VAR)).To(Equal("1"))
+			})
+		})
+
+		Context("when a handler returns a response with Context and StopExecution", func() {
+			stopWithContextHandler := func(rw http.ResponseWriter, r *http.Request) *Response {
+				return &Response{
+					Context:       context.WithValue(r.Context(), "test-val", "exists"),
+					StatusCode:    http.StatusAccepted,
+					StopExecution: true,
+				}
+			}
+
+			It("should stop the chain and write out the response", func() {
+				h := mwHandler.Handle([]Handler{stopWithContextHandler, successHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(os.Getenv(RYE_TEST_HANDLER_ENV_VAR)).ToNot(Equal("1"))
+				Expect(response.Code).To(Equal(http.StatusAccepted))
+			})
+
+			It("should apply the context for the after handlers", func() {
+				h := mwHandler.HandleWithAfter([]Handler{stopWithContextHandler, successHandler}, []Handler{checkContextHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(os.Getenv(RYE_TEST_HANDLER_ENV_VAR)).To(Equal("1"))
+				Expect(response.Code).To(Equal(http.StatusAccepted))
+			})
+
+			It("should apply the context for the logger", func() {
+				logger := &recordingLogger{}
+				mwHandler.Config.Logger = logger
+
+				h := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+					return &Response{
+						Context:       context.WithValue(r.Context(), CONTEXT_PATH_TEMPLATE, "/items/:id"),
+						StopExecution: true,
+					}
+				}})
+				h.ServeHTTP(response, request)
+
+				Expect(logger.entries).To(HaveLen(1))
+				Expect(logger.entries[0].Route).To(Equal("/items/:id"))
+			})
+		})
+
+		Context("when a handler returns a response with Writer", func() {
+			It("should pass that writer to the next handlers", func() {
+				h := mwHandler.Handle([]Handler{writerHandler, checkWriterHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(os.Getenv(RYE_TEST_HANDLER_ENV_VAR)).To(Equal("1"))
+				Expect(response.Code).To(Equal(http.StatusOK))
+			})
+		})
+
+		Context("when a handler returns a response with neither error or StopExecution set", func() {
+			strict, lenient := true, false
+
+			for _, mode := range []struct {
+				name     string
+				strict   *bool
+				code     int
+				carryOn  bool
+				warnings int
+			}{
+				{"by default", nil, http.StatusInternalServerError, false, 0},
+				{"with StrictResponses", &strict, http.StatusInternalServerError, false, 0},
+				{"without StrictResponses", &lenient, http.StatusOK, true, 1},
+			} {
+				mode := mode
+
+				It("should answer "+strconv.Itoa(mode.code)+" "+mode.name, func() {
+					logger := &recordingLogger{}
+					mwHandler.Config.StrictResponses = mode.strict
+					mwHandler.Config.Logger = logger
+
+					var carriedOn bool
+					h := mwHandler.Handle([]Handler{badResponseHandler, func(rw http.ResponseWriter, r *http.Request) *Response {
+						carriedOn = true
+						return nil
+					}})
+					h.ServeHTTP(response, request)
+
+					Expect(response.Code).To(Equal(mode.code))
+					Expect(carriedOn).To(Equal(mode.carryOn))
+
+					var warnings []LogEntry
+					for _, entry := range logger.entries {
+						if entry.Level == LOG_LEVEL_WARN {
+							warnings = append(warnings, entry)
+						}
+					}
+					Expect(warnings).To(HaveLen(mode.warnings))
+
+					if mode.warnings > 0 {
+						Expect(warnings[0].Handler).To(Equal("badResponseHandler"))
+						Expect(warnings[0].Message).To(ContainSubstring("neither Err or StopExecution is set"))
+					}
+				})
+			}
+		})
+
+		Context("when a handler returns a response with a successful StatusCode only", func() {
+			It("should stop the chain cleanly with that status code", func() {
+				h := mwHandler.Handle([]Handler{noContentHandler, successHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusNoContent))
+				Expect(response.Body.Len()).To(BeZero())
+				Expect(os.Getenv(RYE_TEST_HANDLER_ENV_VAR)).ToNot(Equal("1"))
+				Eventually(inc).Should(Receive(&statsInc{"handlers.noContentHandler.204", 1, float32(STATRATE)}))
+			})
+		})
+
+		Context("when a handler writes the response itself", func() {
+			var reporter *recordingReporter
+
+			BeforeEach(func() {
+				reporter = &recordingReporter{}
+				mwHandler = NewMWHandler(Config{Reporter: reporter})
+			})
+
+			It("should record the status code it wrote", func() {
+				h := mwHandler.Handle([]Handler{notFoundWriterHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusNotFound))
+				Eventually(reporter.recordedIncs).Should(ConsistOf(
+					"handlers.notFoundWriterHandler.404",
+					"handlers.notFoundWriterHandler.4xx",
+				))
+			})
+
+			It("should record a successful status code as 2xx", func() {
+				h := mwHandler.Handle([]Handler{NamedHandler("create", func(rw http.ResponseWriter, r *http.Request) *Response {
+					rw.WriteHeader(http.StatusCreated)
+					return nil
+				}), successHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(reporter.recordedIncs).Should(ConsistOf("handlers.create.2xx", "handlers.successHandler.2xx"))
+			})
+
+			It("should only record the status code for the handler that wrote it", func() {
+				h := mwHandler.Handle([]Handler{notFoundWriterHandler, successHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(reporter.recordedIncs).Should(HaveLen(3))
+				Expect(reporter.recordedIncs()).To(ContainElement("handlers.successHandler.2xx"))
+			})
+
+			It("should prefer the status code of the returned Response", func() {
+				h := mwHandler.Handle([]Handler{NamedHandler("remove", func(rw http.ResponseWriter, r *http.Request) *Response {
+					rw.WriteHeader(http.StatusNotFound)
+					return &Response{Err: errors.New("gone"), StatusCode: http.StatusGone}
+				})})
+				h.ServeHTTP(response, request)
+
+				Eventually(reporter.recordedIncs).Should(ContainElement("handlers.remove.410"))
+				Expect(reporter.recordedIncs()).ToNot(ContainElement("handlers.remove.404"))
+			})
+		})
+
+		Context("when adding an erroneous handler", func() {
+			It("should interrupt handler chain and set a response status code", func() {
+
+				h := mwHandler.Handle([]Handler{failureHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(h).ToNot(BeNil())
+				Expect(h).To(BeAssignableToTypeOf(func(http.ResponseWriter, *http.Request) {}))
+				Expect(response.Code).To(Equal(505))
+				Eventually(inc).Should(Receive(&statsInc{"handlers.failureHandler.505", 1, float32(STATRATE)}))
+				Eventually(inc).Should(Receive(&statsInc{"errors", 1, float32(STATRATE)}))
+				Eventually(timing).Should(Receive(HaveTiming("handlers.failureHandler.runtime", float32(STATRATE))))
+			})
+		})
+
+		Context("when a handler returns an error", func() {
+			var names []string
+
+			collect := func() []string {
+				select {
+				case stat := <-inc:
+					names = append(names, stat.Name)
+				default:
+				}
+				return names
+			}
+
+			BeforeEach(func() {
+				names = nil
+			})
+
+			It("should roll up 5xx codes and count a server error", func() {
+				h := mwHandler.Handle([]Handler{failureHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(collect).Should(ContainElements("handlers.failureHandler.505", "handlers.failureHandler.5xx", "errors"))
+				Consistently(collect).ShouldNot(ContainElement("client_errors"))
+			})
+
+			It("should roll up 4xx codes and count a client error", func() {
+				h := mwHandler.Handle([]Handler{badRequestHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(collect).Should(ContainElements("handlers.badRequestHandler.400", "handlers.badRequestHandler.4xx", "client_errors"))
+				Consistently(collect).ShouldNot(ContainElement("errors"))
+			})
+		})
+
+		Context("when an ErrorRenderer is set", func() {
+			BeforeEach(func() {
+				mwHandler.Config.ErrorRenderer = JSONErrorRenderer
+			})
+
+			It("should render the error with it and record the same stats", func() {
+				h := mwHandler.Handle([]Handler{failureHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(505))
+				Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+				Expect(response.Body.String()).To(MatchJSON(`{"status":505,"error":"Foo"}`))
+				Eventually(inc).Should(Receive(&statsInc{"handlers.failureHandler.505", 1, float32(STATRATE)}))
+				Eventually(inc).Should(Receive(&statsInc{"errors", 1, float32(STATRATE)}))
+				Eventually(timing).Should(Receive(HaveTiming("handlers.failureHandler.runtime", float32(STATRATE))))
+			})
+		})
+
+		Context("when a StatPrefix is set", func() {
+			BeforeEach(func() {
+				mwHandler.Config.StatPrefix = "myservice.v2"
+			})
+
+			It("should prefix the handler stats", func() {
+				h := mwHandler.Handle([]Handler{successHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(inc).Should(Receive(Equal(statsInc{"myservice.v2.handlers.successHandler.2xx", 1, float32(STATRATE)})))
+				Eventually(timing).Should(Receive(HaveTiming("myservice.v2.handlers.successHandler.runtime", float32(STATRATE))))
+			})
+
+			It("should let a chain override the prefix", func() {
+				h := mwHandler.HandleWithStatPrefix("myservice.v1.", []Handler{successHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(inc).Should(Receive(Equal(statsInc{"myservice.v1.handlers.successHandler.2xx", 1, float32(STATRATE)})))
+			})
+		})
+
+		Context("when no StatPrefix is set", func() {
+			It("should keep the default stat names", func() {
+				h := mwHandler.Handle([]Handler{successHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(inc).Should(Receive(Equal(statsInc{"handlers.successHandler.2xx", 1, float32(STATRATE)})))
+				Eventually(timing).Should(Receive(HaveTiming("handlers.successHandler.runtime", float32(STATRATE))))
+			})
+		})
+
+		Context("when global handlers are configured", func() {
+			var (
+				ran   []string
+				track func(name string) Handler
+			)
+
+			BeforeEach(func() {
+				ran = nil
+				track = func(name string) Handler {
+					return func(rw http.ResponseWriter, r *http.Request) *Response {
+						ran = append(ran, name)
+						return nil
+					}
+				}
+			})
+
+			It("should run PreHandlers, then the chain, then PostHandlers", func() {
+				mwHandler.Config.PreHandlers = []Handler{track("pre1"), track("pre2")}
+				mwHandler.Config.PostHandlers = []Handler{track("post")}
+
+				h := mwHandler.Handle([]Handler{track("handler1"), track("handler2")})
+				h.ServeHTTP(response, request)
+
+				Expect(ran).To(Equal([]string{"pre1", "pre2", "handler1", "handler2", "post"}))
+			})
+
+			It("should leave chains alone when they are empty", func() {
+				mwHandler.Config.PreHandlers = []Handler{}
+				mwHandler.Config.PostHandlers = nil
+
+				h := mwHandler.Handle([]Handler{track("handler")})
+				h.ServeHTTP(response, request)
+
+				Expect(ran).To(Equal([]string{"handler"}))
+			})
+
+			It("should keep the chain named after its first handler", func() {
+				mwHandler.Config.PreHandlers = []Handler{track("pre")}
+
+				h := mwHandler.Handle([]Handler{successHandler, Checkpoint("auth")})
+				h.ServeHTTP(response, request)
+
+				Eventually(timing).Should(Receive(HaveTiming("handlers.successHandler.checkpoint.auth", float32(STATRATE))))
+			})
+
+			It("should skip the chain and PostHandlers when a PreHandler stops", func() {
+				mwHandler.Config.PreHandlers = []Handler{stopWithStatusHandler}
+				mwHandler.Config.PostHandlers = []Handler{track("post")}
+
+				h := mwHandler.Handle([]Handler{track("handler")})
+				h.ServeHTTP(response, request)
+
+				Expect(ran).To(BeEmpty())
+				Expect(response.Code).To(Equal(http.StatusNotModified))
+			})
+
+			Context("when AlwaysRunPostHandlers is set", func() {
+				BeforeEach(func() {
+					mwHandler.Config.AlwaysRunPostHandlers = true
+				})
+
+				It("should run PostHandlers when a PreHandler stops", func() {
+					mwHandler.Config.PreHandlers = []Handler{stopWithStatusHandler}
+					mwHandler.Config.PostHandlers = []Handler{track("post")}
+
+					h := mwHandler.Handle([]Handler{track("handler")})
+					h.ServeHTTP(response, request)
+
+					Expect(ran).To(Equal([]string{"post"}))
+				})
+
+				It("should run PostHandlers before the after handlers", func() {
+					mwHandler.Config.PostHandlers = []Handler{track("post")}
+
+					h := mwHandler.HandleWithAfter([]Handler{track("handler"), failureHandler}, []Handler{track("after")})
+					h.ServeHTTP(response, request)
+
+					Expect(ran).To(Equal([]string{"handler", "post", "after"}))
+				})
+			})
+		})
+
+		Context("when after handlers are given", func() {
+			var (
+				final    *Response
+				afterRan bool
+				after    Handler
+			)
+
+			BeforeEach(func() {
+				final, afterRan = nil, false
+
+				after = NamedHandler("afterHandler", func(rw http.ResponseWriter, r *http.Request) *Response {
+					final, afterRan = FinalResponse(r), true
+					return nil
+				})
+			})
+
+			It("should run them once the chain stopped", func() {
+				h := mwHandler.HandleWithAfter([]Handler{stopWithStatusHandler, successHandler}, []Handler{after})
+				h.ServeHTTP(response, request)
+
+				Expect(afterRan).To(BeTrue())
+				Expect(final).ToNot(BeNil())
+				Expect(final.StatusCode).To(Equal(http.StatusNotModified))
+				Expect(os.Getenv(RYE_TEST_HANDLER_ENV_VAR)).ToNot(Equal("1"))
+			})
+
+			It("should run them once the chain failed", func() {
+				h := mwHandler.HandleWithAfter([]Handler{failureHandler}, []Handler{after})
+				h.ServeHTTP(response, request)
+
+				Expect(afterRan).To(BeTrue())
+				Expect(final).ToNot(BeNil())
+				Expect(final.StatusCode).To(Equal(505))
+				Expect(final.Err).To(MatchError("Foo"))
+				Expect(response.Code).To(Equal(505))
+			})
+
+			It("should run them with no final response when the whole chain ran", func() {
+				h := mwHandler.HandleWithAfter([]Handler{successHandler}, []Handler{after})
+				h.ServeHTTP(response, request)
+
+				Expect(afterRan).To(BeTrue())
+				Expect(final).To(BeNil())
+			})
+
+			It("should record stats once per handler", func() {
+				incs := make(chan string, 10)
+				fakeStatter.IncStub = func(name string, count int64, statrate float32) error {
+					incs <- name
+					return nil
+				}
+
+				h := mwHandler.HandleWithAfter([]Handler{stopWithStatusHandler}, []Handler{after})
+				h.ServeHTTP(response, request)
+
+				var names []string
+				collect := func() []string {
+					for {
+						select {
+						case name := <-incs:
+							names = append(names, name)
+						default:
+							return names
+						}
+					}
+				}
+
+				Eventually(collect).Should(ContainElements("handlers.stopWithStatusHandler.304", "handlers.afterHandler.2xx"))
+				Consistently(collect).Should(HaveLen(2))
+			})
+		})
+
+		Context("when SeparateHeadStats is set", func() {
+			BeforeEach(func() {
+				mwHandler.Config.SeparateHeadStats = true
+			})
+
+			It("should record HEAD request stats under the head namespace", func() {
+				request.Method = "HEAD"
+
+				h := mwHandler.Handle([]Handler{successHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(inc).Should(Receive(&statsInc{"handlers.successHandler.head.2xx", 1, float32(STATRATE)}))
+				Eventually(timing).Should(Receive(HaveTiming("handlers.successHandler.head.runtime", float32(STATRATE))))
+			})
+
+			It("should record GET request stats as usual", func() {
+				request.Method = "GET"
+
+				h := mwHandler.Handle([]Handler{successHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(inc).Should(Receive(&statsInc{"handlers.successHandler.2xx", 1, float32(STATRATE)}))
+				Eventually(timing).Should(Receive(HaveTiming("handlers.successHandler.runtime", float32(STATRATE))))
+			})
+		})
+
+		Context("when a handler is marked as middleware", func() {
+			It("should record its stats under the middleware namespace", func() {
+				h := mwHandler.Handle([]Handler{AsMiddleware(successHandler)})
+				h.ServeHTTP(response, request)
+
+				Eventually(inc).Should(Receive(&statsInc{"middleware.successHandler.2xx", 1, float32(STATRATE)}))
+				Eventually(timing).Should(Receive(HaveTiming("middleware.successHandler.runtime", float32(STATRATE))))
+			})
+
+			It("should record unmarked handlers in the same chain as usual", func() {
+				h := mwHandler.Handle([]Handler{AsMiddleware(successHandler), stopExecutionHandler})
+				h.ServeHTTP(response, request)
+
+				var first, second, chain statsTiming
+				Eventually(timing).Should(Receive(&first))
+				Eventually(timing).Should(Receive(&second))
+				Eventually(timing).Should(Receive(&chain))
+				Expect([]string{first.Name, second.Name, chain.Name}).To(ContainElements(
+					"middleware.successHandler.runtime",
+					"handlers.stopExecutionHandler.runtime",
+				))
+			})
+		})
+
+		Context("when DisableTiming is set", func() {
+			BeforeEach(func() {
+				mwHandler.Config.DisableTiming = true
+			})
+
+			It("should only record counters", func() {
+				h := mwHandler.Handle([]Handler{successHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(inc).Should(Receive(&statsInc{"handlers.successHandler.2xx", 1, float32(STATRATE)}))
+				Consistently(timing).ShouldNot(Receive())
+				Expect(fakeStatter.TimingDurationCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when DisableCount is set", func() {
+			BeforeEach(func() {
+				mwHandler.Config.DisableCount = true
+			})
+
+			It("should only record timings", func() {
+				h := mwHandler.Handle([]Handler{failureHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(timing).Should(Receive(HaveTiming("handlers.failureHandler.runtime", float32(STATRATE))))
+				Consistently(inc).ShouldNot(Receive())
+				Expect(fakeStatter.IncCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when LatencyBuckets are set", func() {
+			BeforeEach(func() {
+				mwHandler.Config.LatencyBuckets = []time.Duration{100 * time.Millisecond, time.Second}
+			})
+
+			It("should increment the bucket matching the handler runtime", func() {
+				h := mwHandler.Handle([]Handler{successHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(inc).Should(Receive(&statsInc{"handlers.successHandler.latency_bucket.le_100ms", 1, float32(STATRATE)}))
+			})
+
+			It("should increment a larger bucket for slow handlers", func() {
+				h := mwHandler.Handle([]Handler{slowHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(inc).Should(Receive(&statsInc{"handlers.slowHandler.latency_bucket.le_1s", 1, float32(STATRATE)}))
+			})
+		})
+
+		Context("when an OutcomeClassifier is set", func() {
+			It("should use it to classify the handler's response", func() {
+				var finalStatus int
+
+				// Treat the 505 returned by failureHandler as a client error
+				mwHandler.Config.OutcomeClassifier = func(resp *Response, status int) Outcome {
+					finalStatus = status
+					if resp != nil && resp.StatusCode == 505 {
+						return OUTCOME_CLIENT_ERROR
+					}
+					return DefaultOutcomeClassifier(resp, status)
+				}
+
+				h := mwHandler.Handle([]Handler{failureHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(505))
+				Expect(finalStatus).To(Equal(505))
+				Eventually(inc).Should(Receive(&statsInc{"handlers.failureHandler.505", 1, float32(STATRATE)}))
+				Consistently(inc).ShouldNot(Receive(Equal(statsInc{"errors", 1, float32(STATRATE)})))
+			})
+
+			It("should record responses classified as success as 2xx", func() {
+				mwHandler.Config.OutcomeClassifier = func(resp *Response, status int) Outcome {
+					return OUTCOME_SUCCESS
+				}
+
+				h := mwHandler.Handle([]Handler{failureHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(inc).Should(Receive(&statsInc{"handlers.failureHandler.2xx", 1, float32(STATRATE)}))
+			})
 		})
-	})
 
-	Describe("Handle", func() {
-		Context("when adding a valid handler", func() {
-			It("should return valid HandlerFunc", func() {
+		Context("when a handler returns an error without a status code", func() {
+			It("should record it under a 0 status code", func() {
+				reporter := &recordingReporter{}
+				// JSONErrorRenderer writes a 500 without touching the Response
+				mwHandler = NewMWHandler(Config{Reporter: reporter, SyncStats: true, ErrorRenderer: JSONErrorRenderer})
 
-				h := mwHandler.Handle([]Handler{successHandler})
+				h := mwHandler.Handle([]Handler{NamedHandler("noStatus", func(rw http.ResponseWriter, r *http.Request) *Response {
+					return &Response{Err: errors.New("no status")}
+				})})
 				h.ServeHTTP(response, request)
 
-				Expect(h).ToNot(BeNil())
-				Expect(h).To(BeAssignableToTypeOf(func(http.ResponseWriter, *http.Request) {}))
-				Expect(os.Getenv(RYE_TEST_HANDLER_ENV_VAR)).To(Equal("1"))
+				Expect(reporter.recordedIncs()).To(ContainElement("handlers.noStatus.0"))
+				Expect(reporter.recordedIncs()).ToNot(ContainElement("handlers.noStatus.2xx"))
+			})
 
-				Eventually(inc).Should(Receive(&statsInc{"handlers.successHandler.2xx", 1, float32(STATRATE)}))
-				Eventually(timing).Should(Receive(HaveTiming("handlers.successHandler.runtime", float32(STATRATE))))
+			It("should count it as the server error it is written as", func() {
+				reporter := &recordingReporter{}
+				mwHandler = NewMWHandler(Config{Reporter: reporter, SyncStats: true, ErrorRenderer: JSONErrorRenderer})
+
+				h := mwHandler.Handle([]Handler{NamedHandler("noStatus", func(rw http.ResponseWriter, r *http.Request) *Response {
+					return &Response{Err: errors.New("no status")}
+				})})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusInternalServerError))
+				Expect(reporter.recordedIncs()).To(ContainElement("errors"))
+				Expect(reporter.recordedIncs()).ToNot(ContainElement("client_errors"))
 			})
 		})
 
-		Context("when a handler returns a response with StopExecution", func() {
-			It("should not execute any further handlers", func() {
-				request.Method = "OPTIONS"
+		Context("when a Logger is set", func() {
+			It("should log every handler invocation at a level matching its outcome", func() {
+				logger := &recordingLogger{}
+				mwHandler.Config.Logger = logger
 
-				h := mwHandler.Handle([]Handler{stopExecutionHandler, successHandler})
+				h := mwHandler.Handle([]Handler{successHandler, failureHandler})
 				h.ServeHTTP(response, request)
 
-				Expect(os.Getenv(RYE_TEST_HANDLER_ENV_VAR)).ToNot(Equal("1"))
+				Expect(logger.entries).To(HaveLen(2))
+
+				Expect(logger.entries[0].Level).To(Equal(LOG_LEVEL_INFO))
+				Expect(logger.entries[0].Handler).To(Equal("successHandler"))
+				Expect(logger.entries[0].Status).To(BeZero())
+				Expect(logger.entries[0].Err).ToNot(HaveOccurred())
+
+				Expect(logger.entries[1].Level).To(Equal(LOG_LEVEL_ERROR))
+				Expect(logger.entries[1].Handler).To(Equal("failureHandler"))
+				Expect(logger.entries[1].Status).To(Equal(505))
+				Expect(logger.entries[1].Err).To(MatchError("Foo"))
+				Expect(logger.entries[1].Duration).To(BeNumerically(">", 0))
 			})
 		})
 
-		Context("when a handler returns a response with Context", func() {
-			It("should add that new context to the next passed request", func() {
-				h := mwHandler.Handle([]Handler{contextHandler, checkContextHandler})
+		Context("when DefaultResponseHeaders are set", func() {
+			BeforeEach(func() {
+				mwHandler.Config.DefaultResponseHeaders = http.Header{
+					"X-Service-Version": []string{"1.0"},
+					"server":            []string{"rye"},
+				}
+			})
+
+			It("should add them to the response", func() {
+				h := mwHandler.Handle([]Handler{successHandler})
 				h.ServeHTTP(response, request)
 
-				Expect(os.Getenv(RYE_TEST_HANDLER_ENV_VAR)).To(Equal("1"))
+				Expect(response.Header().Get("X-Service-Version")).To(Equal("1.0"))
+				Expect(response.Header().Get("Server")).To(Equal("rye"))
+			})
+
+			It("should let handlers override them", func() {
+				h := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+					rw.Header().Add("X-Service-Version", "2.0")
+					rw.WriteHeader(http.StatusOK)
+					return nil
+				}})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Header()["X-Service-Version"]).To(Equal([]string{"2.0"}))
+				Expect(response.Header().Get("Server")).To(Equal("rye"))
+			})
+
+			It("should add them to error responses", func() {
+				h := mwHandler.Handle([]Handler{failureHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(505))
+				Expect(response.Header().Get("X-Service-Version")).To(Equal("1.0"))
+			})
+
+			It("should still let handlers hijack the connection", func() {
+				mwHandler.Config.DefaultContentType = "application/json"
+
+				var hijackErr error
+				h := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+					conn, _, err := rw.(http.Hijacker).Hijack()
+					if err == nil {
+						conn.Close()
+					}
+					hijackErr = err
+					return nil
+				}})
+				h.ServeHTTP(&hijackableRecorder{ResponseRecorder: response}, request)
+
+				Expect(hijackErr).ToNot(HaveOccurred())
 			})
 		})
 
-		Context("when a handler returns a response with neither error or StopExecution set", func() {
-			It("should return a 500 + error message (and stop execution)", func() {
-				h := mwHandler.Handle([]Handler{badResponseHandler, successHandler})
+		Context("when MaxErrorMessageLength is set", func() {
+			BeforeEach(func() {
+				mwHandler.Config.MaxErrorMessageLength = 5
+			})
+
+			It("should truncate long error messages", func() {
+				var resp *Response
+
+				h := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+					resp = &Response{Err: fmt.Errorf("something went wrong"), StatusCode: http.StatusBadRequest}
+					return resp
+				}})
 				h.ServeHTTP(response, request)
 
-				Expect(response.Code).To(Equal(http.StatusInternalServerError))
-				Expect(os.Getenv(RYE_TEST_HANDLER_ENV_VAR)).ToNot(Equal("1"))
+				status := &JSONStatus{}
+				Expect(json.Unmarshal(response.Body.Bytes(), status)).To(Succeed())
+				Expect(status.Message).To(Equal("somet..."))
+				Expect(resp.Error()).To(Equal("something went wrong"))
+			})
+
+			It("should leave short error messages untouched", func() {
+				mwHandler.Config.MaxErrorMessageLength = 3
+
+				h := mwHandler.Handle([]Handler{failureHandler})
+				h.ServeHTTP(response, request)
+
+				status := &JSONStatus{}
+				Expect(json.Unmarshal(response.Body.Bytes(), status)).To(Succeed())
+				Expect(status.Message).To(Equal("Foo"))
 			})
 		})
 
-		Context("when adding an erroneous handler", func() {
-			It("should interrupt handler chain and set a response status code", func() {
+		Context("when DefaultContentType is set", func() {
+			BeforeEach(func() {
+				mwHandler.Config.DefaultContentType = "application/json"
+			})
 
+			It("should apply it when a handler writes a body without one", func() {
+				h := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+					rw.WriteHeader(http.StatusCreated)
+					rw.Write([]byte(`<not json>`))
+					return nil
+				}})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusCreated))
+				Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+			})
+
+			It("should not override a content type set by the handler", func() {
+				h := mwHandler.Handle([]Handler{textHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Header().Get("Content-Type")).To(Equal("text/plain"))
+			})
+
+			It("should not apply it when there is no body", func() {
+				h := mwHandler.Handle([]Handler{stopWithStatusHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusNotModified))
+				Expect(response.Header().Get("Content-Type")).To(BeEmpty())
+			})
+		})
+
+		Context("when DryRun is set", func() {
+			var results []DryRunResult
+
+			BeforeEach(func() {
+				results = nil
+				mwHandler.Config.DryRun = func(result DryRunResult) {
+					results = append(results, result)
+				}
+			})
+
+			It("should report the intended response without writing it", func() {
+				h := mwHandler.Handle([]Handler{textHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(results).To(HaveLen(1))
+				Expect(results[0].StatusCode).To(Equal(http.StatusOK))
+				Expect(results[0].Header.Get("Content-Type")).To(Equal("text/plain"))
+				Expect(results[0].BodyLength).To(Equal(len("plain text")))
+
+				Expect(response.Flushed).To(BeFalse())
+				Expect(response.Body.Len()).To(Equal(0))
+				Expect(response.Header()).To(BeEmpty())
+			})
+
+			It("should report error responses", func() {
 				h := mwHandler.Handle([]Handler{failureHandler})
 				h.ServeHTTP(response, request)
 
-				Expect(h).ToNot(BeNil())
-				Expect(h).To(BeAssignableToTypeOf(func(http.ResponseWriter, *http.Request) {}))
-				Expect(response.Code).To(Equal(505))
-				Eventually(inc).Should(Receive(&statsInc{"handlers.failureHandler.505", 1, float32(STATRATE)}))
-				Eventually(inc).Should(Receive(&statsInc{"errors", 1, float32(STATRATE)}))
-				Eventually(timing).Should(Receive(HaveTiming("handlers.failureHandler.runtime", float32(STATRATE))))
+				Expect(results).To(HaveLen(1))
+				Expect(results[0].StatusCode).To(Equal(505))
+				Expect(results[0].BodyLength).To(BeNumerically(">", 0))
+				Expect(response.Body.Len()).To(Equal(0))
+			})
+
+			It("should report responses written by finalizers", func() {
+				h := mwHandler.Handle([]Handler{NewMiddlewareEnvelope(), jsonHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(results).To(HaveLen(1))
+				Expect(results[0].Header.Get("Content-Length")).To(Equal(strconv.Itoa(results[0].BodyLength)))
+				Expect(response.Body.Len()).To(Equal(0))
+			})
+
+			It("should still record stats", func() {
+				h := mwHandler.Handle([]Handler{textHandler})
+				h.ServeHTTP(response, request)
+
+				Eventually(inc).Should(Receive(&statsInc{"handlers.textHandler.2xx", 1, float32(STATRATE)}))
+				Eventually(timing).Should(Receive(HaveTiming("handlers.textHandler.runtime", float32(STATRATE))))
 			})
 		})
 
@@ -178,11 +1025,303 @@ var _ = Describe("Rye", func() {
 		})
 	})
 
+	Describe("HandlerFor", func() {
+		It("should produce the same status codes and stats as Handle", func() {
+			handleReporter, handlerForReporter := &recordingReporter{}, &recordingReporter{}
+			handlerForResponse := httptest.NewRecorder()
+
+			chain := []Handler{successHandler, failureHandler}
+
+			NewMWHandler(Config{Reporter: handleReporter, StatRate: 1}).Handle(chain).ServeHTTP(response, request)
+			NewMWHandler(Config{Reporter: handlerForReporter, StatRate: 1}).HandlerFor(chain).ServeHTTP(handlerForResponse, request)
+
+			Expect(handlerForResponse.Code).To(Equal(response.Code))
+			Expect(handlerForResponse.Body.String()).To(Equal(response.Body.String()))
+
+			Eventually(handleReporter.recordedIncs).Should(HaveLen(4))
+			Eventually(handlerForReporter.recordedIncs).Should(HaveLen(4))
+			Expect(handlerForReporter.recordedIncs()).To(ConsistOf(handleReporter.recordedIncs()))
+
+			Eventually(handleReporter.recordedTimings).Should(HaveLen(3))
+			Eventually(handlerForReporter.recordedTimings).Should(ConsistOf(handleReporter.recordedTimings()))
+		})
+	})
+
+	Describe("HandleF", func() {
+		It("should behave like Handle given the same handlers", func() {
+			handleReporter, handleFReporter := &recordingReporter{}, &recordingReporter{}
+			handleFResponse := httptest.NewRecorder()
+
+			NewMWHandler(Config{Reporter: handleReporter, SyncStats: true}).Handle([]Handler{successHandler, failureHandler}).ServeHTTP(response, request)
+			NewMWHandler(Config{Reporter: handleFReporter, SyncStats: true}).HandleF(successHandler, failureHandler).ServeHTTP(handleFResponse, request)
+
+			Expect(handleFResponse.Code).To(Equal(response.Code))
+			Expect(handleFResponse.Body.String()).To(Equal(response.Body.String()))
+			Expect(handleFReporter.recordedIncs()).To(Equal(handleReporter.recordedIncs()))
+			Expect(handleFReporter.recordedTimings()).To(Equal(handleReporter.recordedTimings()))
+		})
+	})
+
+	Describe("chain runtime", func() {
+		var reporter *recordingReporter
+
+		BeforeEach(func() {
+			reporter = &recordingReporter{}
+		})
+
+		chainTimings := func() []string {
+			var names []string
+			for _, name := range reporter.recordedTimings() {
+				if strings.HasSuffix(name, ".chain.runtime") {
+					names = append(names, name)
+				}
+			}
+			return names
+		}
+
+		It("should record exactly one timing per request", func() {
+			h := NewMWHandler(Config{Reporter: reporter}).Handle([]Handler{successHandler, successHandler})
+			h.ServeHTTP(response, request)
+
+			Eventually(reporter.recordedTimings).Should(HaveLen(3))
+			Expect(chainTimings()).To(Equal([]string{"handlers.successHandler.chain.runtime"}))
+		})
+
+		It("should record it when the chain stops early", func() {
+			h := NewMWHandler(Config{Reporter: reporter}).Handle([]Handler{failureHandler, successHandler})
+			h.ServeHTTP(response, request)
+
+			Eventually(reporter.recordedTimings).Should(HaveLen(2))
+			Expect(chainTimings()).To(Equal([]string{"handlers.failureHandler.chain.runtime"}))
+		})
+
+		It("should be named after Config.ChainRuntimeStat and prefixed", func() {
+			h := NewMWHandler(Config{Reporter: reporter, ChainRuntimeStat: "api.request", StatPrefix: "myservice"}).Handle([]Handler{successHandler})
+			h.ServeHTTP(response, request)
+
+			Eventually(reporter.recordedTimings).Should(ContainElement("myservice.api.request"))
+		})
+	})
+
+	Describe("OnError", func() {
+		var (
+			reporter *recordingReporter
+			calls    []*Response
+			handler  *MWHandler
+		)
+
+		BeforeEach(func() {
+			reporter = &recordingReporter{}
+			calls = nil
+			handler = NewMWHandler(Config{
+				Reporter:  reporter,
+				SyncStats: true,
+				OnError: func(r *http.Request, resp *Response) {
+					calls = append(calls, resp)
+				},
+			})
+		})
+
+		It("should be called once with the error of the handler, on top of the errors stat", func() {
+			handler.Handle([]Handler{successHandler, failureHandler, successHandler}).ServeHTTP(response, request)
+
+			Expect(calls).To(HaveLen(1))
+			Expect(calls[0].StatusCode).To(Equal(505))
+			Expect(calls[0].Err).To(MatchError("Foo"))
+			Expect(response.Code).To(Equal(505))
+			Expect(reporter.recordedIncs()).To(ContainElement("errors"))
+		})
+
+		It("should be called with the error answering a malformed Response", func() {
+			handler.Handle([]Handler{badResponseHandler}).ServeHTTP(response, request)
+
+			Expect(calls).To(HaveLen(1))
+			Expect(calls[0].StatusCode).To(Equal(http.StatusInternalServerError))
+			Expect(calls[0].Err).To(HaveOccurred())
+		})
+
+		It("should not be called when no handler fails", func() {
+			handler.Handle([]Handler{successHandler, noContentHandler}).ServeHTTP(response, request)
+
+			Expect(calls).To(BeEmpty())
+		})
+	})
+
+	Describe("stat names", func() {
+		var reporter *recordingReporter
+
+		BeforeEach(func() {
+			reporter = &recordingReporter{}
+		})
+
+		It("should default to the errors counter and the handlers namespace", func() {
+			h := NewMWHandler(Config{Reporter: reporter}).Handle([]Handler{NamedHandler("auth", Checkpoint("auth")), failureHandler})
+			h.ServeHTTP(response, request)
+
+			Eventually(reporter.recordedIncs).Should(ContainElements("errors", "handlers.failureHandler.505"))
+			Eventually(reporter.recordedTimings).Should(ContainElements(
+				"handlers.failureHandler.runtime",
+				"handlers.auth.chain.runtime",
+				"handlers.auth.checkpoint.auth",
+			))
+		})
+
+		It("should use Config.ErrorStatName and Config.HandlerStatNamespace", func() {
+			h := NewMWHandler(Config{
+				Reporter:             reporter,
+				StatPrefix:           "myservice",
+				ErrorStatName:        "failures",
+				HandlerStatNamespace: "api",
+			}).Handle([]Handler{NamedHandler("auth", Checkpoint("auth")), failureHandler})
+			h.ServeHTTP(response, request)
+
+			Eventually(reporter.recordedIncs).Should(ContainElements("failures", "myservice.api.failureHandler.505"))
+			Eventually(reporter.recordedTimings).Should(ContainElements(
+				"myservice.api.failureHandler.runtime",
+				"myservice.api.auth.chain.runtime",
+				"myservice.api.auth.checkpoint.auth",
+			))
+			Expect(reporter.recordedIncs()).ToNot(ContainElement("errors"))
+			Expect(strings.Join(reporter.recordedTimings(), " ")).ToNot(ContainSubstring("handlers."))
+		})
+	})
+
+	Describe("SyncStats", func() {
+		It("should have sent every stat, in order, by the time ServeHTTP returns", func() {
+			reporter := &recordingReporter{}
+
+			h := NewMWHandler(Config{Reporter: reporter, SyncStats: true}).Handle([]Handler{
+				NamedHandler("start", Checkpoint("start")),
+				successHandler,
+				failureHandler,
+			})
+			h.ServeHTTP(response, request)
+
+			Expect(reporter.recordedIncs()).To(Equal([]string{
+				"handlers.start.2xx",
+				"handlers.successHandler.2xx",
+				"errors",
+				"handlers.failureHandler.505",
+				"handlers.failureHandler.5xx",
+			}))
+			Expect(reporter.recordedTimings()).To(Equal([]string{
+				"handlers.start.checkpoint.start",
+				"handlers.start.runtime",
+				"handlers.successHandler.runtime",
+				"handlers.failureHandler.runtime",
+				"handlers.start.chain.runtime",
+			}))
+		})
+
+		It("should send gauges inline too", func() {
+			statter := &statsdfakes.FakeStatter{}
+
+			h := NewMWHandler(Config{Statter: statter, SyncStats: true}).Handle([]Handler{
+				func(rw http.ResponseWriter, r *http.Request) *Response {
+					chainFromRequest(r).gauge("queue.depth", 3)
+					return nil
+				},
+			})
+			h.ServeHTTP(response, request)
+
+			Expect(statter.GaugeCallCount()).To(Equal(1))
+			Expect(statter.IncCallCount()).To(Equal(1))
+		})
+	})
+
+	Describe("ExecutedCount", func() {
+		var statter *statsdfakes.FakeStatter
+
+		BeforeEach(func() {
+			statter = &statsdfakes.FakeStatter{}
+		})
+
+		executedCount := func() (int64, float32, bool) {
+			for i := 0; i < statter.IncCallCount(); i++ {
+				if stat, value, rate := statter.IncArgsForCall(i); stat == "handlers.start.executed_count" {
+					return value, rate, true
+				}
+			}
+			return 0, 0, false
+		}
+
+		serve := func(config Config, handlers ...Handler) {
+			config.Statter = statter
+			config.SyncStats = true
+			NewMWHandler(config).Handle(append([]Handler{NamedHandler("start", Checkpoint("start"))}, handlers...)).ServeHTTP(response, request)
+		}
+
+		It("should count every handler of a full chain", func() {
+			serve(Config{ExecutedCount: true, StatRate: 0.5}, successHandler, successHandler)
+
+			value, rate, ok := executedCount()
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal(int64(3)))
+			Expect(rate).To(Equal(float32(0.5)))
+		})
+
+		It("should only count the handlers that ran before the chain was stopped", func() {
+			serve(Config{ExecutedCount: true}, failureHandler, successHandler, successHandler)
+
+			value, _, ok := executedCount()
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal(int64(2)))
+		})
+
+		It("should not count handlers skipped by When", func() {
+			serve(Config{ExecutedCount: true}, When(OnMethods("POST"), successHandler), successHandler)
+
+			value, _, ok := executedCount()
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal(int64(2)))
+		})
+
+		It("should not be recorded unless enabled", func() {
+			serve(Config{}, successHandler)
+
+			_, _, ok := executedCount()
+			Expect(ok).To(BeFalse())
+		})
+	})
+
 	Describe("getFuncName", func() {
 		It("should return the name of the function as a string", func() {
 			funcName := getFuncName(testFunc)
 			Expect(funcName).To(Equal("testFunc"))
 		})
+
+		It("should name methods after their receiver type", func() {
+			Expect(getFuncName((&testController{}).List)).To(Equal("testController.List"))
+			Expect(getFuncName(testController{}.Show)).To(Equal("testController.Show"))
+		})
+
+		It("should return the compiler given name of closures", func() {
+			closure := func() {}
+			Expect(getFuncName(closure)).To(MatchRegexp(`^func\d+(\.\d+)*$`))
+		})
+	})
+
+	Describe("normalizeFuncName", func() {
+		cases := map[string]string{
+			"github.com/InVisionApp/rye.successHandler":                         "successHandler",
+			"main.successHandler":                                               "successHandler",
+			"github.com/foo/bar.(*MyController).List-fm":                        "MyController.List",
+			"github.com/foo/bar.MyController.List-fm":                           "MyController.List",
+			"github.com/foo/bar.(*MyController).List":                           "MyController.List",
+			"github.com/foo/bar.glob..func1":                                    "func1",
+			"github.com/foo/bar.outer.func2":                                    "func2",
+			"github.com/foo/bar.outer.func2.1":                                  "func2.1",
+			"github.com/foo/bar.(*MyController).List.func1":                     "func1",
+			"github.com/foo/bar.Wrap[go.shape.struct { github.com/foo/baz.X }]": "Wrap[go.shape.struct { github.com/foo/baz.X }]",
+		}
+
+		for fullName, expected := range cases {
+			fullName, expected := fullName, expected
+
+			It("should normalize "+fullName, func() {
+				Expect(normalizeFuncName(fullName)).To(Equal(expected))
+			})
+		}
 	})
 
 	Describe("Error()", func() {
@@ -211,6 +1350,33 @@ func checkContextHandler(rw http.ResponseWriter, r *http.Request) *Response {
 	return nil
 }
 
+type testWriter struct {
+	http.ResponseWriter
+}
+
+// hijackableRecorder is a ResponseRecorder whose connection can be hijacked
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (h *hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	server, client := net.Pipe()
+	client.Close()
+
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+func writerHandler(rw http.ResponseWriter, r *http.Request) *Response {
+	return &Response{Writer: &testWriter{rw}}
+}
+
+func checkWriterHandler(rw http.ResponseWriter, r *http.Request) *Response {
+	if _, ok := rw.(*testWriter); ok {
+		os.Setenv(RYE_TEST_HANDLER_ENV_VAR, "1")
+	}
+	return nil
+}
+
 func successHandler(rw http.ResponseWriter, r *http.Request) *Response {
 	os.Setenv(RYE_TEST_HANDLER_ENV_VAR, "1")
 	return nil
@@ -220,6 +1386,15 @@ func badResponseHandler(rw http.ResponseWriter, r *http.Request) *Response {
 	return &Response{}
 }
 
+func noContentHandler(rw http.ResponseWriter, r *http.Request) *Response {
+	return &Response{StatusCode: http.StatusNoContent}
+}
+
+func notFoundWriterHandler(rw http.ResponseWriter, r *http.Request) *Response {
+	http.NotFound(rw, r)
+	return nil
+}
+
 func failureHandler(rw http.ResponseWriter, r *http.Request) *Response {
 	return &Response{
 		StatusCode: 505,
@@ -227,14 +1402,88 @@ func failureHandler(rw http.ResponseWriter, r *http.Request) *Response {
 	}
 }
 
+func badRequestHandler(rw http.ResponseWriter, r *http.Request) *Response {
+	return &Response{
+		StatusCode: http.StatusBadRequest,
+		Err:        fmt.Errorf("Bad"),
+	}
+}
+
 func stopExecutionHandler(rw http.ResponseWriter, r *http.Request) *Response {
 	return &Response{
 		StopExecution: true,
 	}
 }
 
+func stopWithStatusHandler(rw http.ResponseWriter, r *http.Request) *Response {
+	return &Response{
+		StatusCode:    http.StatusNotModified,
+		StopExecution: true,
+	}
+}
+
+func contentHandler(rw http.ResponseWriter, r *http.Request) *Response {
+	return &Response{
+		StatusCode:    http.StatusCreated,
+		StatusContent: `{"ok":true}`,
+		ContentType:   "application/json",
+		StopExecution: true,
+	}
+}
+
+func contentWithErrorHandler(rw http.ResponseWriter, r *http.Request) *Response {
+	return &Response{
+		Err:           errors.New("content failed"),
+		StatusCode:    http.StatusInternalServerError,
+		StatusContent: `{"ok":true}`,
+		ContentType:   "application/json",
+		StopExecution: true,
+	}
+}
+
+func slowHandler(rw http.ResponseWriter, r *http.Request) *Response {
+	time.Sleep(150 * time.Millisecond)
+	return nil
+}
+
 func testFunc() {}
 
+type testController struct{}
+
+func (c *testController) List(rw http.ResponseWriter, r *http.Request) *Response {
+	return nil
+}
+
+func (c testController) Show(rw http.ResponseWriter, r *http.Request) *Response {
+	return nil
+}
+
+var _ = Describe("JSONErrorRenderer", func() {
+	It("should render a missing status code as a 500", func() {
+		response := httptest.NewRecorder()
+
+		JSONErrorRenderer(response, &Response{Err: errors.New("boom")})
+
+		Expect(response.Code).To(Equal(http.StatusInternalServerError))
+		Expect(response.Body.String()).To(MatchJSON(`{"status":500,"error":"boom"}`))
+	})
+})
+
+var _ = Describe("truncateMessage", func() {
+	It("should truncate at the boundary", func() {
+		Expect(truncateMessage("abcdef", 5)).To(Equal("abcde..."))
+		Expect(truncateMessage("abcde", 5)).To(Equal("abcde"))
+	})
+
+	It("should not split multi-byte characters", func() {
+		Expect(truncateMessage("héllo wörld", 7)).To(Equal("héllo w..."))
+	})
+
+	It("should not truncate without a limit", func() {
+		Expect(truncateMessage("abcdef", 0)).To(Equal("abcdef"))
+	})
+})
+
 func HaveTiming(name string, statrate float32) types.GomegaMatcher {
 	return WithTransform(
 		func(p statsTiming) bool {
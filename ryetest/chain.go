@@ -0,0 +1,52 @@
+package ryetest
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/InVisionApp/rye"
+)
+
+/*
+RunChain runs the handler chain against the request (the way a rye.MWHandler would serve it, without stats)
+and returns the recorded response.
+
+Example usage:
+
+	rec := ryetest.RunChain([]rye.Handler{authHandler, itemHandler}, httptest.NewRequest("GET", "/items/1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d", rec.Code)
+	}
+*/
+func RunChain(handlers []rye.Handler, req *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	rye.NewMWHandler(rye.Config{}).Handle(handlers).ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+/*
+RunChainWithStatter works like RunChain, recording every stat of the chain in the statter. As rye sends stats
+asynchronously, it returns once they have stopped arriving, so they can be inspected right away.
+
+Example usage:
+
+	statter := &ryetest.RecordingStatter{}
+	ryetest.RunChainWithStatter(statter, []rye.Handler{itemHandler}, httptest.NewRequest("GET", "/items/1", nil))
+
+	for _, stat := range statter.Incs() {
+		...
+	}
+*/
+func RunChainWithStatter(statter *RecordingStatter, handlers []rye.Handler, req *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+
+	rye.NewMWHandler(rye.Config{
+		Statter:  statter,
+		StatRate: 1,
+	}).Handle(handlers).ServeHTTP(recorder, req)
+
+	waitForStats(statter)
+
+	return recorder
+}
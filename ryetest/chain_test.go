@@ -0,0 +1,50 @@
+package ryetest
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/InVisionApp/rye"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RunChain", func() {
+
+	It("should run the chain and record the response", func() {
+		rec := RunChain([]rye.Handler{addUserHandler, greetHandler}, httptest.NewRequest("GET", "/", nil))
+
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("text/plain"))
+		Expect(rec.Body.String()).To(Equal("hello rye"))
+	})
+
+	It("should record errors", func() {
+		rec := RunChain([]rye.Handler{failHandler, greetHandler}, httptest.NewRequest("GET", "/", nil))
+
+		Expect(rec.Code).To(Equal(http.StatusBadGateway))
+		Expect(rec.Body.String()).To(ContainSubstring("upstream failed"))
+	})
+})
+
+var _ = Describe("RunChainWithStatter", func() {
+
+	It("should record the stats of the chain by the time it returns", func() {
+		statter := &RecordingStatter{}
+
+		rec := RunChainWithStatter(statter, []rye.Handler{failHandler}, httptest.NewRequest("GET", "/", nil))
+
+		Expect(rec.Code).To(Equal(http.StatusBadGateway))
+		Expect(statter.Incs()).To(ConsistOf(
+			Stat{Type: "Inc", Name: "errors", Value: "1", StatRate: 1},
+			Stat{Type: "Inc", Name: "handlers.failHandler.502", Value: "1", StatRate: 1},
+			Stat{Type: "Inc", Name: "handlers.failHandler.5xx", Value: "1", StatRate: 1},
+		))
+
+		var timings []string
+		for _, stat := range statter.Timings() {
+			timings = append(timings, stat.Name)
+		}
+		Expect(timings).To(ConsistOf("handlers.failHandler.runtime", "handlers.failHandler.chain.runtime"))
+	})
+})
@@ -0,0 +1,114 @@
+package ryetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/InVisionApp/rye"
+)
+
+// ReplayedRequest is a captured request along with what the handler chain produced for it.
+type ReplayedRequest struct {
+	Method string
+	URL    string
+
+	// RecordedStatus is the status code of the captured response (0 if there was none)
+	RecordedStatus int
+
+	ChainSnapshot
+}
+
+// harFile holds the parts of a HAR (HTTP Archive) capture needed to replay its requests
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method   string      `json:"method"`
+				URL      string      `json:"url"`
+				Headers  []harHeader `json:"headers"`
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+			Response struct {
+				Status int `json:"status"`
+			} `json:"response"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+/*
+ReplayHAR runs each request of a HAR (HTTP Archive) capture - as exported by browsers' developer tools
+or proxies - through the handler chain, in order, and returns what the chain produced for each one (see
+Snapshot) along with the status code that was recorded. Replaying production-shaped traffic makes for
+regression tests of middleware changes.
+
+Example usage:
+
+	har, _ := os.ReadFile("testdata/checkout.har")
+
+	replayed, err := ryetest.ReplayHAR(har, []rye.Handler{authHandler, checkoutHandler})
+	for _, r := range replayed {
+		if r.StatusCode != r.RecordedStatus {
+			t.Errorf("%v %v: got %v, recorded %v", r.Method, r.URL, r.StatusCode, r.RecordedStatus)
+		}
+	}
+*/
+func ReplayHAR(har []byte, handlers []rye.Handler) ([]ReplayedRequest, error) {
+	var capture harFile
+	if err := json.Unmarshal(har, &capture); err != nil {
+		return nil, fmt.Errorf("Unable to parse HAR capture: %v", err)
+	}
+
+	replayed := make([]ReplayedRequest, 0, len(capture.Log.Entries))
+
+	for i, entry := range capture.Log.Entries {
+		var body io.Reader
+		if entry.Request.PostData != nil {
+			body = strings.NewReader(entry.Request.PostData.Text)
+		}
+
+		req, err := http.NewRequest(entry.Request.Method, entry.Request.URL, body)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to replay HAR entry %d: %v", i, err)
+		}
+
+		// Same client address as httptest.NewRequest
+		req.RemoteAddr = "192.0.2.1:1234"
+
+		for _, header := range entry.Request.Headers {
+			switch {
+			case strings.HasPrefix(header.Name, ":"):
+				// HTTP/2 pseudo headers are part of the request line
+			case strings.EqualFold(header.Name, "Host"):
+				req.Host = header.Value
+			case strings.EqualFold(header.Name, "Content-Length"):
+				// Set from the body
+			default:
+				req.Header.Add(header.Name, header.Value)
+			}
+		}
+
+		if entry.Request.PostData != nil && req.Header.Get("Content-Type") == "" && entry.Request.PostData.MimeType != "" {
+			req.Header.Set("Content-Type", entry.Request.PostData.MimeType)
+		}
+
+		replayed = append(replayed, ReplayedRequest{
+			Method:         entry.Request.Method,
+			URL:            entry.Request.URL,
+			RecordedStatus: entry.Response.Status,
+			ChainSnapshot:  Snapshot(handlers, req),
+		})
+	}
+
+	return replayed, nil
+}
@@ -0,0 +1,119 @@
+package ryetest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/InVisionApp/rye"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// A small capture of a client creating then fetching an item
+const capturedHAR = `{
+  "log": {
+    "version": "1.2",
+    "entries": [
+      {
+        "request": {
+          "method": "POST",
+          "url": "https://api.example.com/items",
+          "headers": [
+            {"name": ":authority", "value": "api.example.com"},
+            {"name": "Authorization", "value": "Bearer secret"},
+            {"name": "Content-Length", "value": "15"}
+          ],
+          "postData": {"mimeType": "application/json", "text": "{\"name\":\"rye\"}"}
+        },
+        "response": {"status": 201}
+      },
+      {
+        "request": {
+          "method": "GET",
+          "url": "https://api.example.com/items/1?fields=name",
+          "headers": [
+            {"name": "Authorization", "value": "Bearer secret"}
+          ]
+        },
+        "response": {"status": 200}
+      },
+      {
+        "request": {
+          "method": "GET",
+          "url": "https://api.example.com/items/1",
+          "headers": []
+        },
+        "response": {"status": 401}
+      }
+    ]
+  }
+}`
+
+var _ = Describe("ReplayHAR", func() {
+
+	Context("when replaying a capture", func() {
+		It("should run every request through the chain in order", func() {
+			replayed, err := ReplayHAR([]byte(capturedHAR), []rye.Handler{authorizeHandler, itemHandler})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(replayed).To(HaveLen(3))
+
+			for _, r := range replayed {
+				Expect(r.StatusCode).To(Equal(r.RecordedStatus), fmt.Sprintf("%v %v", r.Method, r.URL))
+			}
+
+			Expect(replayed[0].Method).To(Equal("POST"))
+			Expect(replayed[0].Body).To(Equal(`created application/json {"name":"rye"}`))
+			Expect(replayed[1].Body).To(Equal("item 1 name"))
+		})
+
+		It("should capture the stats of each request", func() {
+			replayed, err := ReplayHAR([]byte(capturedHAR), []rye.Handler{authorizeHandler, itemHandler})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(replayed[1].Stats).To(ContainElement(Stat{Type: "Inc", Name: "handlers.itemHandler.2xx", Value: "1", StatRate: 1}))
+			Expect(replayed[2].Stats).To(ContainElement(Stat{Type: "Inc", Name: "handlers.authorizeHandler.401", Value: "1", StatRate: 1}))
+			Expect(replayed[2].Stats).ToNot(ContainElement(Stat{Type: "Inc", Name: "handlers.itemHandler.2xx", Value: "1", StatRate: 1}))
+		})
+	})
+
+	Context("when the capture is malformed", func() {
+		It("should return an error", func() {
+			_, err := ReplayHAR([]byte(`{"log": [`), []rye.Handler{itemHandler})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unable to parse HAR capture"))
+		})
+	})
+
+	Context("when an entry cannot be replayed", func() {
+		It("should return an error", func() {
+			_, err := ReplayHAR([]byte(`{"log": {"entries": [{"request": {"method": "GET", "url": "://nope"}}]}}`), []rye.Handler{itemHandler})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("HAR entry 0"))
+		})
+	})
+})
+
+func authorizeHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		return &rye.Response{
+			StatusCode: http.StatusUnauthorized,
+			Err:        fmt.Errorf("unauthorized"),
+		}
+	}
+
+	return nil
+}
+
+func itemHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+	if r.Method == http.MethodPost {
+		body, _ := io.ReadAll(r.Body)
+
+		rw.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(rw, "created %v %s", r.Header.Get("Content-Type"), body)
+		return nil
+	}
+
+	fmt.Fprintf(rw, "item 1 %v", r.URL.Query().Get("fields"))
+	return nil
+}
@@ -0,0 +1,13 @@
+package ryetest
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestRyeTestSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "RyeTest Suite")
+}
@@ -0,0 +1,57 @@
+package ryetest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/InVisionApp/rye"
+)
+
+// How long RunChainWithStatter waits for stats (which rye emits asynchronously) to stop arriving
+const statsSettleTime = 20 * time.Millisecond
+
+// ChainSnapshot is everything a handler chain produced for a single request.
+type ChainSnapshot struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+	Stats      []Stat
+}
+
+/*
+Snapshot runs the handler chain against the request and returns the captured status code, headers,
+body and stats. The result is well suited for golden-file/snapshot testing of rye chains.
+
+Example usage:
+
+	snapshot := ryetest.Snapshot([]rye.Handler{authHandler, itemHandler}, httptest.NewRequest("GET", "/items/1", nil))
+	if snapshot.StatusCode != http.StatusOK {
+		...
+	}
+*/
+func Snapshot(handlers []rye.Handler, req *http.Request) ChainSnapshot {
+	statter := &RecordingStatter{}
+	recorder := RunChainWithStatter(statter, handlers, req)
+
+	return ChainSnapshot{
+		StatusCode: recorder.Code,
+		Header:     recorder.Header(),
+		Body:       recorder.Body.String(),
+		Stats:      statter.Stats(),
+	}
+}
+
+// waitForStats waits until no new stats have been recorded for statsSettleTime
+func waitForStats(statter *RecordingStatter) []Stat {
+	count := -1
+
+	for {
+		stats := statter.Stats()
+		if len(stats) == count {
+			return stats
+		}
+
+		count = len(stats)
+		time.Sleep(statsSettleTime)
+	}
+}
@@ -0,0 +1,57 @@
+package ryetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/InVisionApp/rye"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Snapshot", func() {
+
+	Context("when running a multi-handler chain", func() {
+		It("should capture the status, headers, body and stats", func() {
+			snapshot := Snapshot([]rye.Handler{addUserHandler, greetHandler}, httptest.NewRequest("GET", "/", nil))
+
+			Expect(snapshot.StatusCode).To(Equal(http.StatusOK))
+			Expect(snapshot.Header.Get("Content-Type")).To(Equal("text/plain"))
+			Expect(snapshot.Body).To(Equal("hello rye"))
+			Expect(snapshot.Stats).To(ContainElement(Stat{Type: "Inc", Name: "handlers.addUserHandler.2xx", Value: "1", StatRate: 1}))
+			Expect(snapshot.Stats).To(ContainElement(Stat{Type: "Inc", Name: "handlers.greetHandler.2xx", Value: "1", StatRate: 1}))
+			Expect(snapshot.Stats).To(HaveLen(5))
+		})
+	})
+
+	Context("when a handler in the chain fails", func() {
+		It("should capture the error response and stats", func() {
+			snapshot := Snapshot([]rye.Handler{failHandler, greetHandler}, httptest.NewRequest("GET", "/", nil))
+
+			Expect(snapshot.StatusCode).To(Equal(http.StatusBadGateway))
+			Expect(snapshot.Body).To(ContainSubstring("upstream failed"))
+			Expect(snapshot.Stats).To(ContainElement(Stat{Type: "Inc", Name: "errors", Value: "1", StatRate: 1}))
+			Expect(snapshot.Stats).To(ContainElement(Stat{Type: "Inc", Name: "handlers.failHandler.502", Value: "1", StatRate: 1}))
+		})
+	})
+})
+
+func addUserHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+	return &rye.Response{Context: context.WithValue(r.Context(), "user", "rye")}
+}
+
+func greetHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+	rw.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintf(rw, "hello %v", r.Context().Value("user"))
+	return nil
+}
+
+func failHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+	return &rye.Response{
+		StatusCode: http.StatusBadGateway,
+		Err:        errors.New("upstream failed"),
+	}
+}
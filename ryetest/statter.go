@@ -0,0 +1,197 @@
+package ryetest
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cactus/go-statsd-client/statsd"
+)
+
+// Stat is a single metric sent to a RecordingStatter.
+type Stat struct {
+	// Type is the name of the Statter method that was called (ie. "Inc" or "TimingDuration")
+	Type     string
+	Name     string
+	Value    string
+	StatRate float32
+}
+
+// RecordingStatter is a statsd.Statter that records every stat it is sent,
+// so tests can inspect what a chain emitted. It is safe for concurrent use.
+type RecordingStatter struct {
+	mu     sync.Mutex
+	prefix string
+	stats  []Stat
+}
+
+// Stats returns a copy of the stats recorded so far
+func (s *RecordingStatter) Stats() []Stat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Stat(nil), s.stats...)
+}
+
+// Names returns the names of the stats recorded so far
+func (s *RecordingStatter) Names() []string {
+	stats := s.Stats()
+	names := make([]string, 0, len(stats))
+
+	for _, stat := range stats {
+		names = append(names, stat.Name)
+	}
+
+	return names
+}
+
+// Incs returns the counters (Inc calls) recorded so far
+func (s *RecordingStatter) Incs() []Stat {
+	return s.statsOfType("Inc")
+}
+
+// Timings returns the timings (Timing and TimingDuration calls) recorded so far
+func (s *RecordingStatter) Timings() []Stat {
+	return s.statsOfType("Timing", "TimingDuration")
+}
+
+// statsOfType returns the stats recorded so far through any of the given methods
+func (s *RecordingStatter) statsOfType(statTypes ...string) []Stat {
+	var stats []Stat
+
+	for _, stat := range s.Stats() {
+		for _, statType := range statTypes {
+			if stat.Type == statType {
+				stats = append(stats, stat)
+				break
+			}
+		}
+	}
+
+	return stats
+}
+
+// Reset drops all recorded stats
+func (s *RecordingStatter) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats = nil
+}
+
+func (s *RecordingStatter) record(statType, name, value string, rate float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.prefix != "" {
+		name = s.prefix + "." + name
+	}
+
+	s.stats = append(s.stats, Stat{
+		Type:     statType,
+		Name:     name,
+		Value:    value,
+		StatRate: rate,
+	})
+
+	return nil
+}
+
+func (s *RecordingStatter) Inc(name string, value int64, rate float32) error {
+	return s.record("Inc", name, strconv.FormatInt(value, 10), rate)
+}
+
+func (s *RecordingStatter) Dec(name string, value int64, rate float32) error {
+	return s.record("Dec", name, strconv.FormatInt(value, 10), rate)
+}
+
+func (s *RecordingStatter) Gauge(name string, value int64, rate float32) error {
+	return s.record("Gauge", name, strconv.FormatInt(value, 10), rate)
+}
+
+func (s *RecordingStatter) GaugeDelta(name string, value int64, rate float32) error {
+	return s.record("GaugeDelta", name, strconv.FormatInt(value, 10), rate)
+}
+
+func (s *RecordingStatter) Timing(name string, value int64, rate float32) error {
+	return s.record("Timing", name, strconv.FormatInt(value, 10), rate)
+}
+
+func (s *RecordingStatter) TimingDuration(name string, value time.Duration, rate float32) error {
+	return s.record("TimingDuration", name, value.String(), rate)
+}
+
+func (s *RecordingStatter) Set(name string, value string, rate float32) error {
+	return s.record("Set", name, value, rate)
+}
+
+func (s *RecordingStatter) SetInt(name string, value int64, rate float32) error {
+	return s.record("SetInt", name, strconv.FormatInt(value, 10), rate)
+}
+
+func (s *RecordingStatter) Raw(name string, value string, rate float32) error {
+	return s.record("Raw", name, value, rate)
+}
+
+func (s *RecordingStatter) NewSubStatter(prefix string) statsd.SubStatter {
+	return &recordingSubStatter{parent: s, prefix: prefix}
+}
+
+func (s *RecordingStatter) SetPrefix(prefix string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prefix = prefix
+}
+
+func (s *RecordingStatter) Close() error {
+	return nil
+}
+
+// recordingSubStatter records into its parent, prefixing stat names
+type recordingSubStatter struct {
+	parent *RecordingStatter
+	prefix string
+}
+
+func (s *recordingSubStatter) Inc(name string, value int64, rate float32) error {
+	return s.parent.Inc(s.prefix+"."+name, value, rate)
+}
+
+func (s *recordingSubStatter) Dec(name string, value int64, rate float32) error {
+	return s.parent.Dec(s.prefix+"."+name, value, rate)
+}
+
+func (s *recordingSubStatter) Gauge(name string, value int64, rate float32) error {
+	return s.parent.Gauge(s.prefix+"."+name, value, rate)
+}
+
+func (s *recordingSubStatter) GaugeDelta(name string, value int64, rate float32) error {
+	return s.parent.GaugeDelta(s.prefix+"."+name, value, rate)
+}
+
+func (s *recordingSubStatter) Timing(name string, value int64, rate float32) error {
+	return s.parent.Timing(s.prefix+"."+name, value, rate)
+}
+
+func (s *recordingSubStatter) TimingDuration(name string, value time.Duration, rate float32) error {
+	return s.parent.TimingDuration(s.prefix+"."+name, value, rate)
+}
+
+func (s *recordingSubStatter) Set(name string, value string, rate float32) error {
+	return s.parent.Set(s.prefix+"."+name, value, rate)
+}
+
+func (s *recordingSubStatter) SetInt(name string, value int64, rate float32) error {
+	return s.parent.SetInt(s.prefix+"."+name, value, rate)
+}
+
+func (s *recordingSubStatter) Raw(name string, value string, rate float32) error {
+	return s.parent.Raw(s.prefix+"."+name, value, rate)
+}
+
+func (s *recordingSubStatter) SetSamplerFunc(statsd.SamplerFunc) {}
+
+func (s *recordingSubStatter) NewSubStatter(prefix string) statsd.SubStatter {
+	return &recordingSubStatter{parent: s.parent, prefix: s.prefix + "." + prefix}
+}
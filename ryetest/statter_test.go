@@ -0,0 +1,59 @@
+package ryetest
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RecordingStatter", func() {
+
+	var (
+		statter *RecordingStatter
+	)
+
+	BeforeEach(func() {
+		statter = &RecordingStatter{}
+	})
+
+	It("should record stats in order", func() {
+		statter.Inc("a", 1, 1)
+		statter.TimingDuration("b", time.Second, 0.5)
+		statter.Gauge("c", 3, 1)
+
+		Expect(statter.Stats()).To(Equal([]Stat{
+			{Type: "Inc", Name: "a", Value: "1", StatRate: 1},
+			{Type: "TimingDuration", Name: "b", Value: "1s", StatRate: 0.5},
+			{Type: "Gauge", Name: "c", Value: "3", StatRate: 1},
+		}))
+		Expect(statter.Names()).To(Equal([]string{"a", "b", "c"}))
+	})
+
+	It("should split out counters and timings", func() {
+		statter.Inc("a", 1, 1)
+		statter.Timing("b", 2, 1)
+		statter.Gauge("c", 3, 1)
+		statter.TimingDuration("d", time.Second, 1)
+
+		Expect(statter.Incs()).To(Equal([]Stat{{Type: "Inc", Name: "a", Value: "1", StatRate: 1}}))
+		Expect(statter.Timings()).To(Equal([]Stat{
+			{Type: "Timing", Name: "b", Value: "2", StatRate: 1},
+			{Type: "TimingDuration", Name: "d", Value: "1s", StatRate: 1},
+		}))
+	})
+
+	It("should apply prefixes", func() {
+		statter.SetPrefix("svc")
+		statter.NewSubStatter("sub").Inc("a", 1, 1)
+
+		Expect(statter.Names()).To(Equal([]string{"svc.sub.a"}))
+	})
+
+	It("should drop recorded stats on Reset", func() {
+		statter.Inc("a", 1, 1)
+		statter.Reset()
+
+		Expect(statter.Stats()).To(BeEmpty())
+	})
+})
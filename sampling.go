@@ -0,0 +1,97 @@
+package rye
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cactus/go-statsd-client/statsd"
+)
+
+const (
+	// W3C trace context header, whose flags carry the sampling decision of the caller
+	TRACEPARENT_HEADER = "traceparent"
+)
+
+// SamplingDecision is whether a request is observed in detail: when Config.UnifiedSampling is set, it
+// is made once per request and drives its stats, its spans and its logs alike.
+type SamplingDecision struct {
+	Sampled bool
+
+	// Rate is the stat rate the stats of a sampled request are sent with
+	Rate float32
+}
+
+// decideSampling makes the sampling decision of a request: requests carrying the debug header are always
+// sampled, requests whose caller made a decision (see TRACEPARENT_HEADER) follow it, and the others are
+// sampled at their stat rate
+func (m *MWHandler) decideSampling(r *http.Request) SamplingDecision {
+	if header := m.Config.SamplingDebugHeader; header != "" && r.Header.Get(header) != "" {
+		return SamplingDecision{Sampled: true, Rate: 1}
+	}
+
+	if sampled, ok := traceparentSampled(r.Header.Get(TRACEPARENT_HEADER)); ok {
+		return SamplingDecision{Sampled: sampled, Rate: 1}
+	}
+
+	rate := m.statRate(r)
+
+	return SamplingDecision{
+		Sampled: rate >= 1 || (rate > 0 && rand.Float32() < rate),
+		Rate:    rate,
+	}
+}
+
+// traceparentSampled returns the sampled flag of a traceparent header (`00-<trace id>-<parent id>-<flags>`)
+func traceparentSampled(traceparent string) (sampled bool, ok bool) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[3]) != 2 {
+		return false, false
+	}
+
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return false, false
+	}
+
+	return flags&1 == 1, true
+}
+
+// alwaysSample is the statsd sampler used with unified sampling: rye already sampled the request
+func alwaysSample(rate float32) bool {
+	return true
+}
+
+// useUnifiedSampling makes sure the statter doesn't sample the stats of requests rye sampled already
+func (m *MWHandler) useUnifiedSampling() {
+	if s, ok := m.Config.Statter.(interface{ SetSamplerFunc(statsd.SamplerFunc) }); ok {
+		s.SetSamplerFunc(alwaysSample)
+	}
+}
+
+// sampled reports whether the request of the chain is observed in detail
+// (always the case unless Config.UnifiedSampling is set)
+func (c *chainState) sampled() bool {
+	return c == nil || c.sampling == nil || c.sampling.Sampled
+}
+
+/*
+Sampled reports whether the request the context belongs to was sampled, that is whether it should be
+observed in detail. It is always true unless `Config.UnifiedSampling` is set, in which case the decision
+is made once per request and shared by rye's stats, spans and logs; use it to sample your own logs the
+same way.
+
+Example usage:
+
+	func yourHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		if rye.Sampled(r.Context()) {
+			log.Debugf("Request body: %s", body)
+		}
+		...
+	}
+*/
+func Sampled(ctx context.Context) bool {
+	return chainFromContext(ctx).sampled()
+}
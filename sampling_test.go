@@ -0,0 +1,175 @@
+package rye
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/cactus/go-statsd-client/statsd"
+
+	"github.com/InVisionApp/rye/fakes/statsdfakes"
+	. "github.com/onsi/gomega"
+)
+
+// samplerStatter is a statter with a settable sampler, like statsd.Client
+type samplerStatter struct {
+	statsdfakes.FakeStatter
+	sampler statsd.SamplerFunc
+}
+
+func (s *samplerStatter) SetSamplerFunc(sampler statsd.SamplerFunc) {
+	s.sampler = sampler
+}
+
+var _ = Describe("Sampling", func() {
+
+	var (
+		mwHandler *MWHandler
+		tracer    *fakeTracer
+		reporter  *recordingReporter
+		output    *bytes.Buffer
+		level     log.Level
+		response  *httptest.ResponseRecorder
+		request   *http.Request
+		sampled   bool
+		rate      float32
+	)
+
+	// sampledHandler records the sampling decision seen by handlers
+	sampledHandler := func(rw http.ResponseWriter, r *http.Request) *Response {
+		sampled = Sampled(r.Context())
+		return nil
+	}
+
+	serve := func() {
+		h := mwHandler.Handle([]Handler{MiddlewareRouteLogger(), NamedHandler("sampled", sampledHandler)})
+		h.ServeHTTP(response, request)
+	}
+
+	// expectObserved asserts that stats, spans and logs all agree with the decision
+	expectObserved := func(observed bool) {
+		Expect(sampled).To(Equal(observed))
+
+		if observed {
+			Eventually(reporter.recordedIncs).Should(ContainElement("handlers.sampled.2xx"))
+			Expect(tracer.spans).ToNot(BeEmpty())
+			Expect(output.String()).To(ContainSubstring(`GET / HTTP/1.1`))
+		} else {
+			Consistently(reporter.recordedIncs).Should(BeEmpty())
+			Expect(tracer.spans).To(BeEmpty())
+			Expect(output.String()).To(BeEmpty())
+		}
+	}
+
+	BeforeEach(func() {
+		tracer = &fakeTracer{}
+		reporter = &recordingReporter{}
+		rate = 1
+
+		mwHandler = NewMWHandler(Config{
+			Reporter:            reporter,
+			Tracer:              tracer,
+			UnifiedSampling:     true,
+			SamplingDebugHeader: "X-Debug",
+			SampleFunc: func(r *http.Request) float32 {
+				return rate
+			},
+		})
+
+		response = httptest.NewRecorder()
+		request = httptest.NewRequest("GET", "/", nil)
+		sampled = false
+
+		output = &bytes.Buffer{}
+		log.SetOutput(output)
+		level = log.GetLevel()
+		log.SetLevel(log.InfoLevel)
+	})
+
+	AfterEach(func() {
+		log.SetOutput(GinkgoWriter)
+		log.SetLevel(level)
+	})
+
+	Describe("UnifiedSampling", func() {
+		It("should observe sampled requests in full", func() {
+			serve()
+			expectObserved(true)
+		})
+
+		It("should leave unsampled requests out of stats, tracing and logging", func() {
+			rate = 0
+
+			serve()
+			expectObserved(false)
+		})
+
+		It("should sample requests carrying the debug header", func() {
+			rate = 0
+			request.Header.Set("X-Debug", "1")
+
+			serve()
+			expectObserved(true)
+		})
+
+		It("should follow the sampled flag of the caller", func() {
+			rate = 0
+			request.Header.Set(TRACEPARENT_HEADER, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+			serve()
+			expectObserved(true)
+		})
+
+		It("should follow the not sampled flag of the caller", func() {
+			request.Header.Set(TRACEPARENT_HEADER, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+			serve()
+			expectObserved(false)
+		})
+
+		It("should make the statter keep every stat", func() {
+			statter := &samplerStatter{}
+			NewMWHandler(Config{Statter: statter, UnifiedSampling: true})
+
+			Expect(statter.sampler).ToNot(BeNil())
+			Expect(statter.sampler(0.01)).To(BeTrue())
+		})
+	})
+
+	Describe("Sampled", func() {
+		It("should be true when UnifiedSampling is not set", func() {
+			mwHandler.Config.UnifiedSampling = false
+			rate = 0
+
+			serve()
+			Expect(sampled).To(BeTrue())
+		})
+	})
+
+	Describe("decideSampling", func() {
+		It("should send the stats of sampled requests at their stat rate", func() {
+			rate = 0.5
+
+			for i := 0; i < 20; i++ {
+				decision := mwHandler.decideSampling(request)
+				Expect(decision.Rate).To(Equal(float32(0.5)))
+			}
+		})
+	})
+
+	Describe("traceparentSampled", func() {
+		It("should read the sampled flag", func() {
+			sampled, ok := traceparentSampled("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03")
+			Expect(ok).To(BeTrue())
+			Expect(sampled).To(BeTrue())
+		})
+
+		It("should ignore malformed headers", func() {
+			for _, header := range []string{"", "00-abc", "00-abc-def-zz", "00-abc-def-1"} {
+				_, ok := traceparentSampled(header)
+				Expect(ok).To(BeFalse(), header)
+			}
+		})
+	})
+})
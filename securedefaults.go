@@ -0,0 +1,80 @@
+package rye
+
+const (
+	// Default request body limit of SecureDefaults
+	DEFAULT_SECURE_DEFAULTS_MAX_BODY = 1 << 20
+)
+
+// SecureDefaultsOptions toggles the components of SecureDefaults; every component is enabled
+// by default, except for the real IP one, which needs to know the trusted proxies.
+type SecureDefaultsOptions struct {
+	// DisableRecover leaves out MiddlewareRecover
+	DisableRecover bool
+
+	// TrustedProxies enables NewMiddlewareRealIP, trusting the forwarding headers of these
+	// proxies (in CIDR notation)
+	TrustedProxies []string
+
+	// DisableRequestID leaves out NewMiddlewareRequestID
+	DisableRequestID bool
+
+	// RequestID configures NewMiddlewareRequestID
+	RequestID RequestIDConfig
+
+	// DisableSecureHeaders leaves out NewMiddlewareSecureHeaders
+	DisableSecureHeaders bool
+
+	// MaxBodyBytes is the request body limit enforced by NewMiddlewareMaxBody (defaults
+	// to DEFAULT_SECURE_DEFAULTS_MAX_BODY); a negative value leaves it out
+	MaxBodyBytes int64
+}
+
+/*
+SecureDefaults returns a recommended chain of built-in middleware giving services a sensible, secure baseline,
+to be followed by their own handlers. In order:
+
+  - MiddlewareRecover, first so that it covers everything after it
+  - NewMiddlewareRealIP (when TrustedProxies are given), so that the rest of the chain sees the client's address
+  - NewMiddlewareRequestID, so that rejected requests can be correlated as well
+  - NewMiddlewareSecureHeaders
+  - NewMiddlewareMaxBody
+
+Each of them can be left out through the options.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		append(rye.SecureDefaults(rye.SecureDefaultsOptions{
+			TrustedProxies: []string{"10.0.0.0/8"},
+		}), yourHandler))).Methods("POST")
+*/
+func SecureDefaults(opts SecureDefaultsOptions) []Handler {
+	var handlers []Handler
+
+	if !opts.DisableRecover {
+		handlers = append(handlers, MiddlewareRecover)
+	}
+
+	if len(opts.TrustedProxies) > 0 {
+		handlers = append(handlers, NewMiddlewareRealIP(opts.TrustedProxies))
+	}
+
+	if !opts.DisableRequestID {
+		handlers = append(handlers, NewMiddlewareRequestID(opts.RequestID))
+	}
+
+	if !opts.DisableSecureHeaders {
+		handlers = append(handlers, NewMiddlewareSecureHeaders())
+	}
+
+	maxBody := opts.MaxBodyBytes
+	if maxBody == 0 {
+		maxBody = DEFAULT_SECURE_DEFAULTS_MAX_BODY
+	}
+
+	if maxBody > 0 {
+		handlers = append(handlers, NewMiddlewareMaxBody(maxBody))
+	}
+
+	return handlers
+}
@@ -0,0 +1,72 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SecureDefaults", func() {
+
+	names := func(handlers []Handler) []string {
+		var names []string
+		for _, h := range handlers {
+			names = append(names, handlerName(h))
+		}
+		return names
+	}
+
+	It("should return the recommended chain in order", func() {
+		handlers := SecureDefaults(SecureDefaultsOptions{TrustedProxies: []string{"10.0.0.0/8"}})
+
+		Expect(names(handlers)).To(Equal([]string{
+			"MiddlewareRecover",
+			"realIP.handle",
+			"requestID.handle",
+			"secureHeaders.handle",
+			"sizeLimitByPath.handle",
+		}))
+	})
+
+	It("should leave out the real IP middleware without trusted proxies", func() {
+		Expect(names(SecureDefaults(SecureDefaultsOptions{}))).To(Equal([]string{
+			"MiddlewareRecover",
+			"requestID.handle",
+			"secureHeaders.handle",
+			"sizeLimitByPath.handle",
+		}))
+	})
+
+	It("should leave out the disabled components", func() {
+		handlers := SecureDefaults(SecureDefaultsOptions{
+			DisableRecover:       true,
+			DisableRequestID:     true,
+			DisableSecureHeaders: true,
+			MaxBodyBytes:         -1,
+		})
+
+		Expect(handlers).To(BeEmpty())
+	})
+
+	It("should make a working chain", func() {
+		handlers := SecureDefaults(SecureDefaultsOptions{MaxBodyBytes: 8})
+
+		h := NewMWHandler(Config{}).Handle(append(handlers, func(rw http.ResponseWriter, r *http.Request) *Response {
+			panic("boom")
+		}))
+
+		response := httptest.NewRecorder()
+		h.ServeHTTP(response, httptest.NewRequest("POST", "/", strings.NewReader("small")))
+
+		Expect(response.Code).To(Equal(http.StatusInternalServerError))
+		Expect(response.Header().Get("X-Content-Type-Options")).To(Equal("nosniff"))
+		Expect(response.Header().Get(DEFAULT_REQUEST_ID_HEADER)).ToNot(BeEmpty())
+
+		response = httptest.NewRecorder()
+		h.ServeHTTP(response, httptest.NewRequest("POST", "/", strings.NewReader("far too large")))
+
+		Expect(response.Code).To(Equal(http.StatusRequestEntityTooLarge))
+	})
+})
@@ -0,0 +1,216 @@
+package rye
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Stat sent by VerifyStatter
+	VERIFY_STATTER_STAT = "rye.statter_check"
+)
+
+/*
+AsMiddleware marks a handler as middleware so that its stats are recorded under
+`middleware.<name>` instead of `handlers.<name>`. This keeps infrastructure overhead
+(auth, CORS, etc.) separate from your business logic on dashboards.
+
+Example usage:
+
+	routes.Handle("/some/route", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.AsMiddleware(rye.NewMiddlewareJWT(secret)), // middleware.jwtVerify.handle.*
+			yourHandler,                                    // handlers.yourHandler.*
+		})).Methods("GET")
+*/
+func AsMiddleware(handler Handler) Handler {
+	name := "middleware." + handlerName(handler)
+
+	return func(rw http.ResponseWriter, r *http.Request) *Response {
+		if c := chainFromRequest(r); c != nil {
+			c.statName = name
+		}
+
+		return handler(rw, r)
+	}
+}
+
+// inflightGauge counts the requests a chain is currently executing
+type inflightGauge struct {
+	count int64 // accessed atomically
+	name  string
+}
+
+// trackInflight adjusts the chain's in-flight count and emits it as a gauge. Unlike other
+// stats it is sent synchronously, but outside of any lock so a slow reporter doesn't
+// serialize requests; concurrent requests may send their values out of order.
+func (m *MWHandler) trackInflight(g *inflightGauge, delta int64) {
+	count := atomic.AddInt64(&g.count, delta)
+
+	if reporter := m.gaugeReporter(); reporter != nil {
+		reporter.Gauge(g.name, count, m.Config.StatRate)
+	}
+}
+
+// latencyBucket returns the name of the smallest bucket (ie. `le_100ms`) the given
+// duration fits in; `le_inf` is returned if it exceeds all of them.
+func latencyBucket(buckets []time.Duration, d time.Duration) string {
+	var match time.Duration
+
+	for _, bucket := range buckets {
+		if d <= bucket && (match == 0 || bucket < match) {
+			match = bucket
+		}
+	}
+
+	if match == 0 {
+		return "le_inf"
+	}
+
+	return "le_" + formatBucketDuration(match)
+}
+
+// formatBucketDuration formats a duration in a stat-name safe way (ie. `250ms`, `2s`, `1500us`)
+func formatBucketDuration(d time.Duration) string {
+	switch {
+	case d%time.Second == 0:
+		return fmt.Sprintf("%ds", d/time.Second)
+	case d%time.Millisecond == 0:
+		return fmt.Sprintf("%dms", d/time.Millisecond)
+	case d%time.Microsecond == 0:
+		return fmt.Sprintf("%dus", d/time.Microsecond)
+	}
+
+	return fmt.Sprintf("%dns", d)
+}
+
+/*
+RecordCacheResult increments the `cache.hit` or `cache.miss` counter through the statter of the
+chain the request is running in (and does nothing outside of a chain). Caching middleware report
+through it so that teams can monitor cache effectiveness without custom instrumentation; rye's own
+NotModifiedIf does so for conditional requests.
+
+Example usage:
+
+	func cachedItemHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		item, ok := itemCache.Get(r.URL.Path)
+		rye.RecordCacheResult(r, ok)
+		...
+	}
+*/
+func RecordCacheResult(r *http.Request, hit bool) {
+	c := chainFromRequest(r)
+
+	if hit {
+		c.inc("cache.hit")
+	} else {
+		c.inc("cache.miss")
+	}
+}
+
+/*
+VerifyStatter sends a test counter (`rye.statter_check`) through the configured statter and returns any
+error it reports, so that services can fail fast at startup when stats are misconfigured (ie. a bad
+statsd address). An error is also returned when no statter is configured or when ctx is done first.
+
+Example usage:
+
+	mwHandler := rye.NewMWHandler(config)
+
+	if err := mwHandler.VerifyStatter(ctx); err != nil {
+		log.Fatalf("Unable to send stats: %v", err)
+	}
+*/
+func (m *MWHandler) VerifyStatter(ctx context.Context) error {
+	if m.Config.Statter == nil {
+		return errors.New("No statter configured")
+	}
+
+	result := make(chan error, 1)
+
+	go func() {
+		result <- m.Config.Statter.Inc(VERIFY_STATTER_STAT, 1, 1.0)
+	}()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			return fmt.Errorf("Unable to send test stat: %v", err)
+		}
+
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("Unable to send test stat: %v", ctx.Err())
+	}
+}
+
+// statRate returns the stat rate of the request: the one decided by the SampleFunc if set,
+// StatRate otherwise
+func (m *MWHandler) statRate(r *http.Request) float32 {
+	if m.Config.SampleFunc != nil {
+		return m.Config.SampleFunc(r)
+	}
+
+	return m.Config.StatRate
+}
+
+// EmittedMetric is a stat sent during a request (see EmittedMetrics).
+type EmittedMetric struct {
+	// Type is the kind of stat ("Inc", "TimingDuration" or "Gauge")
+	Type  string
+	Name  string
+	Value string
+}
+
+// emittedMetrics collects the stats sent during a request
+type emittedMetrics struct {
+	mu      sync.Mutex
+	metrics []EmittedMetric
+}
+
+// record adds a stat to the collection (if there is one)
+func (e *emittedMetrics) record(statType, name, value string) {
+	if e == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.metrics = append(e.metrics, EmittedMetric{Type: statType, Name: name, Value: value})
+}
+
+/*
+EmittedMetrics returns the stats rye (and handlers, through helpers such as RecordCacheResult) sent so far
+during the request the context belongs to, in order. This makes it easy to check instrumentation during
+development, ie. by adding them to a debug response header.
+
+To avoid the overhead in production, stats are only collected when built with `-tags ryedebug`; otherwise
+EmittedMetrics always returns nil.
+
+Example usage:
+
+	func yourHandler(rw http.ResponseWriter, r *http.Request) *rye.Response {
+		...
+		for _, metric := range rye.EmittedMetrics(r.Context()) {
+			rw.Header().Add("X-Debug-Metrics", metric.Name+"="+metric.Value)
+		}
+		...
+	}
+*/
+func EmittedMetrics(ctx context.Context) []EmittedMetric {
+	c := chainFromContext(ctx)
+	if c == nil || c.emitted == nil {
+		return nil
+	}
+
+	c.emitted.mu.Lock()
+	defer c.emitted.mu.Unlock()
+
+	return append([]EmittedMetric(nil), c.emitted.metrics...)
+}
@@ -0,0 +1,430 @@
+package rye
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/InVisionApp/rye/fakes/statsdfakes"
+
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stats helpers", func() {
+
+	Describe("latencyBucket", func() {
+		var buckets = []time.Duration{time.Second, 100 * time.Millisecond, 250 * time.Millisecond}
+
+		It("should return the smallest bucket the duration fits in", func() {
+			Expect(latencyBucket(buckets, 50*time.Millisecond)).To(Equal("le_100ms"))
+			Expect(latencyBucket(buckets, 100*time.Millisecond)).To(Equal("le_100ms"))
+			Expect(latencyBucket(buckets, 101*time.Millisecond)).To(Equal("le_250ms"))
+			Expect(latencyBucket(buckets, 900*time.Millisecond)).To(Equal("le_1s"))
+		})
+
+		It("should return le_inf when the duration exceeds all buckets", func() {
+			Expect(latencyBucket(buckets, 2*time.Second)).To(Equal("le_inf"))
+		})
+	})
+
+	Describe("SampleFunc", func() {
+		var (
+			mwHandler *MWHandler
+			rates     chan float32
+		)
+
+		BeforeEach(func() {
+			// Stats are sent asynchronously and may outlive the spec, so the
+			// stubs hold on to this spec's channel rather than the shared one
+			specRates := make(chan float32, 10)
+			rates = specRates
+
+			fakeStatter := &statsdfakes.FakeStatter{}
+			fakeStatter.IncStub = func(name string, value int64, rate float32) error {
+				if strings.HasSuffix(name, ".2xx") {
+					specRates <- rate
+				}
+				return nil
+			}
+			fakeStatter.TimingDurationStub = func(name string, d time.Duration, rate float32) error {
+				specRates <- rate
+				return nil
+			}
+
+			mwHandler = NewMWHandler(Config{
+				Statter:  fakeStatter,
+				StatRate: 0.5,
+				SampleFunc: func(r *http.Request) float32 {
+					if r.URL.Path == "/ping" {
+						return 0.01
+					}
+					return 1
+				},
+			})
+		})
+
+		It("should record stats at the rate decided for the request", func() {
+			h := mwHandler.Handle([]Handler{successHandler})
+
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ping", nil))
+			Eventually(rates).Should(Receive(Equal(float32(0.01))))
+			Eventually(rates).Should(Receive(Equal(float32(0.01))))
+
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/checkout", nil))
+			Eventually(rates).Should(Receive(Equal(float32(1))))
+			Eventually(rates).Should(Receive(Equal(float32(1))))
+		})
+
+		It("should apply to stats recorded by handlers", func() {
+			h := mwHandler.Handle([]Handler{Checkpoint("auth")})
+
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ping", nil))
+			for i := 0; i < 3; i++ {
+				Eventually(rates).Should(Receive(Equal(float32(0.01))))
+			}
+		})
+
+		It("should fall back to StatRate when not set", func() {
+			mwHandler.Config.SampleFunc = nil
+			h := mwHandler.Handle([]Handler{successHandler})
+
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ping", nil))
+			Eventually(rates).Should(Receive(Equal(float32(0.5))))
+		})
+	})
+
+	Describe("VerifyStatter", func() {
+		var (
+			fakeStatter *statsdfakes.FakeStatter
+			mwHandler   *MWHandler
+		)
+
+		BeforeEach(func() {
+			fakeStatter = &statsdfakes.FakeStatter{}
+			mwHandler = NewMWHandler(Config{Statter: fakeStatter})
+		})
+
+		It("should send a test stat through a working statter", func() {
+			Expect(mwHandler.VerifyStatter(context.Background())).To(Succeed())
+
+			Expect(fakeStatter.IncCallCount()).To(Equal(1))
+			name, value, _ := fakeStatter.IncArgsForCall(0)
+			Expect(name).To(Equal(VERIFY_STATTER_STAT))
+			Expect(value).To(Equal(int64(1)))
+		})
+
+		It("should return the error of a failing statter", func() {
+			fakeStatter.IncReturns(errors.New("bad address"))
+
+			err := mwHandler.VerifyStatter(context.Background())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("bad address"))
+		})
+
+		It("should give up when the context is done", func() {
+			block := make(chan struct{})
+			defer close(block)
+
+			fakeStatter.IncStub = func(string, int64, float32) error {
+				<-block
+				return nil
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			Expect(mwHandler.VerifyStatter(ctx)).To(MatchError(ContainSubstring("deadline exceeded")))
+		})
+
+		It("should return an error when no statter is configured", func() {
+			Expect(NewMWHandler(Config{}).VerifyStatter(context.Background())).ToNot(Succeed())
+		})
+	})
+
+	Describe("EmittedMetrics", func() {
+		var (
+			mwHandler *MWHandler
+			debug     bool
+			emitted   []EmittedMetric
+		)
+
+		BeforeEach(func() {
+			debug = debugMode
+			mwHandler = NewMWHandler(Config{Reporter: &recordingReporter{}, StatRate: 1})
+			emitted = nil
+		})
+
+		AfterEach(func() {
+			debugMode = debug
+		})
+
+		serve := func() {
+			h := mwHandler.Handle([]Handler{successHandler, func(rw http.ResponseWriter, r *http.Request) *Response {
+				RecordCacheResult(r, true)
+				return nil
+			}, func(rw http.ResponseWriter, r *http.Request) *Response {
+				emitted = EmittedMetrics(r.Context())
+				return nil
+			}})
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}
+
+		It("should return the stats sent so far during the request in debug mode", func() {
+			debugMode = true
+
+			serve()
+
+			// The stats of the last handler are only sent once it returns
+			Expect(emitted).To(HaveLen(5))
+			Expect(emitted[0].Type).To(Equal("TimingDuration"))
+			Expect(emitted[0].Name).To(Equal("handlers.successHandler.runtime"))
+			Expect(emitted[1]).To(Equal(EmittedMetric{Type: "Inc", Name: "handlers.successHandler.2xx", Value: "1"}))
+			Expect(emitted[2]).To(Equal(EmittedMetric{Type: "Inc", Name: "cache.hit", Value: "1"}))
+			Expect(emitted[3].Type).To(Equal("TimingDuration"))
+			Expect(emitted[4].Name).To(HaveSuffix(".2xx"))
+		})
+
+		It("should not collect anything outside of debug mode", func() {
+			debugMode = false
+
+			serve()
+
+			Expect(emitted).To(BeNil())
+		})
+
+		It("should return nil outside of a chain", func() {
+			Expect(EmittedMetrics(context.Background())).To(BeNil())
+		})
+	})
+
+	Describe("BodyReadTiming", func() {
+		var (
+			mwHandler *MWHandler
+			timings   chan statsTiming
+		)
+
+		BeforeEach(func() {
+			// Stats are sent asynchronously and may outlive the spec, so the
+			// stub holds on to this spec's channel rather than the shared one
+			specTimings := make(chan statsTiming, 10)
+			timings = specTimings
+
+			fakeStatter := &statsdfakes.FakeStatter{}
+			fakeStatter.TimingDurationStub = func(name string, d time.Duration, rate float32) error {
+				specTimings <- statsTiming{name, d, rate}
+				return nil
+			}
+
+			mwHandler = NewMWHandler(Config{
+				Statter:        fakeStatter,
+				StatRate:       1,
+				BodyReadTiming: true,
+			})
+		})
+
+		readBodyHandler := func(rw http.ResponseWriter, r *http.Request) *Response {
+			io.ReadAll(r.Body)
+			return nil
+		}
+
+		It("should record the time the handler spent reading the body", func() {
+			request := httptest.NewRequest("POST", "/", &slowReader{chunks: 3, delay: 30 * time.Millisecond})
+
+			h := mwHandler.Handle([]Handler{NamedHandler("upload", readBodyHandler)})
+			h.ServeHTTP(httptest.NewRecorder(), request)
+
+			var stat statsTiming
+			Eventually(timings).Should(Receive(&stat, WithTransform(func(t statsTiming) string { return t.Name }, Equal("handlers.upload.body_read_time"))))
+			Expect(stat.Time).To(BeNumerically(">=", 90*time.Millisecond))
+			Expect(stat.Time).To(BeNumerically("<", 150*time.Millisecond))
+		})
+
+		It("should not record anything for handlers that don't read the body", func() {
+			request := httptest.NewRequest("POST", "/", &slowReader{chunks: 1, delay: time.Millisecond})
+
+			h := mwHandler.Handle([]Handler{successHandler})
+			h.ServeHTTP(httptest.NewRecorder(), request)
+
+			var stat, chain statsTiming
+			Eventually(timings).Should(Receive(&stat))
+			Eventually(timings).Should(Receive(&chain))
+			Expect([]string{stat.Name, chain.Name}).To(ConsistOf("handlers.successHandler.runtime", "handlers.successHandler.chain.runtime"))
+			Consistently(timings).ShouldNot(Receive())
+		})
+
+		It("should be opt-in", func() {
+			mwHandler.Config.BodyReadTiming = false
+			request := httptest.NewRequest("POST", "/", &slowReader{chunks: 1, delay: time.Millisecond})
+
+			h := mwHandler.Handle([]Handler{NamedHandler("upload", readBodyHandler)})
+			h.ServeHTTP(httptest.NewRecorder(), request)
+
+			var stat, chain statsTiming
+			Eventually(timings).Should(Receive(&stat))
+			Eventually(timings).Should(Receive(&chain))
+			Expect([]string{stat.Name, chain.Name}).To(ConsistOf("handlers.upload.runtime", "handlers.upload.chain.runtime"))
+			Consistently(timings).ShouldNot(Receive())
+		})
+	})
+
+	Describe("InflightGauge", func() {
+		var (
+			mu        sync.Mutex
+			gauges    []int64
+			mwHandler *MWHandler
+		)
+
+		gaugeValues := func() []int64 {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return append([]int64(nil), gauges...)
+		}
+
+		BeforeEach(func() {
+			gauges = nil
+
+			fakeStatter := &statsdfakes.FakeStatter{}
+			fakeStatter.GaugeStub = func(name string, value int64, rate float32) error {
+				mu.Lock()
+				defer mu.Unlock()
+
+				if strings.HasSuffix(name, ".inflight") {
+					gauges = append(gauges, value)
+				}
+				return nil
+			}
+
+			mwHandler = NewMWHandler(Config{Statter: fakeStatter, InflightGauge: true})
+		})
+
+		It("should return to baseline after concurrent requests finish", func() {
+			release := make(chan struct{})
+			started := make(chan struct{}, 2)
+
+			inflightHandler := func(rw http.ResponseWriter, r *http.Request) *Response {
+				started <- struct{}{}
+				<-release
+				return nil
+			}
+
+			h := mwHandler.Handle([]Handler{inflightHandler})
+
+			var wg sync.WaitGroup
+			for i := 0; i < 2; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+				}()
+			}
+
+			Eventually(started).Should(Receive())
+			Eventually(started).Should(Receive())
+			Expect(gaugeValues()).To(ConsistOf(int64(1), int64(2)))
+
+			close(release)
+			wg.Wait()
+
+			Expect(gaugeValues()).To(ConsistOf(int64(1), int64(2), int64(1), int64(0)))
+		})
+
+		It("should decrement when a handler panics", func() {
+			inflightHandler := func(rw http.ResponseWriter, r *http.Request) *Response {
+				panic("boom")
+			}
+
+			h := mwHandler.Handle([]Handler{inflightHandler})
+
+			Expect(func() {
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			}).To(Panic())
+
+			Expect(gaugeValues()).To(Equal([]int64{1, 0}))
+		})
+
+		Context("with a Reporter", func() {
+			// serveConcurrently serves n concurrent requests, all in flight at the same time
+			serveConcurrently := func(mwHandler *MWHandler, n int) {
+				release := make(chan struct{})
+				started := make(chan struct{}, n)
+
+				h := mwHandler.Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+					started <- struct{}{}
+					<-release
+					return nil
+				}})
+
+				var wg sync.WaitGroup
+				for i := 0; i < n; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+					}()
+				}
+
+				for i := 0; i < n; i++ {
+					Eventually(started).Should(Receive())
+				}
+
+				close(release)
+				wg.Wait()
+			}
+
+			It("should pair every increment with a decrement through a reporter supporting gauges", func() {
+				reporter := &gaugeRecordingReporter{}
+
+				serveConcurrently(NewMWHandler(Config{Reporter: reporter, InflightGauge: true}), 20)
+
+				// values may be sent out of order, but each count is seen once on the way up
+				// (1..20) and once on the way down (19..0)
+				expected := []int64{}
+				for i := int64(1); i <= 20; i++ {
+					expected = append(expected, i, i-1)
+				}
+
+				Expect(reporter.recordedGauges()).To(ConsistOf(expected))
+			})
+
+			It("should keep working with a reporter without gauge support", func() {
+				reporter := &recordingReporter{}
+
+				serveConcurrently(NewMWHandler(Config{Reporter: reporter, InflightGauge: true}), 2)
+
+				Eventually(reporter.recordedIncs).Should(HaveLen(2))
+			})
+
+			It("should not fall back to the Statter for gauges", func() {
+				fakeStatter := &statsdfakes.FakeStatter{}
+
+				serveConcurrently(NewMWHandler(Config{Statter: fakeStatter, Reporter: &recordingReporter{}, InflightGauge: true}), 2)
+
+				Expect(fakeStatter.GaugeCallCount()).To(Equal(0))
+			})
+		})
+	})
+})
+
+// slowReader delivers a byte every delay, chunks times
+type slowReader struct {
+	chunks int
+	delay  time.Duration
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	if s.chunks == 0 {
+		return 0, io.EOF
+	}
+
+	time.Sleep(s.delay)
+	s.chunks--
+	p[0] = 'x'
+
+	return 1, nil
+}
@@ -0,0 +1,97 @@
+package rye
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// Header carrying the baggage items of the incoming request (W3C format)
+	BAGGAGE_HEADER = "baggage"
+
+	DEFAULT_CHAIN_SPAN_PREFIX = "chain."
+)
+
+// Tracer is the interface used by rye to trace chains; implement it to plug in
+// your tracing library of choice.
+type Tracer interface {
+	// StartSpan starts a span; parent is nil for the root span of a chain
+	StartSpan(name string, parent Span) Span
+}
+
+// Span is a single traced operation started by a Tracer.
+type Span interface {
+	SetBaggageItem(key, value string)
+	Finish()
+}
+
+// SpanFromRequest returns the span of the handler currently running (a child of
+// the chain span) or nil if tracing is disabled. Handlers can use it as the parent
+// of the spans of their own downstream calls.
+func SpanFromRequest(r *http.Request) Span {
+	c := chainFromRequest(r)
+	if c == nil {
+		return nil
+	}
+
+	return c.span
+}
+
+// startChainSpan starts the root span of the chain, carrying the baggage items of the request
+func (m *MWHandler) startChainSpan(chainName string, r *http.Request) Span {
+	name := m.Config.ChainSpanName
+	if name == "" {
+		name = DEFAULT_CHAIN_SPAN_PREFIX + chainName
+	}
+
+	span := m.Config.Tracer.StartSpan(name, nil)
+
+	for _, header := range r.Header.Values(BAGGAGE_HEADER) {
+		for key, value := range parseBaggage(header) {
+			span.SetBaggageItem(key, value)
+		}
+	}
+
+	return span
+}
+
+// startHandlerSpan starts the span of the handler about to run as a child of the chain span
+func (c *chainState) startHandlerSpan(name string) {
+	c.span = c.mw.Config.Tracer.StartSpan(name, c.chainSpan)
+}
+
+// finishHandlerSpan finishes the span of the handler that just ran (if any)
+func (c *chainState) finishHandlerSpan() {
+	if c.span == nil || c.span == c.chainSpan {
+		return
+	}
+
+	c.span.Finish()
+	c.span = c.chainSpan
+}
+
+// parseBaggage parses the list members of a W3C baggage header (ie. `userId=alice,isProduction=false`),
+// ignoring their properties and any malformed member
+func parseBaggage(header string) map[string]string {
+	items := make(map[string]string)
+
+	for _, member := range strings.Split(header, ",") {
+		member = strings.SplitN(member, ";", 2)[0]
+
+		parts := strings.SplitN(member, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key := strings.TrimSpace(parts[0])
+		value, err := url.PathUnescape(strings.TrimSpace(parts[1]))
+		if key == "" || err != nil {
+			continue
+		}
+
+		items[key] = value
+	}
+
+	return items
+}
@@ -0,0 +1,135 @@
+package rye
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	. "github.com/onsi/gomega"
+)
+
+type fakeSpan struct {
+	name     string
+	parent   *fakeSpan
+	baggage  map[string]string
+	finished bool
+}
+
+func (s *fakeSpan) SetBaggageItem(key, value string) {
+	s.baggage[key] = value
+}
+
+func (s *fakeSpan) Finish() {
+	s.finished = true
+}
+
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) StartSpan(name string, parent Span) Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	span := &fakeSpan{name: name, baggage: make(map[string]string)}
+	if parent != nil {
+		span.parent = parent.(*fakeSpan)
+	}
+
+	t.spans = append(t.spans, span)
+	return span
+}
+
+var _ = Describe("Tracing", func() {
+
+	var (
+		mwHandler *MWHandler
+		tracer    *fakeTracer
+		response  *httptest.ResponseRecorder
+		request   *http.Request
+	)
+
+	BeforeEach(func() {
+		tracer = &fakeTracer{}
+		mwHandler = NewMWHandler(Config{Tracer: tracer})
+		response = httptest.NewRecorder()
+		request = httptest.NewRequest("GET", "/", nil)
+	})
+
+	Describe("Handle", func() {
+		Context("when a Tracer is set", func() {
+			It("should start one root span covering the chain with a child span per handler", func() {
+				var current Span
+
+				spanHandler := func(rw http.ResponseWriter, r *http.Request) *Response {
+					current = SpanFromRequest(r)
+					return nil
+				}
+
+				h := mwHandler.Handle([]Handler{successHandler, spanHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(tracer.spans).To(HaveLen(3))
+
+				root := tracer.spans[0]
+				Expect(root.name).To(Equal("chain.successHandler"))
+				Expect(root.parent).To(BeNil())
+				Expect(root.finished).To(BeTrue())
+
+				Expect(tracer.spans[1].name).To(Equal("successHandler"))
+				for _, span := range tracer.spans[1:] {
+					Expect(span.parent).To(Equal(root))
+					Expect(span.finished).To(BeTrue())
+				}
+
+				Expect(current).To(Equal(tracer.spans[2]))
+			})
+
+			It("should name the chain span after ChainSpanName", func() {
+				mwHandler.Config.ChainSpanName = "request"
+
+				h := mwHandler.Handle([]Handler{successHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(tracer.spans[0].name).To(Equal("request"))
+			})
+
+			It("should carry the baggage items of the request on the chain span", func() {
+				request.Header.Add("Baggage", "userId=alice, team=a%20b;prop=1")
+				request.Header.Add("Baggage", "malformed,region=eu")
+
+				h := mwHandler.Handle([]Handler{successHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(tracer.spans[0].baggage).To(Equal(map[string]string{
+					"userId": "alice",
+					"team":   "a b",
+					"region": "eu",
+				}))
+			})
+
+			It("should finish the span of a handler that stops the chain", func() {
+				h := mwHandler.Handle([]Handler{failureHandler, successHandler})
+				h.ServeHTTP(response, request)
+
+				Expect(tracer.spans).To(HaveLen(2))
+				Expect(tracer.spans[1].finished).To(BeTrue())
+			})
+		})
+
+		Context("when no Tracer is set", func() {
+			It("should not expose a span", func() {
+				var current Span = &fakeSpan{}
+
+				h := NewMWHandler(Config{}).Handle([]Handler{func(rw http.ResponseWriter, r *http.Request) *Response {
+					current = SpanFromRequest(r)
+					return nil
+				}})
+				h.ServeHTTP(response, request)
+
+				Expect(current).To(BeNil())
+			})
+		})
+	})
+})
@@ -0,0 +1,113 @@
+package rye
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// HTTPError is an error carrying the HTTP status code it should be reported with.
+// Handlers built with Typed can return one to control the status of an error response.
+type HTTPError struct {
+	StatusCode int
+	Err        error
+}
+
+// NewHTTPError creates an HTTPError with the given status code and message.
+func NewHTTPError(statusCode int, message string) *HTTPError {
+	return &HTTPError{
+		StatusCode: statusCode,
+		Err:        errors.New(message),
+	}
+}
+
+func (e *HTTPError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// errorStatusCode returns the status code carried by the error (via HTTPError) or a 500
+func errorStatusCode(err error) int {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode != 0 {
+		return httpErr.StatusCode
+	}
+
+	return http.StatusInternalServerError
+}
+
+/*
+Typed adapts a function working on typed values into a Handler: the JSON request body is decoded
+into `Req` (a missing body leaves it as its zero value), `fn` is called with the request context and
+the result is written out as JSON with a 200, stopping the chain.
+
+A body that cannot be decoded results in a 400. Errors returned by `fn` result in a 500 unless they
+are (or wrap) an *HTTPError, in which case its status code is used.
+
+Example usage:
+
+	type createItemRequest struct {
+		Name string `json:"name"`
+	}
+
+	func createItem(ctx context.Context, req createItemRequest) (*Item, error) {
+		if req.Name == "" {
+			return nil, rye.NewHTTPError(http.StatusBadRequest, "name is required")
+		}
+		...
+	}
+
+	routes.Handle("/items", a.Dependencies.MWHandler.Handle(
+		[]rye.Handler{
+			rye.NewMiddlewareJWT(secret),
+			rye.Typed(createItem),
+		})).Methods("POST")
+*/
+func Typed[Req, Resp any](fn func(context.Context, Req) (Resp, error)) Handler {
+	return func(rw http.ResponseWriter, r *http.Request) *Response {
+		var req Req
+
+		body, err := readBody(r)
+		if err != nil {
+			return bodyReadError(r, err)
+		}
+
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &req); err != nil {
+				return &Response{
+					Err:        fmt.Errorf("Unable to decode request body: %v", err),
+					StatusCode: http.StatusBadRequest,
+				}
+			}
+		}
+
+		resp, err := fn(r.Context(), req)
+		if err != nil {
+			return &Response{
+				Err:        err,
+				StatusCode: errorStatusCode(err),
+			}
+		}
+
+		data, err := json.Marshal(resp)
+		if err != nil {
+			return &Response{
+				Err:        fmt.Errorf("Unable to marshal JSON response: %v", err),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		return &Response{
+			StatusCode:    http.StatusOK,
+			StopExecution: true,
+			StatusContent: string(data),
+			ContentType:   "application/json",
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package rye
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/gomega"
+)
+
+type typedGreetRequest struct {
+	Name string `json:"name"`
+}
+
+type typedGreetResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+func typedGreet(ctx context.Context, req typedGreetRequest) (*typedGreetResponse, error) {
+	switch req.Name {
+	case "":
+		return nil, NewHTTPError(http.StatusUnprocessableEntity, "name is required")
+	case "error":
+		return nil, errors.New("greeting service down")
+	case "wrapped":
+		return nil, fmt.Errorf("lookup failed: %w", NewHTTPError(http.StatusNotFound, "no such person"))
+	}
+
+	return &typedGreetResponse{Greeting: "hello " + req.Name}, nil
+}
+
+var _ = Describe("Typed", func() {
+
+	var (
+		response  *httptest.ResponseRecorder
+		mwHandler *MWHandler
+	)
+
+	serve := func(body string) {
+		request := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		mwHandler.Handle([]Handler{Typed(typedGreet)}).ServeHTTP(response, request)
+	}
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		mwHandler = NewMWHandler(Config{})
+	})
+
+	Context("when the function succeeds", func() {
+		It("should round-trip the request and response as JSON", func() {
+			serve(`{"name": "rye"}`)
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+
+			resp := &typedGreetResponse{}
+			Expect(json.Unmarshal(response.Body.Bytes(), resp)).To(Succeed())
+			Expect(resp.Greeting).To(Equal("hello rye"))
+		})
+	})
+
+	Context("when the function returns an error", func() {
+		It("should use the status code of an HTTPError", func() {
+			serve(`{}`)
+
+			Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(response.Body.String()).To(ContainSubstring("name is required"))
+		})
+
+		It("should use the status code of a wrapped HTTPError", func() {
+			serve(`{"name": "wrapped"}`)
+
+			Expect(response.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("should default to a 500", func() {
+			serve(`{"name": "error"}`)
+
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Context("when the request body is not valid JSON", func() {
+		It("should return a 400", func() {
+			serve(`{`)
+
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})